@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kong"
+	"github.com/elee1766/gofer/src/audit"
+)
+
+// AuditCmd inspects the audit log
+type AuditCmd struct {
+	Verify AuditVerifyCmd `cmd:"" help:"Check that the audit log was not edited or truncated"`
+}
+
+// AuditVerifyCmd checks the hash chain of the audit log
+type AuditVerifyCmd struct {
+	Path string `help:"Audit log path (defaults to config)"`
+}
+
+// Run executes the audit verify command
+func (c *AuditVerifyCmd) Run(ctx *kong.Context, cli *CLI) error {
+	path := c.Path
+	if path == "" {
+		cfg, err := loadConfig(cli.Config)
+		if err != nil {
+			return err
+		}
+		path = cfg.Security.AuditLog.Path
+	}
+
+	result, err := audit.Verify(path)
+	if err != nil {
+		if result != nil && result.Entries > 0 {
+			fmt.Printf("Verified %d entries before the error\n", result.Entries)
+		}
+		return fmt.Errorf("audit log verification failed: %w", err)
+	}
+	fmt.Printf("Audit log %s is intact: %s\n", path, result)
+	return nil
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/alecthomas/kong"
 	"github.com/elee1766/gofer/src/aisdk"
-	"github.com/elee1766/gofer/src/orclient"
 )
 
 // ModelCmd manages model operations
@@ -29,11 +28,12 @@ type ModelListCmd struct {
 
 // Run executes the model list command
 func (c *ModelListCmd) Run(ctx *kong.Context, cli *CLI) error {
-	client := orclient.NewClient(orclient.Config{
-		APIKey: cli.APIKey,
-	})
+	providers, err := newProviderRegistry(cli)
+	if err != nil {
+		return err
+	}
 
-	models, err := client.ListModels(context.Background())
+	models, err := providers.GetModels(context.Background())
 	if err != nil {
 		return fmt.Errorf("failed to list models: %w", err)
 	}
@@ -50,20 +50,22 @@ func (c *ModelListCmd) Run(ctx *kong.Context, cli *CLI) error {
 
 // ModelInfoCmd gets information about a specific model
 type ModelInfoCmd struct {
-	Model  string `arg:"" help:"Model ID or name"`
+	Model  string `arg:"" help:"Model ID, optionally prefixed with a provider"`
 	Format string `help:"Output format (table, json)" default:"table"`
 }
 
 // Run executes the model info command
 func (c *ModelInfoCmd) Run(ctx *kong.Context, cli *CLI) error {
-	client := orclient.NewClient(orclient.Config{
-		APIKey: cli.APIKey,
-	})
+	providers, err := newProviderRegistry(cli)
+	if err != nil {
+		return err
+	}
 
-	model, err := client.GetModelByID(context.Background(), c.Model)
+	modelClient, err := providers.Model(context.Background(), c.Model)
 	if err != nil {
 		return fmt.Errorf("failed to get model info: %w", err)
 	}
+	model := modelClient.GetModelInfo()
 
 	switch c.Format {
 	case "json":
@@ -77,17 +79,18 @@ func (c *ModelInfoCmd) Run(ctx *kong.Context, cli *CLI) error {
 
 // ModelTestCmd tests a model with a simple prompt
 type ModelTestCmd struct {
-	Model  string `arg:"" help:"Model ID or name"`
+	Model  string `arg:"" help:"Model ID, optionally prefixed with a provider"`
 	Prompt string `help:"Test prompt" default:"what is 9 + 10?"`
 }
 
 // Run executes the model test command
 func (c *ModelTestCmd) Run(ctx *kong.Context, cli *CLI) error {
-	client := orclient.NewClient(orclient.Config{
-		APIKey: cli.APIKey,
-	})
+	providers, err := newProviderRegistry(cli)
+	if err != nil {
+		return err
+	}
 
-	modelClient, err := client.Model(context.Background(), c.Model)
+	modelClient, err := providers.Model(context.Background(), c.Model)
 	if err != nil {
 		return fmt.Errorf("failed to create model client: %w", err)
 	}
@@ -131,8 +134,11 @@ func (c *ModelTestCmd) Run(ctx *kong.Context, cli *CLI) error {
 func formatCurrency(amount float64, currency string) string {
 	switch currency {
 	case "USD":
+		if amount == 0 {
+			return "$0.00"
+		}
 		if amount < 0.01 {
-			return "$0.00" // Round very small amounts to zero
+			return fmt.Sprintf("$%.4f", amount) // Keep small amounts visible
 		}
 		return fmt.Sprintf("$%.2f", amount)
 	default:
@@ -148,11 +154,12 @@ type ModelSearchCmd struct {
 
 // Run executes the model search command
 func (c *ModelSearchCmd) Run(ctx *kong.Context, cli *CLI) error {
-	client := orclient.NewClient(orclient.Config{
-		APIKey: cli.APIKey,
-	})
+	providers, err := newProviderRegistry(cli)
+	if err != nil {
+		return err
+	}
 
-	models, err := client.ListModels(context.Background())
+	models, err := providers.GetModels(context.Background())
 	if err != nil {
 		return fmt.Errorf("failed to list models: %w", err)
 	}
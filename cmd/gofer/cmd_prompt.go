@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/alecthomas/kong"
 	"github.com/elee1766/gofer/src/app"
@@ -12,17 +15,22 @@ import (
 
 // PromptCmd represents the single prompt command
 type PromptCmd struct {
-	Text         []string `arg:"" help:"The prompt text to send"`
+	Text         []string `arg:"" optional:"" help:"The prompt text to send, or /compact to summarize the conversation; optional when resuming an interrupted run"`
 	SystemPrompt string   `short:"s" help:"System prompt"`
 	File         string   `short:"f" help:"Load prompt from file"`
 	Output       string   `short:"o" help:"Output format (text, json, markdown)" default:"text"`
 	Raw          bool     `help:"Output raw response without formatting"`
-	Model        string   `short:"m" help:"Model to use for this prompt" default:"google/gemini-2.5-flash"`
+	Model        string   `short:"m" help:"Model to use, optionally prefixed with a provider (e.g. anthropic:claude-sonnet-4-20250514)" default:"google/gemini-2.5-flash"`
 	Temperature  float64  `help:"Override temperature for this prompt"`
 	MaxTokens    int      `help:"Override max tokens for this prompt"`
 	MaxTurns     int      `help:"Maximum conversation turns" default:"3"`
 	Resume       bool     `short:"r" help:"Resume last conversation"`
 	SessionID    string   `help:"Resume specific session by ID"`
+	Pending      string   `enum:"ask,rerun,cancel" default:"ask" help:"What to do with the unfinished tool calls of an interrupted run when resuming (ask, rerun, cancel)"`
+	NoStream     bool     `help:"Wait for the full response instead of streaming it"`
+	Record       string   `help:"Record model requests and responses to a cassette file" type:"path" xor:"cassette"`
+	Replay       string   `help:"Serve model responses from a recorded cassette file instead of calling the model" type:"path" xor:"cassette"`
+	Schema       string   `help:"JSON Schema file the response must match; only the validated JSON is printed" type:"path"`
 }
 
 func (p *PromptCmd) Run(ctx *kong.Context, cli *CLI) error {
@@ -33,22 +41,27 @@ func (p *PromptCmd) Run(ctx *kong.Context, cli *CLI) error {
 	toolsutil.SetLogger(logger)
 
 	// Create app instance with shared state
-	projectDir, _ := os.Getwd()
-	appInstance, err := app.InitializeAgentAppWithTools(context.Background(), app.AppConfig{
-		APIKey:       cli.APIKey,
-		BaseURL:      cli.BaseURL,
-		Model:        p.Model,
-		SystemPrompt: p.SystemPrompt,
-		EnableTools:  !cli.NoTools,
-		Logger:       logger,
-		ProjectDir:   projectDir,
-	})
+	appConfig, err := newAppConfig(cli, logger)
 	if err != nil {
 		return err
 	}
-	cctx := context.Background()
+	appConfig.Model = p.Model
+	appConfig.SystemPrompt = p.SystemPrompt
 
-	return RunPrompt(cctx, appInstance, RunPromptParams{
+	appInstance, err := app.InitializeAgentAppWithTools(context.Background(), appConfig)
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C cancels the run, stopping running tools; a second Ctrl-C exits
+	cctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-cctx.Done()
+		stop()
+	}()
+
+	err = RunPrompt(cctx, appInstance, RunPromptParams{
 		Text:         strings.Join(p.Text, " "),
 		SystemPrompt: p.SystemPrompt,
 		Output:       p.Output,
@@ -60,5 +73,14 @@ func (p *PromptCmd) Run(ctx *kong.Context, cli *CLI) error {
 		MaxTurns:     p.MaxTurns,
 		Model:        p.Model,
 		APIKey:       cli.APIKey,
+		Stream:       !p.NoStream,
+		Record:       p.Record,
+		Replay:       p.Replay,
+		Schema:       p.Schema,
+		Pending:      p.Pending,
 	})
+	if err != nil && cctx.Err() != nil {
+		return fmt.Errorf("interrupted, continue with gofer prompt --resume: %w", err)
+	}
+	return err
 }
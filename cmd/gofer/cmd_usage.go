@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/alecthomas/kong"
+	"github.com/elee1766/gofer/src/storage"
+)
+
+// UsageCmd reports model token usage and cost
+type UsageCmd struct {
+	By      string   `short:"b" enum:"day,model,conversation,project" default:"day" help:"Group usage by day, model, conversation or project"`
+	Since   string   `help:"First day to include (YYYY-MM-DD)"`
+	Until   string   `help:"Last day to include (YYYY-MM-DD)"`
+	Project string   `help:"Only include conversations of this project directory"`
+	Model   string   `short:"m" help:"Only include calls to this model"`
+	DBPath  []string `name:"db" type:"existingfile" help:"Project databases to read; repeat to combine projects (defaults to the current project's database)"`
+	Format  string   `short:"f" enum:"table,json" default:"table" help:"Output format (table, json)"`
+}
+
+// Run executes the usage command
+func (c *UsageCmd) Run(ctx *kong.Context, cli *CLI) error {
+	paths := c.DBPath
+	if len(paths) == 0 {
+		projectDir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get working directory: %w", err)
+		}
+		path := filepath.Join(projectDir, ".gofer", "sqlite.db")
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("no gofer database in %s: %w", projectDir, err)
+		}
+		paths = []string{path}
+	}
+
+	filter := storage.UsageFilter{
+		Since:   c.Since,
+		Until:   c.Until,
+		Project: c.Project,
+		Model:   c.Model,
+	}
+	summaries, err := summarizeUsage(context.Background(), paths, storage.UsageGroup(c.By), filter)
+	if err != nil {
+		return err
+	}
+
+	switch c.Format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summaries)
+	default:
+		return printUsageTable(c.By, summaries)
+	}
+}
+
+// summarizeUsage summarizes the usage of several databases, combining groups
+// with the same key
+func summarizeUsage(ctx context.Context, paths []string, group storage.UsageGroup, filter storage.UsageFilter) ([]storage.UsageSummary, error) {
+	if len(paths) == 1 {
+		db, err := storage.Open(paths[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", paths[0], err)
+		}
+		defer db.Close()
+		return storage.SummarizeUsage(ctx, db.DB(), group, filter)
+	}
+
+	var combined []storage.UsageSummary
+	index := make(map[string]int)
+	for _, path := range paths {
+		db, err := storage.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		summaries, err := storage.SummarizeUsage(ctx, db.DB(), group, filter)
+		db.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read usage from %s: %w", path, err)
+		}
+		for _, s := range summaries {
+			if i, ok := index[s.Key]; ok {
+				combined[i].Add(s)
+				continue
+			}
+			index[s.Key] = len(combined)
+			combined = append(combined, s)
+		}
+	}
+
+	sortUsage(group, combined)
+	return combined, nil
+}
+
+// sortUsage orders summaries the way storage.SummarizeUsage does
+func sortUsage(group storage.UsageGroup, summaries []storage.UsageSummary) {
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if group != storage.UsageByDay && summaries[i].TotalCost != summaries[j].TotalCost {
+			return summaries[i].TotalCost > summaries[j].TotalCost
+		}
+		return summaries[i].Key < summaries[j].Key
+	})
+}
+
+// printUsageTable prints usage summaries with a total row
+func printUsageTable(by string, summaries []storage.UsageSummary) error {
+	if len(summaries) == 0 {
+		fmt.Println("No usage recorded")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	header := "Day"
+	switch by {
+	case "model":
+		header = "Model"
+	case "conversation":
+		header = "Conversation"
+	case "project":
+		header = "Project"
+	}
+	fmt.Fprintf(w, "%s\tCalls\tPrompt\tCached\tCompletion\tCost\n", header)
+
+	var total storage.UsageSummary
+	for _, s := range summaries {
+		key := s.Key
+		if s.Label != "" {
+			key = fmt.Sprintf("%s (%s)", s.Label, s.Key)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n",
+			key, s.Calls, s.PromptTokens, s.CachedTokens, s.CompletionTokens, usageCost(s))
+		total.Add(s)
+	}
+	fmt.Fprintf(w, "Total\t%d\t%d\t%d\t%d\t%s\n",
+		total.Calls, total.PromptTokens, total.CachedTokens, total.CompletionTokens, usageCost(total))
+
+	if total.UnpricedCalls > 0 {
+		w.Flush()
+		fmt.Printf("\n%d calls were to models without pricing and are not included in the cost\n", total.UnpricedCalls)
+	}
+	return nil
+}
+
+// usageCost formats the cost of a summary, marking costs that leave out unpriced calls
+func usageCost(s storage.UsageSummary) string {
+	cost := formatCurrency(s.TotalCost, "USD")
+	if s.UnpricedCalls > 0 {
+		cost += "*"
+	}
+	return cost
+}
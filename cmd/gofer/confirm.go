@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/elee1766/gofer/src/executor"
+	"github.com/elee1766/gofer/src/goferagent/tools"
+	"github.com/mattn/go-isatty"
+)
+
+// newConfirmer returns the confirmer of tool calls the permissions require
+// to be confirmed: a prompt on the terminal, or without one a confirmer
+// that denies them
+func newConfirmer() executor.Confirmer {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return executor.DenyConfirmer{}
+	}
+	return &terminalConfirmer{always: make(map[string]bool)}
+}
+
+// terminalConfirmer asks on the terminal whether tool calls may run. The
+// executor asks about one call at a time.
+type terminalConfirmer struct {
+	// always holds the tools, or for run_command the exact commands, the
+	// user allowed for the run
+	always map[string]bool
+
+	// lines are read from stdin in the background, so a cancelled run
+	// does not wait for an answer
+	lines    chan string
+	readOnce sync.Once
+}
+
+// Confirm prints the call and waits for an answer. Closing stdin denies the
+// call.
+func (c *terminalConfirmer) Confirm(ctx context.Context, req *executor.ConfirmationRequest) (bool, error) {
+	name := req.ToolCall.Function.Name
+	key, scope := alwaysKey(name, req.ToolCall.Function.Arguments)
+	if c.always[key] {
+		return true, nil
+	}
+	c.readOnce.Do(c.readLines)
+
+	message := req.Message
+	if message == "" {
+		message = fmt.Sprintf("Allow tool call: %s?", name)
+	}
+	fmt.Fprintf(os.Stderr, "\n🔒 %s\n   %s %s\n", message, name, string(req.ToolCall.Function.Arguments))
+	for {
+		fmt.Fprintf(os.Stderr, "Allow (y), deny (n) or allow %s for the rest of this run (a)? [y/n/a] ", scope)
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(os.Stderr)
+			return false, ctx.Err()
+		case line, ok := <-c.lines:
+			if !ok {
+				fmt.Fprintln(os.Stderr)
+				return false, nil
+			}
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "y", "yes":
+				return true, nil
+			case "n", "no":
+				return false, nil
+			case "a", "always":
+				c.always[key] = true
+				return true, nil
+			}
+		}
+	}
+}
+
+// alwaysKey returns the key an "always" answer is kept under and what it
+// allows. A run_command answer allows only the same command, so allowing
+// one command does not allow every other.
+func alwaysKey(name string, arguments []byte) (key, scope string) {
+	if name != tools.RunCommandName {
+		return name, fmt.Sprintf("every %s call", name)
+	}
+	var args struct {
+		Command string `json:"command"`
+	}
+	_ = json.Unmarshal(arguments, &args)
+	return name + "\x00" + args.Command, "this command"
+}
+
+// readLines starts reading stdin into c.lines, closing it at the end of input
+func (c *terminalConfirmer) readLines() {
+	c.lines = make(chan string)
+	go func() {
+		defer close(c.lines)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			c.lines <- scanner.Text()
+		}
+	}()
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlwaysKey(t *testing.T) {
+	ls, _ := alwaysKey("run_command", []byte(`{"command":"ls"}`))
+	rm, _ := alwaysKey("run_command", []byte(`{"command":"rm -rf build"}`))
+	assert.NotEqual(t, ls, rm, "allowing one command must not allow another")
+
+	again, _ := alwaysKey("run_command", []byte(`{"command":"ls","timeout":5}`))
+	assert.Equal(t, ls, again)
+
+	first, _ := alwaysKey("write_file", []byte(`{"path":"a"}`))
+	second, _ := alwaysKey("write_file", []byte(`{"path":"b"}`))
+	assert.Equal(t, first, second)
+}
@@ -9,10 +9,12 @@ import (
 
 // CLI represents the main CLI structure
 type CLI struct {
-	APIKey   string `env:"OPENROUTER_API_KEY" help:"OpenRouter API key"`
-	NoTools  bool   `help:"Disable tool usage"`
-	BaseURL  string `help:"Custom API base URL"`
-	LogLevel string `default:"warn" help:"Log level"`
+	APIKey          string `env:"OPENROUTER_API_KEY" help:"OpenRouter API key"`
+	AnthropicAPIKey string `env:"ANTHROPIC_API_KEY" help:"Anthropic API key, enables the anthropic provider"`
+	NoTools         bool   `help:"Disable tool usage"`
+	BaseURL         string `help:"Custom API base URL"`
+	LogLevel        string `default:"warn" help:"Log level"`
+	Config          string `help:"Path to config file"`
 
 	// TUI is the default command - interactive chat interface
 	// TUI TUICmd `default:"1" cmd:"" help:"Start interactive TUI (default)"`
@@ -21,6 +23,8 @@ type CLI struct {
 	Prompt  PromptCmd  `cmd:"" help:"Execute a single prompt"`
 	Migrate MigrateCmd `cmd:"" help:"Database migrations"`
 	Model   ModelCmd   `cmd:"" help:"Model management and information"`
+	Usage   UsageCmd   `cmd:"" help:"Report model token usage and cost"`
+	Audit   AuditCmd   `cmd:"" help:"Audit log tools"`
 }
 
 func main() {
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elee1766/gofer/src/config"
+	"github.com/elee1766/gofer/src/goferagent/tools"
+)
+
+// toolPermissions checks tool calls against the permissions, the paths of
+// file tool calls against the file system permissions, and the commands of
+// run_command calls against the command permissions
+type toolPermissions struct {
+	*config.PermissionChecker
+
+	// confirm keeps the calls that require confirmation to be asked about.
+	// Without it they are allowed.
+	confirm bool
+}
+
+// CheckToolPermission checks a tool call. A run_command call is denied when
+// the tool or its command is denied, requires confirmation when its command
+// does, and is allowed without confirmation when every part of its command
+// is allowed. A file tool call is denied when the tool or one of its paths
+// is denied, and requires confirmation when the tool or one of its paths
+// does. Otherwise the tool permissions decide. Without confirm, calls that
+// require confirmation are allowed.
+func (p toolPermissions) CheckToolPermission(toolName string, args map[string]interface{}) (config.PermissionResult, error) {
+	result, err := p.checkTool(toolName, args)
+	if err != nil || !result.RequiresConfirmation || p.confirm {
+		return result, err
+	}
+	return config.PermissionResult{Allowed: true, Reason: "allowed without confirmation, which is off"}, nil
+}
+
+func (p toolPermissions) checkTool(toolName string, args map[string]interface{}) (config.PermissionResult, error) {
+	result, err := p.PermissionChecker.CheckToolPermission(toolName, args)
+	if err != nil {
+		return result, err
+	}
+	if !result.Allowed && !result.RequiresConfirmation {
+		return result, nil
+	}
+	if toolName == tools.RunCommandName {
+		return p.checkCommand(result, args), nil
+	}
+	return p.checkPaths(toolName, result, args)
+}
+
+// checkCommand checks the command of a run_command call the tool
+// permissions did not deny
+func (p toolPermissions) checkCommand(result config.PermissionResult, args map[string]interface{}) config.PermissionResult {
+	command, _ := args["command"].(string)
+	if command == "" {
+		return result
+	}
+
+	eval := p.EvaluateCommand(command)
+	switch eval.Decision {
+	case config.CommandDeny:
+		return config.PermissionResult{Reason: eval.Explain()}
+	case config.CommandConfirm:
+		return config.PermissionResult{
+			Allowed:              true,
+			RequiresConfirmation: true,
+			ConfirmationMessage:  fmt.Sprintf("Allow command: %s", eval.Explain()),
+		}
+	case config.CommandAllow:
+		return config.PermissionResult{Allowed: true, Reason: eval.Explain()}
+	}
+	return result
+}
+
+// checkPaths checks the paths a file tool call the tool permissions did not
+// deny reads and writes. The first denied path denies the call.
+func (p toolPermissions) checkPaths(toolName string, result config.PermissionResult, args map[string]interface{}) (config.PermissionResult, error) {
+	read, write := tools.FilePaths(toolName, args)
+
+	var messages []string
+	if result.RequiresConfirmation {
+		messages = append(messages, result.ConfirmationMessage)
+	}
+	var pathResults []config.PermissionResult
+	for _, path := range read {
+		pathResult, err := p.CheckFileReadPermission(path)
+		if err != nil {
+			return config.PermissionResult{}, err
+		}
+		pathResults = append(pathResults, pathResult)
+	}
+	for _, path := range write {
+		pathResult, err := p.CheckFileWritePermission(path)
+		if err != nil {
+			return config.PermissionResult{}, err
+		}
+		pathResults = append(pathResults, pathResult)
+	}
+	for _, pathResult := range pathResults {
+		if !pathResult.Allowed && !pathResult.RequiresConfirmation {
+			return pathResult, nil
+		}
+		if pathResult.RequiresConfirmation {
+			messages = append(messages, pathResult.ConfirmationMessage)
+		}
+	}
+
+	if len(messages) == 0 {
+		return result, nil
+	}
+	return config.PermissionResult{
+		Allowed:              true,
+		RequiresConfirmation: true,
+		ConfirmationMessage:  strings.Join(messages, "; "),
+	}, nil
+}
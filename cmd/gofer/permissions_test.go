@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elee1766/gofer/src/config"
+)
+
+func TestToolPermissions(t *testing.T) {
+	tests := []struct {
+		name         string
+		confirm      bool
+		tool         string
+		args         map[string]interface{}
+		allowed      bool
+		confirmation bool
+	}{
+		{
+			name: "denied tool",
+			tool: "system_shutdown",
+			args: map[string]interface{}{},
+		},
+		{
+			name: "denied read path",
+			tool: "read_file",
+			args: map[string]interface{}{"path": "/etc/shadow"},
+		},
+		{
+			name: "denied command",
+			tool: "run_command",
+			args: map[string]interface{}{"command": "rm -rf /"},
+		},
+		{
+			name:    "command without confirmation",
+			tool:    "run_command",
+			args:    map[string]interface{}{"command": "make deploy"},
+			allowed: true,
+		},
+		{
+			name:         "command with confirmation",
+			confirm:      true,
+			tool:         "run_command",
+			args:         map[string]interface{}{"command": "make deploy"},
+			allowed:      true,
+			confirmation: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permissions := config.DefaultConfig().Permissions
+			permissions.Confirm = tt.confirm
+			checker := toolPermissions{config.NewPermissionChecker(&permissions), tt.confirm}
+
+			result, err := checker.CheckToolPermission(tt.tool, tt.args)
+			require.NoError(t, err)
+			assert.Equal(t, tt.allowed, result.Allowed, result.Reason)
+			assert.Equal(t, tt.confirmation, result.RequiresConfirmation)
+		})
+	}
+}
@@ -1,21 +1,36 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/elee1766/gofer/src/agent"
 	"github.com/elee1766/gofer/src/aisdk"
 	"github.com/elee1766/gofer/src/app"
+	"github.com/elee1766/gofer/src/audit"
+	"github.com/elee1766/gofer/src/cassette"
+	"github.com/elee1766/gofer/src/config"
 	"github.com/elee1766/gofer/src/goferagent"
 	"github.com/elee1766/gofer/src/goferagent/tools"
+	"github.com/elee1766/gofer/src/hooks"
 	"github.com/elee1766/gofer/src/executor"
+	"github.com/elee1766/gofer/src/jsonvalidate"
+	"github.com/elee1766/gofer/src/redact"
 	"github.com/elee1766/gofer/src/shell"
 	"github.com/elee1766/gofer/src/storage"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/afero"
 )
 
+// compactCommand is the prompt text that compacts the conversation
+const compactCommand = "/compact"
+
 type RunPromptParams struct {
 	APIKey       string
 	Model        string
@@ -29,23 +44,67 @@ type RunPromptParams struct {
 	SessionID    string
 	MaxTurns     int
 	Verbose      bool
+	Stream       bool
+	Record       string // Cassette file to record model interactions to
+	Replay       string // Cassette file to replay model interactions from
+	Schema       string // JSON Schema file the final response must match
+	Pending      string // Unfinished tool calls of an interrupted run: ask, rerun or cancel
 }
 
 // RunPrompt executes a single prompt command using the new prompt package
 func RunPrompt(ctx context.Context, a *app.App, params RunPromptParams) error {
-	if params.Text == "" {
-		return fmt.Errorf("prompt text is required")
-	}
-
 	// Determine the model to use
 	model := params.Model
 	if model == "" {
 		return fmt.Errorf("model is required - no default model configured")
 	}
 
-	modelClient, err := a.ModelProvider.Model(ctx, model)
+	modelClient, closeModel, err := promptModelClient(ctx, a, model, params)
 	if err != nil {
-		return fmt.Errorf("failed to get model client: %w", err)
+		return err
+	}
+	defer closeModel()
+	fallbacks := fallbackModelClients(ctx, a, params)
+
+	// With a schema the final response is printed as validated JSON only
+	var responseFormat *aisdk.ResponseFormat
+	if params.Schema != "" {
+		responseFormat, err = loadResponseSchema(params.Schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The permissions from the config decide whether tool calls may run.
+	// Commands are checked against the command permissions by the gate and
+	// again by the shell. Calls that need confirmation are asked about on
+	// the terminal when confirm is set, and run otherwise.
+	permissionsConfig := &config.DefaultConfig().Permissions
+	if a.Config != nil && a.Config.Permissions != nil {
+		permissionsConfig = a.Config.Permissions
+	}
+	checker := config.NewPermissionChecker(permissionsConfig)
+	var permissions executor.PermissionChecker = toolPermissions{checker, permissionsConfig.Confirm}
+	var confirmer executor.Confirmer
+	if permissionsConfig.Confirm {
+		confirmer = newConfirmer()
+	}
+
+	// The audit log records what the run does when it is enabled
+	var auditLog *audit.Log
+	if a.Config != nil && a.Config.AuditLog.Enabled {
+		auditLog, err = audit.Open(a.Config.AuditLog, params.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		defer auditLog.Close()
+	}
+
+	// Secrets in tool results are replaced with placeholders when redaction
+	// is enabled, by the redactor the providers log with
+	var redactor *redact.Redactor
+	if a.Config != nil {
+		redactor = a.Config.Redactor
 	}
 
 	// Create single shell manager for tools that need it
@@ -57,15 +116,55 @@ func RunPrompt(ctx context.Context, a *app.App, params RunPromptParams) error {
 			return fmt.Errorf("failed to create shell manager: %w", err)
 		}
 		defer singleShellManager.Close()
+		singleShellManager.SetCommandPolicy(checker)
+	}
+
+	// Hooks from the config run commands around tool calls and runs
+	var runHooks *hooks.Hooks
+	if a.Config != nil {
+		runHooks = hooks.New(a.Config.Hooks, a.ProjectDir, params.Logger)
 	}
 
 	// Set up toolbox (will be created contextually later)
 	var toolbox *agent.DefaultToolbox
 	if params.EnableTools {
-		toolbox, err = createToolbox(params.Logger, afero.NewOsFs(), singleShellManager)
+		network := config.DefaultConfig().Permissions.Network
+		if a.Config != nil && a.Config.Permissions != nil {
+			network = a.Config.Permissions.Network
+		}
+		toolbox, err = createToolbox(params.Logger, afero.NewOsFs(), singleShellManager, network)
 		if err != nil {
 			return fmt.Errorf("failed to create toolbox: %w", err)
 		}
+		if runHooks != nil && !runHooks.Empty() {
+			toolbox.RegisterMiddleware(runHooks.Middleware())
+		}
+		if auditLog != nil {
+			toolbox.RegisterMiddleware(auditLog.Middleware(func(ctx context.Context) string {
+				info, _ := executor.ToolCallInfoFromContext(ctx)
+				return info.ConversationID
+			}))
+		}
+		if a.Config != nil {
+			for _, middleware := range toolMiddleware(a.Config.Tools, toolbox, params.Logger) {
+				toolbox.RegisterMiddleware(middleware)
+			}
+		}
+		if redactor != nil {
+			toolbox.RegisterMiddleware(redactor.Middleware())
+		}
+	}
+
+	contextWindow, err := newContextWindow(a.Config)
+	if err != nil {
+		return err
+	}
+
+	// The task tool hands subtasks to a sub-agent with a subset of the tools
+	if params.EnableTools && a.Config != nil && a.Config.SubAgent.Enabled {
+		if err := registerTaskTool(ctx, a, toolbox, modelClient, fallbacks, contextWindow, permissions, confirmer, auditLog, redactor, params); err != nil {
+			return err
+		}
 	}
 
 	// Determine system prompt
@@ -73,15 +172,29 @@ func RunPrompt(ctx context.Context, a *app.App, params RunPromptParams) error {
 	if systemPrompt == "" && params.EnableTools {
 		systemPrompt = goferagent.GetDefaultSystemPrompt(toolbox)
 	}
+	if redactor != nil && params.EnableTools {
+		systemPrompt += redactionPrompt(redactor)
+	}
 
 	// Create executor service
-	service := executor.NewService(executor.ServiceConfig{
-		Database:     a.Store.DB(),
-		ProjectDir:   a.ProjectDir,
-		SystemPrompt: systemPrompt,
-		MaxTurns:     3,
-		Logger:       params.Logger,
-	})
+	serviceConfig := executor.ServiceConfig{
+		Database:      a.Store.DB(),
+		ProjectDir:    a.ProjectDir,
+		SystemPrompt:  systemPrompt,
+		MaxTurns:      3,
+		Logger:        params.Logger,
+		ContextWindow: contextWindow,
+		Permissions:   permissions,
+		Confirmer:     confirmer,
+		AuditLog:      auditLog,
+		Redactor:      redactor,
+	}
+	if a.Config != nil {
+		serviceConfig.MaxParallelTools = a.Config.MaxParallelTools
+		serviceConfig.AutoCompact = a.Config.AutoCompact
+		serviceConfig.CompactThreshold = a.Config.CompactThreshold
+	}
+	service := executor.NewService(serviceConfig)
 
 	// Create event sink and processor
 	processorConfig := executor.ConsoleProcessorConfig{
@@ -94,10 +207,17 @@ func RunPrompt(ctx context.Context, a *app.App, params RunPromptParams) error {
 		MaxResultPreview:    200,
 	}
 	
-	consoleProcessor := executor.NewConsoleEventProcessor(processorConfig)
-	eventSink := executor.NewChannelEventSink(100, consoleProcessor)
-	defer eventSink.Close()
-	
+	// Console output is skipped for structured output so stdout only
+	// carries the JSON response
+	var eventSink *executor.ChannelEventSink
+	var sink executor.EventSink
+	if responseFormat == nil {
+		consoleProcessor := executor.NewConsoleEventProcessor(processorConfig)
+		eventSink = executor.NewChannelEventSink(100, consoleProcessor)
+		defer eventSink.Close()
+		sink = eventSink
+	}
+
 	// Legacy callbacks for compatibility (will be phased out)
 	callbacks := &executor.Callbacks{}
 
@@ -107,140 +227,312 @@ func RunPrompt(ctx context.Context, a *app.App, params RunPromptParams) error {
 		return err
 	}
 
+	// Settle the unfinished tool calls of an interrupted run before the
+	// conversation continues
+	var interrupted *executor.InterruptedRun
+	if params.Resume || params.SessionID != "" {
+		interrupted, err = resumeInterruptedRun(ctx, service, conversation, toolbox, sink, modelClient, params)
+		if err != nil {
+			return err
+		}
+	}
+	if params.Text == "" && interrupted == nil {
+		return fmt.Errorf("prompt text is required")
+	}
+
 	// Build conversation from existing messages
 	aisdkConv, err := buildConversationFromDB(ctx, service, conversation, params.SystemPrompt)
 	if err != nil {
 		return err
 	}
 
-	// Create and save user message (will be wrapped with context later)
-	originalUserText := params.Text
-
-	// Save user message to database
-	err = service.SaveUserMessage(ctx, conversation.ID, params.Text)
-	if err != nil {
-		return fmt.Errorf("failed to save user message: %w", err)
+	// "/compact" summarizes the conversation instead of sending a prompt
+	if strings.TrimSpace(params.Text) == compactCommand {
+		_, err := service.Compact(ctx, &executor.CompactRequest{
+			Conversation:   aisdkConv,
+			ConversationID: conversation.ID,
+			ModelClient:    modelClient,
+			Trigger:        executor.CompactManual,
+			EventSink:      sink,
+		})
+		if errors.Is(err, executor.ErrNothingToCompact) {
+			fmt.Println("Nothing to compact")
+			return nil
+		}
+		return err
 	}
 
-	// Execute conversation with turn tracking
+	// Run the turn loop. Without a new prompt the interrupted run goes on
+	// with the turns it had left.
 	maxTurns := params.MaxTurns
 	if maxTurns <= 0 {
 		maxTurns = 3
 	}
+	if params.Text == "" && interrupted.TurnsRemaining() > 0 {
+		maxTurns = interrupted.TurnsRemaining()
+	}
+	runnerConfig := executor.RunnerConfig{
+		ModelClient:    modelClient,
+		FallbackModels: fallbacks,
+		Toolbox:        toolbox,
+		EventSink:      sink,
+		Callbacks:      callbacks,
+		Policy: executor.RunPolicy{
+			MaxTurns:       maxTurns,
+			Stream:         params.Stream,
+			ResponseFormat: responseFormat,
+		},
+	}
+	if runHooks != nil {
+		runnerConfig.Hooks = runHooks.RunHooks(conversation.ID)
+	}
+	runner := executor.NewRunner(service, runnerConfig)
+	result, err := runner.Run(ctx, &executor.RunRequest{
+		Prompt:       params.Text,
+		Session:      session,
+		Conversation: conversation,
+		History:      aisdkConv,
+	})
+	if err != nil {
+		return err
+	}
 
-	currentConv := aisdkConv
-	turnsRemaining := maxTurns
-	isFirstTurn := true
-	justExecutedTools := false
-
-	for turnsRemaining > 0 {
-		// For single shell, we just use the same toolbox without conversation-specific context
-		contextualToolbox := toolbox
-
-		// Prepare the message for this step
-		var messageToSend *aisdk.Message
-		
-		if isFirstTurn {
-			// Wrap the initial user message with context
-			wrappedContent := executor.WrapFirstMessage(originalUserText, turnsRemaining, params.EnableTools)
-			messageToSend = &aisdk.Message{
-				Role:    "user",
-				Content: wrappedContent,
-			}
-		} else if justExecutedTools && turnsRemaining > 1 {
-			// After tool execution, let LLM assess completion rather than pushing to continue
-			messageToSend = nil
-		} else {
-			// No message needed - just tool results
-			messageToSend = nil
-		}
+	// Output is handled by the console processor via events, except for
+	// structured output which is printed as validated JSON only
+	if responseFormat != nil {
+		fmt.Fprintln(os.Stdout, strings.TrimSpace(string(result.Structured)))
+	}
 
-		// Step 1: Send message to LLM
-		stepReq := &executor.StepRequest{
-			Conversation:   currentConv,
-			Message:        messageToSend,
-			ModelClient:    modelClient,
-			SessionID:      session.ID,
-			ConversationID: conversation.ID,
-			Toolbox:        contextualToolbox,
-			Callbacks:      callbacks,
-			EventSink:      eventSink,
-			TurnNumber:     maxTurns - turnsRemaining + 1,
+	return nil
+}
+
+// redactionPrompt tells the model about the placeholders of redacted secrets
+func redactionPrompt(redactor *redact.Redactor) string {
+	prompt := "\n\nSecrets in tool results are replaced with placeholders like [REDACTED:github_token:4f1c9a0b27de]."
+	if redactor.CanResolve() {
+		prompt += fmt.Sprintf(" To write a secret to a file, put its placeholder in the arguments of %s, %s or %s; other tools get placeholders as they are.",
+			tools.WriteFileName, tools.EditFileName, tools.PatchName)
+	}
+	return prompt
+}
+
+// registerTaskTool adds the task tool to the toolbox. Its sub-agent may use
+// the tools named in the config, or else the read-only tools of the toolbox.
+// Its tool calls are subject to the same permissions, audit log and
+// redaction.
+func registerTaskTool(ctx context.Context, a *app.App, toolbox *agent.DefaultToolbox, modelClient aisdk.ModelClient, fallbacks []aisdk.ModelClient, contextWindow *agent.ContextWindow, permissions executor.PermissionChecker, confirmer executor.Confirmer, auditLog *audit.Log, redactor *redact.Redactor, params RunPromptParams) error {
+	cfg := a.Config.SubAgent
+
+	keep := agent.IsParallelSafe
+	if len(cfg.Tools) > 0 {
+		names := make(map[string]bool, len(cfg.Tools))
+		for _, name := range cfg.Tools {
+			if name == executor.TaskToolName {
+				return fmt.Errorf("sub_agent.tools cannot contain the %s tool", executor.TaskToolName)
+			}
+			if !toolbox.HasTool(name) {
+				return fmt.Errorf("sub_agent.tools: unknown tool %q", name)
+			}
+			names[name] = true
 		}
+		keep = func(tool agent.Tool) bool { return names[tool.GetName()] }
+	}
+	subToolbox := toolbox.Subset(keep)
 
-		stepResult, err := service.Step(ctx, stepReq)
+	// A replayed cassette only has the prompt's model
+	subModel := modelClient
+	if cfg.Model != "" && cfg.Model != params.Model && params.Replay == "" {
+		var err error
+		subModel, err = a.ModelProvider.Model(ctx, cfg.Model)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to get sub-agent model client: %w", err)
 		}
+	}
 
-		if stepResult.State == executor.StateError {
-			return stepResult.Error
-		}
+	systemPrompt := cfg.SystemPrompt
+	if systemPrompt == "" {
+		systemPrompt = goferagent.GenerateSubAgentSystemPrompt(subToolbox)
+	}
+	if redactor != nil {
+		systemPrompt += redactionPrompt(redactor)
+	}
+	service := executor.NewService(executor.ServiceConfig{
+		Database:         a.Store.DB(),
+		ProjectDir:       a.ProjectDir,
+		SystemPrompt:     systemPrompt,
+		Logger:           params.Logger,
+		ContextWindow:    contextWindow,
+		MaxParallelTools: a.Config.MaxParallelTools,
+		Permissions:      permissions,
+		Confirmer:        confirmer,
+		AuditLog:         auditLog,
+		Redactor:         redactor,
+	})
+	taskTool, err := service.TaskTool(executor.TaskToolConfig{
+		ModelClient:    subModel,
+		FallbackModels: fallbacks,
+		Toolbox:        subToolbox,
+		SystemPrompt:   systemPrompt,
+		MaxTurns:       cfg.MaxTurns,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create task tool: %w", err)
+	}
+	if err := toolbox.RegisterTool(taskTool); err != nil {
+		return fmt.Errorf("failed to register task tool: %w", err)
+	}
+	if params.Logger != nil {
+		params.Logger.Debug("Registered tool", "tool", executor.TaskToolName, "sub_agent_tools", len(subToolbox.Tools()))
+	}
+	return nil
+}
 
-		currentConv = stepResult.UpdatedConversation
-		isFirstTurn = false
-		justExecutedTools = false
+// resumeInterruptedRun reruns or cancels the unfinished tool calls of the
+// conversation's interrupted run, asking the user what to do unless
+// params.Pending decides it. It returns nil if no run was interrupted.
+func resumeInterruptedRun(ctx context.Context, service *executor.Service, conversation *storage.Conversation, toolbox *agent.DefaultToolbox, sink executor.EventSink, modelClient aisdk.ModelClient, params RunPromptParams) (*executor.InterruptedRun, error) {
+	run, err := service.InterruptedRun(ctx, conversation.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for an interrupted run: %w", err)
+	}
+	if run == nil {
+		return nil, nil
+	}
 
-		// Events are now handled by the event processor, no need for manual printing
+	action := executor.PendingAction(params.Pending)
+	if params.Pending == "" || params.Pending == "ask" {
+		action = askPendingAction(run)
+	}
+	if action == executor.PendingRerun && toolbox == nil {
+		return nil, fmt.Errorf("cannot rerun tool calls with tools disabled")
+	}
 
-		// Step 2: If no tool calls, we're done
-		if stepResult.State == executor.StateTextResponse {
-			turnsRemaining--
-			break
-		}
+	_, err = service.ResumeInterruptedRun(ctx, &executor.ResumeRequest{
+		Run:            run,
+		Action:         action,
+		ConversationID: conversation.ID,
+		Toolbox:        toolbox,
+		Model:          modelClient.GetModelInfo().ID,
+		Provider:       aisdk.ProviderName(modelClient),
+		EventSink:      sink,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume interrupted run: %w", err)
+	}
+	return run, nil
+}
 
-		// Step 3: Execute tool calls if needed
-		if stepResult.State == executor.StateToolCallsNeeded {
-			toolReq := &executor.ToolExecutionRequest{
-				ToolCalls:      stepResult.ToolCalls,
-				Toolbox:        contextualToolbox,
-				SessionID:      session.ID,
-				ConversationID: conversation.ID,
-				Model:          modelClient.GetModelInfo().ID,
-				Callbacks:      callbacks,
-				EventSink:      eventSink,
-				TurnNumber:     maxTurns - turnsRemaining + 1,
-			}
+// askPendingAction asks on the terminal whether to rerun the unfinished tool
+// calls of an interrupted run. Without a terminal they are cancelled.
+func askPendingAction(run *executor.InterruptedRun) executor.PendingAction {
+	fmt.Fprintf(os.Stderr, "The last run was interrupted with %d unfinished tool calls:\n", len(run.Pending))
+	for _, tc := range run.Pending {
+		fmt.Fprintf(os.Stderr, "  - %s %s\n", tc.Function.Name, string(tc.Function.Arguments))
+	}
 
-			toolResult, err := service.ExecuteToolCalls(ctx, toolReq)
-			if err != nil {
-				return err
-			}
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		fmt.Fprintln(os.Stderr, "Telling the model they were cancelled (use --pending rerun to run them again)")
+		return executor.PendingCancel
+	}
 
-			if toolResult.State == executor.StateError {
-				return toolResult.Error
-			}
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "Run them again (r) or tell the model they were cancelled (c)? [r/c] ")
+		answer, err := reader.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "r", "rerun":
+			return executor.PendingRerun
+		case "c", "cancel":
+			return executor.PendingCancel
+		}
+		if err != nil {
+			return executor.PendingCancel
+		}
+	}
+}
 
-			// Add tool results to conversation
-			for _, toolMsg := range toolResult.ToolResults {
-				currentConv.Messages = append(currentConv.Messages, toolMsg)
-			}
+// newContextWindow creates the context window check from the app config
+func newContextWindow(cfg *app.AppConfig) (*agent.ContextWindow, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	strategy, err := agent.ParseContextStrategy(cfg.ContextStrategy)
+	if err != nil {
+		return nil, err
+	}
+	return &agent.ContextWindow{Strategy: strategy, ReserveTokens: cfg.ContextReserveTokens}, nil
+}
 
-			// Mark that we just executed tools and continue to next iteration
-			justExecutedTools = true
-			turnsRemaining--
-			continue
-		}
+// loadResponseSchema reads a JSON Schema file into a json_schema response
+// format named after the file
+func loadResponseSchema(path string) (*aisdk.ResponseFormat, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	if _, err := jsonvalidate.Compile(data); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return aisdk.NewJSONSchemaResponseFormat(name, data), nil
+}
+
+// promptModelClient returns the model client for a prompt run. With a replay
+// cassette the responses come from the file and no provider is contacted; with
+// a record cassette the provider's client is wrapped to save every interaction.
+func promptModelClient(ctx context.Context, a *app.App, model string, params RunPromptParams) (aisdk.ModelClient, func() error, error) {
+	noop := func() error { return nil }
 
-		// Fallback - shouldn't reach here normally
-		turnsRemaining--
-		break
+	if params.Replay != "" {
+		replayer, err := cassette.NewReplayer(params.Replay)
+		if err != nil {
+			return nil, nil, err
+		}
+		if params.Logger != nil {
+			params.Logger.Debug("Replaying model responses", "cassette", params.Replay, "model", replayer.GetModelInfo().ID)
+		}
+		return replayer, noop, nil
 	}
 
-	// Output is now handled by the console processor via events
+	modelClient, err := a.ModelProvider.Model(ctx, model)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get model client: %w", err)
+	}
 
-	// Emit conversation complete event
-	if eventSink != nil {
-		emitter := executor.NewEventEmitter(eventSink, conversation.ID, maxTurns - turnsRemaining)
-		if turnsRemaining <= 0 {
-			emitter.EmitConversationComplete("max_turns", maxTurns - turnsRemaining, turnsRemaining)
-			params.Logger.Warn("Max turns reached", "turns", params.MaxTurns)
-		} else {
-			emitter.EmitConversationComplete("task_complete", maxTurns - turnsRemaining, turnsRemaining)
+	if params.Record != "" {
+		recorder, err := cassette.NewRecorder(modelClient, params.Record)
+		if err != nil {
+			return nil, nil, err
 		}
+		return recorder, recorder.Close, nil
 	}
 
-	return nil
+	return modelClient, noop, nil
+}
+
+// fallbackModelClients returns the clients of the configured fallback
+// models. Cassettes hold the interactions of one model, so runs that record
+// or replay one have no fallbacks. Models that cannot be resolved are
+// skipped with a warning.
+func fallbackModelClients(ctx context.Context, a *app.App, params RunPromptParams) []aisdk.ModelClient {
+	if a.Config == nil || params.Record != "" || params.Replay != "" {
+		return nil
+	}
+	var clients []aisdk.ModelClient
+	for _, model := range a.Config.FallbackModels {
+		if model == params.Model {
+			continue
+		}
+		client, err := a.ModelProvider.Model(ctx, model)
+		if err != nil {
+			if params.Logger != nil {
+				params.Logger.Warn("Skipping fallback model", "model", model, "error", err)
+			}
+			continue
+		}
+		clients = append(clients, client)
+	}
+	return clients
 }
 
 // RunPromptWithApp executes a single prompt command using the shared app instance
@@ -248,9 +540,11 @@ func RunPromptWithApp(ctx context.Context, a *app.App, params RunPromptParams) e
 	return RunPrompt(ctx, a, params)
 }
 
-// createToolbox creates a toolbox with all the default tools using the provided filesystem and shell manager
-func createToolbox(logger *slog.Logger, fs afero.Fs, singleShellManager *shell.SingleShellManager) (*agent.DefaultToolbox, error) {
+// createToolbox creates a toolbox with all the default tools using the provided filesystem and shell manager.
+// web_fetch requests are checked against the network permissions.
+func createToolbox(logger *slog.Logger, fs afero.Fs, singleShellManager *shell.SingleShellManager, network config.NetworkPermissions) (*agent.DefaultToolbox, error) {
 	toolbox := agent.NewToolbox[agent.Tool]()
+	toolbox.RegisterMiddleware(agent.RecoveryMiddleware(logger))
 
 	// List of filesystem-based tool creation functions
 	fsToolCreators := []struct {
@@ -433,7 +727,7 @@ func createToolbox(logger *slog.Logger, fs afero.Fs, singleShellManager *shell.S
 	}
 
 	// Register WebFetchTool (now returns error)
-	webFetchTool, err := tools.WebFetchTool()
+	webFetchTool, err := tools.WebFetchTool(network)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create web fetch tool: %w", err)
 	}
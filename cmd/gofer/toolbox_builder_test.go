@@ -1,13 +1,24 @@
 package main
 
 import (
+	"log/slog"
 	"testing"
+
+	"github.com/elee1766/gofer/src/config"
+	"github.com/elee1766/gofer/src/shell"
 	"github.com/spf13/afero"
 )
 
 func TestCreateDefaultToolbox(t *testing.T) {
+	// run_command needs a shell manager
+	shellManager, err := shell.NewSingleShellManager(slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to create shell manager: %v", err)
+	}
+	defer shellManager.Close()
+
 	// Test creating toolbox without logger
-	toolbox, err := createToolbox(nil, afero.NewOsFs())
+	toolbox, err := createToolbox(nil, afero.NewOsFs(), shellManager, config.DefaultConfig().Permissions.Network)
 	if err != nil {
 		t.Fatalf("Failed to create default toolbox: %v", err)
 	}
@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"log/slog"
+	"sort"
 
 	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/config"
 	"github.com/spf13/afero"
 )
 
@@ -21,7 +23,7 @@ type ToolInfo struct {
 // GetAllTools returns information about all available tools
 func GetAllTools() ([]ToolInfo, error) {
 	// Create a temporary toolbox to get all tools
-	toolbox, err := createToolbox(slog.Default(), afero.NewOsFs(), nil)
+	toolbox, err := createToolbox(slog.Default(), afero.NewOsFs(), nil, config.DefaultConfig().Permissions.Network)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create toolbox: %w", err)
 	}
@@ -65,6 +67,49 @@ func GetAllTools() ([]ToolInfo, error) {
 	return toolInfos, nil
 }
 
+// toolMiddleware builds the middleware for the per-tool settings of the
+// config. For each tool, calls are logged once, retried as a whole, and
+// timed out and truncated per attempt. Only tools of the toolbox that are
+// parallel-safe are retried, since retrying a call with side effects could
+// repeat them.
+func toolMiddleware(toolConfigs map[string]config.ToolConfig, toolbox *agent.DefaultToolbox, logger *slog.Logger) []agent.ToolMiddleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	names := make([]string, 0, len(toolConfigs))
+	for name := range toolConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var middleware []agent.ToolMiddleware
+	for _, name := range names {
+		tc := toolConfigs[name]
+		var chain []agent.ToolMiddleware
+		if tc.LogArguments {
+			chain = append(chain, agent.ArgumentLoggingMiddleware(logger))
+		}
+		if tc.Retries > 0 {
+			if tool, ok := toolbox.GetTool(name); ok && agent.IsParallelSafe(tool) {
+				chain = append(chain, agent.RetryMiddleware(tc.Retries, tc.RetryDelay, logger))
+			} else {
+				logger.Warn("ignoring retries of a tool that may have side effects", "tool", name)
+			}
+		}
+		if tc.Timeout > 0 {
+			chain = append(chain, agent.TimeoutMiddleware(tc.Timeout, logger))
+		}
+		if tc.MaxOutputBytes > 0 {
+			chain = append(chain, agent.TruncateOutputMiddleware(tc.MaxOutputBytes))
+		}
+		if len(chain) > 0 {
+			middleware = append(middleware, agent.ForTool(name, agent.ChainMiddleware(chain...)))
+		}
+	}
+	return middleware
+}
+
 // categorizeToolByName categorizes a tool based on its name
 func categorizeToolByName(name string) string {
 	switch name {
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/elee1766/gofer/src/config"
+	"github.com/elee1766/gofer/src/shell"
+	"github.com/spf13/afero"
+)
+
+func TestToolMiddlewareRetriesOnlyParallelSafeTools(t *testing.T) {
+	shellManager, err := shell.NewSingleShellManager(slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to create shell manager: %v", err)
+	}
+	defer shellManager.Close()
+
+	toolbox, err := createToolbox(nil, afero.NewMemMapFs(), shellManager, config.DefaultConfig().Permissions.Network)
+	if err != nil {
+		t.Fatalf("Failed to create toolbox: %v", err)
+	}
+
+	middleware := toolMiddleware(map[string]config.ToolConfig{
+		"read_file":   {Retries: 2},
+		"run_command": {Retries: 2},
+		"write_file":  {Retries: 2},
+	}, toolbox, nil)
+	if len(middleware) != 1 {
+		t.Errorf("Expected only read_file to be retried, got middleware for %d tools", len(middleware))
+	}
+}
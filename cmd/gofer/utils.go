@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/adrg/xdg"
+	"github.com/elee1766/gofer/src/app"
 	"github.com/elee1766/gofer/src/config"
 )
 
@@ -51,6 +52,49 @@ func overrideConfigFromCLI(cfg *config.Config, cli *CLI) {
 	}
 }
 
+// newAppConfig builds the app configuration from the config files and CLI flags
+func newAppConfig(cli *CLI, logger *slog.Logger) (app.AppConfig, error) {
+	cfg, err := loadConfig(cli.Config)
+	if err != nil {
+		return app.AppConfig{}, err
+	}
+	overrideConfigFromCLI(cfg, cli)
+
+	projectDir, _ := os.Getwd()
+	return app.AppConfig{
+		APIKey:          cfg.API.APIKey,
+		BaseURL:         cfg.API.BaseURL,
+		EnableTools:     !cli.NoTools,
+		Logger:          logger,
+		ProjectDir:      projectDir,
+		Providers:       cfg.Providers,
+		DefaultProvider: cfg.API.Provider,
+		AnthropicAPIKey: cli.AnthropicAPIKey,
+
+		ContextStrategy:      cfg.Agent.ContextStrategy,
+		ContextReserveTokens: cfg.Agent.ContextReserveTokens,
+		MaxParallelTools:     cfg.Agent.MaxParallelTools,
+		FallbackModels:       cfg.Agent.FallbackModels,
+		Tools:                cfg.Tools,
+		AutoCompact:          cfg.AutoCompact,
+		CompactThreshold:     cfg.CompactThreshold,
+		SubAgent:             cfg.SubAgent,
+		Hooks:                cfg.Hooks,
+		Permissions:          &cfg.Permissions,
+		AuditLog:             cfg.Security.AuditLog,
+		Redaction:            cfg.Security.Redaction,
+	}, nil
+}
+
+// newProviderRegistry creates the model provider registry without opening the rest of the app
+func newProviderRegistry(cli *CLI) (*app.ProviderRegistry, error) {
+	appConfig, err := newAppConfig(cli, createCLILogger(cli.LogLevel))
+	if err != nil {
+		return nil, err
+	}
+	return app.NewProviderRegistryFromConfig(appConfig)
+}
+
 // getLogFilePath returns the path for the current session's log file
 func getLogFilePath() (string, error) {
 	// Create logs directory under XDG_STATE_HOME
@@ -13,10 +13,28 @@ type Agent struct {
 	Model        aisdk.ModelClient
 	Toolbox      *DefaultToolbox
 	Logger       *slog.Logger
+	// OnStreamChunk enables streaming when set and the model supports it
+	OnStreamChunk aisdk.StreamHandler
+	// ResponseFormat constrains the format of the model's responses
+	ResponseFormat *aisdk.ResponseFormat
+	// ContextWindow checks requests against the model's context length,
+	// the default strategy is used when nil
+	ContextWindow *ContextWindow
 }
 
 // TODO: this probably should have a parameters struct
 func (a *Agent) SendMessage(ctx context.Context, conversation *aisdk.Conversation, message *aisdk.Message) (*aisdk.Message, error) {
+	response, err := a.Complete(ctx, conversation, message)
+	if err != nil {
+		return nil, err
+	}
+	return &response.Choices[0].Message, nil
+}
+
+// Complete sends the conversation and message like SendMessage, but returns
+// the whole response so callers can account for its usage. The response has
+// at least one choice.
+func (a *Agent) Complete(ctx context.Context, conversation *aisdk.Conversation, message *aisdk.Message) (*aisdk.ChatCompletionResponse, error) {
 	messages := conversation.Messages
 	// TODO: if the existing messages dont exist, need to initialize a system prompt
 	if message != nil {
@@ -27,12 +45,31 @@ func (a *Agent) SendMessage(ctx context.Context, conversation *aisdk.Conversatio
 	if a.Toolbox != nil {
 		chatTools = ToChatTools(a.Toolbox.Tools())
 	}
+
+	// Make sure the request fits in the model's context window
+	window := a.ContextWindow
+	if window == nil {
+		window = &ContextWindow{}
+	}
+	messages, changed, err := window.Fit(a.Model.GetModelInfo(), messages, chatTools)
+	if err != nil {
+		return nil, err
+	}
+	if changed > 0 && a.Logger != nil {
+		a.Logger.Warn("shortened tool results to fit the context window", "strategy", window.Strategy, "tool_results", changed)
+	}
 	
 	ccr := &aisdk.ChatCompletionRequest{
-		Messages: messages,
-		Tools:    chatTools,
+		Messages:       messages,
+		Tools:          chatTools,
+		ResponseFormat: a.ResponseFormat,
+	}
+	var response *aisdk.ChatCompletionResponse
+	if streamer, ok := a.Model.(aisdk.StreamingModelClient); ok && a.OnStreamChunk != nil {
+		response, err = streamer.CreateChatCompletionStream(ctx, ccr, a.OnStreamChunk)
+	} else {
+		response, err = a.Model.CreateChatCompletion(ctx, ccr)
 	}
-	response, err := a.Model.CreateChatCompletion(ctx, ccr)
 	if err != nil {
 		return nil, err
 	}
@@ -41,5 +78,5 @@ func (a *Agent) SendMessage(ctx context.Context, conversation *aisdk.Conversatio
 		return nil, fmt.Errorf("no choices in response")
 	}
 	
-	return &response.Choices[0].Message, nil
+	return response, nil
 }
@@ -0,0 +1,278 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+// ContextStrategy decides what happens when a conversation does not fit in
+// the model's context window
+type ContextStrategy string
+
+const (
+	// ContextStrategyTruncate shortens the largest tool results, keeping
+	// their beginning and end
+	ContextStrategyTruncate ContextStrategy = "truncate_tool_results"
+	// ContextStrategyDrop replaces the oldest tool outputs with a note,
+	// keeping the results of the latest tool calls
+	ContextStrategyDrop ContextStrategy = "drop_tool_outputs"
+	// ContextStrategyRefuse fails the request with a token breakdown
+	ContextStrategyRefuse ContextStrategy = "refuse"
+)
+
+const (
+	// defaultReserveTokens is kept free for the response when the model does
+	// not report its maximum completion tokens
+	defaultReserveTokens = 4096
+
+	// minToolResultChars is the size truncated tool results are never
+	// shortened below
+	minToolResultChars = 2000
+
+	droppedToolOutput = "[Tool output omitted to fit the context window]"
+)
+
+// ParseContextStrategy parses a strategy name. The empty string selects the
+// default strategy.
+func ParseContextStrategy(s string) (ContextStrategy, error) {
+	switch ContextStrategy(s) {
+	case "":
+		return ContextStrategyTruncate, nil
+	case ContextStrategyTruncate, ContextStrategyDrop, ContextStrategyRefuse:
+		return ContextStrategy(s), nil
+	}
+	return "", fmt.Errorf("unknown context strategy %q (expected %s, %s or %s)",
+		s, ContextStrategyTruncate, ContextStrategyDrop, ContextStrategyRefuse)
+}
+
+// ContextWindow checks requests against the model's context length before
+// they are sent
+type ContextWindow struct {
+	Strategy ContextStrategy
+	// ReserveTokens are kept free for the response. Defaults to the model's
+	// maximum completion tokens, capped at a quarter of the context.
+	ReserveTokens int
+}
+
+// ContextBreakdown is the estimated token usage of a request by source
+type ContextBreakdown struct {
+	System          int
+	User            int
+	Assistant       int
+	ToolResults     int
+	ToolResultCount int
+	ToolDefinitions int
+
+	// LargestToolResult is the size and tool name of the largest tool result
+	LargestToolResult     int
+	LargestToolResultName string
+}
+
+// Total returns the estimated tokens of the whole request
+func (b ContextBreakdown) Total() int {
+	return b.System + b.User + b.Assistant + b.ToolResults + b.ToolDefinitions
+}
+
+func (b ContextBreakdown) String() string {
+	s := fmt.Sprintf("system %d, user %d, assistant %d, tool results %d in %d messages",
+		b.System, b.User, b.Assistant, b.ToolResults, b.ToolResultCount)
+	if b.LargestToolResult > 0 {
+		s += fmt.Sprintf(" (largest %d from %s)", b.LargestToolResult, b.LargestToolResultName)
+	}
+	return s + fmt.Sprintf(", tool definitions %d", b.ToolDefinitions)
+}
+
+// ContextWindowError is returned when a request does not fit in the context
+// window, even after applying the strategy
+type ContextWindowError struct {
+	Model     string
+	Limit     int // Context length of the model
+	Reserved  int // Tokens kept free for the response
+	Strategy  ContextStrategy
+	Breakdown ContextBreakdown
+}
+
+func (e *ContextWindowError) Error() string {
+	return fmt.Sprintf("conversation needs about %d tokens but %s accepts %d with %d reserved for the response (strategy %s): %s",
+		e.Breakdown.Total(), e.Model, e.Limit, e.Reserved, e.Strategy, e.Breakdown)
+}
+
+// Breakdown estimates the token usage of a request
+func Breakdown(estimator aisdk.TokenEstimator, messages []*aisdk.Message, tools []*aisdk.ChatTool) ContextBreakdown {
+	b := ContextBreakdown{ToolDefinitions: estimator.Tools(tools)}
+	for _, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		tokens := estimator.Message(msg)
+		switch msg.Role {
+		case "system":
+			b.System += tokens
+		case "assistant":
+			b.Assistant += tokens
+		case "tool":
+			b.ToolResults += tokens
+			b.ToolResultCount++
+			if tokens > b.LargestToolResult {
+				b.LargestToolResult = tokens
+				b.LargestToolResultName = msg.Name
+			}
+		default:
+			b.User += tokens
+		}
+	}
+	return b
+}
+
+// Fit returns messages that fit in the model's context window, applying the
+// strategy if needed, and the number of tool results it shortened or
+// dropped. The input messages are not modified. Models without a known
+// context length are not checked.
+func (cw *ContextWindow) Fit(model *aisdk.ModelInfo, messages []*aisdk.Message, tools []*aisdk.ChatTool) ([]*aisdk.Message, int, error) {
+	limit := aisdk.ContextLimit(model)
+	if limit == 0 {
+		return messages, 0, nil
+	}
+
+	reserved := cw.reserve(model, limit)
+	budget := limit - reserved
+	estimator := aisdk.EstimatorFor(model)
+	breakdown := Breakdown(estimator, messages, tools)
+	if breakdown.Total() <= budget {
+		return messages, 0, nil
+	}
+
+	strategy := cw.Strategy
+	if strategy == "" {
+		strategy = ContextStrategyTruncate
+	}
+
+	fitted := messages
+	changed := 0
+	switch strategy {
+	case ContextStrategyTruncate:
+		fitted, changed = truncateToolResults(estimator, messages, breakdown.Total()-budget)
+	case ContextStrategyDrop:
+		fitted, changed = dropToolOutputs(estimator, messages, breakdown.Total()-budget)
+	}
+
+	if changed > 0 {
+		breakdown = Breakdown(estimator, fitted, tools)
+	}
+	if breakdown.Total() > budget {
+		return nil, changed, &ContextWindowError{
+			Model:     model.ID,
+			Limit:     limit,
+			Reserved:  reserved,
+			Strategy:  strategy,
+			Breakdown: breakdown,
+		}
+	}
+	return fitted, changed, nil
+}
+
+// reserve returns the tokens kept free for the response
+func (cw *ContextWindow) reserve(model *aisdk.ModelInfo, limit int) int {
+	if cw.ReserveTokens > 0 {
+		return cw.ReserveTokens
+	}
+	reserve := defaultReserveTokens
+	if model.TopProvider != nil && model.TopProvider.MaxCompletionTokens > 0 {
+		reserve = model.TopProvider.MaxCompletionTokens
+	}
+	if reserve > limit/4 {
+		reserve = limit / 4
+	}
+	return reserve
+}
+
+// truncateToolResults shortens the largest tool results until the excess
+// tokens are removed or every result is at the minimum size
+func truncateToolResults(estimator aisdk.TokenEstimator, messages []*aisdk.Message, excess int) ([]*aisdk.Message, int) {
+	out := append([]*aisdk.Message(nil), messages...)
+	truncated := make(map[int]bool)
+	for excess > 0 {
+		// Each result is truncated at most once, either by the whole excess
+		// or down to the minimum size
+		largest, largestTokens := -1, 0
+		for i, msg := range out {
+			if msg == nil || msg.Role != "tool" || truncated[i] || len(msg.GetContent()) <= minToolResultChars {
+				continue
+			}
+			if tokens := estimator.Message(msg); tokens > largestTokens {
+				largest, largestTokens = i, tokens
+			}
+		}
+		if largest < 0 {
+			break
+		}
+
+		content := out[largest].GetContent()
+		keepChars := len(content) - int(float64(excess)*estimator.CharsPerToken) - 200 // room for the marker
+		if keepChars < minToolResultChars {
+			keepChars = minToolResultChars
+		}
+		msg := *out[largest]
+		msg.Content = truncateMiddle(content, keepChars, "to fit the context window")
+		msg.MultimodalContent = nil
+		out[largest] = &msg
+		truncated[largest] = true
+		excess -= largestTokens - estimator.Message(&msg)
+	}
+	return out, len(truncated)
+}
+
+// dropToolOutputs replaces the oldest tool outputs with a note until the
+// excess tokens are removed. The results of the latest tool calls are kept,
+// since the model has not seen them yet.
+func dropToolOutputs(estimator aisdk.TokenEstimator, messages []*aisdk.Message, excess int) ([]*aisdk.Message, int) {
+	lastAssistant := -1
+	for i, msg := range messages {
+		if msg != nil && msg.Role == "assistant" {
+			lastAssistant = i
+		}
+	}
+
+	out := append([]*aisdk.Message(nil), messages...)
+	dropped := 0
+	for i := 0; i < lastAssistant && excess > 0; i++ {
+		msg := out[i]
+		if msg == nil || msg.Role != "tool" || msg.GetContent() == droppedToolOutput {
+			continue
+		}
+		before := estimator.Message(msg)
+		replaced := *msg
+		replaced.Content = droppedToolOutput
+		replaced.MultimodalContent = nil
+		out[i] = &replaced
+		dropped++
+		excess -= before - estimator.Message(&replaced)
+	}
+	return out, dropped
+}
+
+// truncateMiddle shortens s to about maxLen bytes, keeping the first two
+// thirds and the last third and noting how much was removed and why
+func truncateMiddle(s string, maxLen int, reason string) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	head := maxLen * 2 / 3
+	tail := maxLen - head
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	start := len(s) - tail
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+
+	var b strings.Builder
+	b.WriteString(s[:head])
+	fmt.Fprintf(&b, "\n\n[... %d bytes omitted %s ...]\n\n", start-head, reason)
+	b.WriteString(s[start:])
+	return b.String()
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/fakeprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolConversation returns a conversation with one tool round trip per
+// result, followed by a user question
+func toolConversation(results ...string) []*aisdk.Message {
+	messages := []*aisdk.Message{{Role: "user", Content: "look at the logs"}}
+	for i, result := range results {
+		id := fmt.Sprintf("call_%d", i)
+		messages = append(messages,
+			&aisdk.Message{Role: "assistant", ToolCalls: []aisdk.ToolCall{{ID: id, Type: "function", Function: aisdk.FunctionCall{Name: "run_command", Arguments: []byte(`{}`)}}}},
+			&aisdk.Message{Role: "tool", Name: "run_command", ToolCallID: id, Content: result},
+		)
+	}
+	return messages
+}
+
+func testModel(contextLength int) *aisdk.ModelInfo {
+	return &aisdk.ModelInfo{
+		ID:            "test-model",
+		ContextLength: contextLength,
+		Architecture:  &aisdk.Architecture{Tokenizer: "GPT"},
+		TopProvider:   &aisdk.TopProvider{MaxCompletionTokens: 1000},
+	}
+}
+
+func TestEstimator(t *testing.T) {
+	gpt := aisdk.EstimatorFor(testModel(0))
+	assert.Equal(t, 4.0, gpt.CharsPerToken)
+	assert.Equal(t, 3.5, aisdk.EstimatorFor(&aisdk.ModelInfo{}).CharsPerToken)
+	assert.Equal(t, 25, gpt.Text(strings.Repeat("x", 100)))
+	assert.Equal(t, 29, gpt.Message(&aisdk.Message{Role: "user", Content: strings.Repeat("x", 100)}))
+
+	assert.Equal(t, 8000, aisdk.ContextLimit(&aisdk.ModelInfo{ContextLength: 10000, TopProvider: &aisdk.TopProvider{ContextLength: 8000}}))
+	assert.Equal(t, 10000, aisdk.ContextLimit(&aisdk.ModelInfo{ContextLength: 10000, TopProvider: &aisdk.TopProvider{}}))
+}
+
+func TestContextWindowFits(t *testing.T) {
+	messages := toolConversation("short output")
+	cw := &ContextWindow{Strategy: ContextStrategyRefuse}
+
+	fitted, changed, err := cw.Fit(testModel(10000), messages, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, changed)
+	assert.Equal(t, messages, fitted)
+
+	// Unknown context lengths are not checked
+	_, _, err = cw.Fit(testModel(0), toolConversation(strings.Repeat("x", 1000000)), nil)
+	assert.NoError(t, err)
+}
+
+func TestContextWindowTruncate(t *testing.T) {
+	large := "first line\n" + strings.Repeat("x", 40000) + "\nlast line"
+	messages := toolConversation("small", large)
+	cw := &ContextWindow{Strategy: ContextStrategyTruncate}
+
+	fitted, changed, err := cw.Fit(testModel(8000), messages, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, changed)
+
+	content := fitted[4].Content
+	assert.Less(t, len(content), len(large))
+	assert.True(t, strings.HasPrefix(content, "first line\n"))
+	assert.True(t, strings.HasSuffix(content, "\nlast line"))
+	assert.Contains(t, content, "bytes omitted to fit the context window")
+	assert.Equal(t, "small", fitted[2].Content)
+
+	// The conversation itself is left alone
+	assert.Equal(t, large, messages[4].Content)
+	assert.LessOrEqual(t, Breakdown(aisdk.EstimatorFor(testModel(0)), fitted, nil).Total(), 7000)
+}
+
+func TestContextWindowDrop(t *testing.T) {
+	old := strings.Repeat("a", 20000)
+	messages := toolConversation(old, old, "latest result")
+	cw := &ContextWindow{Strategy: ContextStrategyDrop}
+
+	fitted, changed, err := cw.Fit(testModel(8000), messages, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, changed)
+	assert.Equal(t, droppedToolOutput, fitted[2].Content)
+	assert.Equal(t, old, fitted[4].Content)
+	assert.Equal(t, "latest result", fitted[6].Content)
+
+	// The latest results are never dropped
+	_, _, err = cw.Fit(testModel(8000), toolConversation(strings.Repeat("b", 40000)), nil)
+	var windowErr *ContextWindowError
+	require.True(t, errors.As(err, &windowErr), "got %v", err)
+	assert.Equal(t, ContextStrategyDrop, windowErr.Strategy)
+}
+
+func TestContextWindowRefuse(t *testing.T) {
+	messages := toolConversation(strings.Repeat("x", 40000))
+	cw := &ContextWindow{Strategy: ContextStrategyRefuse}
+
+	_, _, err := cw.Fit(testModel(8000), messages, nil)
+	var windowErr *ContextWindowError
+	require.True(t, errors.As(err, &windowErr), "got %v", err)
+	assert.Equal(t, 8000, windowErr.Limit)
+	assert.Equal(t, 1000, windowErr.Reserved)
+	assert.Equal(t, 1, windowErr.Breakdown.ToolResultCount)
+	assert.Equal(t, "run_command", windowErr.Breakdown.LargestToolResultName)
+	assert.Contains(t, err.Error(), "tool results 10004 in 1 messages (largest 10004 from run_command)")
+}
+
+func TestSendMessageTruncatesToolResults(t *testing.T) {
+	provider := fakeprovider.New(&fakeprovider.Script{ContextLength: 8000, Turns: []fakeprovider.Turn{{
+		Text: "done",
+		Check: func(req *aisdk.ChatCompletionRequest) error {
+			if content := req.Messages[2].Content; len(content) > 30000 {
+				return fmt.Errorf("tool result was sent with %d bytes", len(content))
+			}
+			return nil
+		},
+	}}})
+	model, err := provider.Model(context.Background(), "fake-model")
+	require.NoError(t, err)
+
+	a := &Agent{Model: model}
+	conv := &aisdk.Conversation{Messages: toolConversation(strings.Repeat("x", 100000))}
+	reply, err := a.SendMessage(context.Background(), conv, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "done", reply.Content)
+	assert.Len(t, conv.Messages[2].Content, 100000)
+}
+
+func TestParseContextStrategy(t *testing.T) {
+	strategy, err := ParseContextStrategy("")
+	require.NoError(t, err)
+	assert.Equal(t, ContextStrategyTruncate, strategy)
+
+	strategy, err = ParseContextStrategy("refuse")
+	require.NoError(t, err)
+	assert.Equal(t, ContextStrategyRefuse, strategy)
+
+	_, err = ParseContextStrategy("summarize")
+	assert.Error(t, err)
+}
@@ -58,6 +58,17 @@ func NewGenericTool[TInput any, TOutput any](name, description string, handler G
 	return NewGenericToolFixed(name, description, handler)
 }
 
+// NewParallelSafeTool creates a generic tool without side effects, which the
+// executor may run concurrently with other parallel-safe tools
+func NewParallelSafeTool[TInput any, TOutput any](name, description string, handler GenericToolHandler[TInput, TOutput]) (Tool, error) {
+	tool, err := NewGenericToolFixed(name, description, handler)
+	if err != nil {
+		return nil, err
+	}
+	tool.Parallel = true
+	return tool, nil
+}
+
 // MustNewGenericTool creates a new generic tool and panics on error
 func MustNewGenericTool[TInput any, TOutput any](name, description string, handler GenericToolHandler[TInput, TOutput]) Tool {
 	tool, err := NewGenericTool(name, description, handler)
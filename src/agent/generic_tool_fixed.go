@@ -20,6 +20,9 @@ type GenericToolFixed[TInput any, TOutput any] struct {
 	OutputType  reflect.Type
 	Schema      *jsonschema.Schema
 	Handler     GenericToolHandler[TInput, TOutput]
+
+	// Parallel marks tools without side effects that can run concurrently
+	Parallel bool
 }
 
 // GetType returns the tool type (always "function" for now)
@@ -42,6 +45,11 @@ func (gt *GenericToolFixed[TInput, TOutput]) GetParameters() *jsonschema.Schema
 	return gt.Schema
 }
 
+// ParallelSafe reports whether the tool can run concurrently with other tools
+func (gt *GenericToolFixed[TInput, TOutput]) ParallelSafe() bool {
+	return gt.Parallel
+}
+
 // Execute runs the tool with the given parameters
 func (gt *GenericToolFixed[TInput, TOutput]) Execute(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
 	// Skip kaptinlin validation and just use Go's type system
@@ -14,6 +14,7 @@ type LegacyTool struct {
 	Type     string              `json:"type"` // Always "function" for function tools
 	Function aisdk.ToolFunction  `json:"function"`
 	Executor aisdk.ToolExecutor  `json:"-"` // Execution function, not serialized
+	Parallel bool                `json:"-"` // No side effects, can run concurrently
 }
 
 // GetType returns the tool type
@@ -36,6 +37,11 @@ func (t *LegacyTool) GetParameters() *jsonschema.Schema {
 	return t.Function.Parameters
 }
 
+// ParallelSafe reports whether the tool can run concurrently with other tools
+func (t *LegacyTool) ParallelSafe() bool {
+	return t.Parallel
+}
+
 // Execute runs the tool
 func (t *LegacyTool) Execute(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
 	if t.Executor == nil {
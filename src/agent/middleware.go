@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+// ChainMiddleware combines middleware into one, the first being the outermost layer
+func ChainMiddleware(middleware ...ToolMiddleware) ToolMiddleware {
+	return func(next ToolExecutor) ToolExecutor {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		return next
+	}
+}
+
+// ForTool applies middleware to the calls of one tool only
+func ForTool(name string, middleware ToolMiddleware) ToolMiddleware {
+	return func(next ToolExecutor) ToolExecutor {
+		wrapped := middleware(next)
+		return func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+			if call.Function.Name != name {
+				return next(ctx, call)
+			}
+			return wrapped(ctx, call)
+		}
+	}
+}
+
+// RecoveryMiddleware turns panics in tools into error responses, so a broken
+// tool fails its call instead of the whole agent
+func RecoveryMiddleware(logger *slog.Logger) ToolMiddleware {
+	return func(next ToolExecutor) ToolExecutor {
+		return func(ctx context.Context, call *aisdk.ToolCall) (result *aisdk.ToolResponse, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					result, err = panicResponse(logger, call, r), nil
+				}
+			}()
+			return next(ctx, call)
+		}
+	}
+}
+
+// panicResponse logs a recovered panic and returns the error response for it
+func panicResponse(logger *slog.Logger, call *aisdk.ToolCall, r interface{}) *aisdk.ToolResponse {
+	if logger != nil {
+		logger.Error("tool panicked", "tool", call.Function.Name, "id", call.ID, "panic", r, "stack", string(debug.Stack()))
+	}
+	return aisdk.NewErrorToolResponse(fmt.Sprintf("tool %s panicked: %v", call.Function.Name, r))
+}
+
+// TimeoutMiddleware fails calls that run longer than the timeout. The context
+// passed to the tool is cancelled at the deadline; tools that ignore it keep
+// running in the background, but their result is discarded.
+func TimeoutMiddleware(timeout time.Duration, logger *slog.Logger) ToolMiddleware {
+	return func(next ToolExecutor) ToolExecutor {
+		return func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			type outcome struct {
+				result *aisdk.ToolResponse
+				err    error
+			}
+			done := make(chan outcome, 1)
+			go func() {
+				// Panics can't reach the caller's recovery from this goroutine
+				defer func() {
+					if r := recover(); r != nil {
+						done <- outcome{result: panicResponse(logger, call, r)}
+					}
+				}()
+				result, err := next(ctx, call)
+				done <- outcome{result: result, err: err}
+			}()
+
+			select {
+			case o := <-done:
+				return o.result, o.err
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					return aisdk.NewErrorToolResponse(fmt.Sprintf("tool %s timed out after %s", call.Function.Name, timeout)), nil
+				}
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// TruncateOutputMiddleware shortens text output longer than maxBytes, keeping
+// its beginning and end
+func TruncateOutputMiddleware(maxBytes int) ToolMiddleware {
+	return func(next ToolExecutor) ToolExecutor {
+		return func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+			result, err := next(ctx, call)
+			if err != nil || result == nil || len(result.Content) <= maxBytes {
+				return result, err
+			}
+			truncated := *result
+			truncated.Content = []byte(truncateMiddle(string(result.Content), maxBytes, "by the tool output limit"))
+			return &truncated, nil
+		}
+	}
+}
+
+// ArgumentLoggingMiddleware logs the arguments, duration and outcome of each call
+func ArgumentLoggingMiddleware(logger *slog.Logger) ToolMiddleware {
+	return func(next ToolExecutor) ToolExecutor {
+		return func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+			start := time.Now()
+			result, err := next(ctx, call)
+			attrs := []any{
+				"tool", call.Function.Name,
+				"id", call.ID,
+				"arguments", string(call.Function.Arguments),
+				"duration", time.Since(start),
+			}
+			switch {
+			case err != nil:
+				logger.Warn("tool call failed", append(attrs, "error", err)...)
+			case result != nil && result.IsError:
+				logger.Info("tool call returned an error", append(attrs, "output", string(result.Content))...)
+			default:
+				size := 0
+				if result != nil {
+					size = len(result.Content)
+				}
+				logger.Info("tool call completed", append(attrs, "output_bytes", size)...)
+			}
+			return result, err
+		}
+	}
+}
+
+// RetryMiddleware retries failed calls up to retries times, waiting delay
+// between attempts. Calls fail when they return an error or an error
+// response. Only use it for idempotent tools.
+func RetryMiddleware(retries int, delay time.Duration, logger *slog.Logger) ToolMiddleware {
+	return func(next ToolExecutor) ToolExecutor {
+		return func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+			result, err := next(ctx, call)
+			for attempt := 1; attempt <= retries && (err != nil || (result != nil && result.IsError)); attempt++ {
+				if logger != nil {
+					logger.Debug("retrying tool call", "tool", call.Function.Name, "id", call.ID, "attempt", attempt)
+				}
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return result, err
+				}
+				result, err = next(ctx, call)
+			}
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// middlewareToolbox returns a toolbox with a tool running fn and a plain echo tool
+func middlewareToolbox(t *testing.T, fn aisdk.ToolExecutor, middleware ...ToolMiddleware) *DefaultToolbox {
+	t.Helper()
+	toolbox := NewToolbox[Tool]()
+	require.NoError(t, toolbox.RegisterTool(&LegacyTool{Type: "function", Function: aisdk.ToolFunction{Name: "flaky"}, Executor: fn}))
+	require.NoError(t, toolbox.RegisterTool(&LegacyTool{Type: "function", Function: aisdk.ToolFunction{Name: "echo"}, Executor: func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+		return &aisdk.ToolResponse{Type: "success", Content: call.Function.Arguments}, nil
+	}}))
+	for _, m := range middleware {
+		toolbox.RegisterMiddleware(m)
+	}
+	return toolbox
+}
+
+func execute(t *testing.T, toolbox *DefaultToolbox, name, args string) (*aisdk.ToolResponse, error) {
+	t.Helper()
+	return toolbox.ExecuteTool(context.Background(), &aisdk.ToolCall{ID: "call_0", Type: "function", Function: aisdk.FunctionCall{Name: name, Arguments: []byte(args)}})
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	toolbox := middlewareToolbox(t, func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+		panic("boom")
+	}, RecoveryMiddleware(nil))
+
+	result, err := execute(t, toolbox, "flaky", `{}`)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Contains(t, string(result.Content), "tool flaky panicked: boom")
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	toolbox := middlewareToolbox(t, func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+		// Ignores the context, like a tool stuck in a syscall
+		time.Sleep(time.Second)
+		return &aisdk.ToolResponse{Type: "success", Content: []byte("late")}, nil
+	}, TimeoutMiddleware(20*time.Millisecond, nil))
+
+	start := time.Now()
+	result, err := execute(t, toolbox, "flaky", `{}`)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.True(t, result.IsError)
+	assert.Contains(t, string(result.Content), "timed out after 20ms")
+
+	result, err = execute(t, toolbox, "echo", `{"fast": true}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"fast": true}`, string(result.Content))
+}
+
+func TestTimeoutMiddlewareRecoversPanics(t *testing.T) {
+	toolbox := middlewareToolbox(t, func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+		panic("boom")
+	}, TimeoutMiddleware(time.Second, nil))
+
+	result, err := execute(t, toolbox, "flaky", `{}`)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+}
+
+func TestTruncateOutputMiddleware(t *testing.T) {
+	toolbox := middlewareToolbox(t, nil, TruncateOutputMiddleware(100))
+
+	long := `"` + strings.Repeat("a", 300) + strings.Repeat("z", 300) + `"`
+	result, err := execute(t, toolbox, "echo", long)
+	require.NoError(t, err)
+	content := string(result.Content)
+	assert.Less(t, len(content), 200)
+	assert.True(t, strings.HasPrefix(content, `"aaa`))
+	assert.True(t, strings.HasSuffix(content, `zzz"`))
+	assert.Contains(t, content, "bytes omitted by the tool output limit")
+
+	result, err = execute(t, toolbox, "echo", `"short"`)
+	require.NoError(t, err)
+	assert.Equal(t, `"short"`, string(result.Content))
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	attempts := 0
+	recoverAfter := 3
+	toolbox := middlewareToolbox(t, func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+		attempts++
+		switch {
+		case attempts >= recoverAfter:
+			return &aisdk.ToolResponse{Type: "success", Content: []byte("ok")}, nil
+		case attempts == 1:
+			return nil, errors.New("connection reset")
+		}
+		return aisdk.NewErrorToolResponse("503 service unavailable"), nil
+	}, RetryMiddleware(2, time.Millisecond, nil))
+
+	result, err := execute(t, toolbox, "flaky", `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(result.Content))
+	assert.Equal(t, 3, attempts)
+
+	attempts, recoverAfter = 0, 10
+	result, err = execute(t, toolbox, "flaky", `{}`)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Equal(t, 3, attempts, "one call and two retries")
+}
+
+func TestForTool(t *testing.T) {
+	toolbox := middlewareToolbox(t, func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+		return &aisdk.ToolResponse{Type: "success", Content: []byte("0123456789")}, nil
+	}, ForTool("flaky", TruncateOutputMiddleware(2)))
+
+	result, err := execute(t, toolbox, "flaky", `{}`)
+	require.NoError(t, err)
+	assert.Contains(t, string(result.Content), "omitted")
+
+	result, err = execute(t, toolbox, "echo", `"0123456789"`)
+	require.NoError(t, err)
+	assert.Equal(t, `"0123456789"`, string(result.Content))
+}
+
+func TestToolboxSubsetKeepsMiddleware(t *testing.T) {
+	calls := 0
+	toolbox := middlewareToolbox(t, nil, func(next ToolExecutor) ToolExecutor {
+		return func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+			calls++
+			return next(ctx, call)
+		}
+	})
+
+	subset := toolbox.Subset(func(tool Tool) bool { return tool.GetName() == "echo" })
+	assert.False(t, subset.HasTool("flaky"))
+	assert.True(t, toolbox.HasTool("flaky"), "the parent toolbox is unchanged")
+
+	result, err := execute(t, subset, "echo", `{"a": 1}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a": 1}`, string(result.Content))
+	assert.Equal(t, 1, calls)
+
+	_, err = execute(t, subset, "flaky", `{}`)
+	assert.Error(t, err)
+}
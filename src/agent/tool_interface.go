@@ -23,4 +23,19 @@ type Tool interface {
 	
 	// Execute runs the tool with the given parameters
 	Execute(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error)
-}
\ No newline at end of file
+}
+
+// ParallelTool is implemented by tools that declare whether they can run
+// concurrently with other tool calls. Tools that don't implement it are
+// assumed to have side effects and run alone.
+type ParallelTool interface {
+	// ParallelSafe reports whether the tool can run concurrently with other
+	// parallel-safe tools
+	ParallelSafe() bool
+}
+
+// IsParallelSafe reports whether the tool declared itself safe to run concurrently
+func IsParallelSafe(tool Tool) bool {
+	p, ok := tool.(ParallelTool)
+	return ok && p.ParallelSafe()
+}
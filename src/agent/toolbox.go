@@ -3,6 +3,7 @@ package agent
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/elee1766/gofer/src/aisdk"
 )
@@ -66,11 +67,18 @@ func (tm *Toolbox[T]) ToolMap() map[string]T {
 	return tm.tools
 }
 
-// Tools returns the list of available tools
+// Tools returns the list of available tools, sorted by name so requests are
+// identical between runs
 func (tm *Toolbox[T]) Tools() []T {
+	names := make([]string, 0, len(tm.tools))
+	for name := range tm.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
 	out := make([]T, 0, len(tm.tools))
-	for _, tool := range tm.tools {
-		out = append(out, tool)
+	for _, name := range names {
+		out = append(out, tm.tools[name])
 	}
 	return out
 }
@@ -96,6 +104,19 @@ func (tm *Toolbox[T]) ExecuteTool(ctx context.Context, call *aisdk.ToolCall) (*a
 	return finalExecutor(ctx, call)
 }
 
+// Subset returns a toolbox with the tools keep returns true for. The subset
+// shares the middleware registered so far.
+func (tm *Toolbox[T]) Subset(keep func(tool T) bool) *Toolbox[T] {
+	subset := NewToolbox[T]()
+	for name, tool := range tm.tools {
+		if keep(tool) {
+			subset.tools[name] = tool
+		}
+	}
+	subset.middleware = append(subset.middleware, tm.middleware...)
+	return subset
+}
+
 // GetTool returns a specific tool by name.
 func (tm *Toolbox[T]) GetTool(name string) (T, bool) {
 	tool, exists := tm.tools[name]
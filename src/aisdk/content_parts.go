@@ -0,0 +1,225 @@
+package aisdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ContentPart is an element of an OpenAI style content array
+type ContentPart struct {
+	Type     string    `json:"type"` // "text" or "image_url"
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL references an image, either by URL or as a base64 data URI
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// MediaType returns the MIME type of the image, e.g. "image/png"
+func (ic ImageContent) MediaType() string {
+	format := strings.ToLower(strings.TrimPrefix(ic.Format, "."))
+	switch format {
+	case "":
+		return "image/png"
+	case "jpg":
+		return "image/jpeg"
+	case "svg":
+		return "image/svg+xml"
+	}
+	if strings.Contains(format, "/") {
+		return format
+	}
+	return "image/" + format
+}
+
+// DataURI returns the image encoded as a data URI
+func (ic ImageContent) DataURI() string {
+	return fmt.Sprintf("data:%s;base64,%s", ic.MediaType(), ic.Data)
+}
+
+// UnmarshalJSON decodes the item data into its concrete type, so content
+// survives a round trip through storage or recorded sessions.
+func (ci *ContentItem) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type ContentType     `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	ci.Type = raw.Type
+	var target interface{}
+	switch raw.Type {
+	case ContentTypeText:
+		target = &TextContent{}
+	case ContentTypeImage:
+		target = &ImageContent{}
+	case ContentTypeFile:
+		target = &FileContent{}
+	case ContentTypeJSON:
+		target = &JSONContent{}
+	default:
+		target = new(interface{})
+	}
+	if len(raw.Data) > 0 {
+		if err := json.Unmarshal(raw.Data, target); err != nil {
+			return fmt.Errorf("failed to decode %s content: %w", raw.Type, err)
+		}
+	}
+
+	switch v := target.(type) {
+	case *TextContent:
+		ci.Data = *v
+	case *ImageContent:
+		ci.Data = *v
+	case *FileContent:
+		ci.Data = *v
+	case *JSONContent:
+		ci.Data = *v
+	case *interface{}:
+		ci.Data = *v
+	}
+	return nil
+}
+
+// itemText renders a non-image item as text
+func itemText(item ContentItem) string {
+	switch data := item.Data.(type) {
+	case TextContent:
+		return data.Text
+	case JSONContent:
+		return string(data.Data)
+	case FileContent:
+		return fmt.Sprintf("[File: %s, Type: %s, Size: %d bytes]", data.Filename, data.MimeType, data.Size)
+	}
+	return ""
+}
+
+// ContentParts converts the content into OpenAI style content parts. Images
+// become image_url parts with data URIs; all other items become text parts.
+func (mc *MultimodalContent) ContentParts() []ContentPart {
+	parts := make([]ContentPart, 0, len(mc.Items))
+	for _, item := range mc.Items {
+		if img, ok := item.Data.(ImageContent); ok {
+			parts = append(parts, ContentPart{
+				Type:     "image_url",
+				ImageURL: &ImageURL{URL: img.DataURI()},
+			})
+			continue
+		}
+		if text := itemText(item); text != "" {
+			parts = append(parts, ContentPart{Type: "text", Text: text})
+		}
+	}
+	return parts
+}
+
+// WithoutImages returns a copy of the content with each image replaced by a
+// text note, for models that do not accept image input.
+func (mc *MultimodalContent) WithoutImages() *MultimodalContent {
+	out := &MultimodalContent{Items: make([]ContentItem, 0, len(mc.Items))}
+	for _, item := range mc.Items {
+		if img, ok := item.Data.(ImageContent); ok {
+			out.AddText(fmt.Sprintf("[Image %s (%s, %d bytes) omitted: the model does not support image input]",
+				img.Filename, img.MediaType(), img.Size))
+			continue
+		}
+		out.Items = append(out.Items, item)
+	}
+	return out
+}
+
+// HasImages reports whether the message carries image content
+func (m *Message) HasImages() bool {
+	return m.MultimodalContent != nil && m.MultimodalContent.HasImages()
+}
+
+// OpenAIContent returns the message content in the OpenAI wire format: an
+// array of content parts when the message carries images, otherwise a string.
+func (m *Message) OpenAIContent() interface{} {
+	if m.HasImages() {
+		return m.MultimodalContent.ContentParts()
+	}
+	return m.GetContent()
+}
+
+// PrepareMessagesForModel adapts messages to what the model accepts. When the
+// model does not support image input, images are replaced by text notes. The
+// input messages are not modified.
+func PrepareMessagesForModel(messages []*Message, model *ModelInfo) []*Message {
+	if model.SupportsInputModality("image") {
+		return messages
+	}
+
+	out := make([]*Message, len(messages))
+	for i, msg := range messages {
+		if msg == nil || !msg.HasImages() {
+			out[i] = msg
+			continue
+		}
+		stripped := *msg
+		stripped.SetMultimodalContent(msg.MultimodalContent.WithoutImages())
+		out[i] = &stripped
+	}
+	return out
+}
+
+// HoistToolImages moves images out of tool messages for APIs that only accept
+// text in tool results, such as OpenAI chat completions. The tool message keeps
+// its text, and the images are sent in a user message placed after the run of
+// tool messages, since tool results must directly follow the assistant turn.
+func HoistToolImages(messages []*Message) []*Message {
+	var out []*Message
+	var pending *MultimodalContent
+
+	flush := func() {
+		if pending != nil {
+			user := &Message{Role: "user"}
+			user.SetMultimodalContent(pending)
+			out = append(out, user)
+			pending = nil
+		}
+	}
+
+	for _, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		if msg.Role != "tool" {
+			flush()
+			out = append(out, msg)
+			continue
+		}
+		if !msg.HasImages() {
+			out = append(out, msg)
+			continue
+		}
+
+		if pending == nil {
+			pending = &MultimodalContent{}
+		}
+		pending.AddText(fmt.Sprintf("Images returned by the %s tool call %s:", msg.Name, msg.ToolCallID))
+
+		text := &MultimodalContent{}
+		for _, item := range msg.MultimodalContent.Items {
+			if item.Type == ContentTypeImage {
+				pending.Items = append(pending.Items, item)
+			} else {
+				text.Items = append(text.Items, item)
+			}
+		}
+		text.AddText("[Images attached in the following user message]")
+
+		toolMsg := *msg
+		toolMsg.SetMultimodalContent(text)
+		out = append(out, &toolMsg)
+	}
+	flush()
+
+	return out
+}
@@ -0,0 +1,60 @@
+package aisdk
+
+import (
+	"strconv"
+)
+
+// EstimateCost computes the cost of a model call from the model's per-token
+// prices. Cached prompt tokens use the cache read and write prices when the
+// model has them. It returns false when the model has no usable pricing.
+func EstimateCost(pricing *Pricing, usage Usage) (CostEstimate, bool) {
+	if pricing == nil {
+		return CostEstimate{}, false
+	}
+	promptPrice, ok := parsePrice(pricing.Prompt)
+	if !ok {
+		return CostEstimate{}, false
+	}
+	completionPrice, ok := parsePrice(pricing.Completion)
+	if !ok {
+		return CostEstimate{}, false
+	}
+	cacheReadPrice, ok := parsePrice(pricing.InputCacheRead)
+	if !ok {
+		cacheReadPrice = promptPrice
+	}
+	cacheWritePrice, ok := parsePrice(pricing.InputCacheWrite)
+	if !ok {
+		cacheWritePrice = promptPrice
+	}
+	requestPrice, _ := parsePrice(pricing.Request)
+
+	uncached := usage.PromptTokens - usage.PromptTokensCached - usage.PromptTokensCacheWrite
+	if uncached < 0 {
+		uncached = 0
+	}
+	prompt := float64(uncached)*promptPrice +
+		float64(usage.PromptTokensCached)*cacheReadPrice +
+		float64(usage.PromptTokensCacheWrite)*cacheWritePrice +
+		requestPrice
+	completion := float64(usage.CompletionTokens) * completionPrice
+
+	return CostEstimate{
+		PromptCost:     prompt,
+		CompletionCost: completion,
+		TotalCost:      prompt + completion,
+		Currency:       "USD",
+	}, true
+}
+
+// parsePrice parses a per-unit price as reported by OpenRouter
+func parsePrice(s string) (float64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	price, err := strconv.ParseFloat(s, 64)
+	if err != nil || price < 0 {
+		return 0, false
+	}
+	return price, true
+}
@@ -0,0 +1,32 @@
+package aisdk_test
+
+import (
+	"testing"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateCost(t *testing.T) {
+	pricing := &aisdk.Pricing{
+		Prompt:         "0.000003",
+		Completion:     "0.000015",
+		InputCacheRead: "0.0000003",
+	}
+	cost, ok := aisdk.EstimateCost(pricing, aisdk.Usage{
+		PromptTokens:       1000,
+		PromptTokensCached: 800,
+		CompletionTokens:   100,
+	})
+	assert.True(t, ok)
+	// 200 uncached and 800 cached prompt tokens
+	assert.InDelta(t, 200*0.000003+800*0.0000003, cost.PromptCost, 1e-12)
+	assert.InDelta(t, 100*0.000015, cost.CompletionCost, 1e-12)
+	assert.InDelta(t, cost.PromptCost+cost.CompletionCost, cost.TotalCost, 1e-12)
+	assert.Equal(t, "USD", cost.Currency)
+
+	_, ok = aisdk.EstimateCost(nil, aisdk.Usage{PromptTokens: 10})
+	assert.False(t, ok)
+	_, ok = aisdk.EstimateCost(&aisdk.Pricing{Prompt: "n/a", Completion: "0"}, aisdk.Usage{PromptTokens: 10})
+	assert.False(t, ok)
+}
@@ -15,3 +15,25 @@ type ModelClient interface {
 	CreateChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error)
 	GetModelInfo() *ModelInfo
 }
+
+// StreamingModelClient is a ModelClient that can stream responses as they are generated.
+// The handler receives every chunk; the accumulated response is returned once the stream ends.
+type StreamingModelClient interface {
+	ModelClient
+	CreateChatCompletionStream(ctx context.Context, req *ChatCompletionRequest, handler StreamHandler) (*ChatCompletionResponse, error)
+}
+
+// NamedModelClient is a ModelClient that knows the name of the provider serving it
+type NamedModelClient interface {
+	ModelClient
+	ProviderName() string
+}
+
+// ProviderName returns the name of the provider of a model client, or "" if
+// the client does not know it
+func ProviderName(client ModelClient) string {
+	if named, ok := client.(NamedModelClient); ok {
+		return named.ProviderName()
+	}
+	return ""
+}
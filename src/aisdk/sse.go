@@ -0,0 +1,39 @@
+package aisdk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadSSE reads server-sent events from r and calls onData with the payload of
+// every data line until the [DONE] sentinel or the end of the body.
+// Comment lines (such as OpenRouter's ": OPENROUTER PROCESSING" keep-alives) and
+// event name lines are skipped; providers repeat the event type in the payload.
+func ReadSSE(r io.Reader, onData func(data []byte) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read stream: %w", err)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				return nil
+			}
+			if data != "" {
+				if cbErr := onData([]byte(data)); cbErr != nil {
+					return cbErr
+				}
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
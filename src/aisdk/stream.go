@@ -0,0 +1,160 @@
+package aisdk
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// StreamOptions controls what the server includes in a streamed response.
+type StreamOptions struct {
+	// IncludeUsage requests a final chunk carrying token usage
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// StreamChunk is a single incremental update from a streaming chat completion.
+type StreamChunk struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []StreamChoice `json:"choices"`
+	// Usage is only present on the final chunk
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// StreamChoice is the per-choice part of a stream chunk.
+type StreamChoice struct {
+	Index        int         `json:"index"`
+	Delta        StreamDelta `json:"delta"`
+	FinishReason string      `json:"finish_reason,omitempty"`
+}
+
+// StreamDelta contains the content added by a stream chunk.
+type StreamDelta struct {
+	Role      string          `json:"role,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// ToolCallDelta is a fragment of a tool call. The first fragment for an index
+// carries the ID and function name, later fragments only carry more arguments.
+type ToolCallDelta struct {
+	Index    int               `json:"index"`
+	ID       string            `json:"id,omitempty"`
+	Type     string            `json:"type,omitempty"`
+	Function FunctionCallDelta `json:"function"`
+}
+
+// FunctionCallDelta is a fragment of a function call.
+type FunctionCallDelta struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// StreamHandler is called for every chunk received from a streaming completion.
+// Returning an error aborts the stream.
+type StreamHandler func(chunk *StreamChunk) error
+
+// StreamAccumulator assembles stream chunks into a complete response.
+type StreamAccumulator struct {
+	response     ChatCompletionResponse
+	content      strings.Builder
+	role         string
+	finishReason string
+	toolCalls    map[int]*ToolCall
+	toolArgs     map[int]*strings.Builder
+}
+
+// NewStreamAccumulator creates an empty stream accumulator
+func NewStreamAccumulator() *StreamAccumulator {
+	return &StreamAccumulator{
+		toolCalls: make(map[int]*ToolCall),
+		toolArgs:  make(map[int]*strings.Builder),
+	}
+}
+
+// Add merges a chunk into the accumulated response. Only the first choice is kept.
+func (a *StreamAccumulator) Add(chunk *StreamChunk) {
+	if chunk.ID != "" {
+		a.response.ID = chunk.ID
+	}
+	if chunk.Model != "" {
+		a.response.Model = chunk.Model
+	}
+	if chunk.Created != 0 {
+		a.response.Created = chunk.Created
+	}
+	if chunk.Usage != nil {
+		a.response.Usage = *chunk.Usage
+	}
+
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		if choice.Delta.Role != "" {
+			a.role = choice.Delta.Role
+		}
+		a.content.WriteString(choice.Delta.Content)
+		if choice.FinishReason != "" {
+			a.finishReason = choice.FinishReason
+		}
+
+		for _, delta := range choice.Delta.ToolCalls {
+			tc, ok := a.toolCalls[delta.Index]
+			if !ok {
+				tc = &ToolCall{Type: "function"}
+				a.toolCalls[delta.Index] = tc
+				a.toolArgs[delta.Index] = &strings.Builder{}
+			}
+			if delta.ID != "" {
+				tc.ID = delta.ID
+			}
+			if delta.Type != "" {
+				tc.Type = delta.Type
+			}
+			if delta.Function.Name != "" {
+				tc.Function.Name += delta.Function.Name
+			}
+			a.toolArgs[delta.Index].WriteString(delta.Function.Arguments)
+		}
+	}
+}
+
+// Response returns the complete response built from all chunks seen so far.
+func (a *StreamAccumulator) Response() *ChatCompletionResponse {
+	resp := a.response
+	resp.Object = "chat.completion"
+
+	role := a.role
+	if role == "" {
+		role = "assistant"
+	}
+	msg := Message{
+		Role:    role,
+		Content: a.content.String(),
+	}
+
+	indexes := make([]int, 0, len(a.toolCalls))
+	for i := range a.toolCalls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		tc := *a.toolCalls[i]
+		args := a.toolArgs[i].String()
+		if strings.TrimSpace(args) == "" {
+			args = "{}"
+		}
+		tc.Function.Arguments = json.RawMessage(args)
+		msg.ToolCalls = append(msg.ToolCalls, tc)
+	}
+
+	resp.Choices = []Choice{{
+		Index:        0,
+		Message:      msg,
+		FinishReason: a.finishReason,
+	}}
+	return &resp
+}
@@ -0,0 +1,168 @@
+package aisdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/elee1766/gofer/src/jsonvalidate"
+	jsonschema "github.com/swaggest/jsonschema-go"
+)
+
+// DefaultStructuredRepairs is the number of repair attempts made when a
+// structured response does not match its schema
+const DefaultStructuredRepairs = 2
+
+// StructuredOutputError is returned when a model does not produce a response
+// that matches the schema, even after the repair attempts
+type StructuredOutputError struct {
+	Content  string // Content of the last response
+	Attempts int    // Number of responses that were validated
+	Err      error  // Validation error of the last response
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("structured output does not match the schema after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *StructuredOutputError) Unwrap() error {
+	return e.Err
+}
+
+// NewJSONSchemaResponseFormat returns a "json_schema" response format for a
+// raw JSON Schema document
+func NewJSONSchemaResponseFormat(name string, schema json.RawMessage) *ResponseFormat {
+	return &ResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &JSONSchemaFormat{
+			Name:   name,
+			Schema: schema,
+		},
+	}
+}
+
+// JSONSchemaFormatFor returns a "json_schema" response format with the
+// schema reflected from T. Definitions are inlined, since not every provider
+// resolves references in response schemas.
+func JSONSchemaFormatFor[T any](name string) (*ResponseFormat, error) {
+	var value T
+	reflector := jsonschema.Reflector{}
+	schema, err := reflector.Reflect(value, jsonschema.InlineRefs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reflect schema for %s: %w", name, err)
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema for %s: %w", name, err)
+	}
+	return NewJSONSchemaResponseFormat(name, data), nil
+}
+
+// ParseStructuredContent extracts the JSON value from a response and
+// validates it against the schema of the format. Markdown code fences around
+// the value are tolerated.
+func ParseStructuredContent(content string, format *ResponseFormat) (json.RawMessage, error) {
+	data := []byte(stripCodeFence(content))
+	if !json.Valid(data) {
+		return nil, errors.New("response is not valid JSON")
+	}
+	if format == nil || format.JSONSchema == nil || len(format.JSONSchema.Schema) == 0 {
+		return data, nil
+	}
+
+	schema, err := jsonvalidate.Compile(format.JSONSchema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", format.JSONSchema.Name, err)
+	}
+	if err := schema.ValidateJSON(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// StructuredRepairMessage returns the user message that asks the model to fix
+// a response that failed validation
+func StructuredRepairMessage(err error) *Message {
+	var b strings.Builder
+	b.WriteString("Your response does not match the required JSON schema:\n")
+	var verr *jsonvalidate.ValidationError
+	if errors.As(err, &verr) {
+		for _, fe := range verr.Errors {
+			fmt.Fprintf(&b, "- %s\n", fe)
+		}
+	} else {
+		fmt.Fprintf(&b, "- %v\n", err)
+	}
+	b.WriteString("Respond again with only a JSON value that matches the schema.")
+	return &Message{Role: "user", Content: b.String()}
+}
+
+// CreateStructuredCompletion sends the request and validates the response
+// against req.ResponseFormat. Invalid responses are sent back to the model
+// with the validation errors, up to maxRepairs times.
+func CreateStructuredCompletion(ctx context.Context, model ModelClient, req *ChatCompletionRequest, maxRepairs int) (json.RawMessage, *ChatCompletionResponse, error) {
+	if req.ResponseFormat == nil || req.ResponseFormat.JSONSchema == nil {
+		return nil, nil, errors.New("request has no json_schema response format")
+	}
+
+	attempt := *req
+	attempt.Messages = append([]*Message(nil), req.Messages...)
+	for i := 0; ; i++ {
+		resp, err := model.CreateChatCompletion(ctx, &attempt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(resp.Choices) == 0 {
+			return nil, resp, errors.New("no choices in response")
+		}
+
+		reply := resp.Choices[0].Message
+		data, err := ParseStructuredContent(reply.Content, attempt.ResponseFormat)
+		if err == nil {
+			return data, resp, nil
+		}
+		if i >= maxRepairs {
+			return nil, resp, &StructuredOutputError{Content: reply.Content, Attempts: i + 1, Err: err}
+		}
+		attempt.Messages = append(attempt.Messages, &reply, StructuredRepairMessage(err))
+	}
+}
+
+// GenerateStructured asks the model for a value of type T, using a response
+// format reflected from T and the repair loop of CreateStructuredCompletion
+func GenerateStructured[T any](ctx context.Context, model ModelClient, messages []*Message, maxRepairs int) (*T, error) {
+	format, err := JSONSchemaFormatFor[T]("response")
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := CreateStructuredCompletion(ctx, model, &ChatCompletionRequest{
+		Model:          model.GetModelInfo().ID,
+		Messages:       messages,
+		ResponseFormat: format,
+	}, maxRepairs)
+	if err != nil {
+		return nil, err
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode structured output: %w", err)
+	}
+	return &value, nil
+}
+
+// stripCodeFence removes a markdown code fence around the content
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") || !strings.HasSuffix(content, "```") || len(content) < 6 {
+		return content
+	}
+	body := strings.TrimSuffix(content[3:], "```")
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+		body = body[nl+1:] // drop the language tag
+	}
+	return strings.TrimSpace(body)
+}
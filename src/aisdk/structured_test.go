@@ -0,0 +1,86 @@
+package aisdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/fakeprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type triageReport struct {
+	Summary  string   `json:"summary" required:"true"`
+	Severity string   `json:"severity" required:"true" enum:"low,medium,high"`
+	Files    []string `json:"files,omitempty"`
+}
+
+func TestGenerateStructuredRepairs(t *testing.T) {
+	provider := fakeprovider.New(&fakeprovider.Script{Turns: []fakeprovider.Turn{
+		{
+			Text: `{"summary": "nil pointer in parser", "severity": "critical"}`,
+			Check: func(req *aisdk.ChatCompletionRequest) error {
+				if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_schema" {
+					return errors.New("expected a json_schema response format")
+				}
+				return nil
+			},
+		},
+		fakeprovider.RespondText("```json\n{\"summary\": \"nil pointer in parser\", \"severity\": \"high\"}\n```").
+			Expecting(fakeprovider.Expectation{
+				MessageCount: 3,
+				Contains:     []string{`/severity: must be one of ["low","medium","high"]`},
+			}),
+	}})
+	model, err := provider.Model(context.Background(), "fake-model")
+	require.NoError(t, err)
+
+	report, err := aisdk.GenerateStructured[triageReport](context.Background(), model, []*aisdk.Message{
+		{Role: "user", Content: "triage the crash"},
+	}, aisdk.DefaultStructuredRepairs)
+	require.NoError(t, err)
+	assert.Equal(t, &triageReport{Summary: "nil pointer in parser", Severity: "high"}, report)
+	assert.NoError(t, provider.Done())
+}
+
+func TestCreateStructuredCompletionGivesUp(t *testing.T) {
+	provider := fakeprovider.New(&fakeprovider.Script{Turns: []fakeprovider.Turn{
+		fakeprovider.RespondText("not json"),
+		fakeprovider.RespondText(`{"severity": "low"}`),
+	}})
+	model, err := provider.Model(context.Background(), "fake-model")
+	require.NoError(t, err)
+	format, err := aisdk.JSONSchemaFormatFor[triageReport]("triage")
+	require.NoError(t, err)
+
+	_, _, err = aisdk.CreateStructuredCompletion(context.Background(), model, &aisdk.ChatCompletionRequest{
+		Messages:       []*aisdk.Message{{Role: "user", Content: "triage the crash"}},
+		ResponseFormat: format,
+	}, 1)
+	var structErr *aisdk.StructuredOutputError
+	require.True(t, errors.As(err, &structErr), "got %v", err)
+	assert.Equal(t, 2, structErr.Attempts)
+	assert.Contains(t, err.Error(), `missing required property "summary"`)
+
+	requests := provider.Requests()
+	require.Len(t, requests, 2)
+	repair := requests[1].Messages[2].Content
+	assert.True(t, strings.HasPrefix(repair, "Your response does not match the required JSON schema"), repair)
+	assert.Contains(t, repair, "not valid JSON")
+}
+
+func TestJSONSchemaFormatFor(t *testing.T) {
+	format, err := aisdk.JSONSchemaFormatFor[triageReport]("triage")
+	require.NoError(t, err)
+	require.NotNil(t, format.JSONSchema)
+	assert.Equal(t, "json_schema", format.Type)
+	assert.Equal(t, "triage", format.JSONSchema.Name)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(format.JSONSchema.Schema, &schema))
+	assert.ElementsMatch(t, []interface{}{"summary", "severity"}, schema["required"])
+}
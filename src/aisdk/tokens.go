@@ -0,0 +1,122 @@
+package aisdk
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+)
+
+const (
+	// messageOverheadTokens approximates the role markers and separators
+	// each message adds to the prompt
+	messageOverheadTokens = 4
+
+	// imageTokens approximates the cost of an image input. Providers charge
+	// by resolution; this matches a typical screenshot.
+	imageTokens = 1600
+
+	// defaultCharsPerToken is used for unknown tokenizers. It is on the low
+	// side so that estimates err towards too many tokens.
+	defaultCharsPerToken = 3.5
+)
+
+// charsPerToken maps OpenRouter tokenizer families to the average number of
+// bytes of English text and code per token
+var charsPerToken = map[string]float64{
+	"gpt":      4.0,
+	"claude":   3.5,
+	"gemini":   4.0,
+	"llama2":   3.5,
+	"llama3":   4.0,
+	"llama4":   4.0,
+	"mistral":  3.5,
+	"qwen":     3.8,
+	"qwen3":    3.8,
+	"deepseek": 3.8,
+	"cohere":   4.0,
+	"grok":     4.0,
+}
+
+// TokenEstimator estimates token counts without a tokenizer. The estimate is
+// a heuristic based on the tokenizer family and is only meant for budgeting.
+type TokenEstimator struct {
+	CharsPerToken float64
+}
+
+// EstimatorFor returns the estimator for the model's tokenizer family, as
+// reported in Architecture.Tokenizer
+func EstimatorFor(model *ModelInfo) TokenEstimator {
+	if model != nil && model.Architecture != nil {
+		if cpt, ok := charsPerToken[strings.ToLower(model.Architecture.Tokenizer)]; ok {
+			return TokenEstimator{CharsPerToken: cpt}
+		}
+	}
+	return TokenEstimator{CharsPerToken: defaultCharsPerToken}
+}
+
+// Text estimates the tokens of a string
+func (e TokenEstimator) Text(s string) int {
+	if s == "" {
+		return 0
+	}
+	cpt := e.CharsPerToken
+	if cpt <= 0 {
+		cpt = defaultCharsPerToken
+	}
+	return int(math.Ceil(float64(len(s)) / cpt))
+}
+
+// Message estimates the tokens of a message, including its tool calls and images
+func (e TokenEstimator) Message(m *Message) int {
+	if m == nil {
+		return 0
+	}
+	tokens := messageOverheadTokens
+	if m.HasImages() {
+		for _, item := range m.MultimodalContent.Items {
+			if _, ok := item.Data.(ImageContent); ok {
+				tokens += imageTokens
+				continue
+			}
+			tokens += e.Text(itemText(item))
+		}
+	} else {
+		tokens += e.Text(m.GetContent())
+	}
+	for _, tc := range m.ToolCalls {
+		tokens += e.Text(tc.Function.Name) + e.Text(string(tc.Function.Arguments)) + messageOverheadTokens
+	}
+	return tokens
+}
+
+// Tools estimates the tokens of the tool definitions sent with a request
+func (e TokenEstimator) Tools(tools []*ChatTool) int {
+	tokens := 0
+	for _, t := range tools {
+		if t == nil {
+			continue
+		}
+		data, err := json.Marshal(t)
+		if err != nil {
+			continue
+		}
+		tokens += e.Text(string(data))
+	}
+	return tokens
+}
+
+// ContextLimit returns the number of tokens the model accepts, or 0 if it is
+// unknown. When the top provider has a smaller limit than the model, the
+// provider's limit applies.
+func ContextLimit(model *ModelInfo) int {
+	if model == nil {
+		return 0
+	}
+	limit := model.ContextLength
+	if model.TopProvider != nil && model.TopProvider.ContextLength > 0 {
+		if limit == 0 || model.TopProvider.ContextLength < limit {
+			limit = model.TopProvider.ContextLength
+		}
+	}
+	return limit
+}
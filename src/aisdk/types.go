@@ -19,6 +19,10 @@ type Message struct {
 	Name string `json:"name,omitempty"`
 	// ToolCallID is required for tool responses to reference the original call
 	ToolCallID string `json:"tool_call_id,omitempty"`
+	// IsError marks a tool response as the failure of its call
+	IsError bool `json:"is_error,omitempty"`
+	// ID is the ID of the saved message, if it was saved. It is not sent.
+	ID string `json:"-"`
 	// CacheControl is used for prompt caching with Anthropic models.
 	CacheControl *CacheControl `json:"cache_control,omitempty"`
 	// ToolCalls contains function calls requested by the assistant.
@@ -113,11 +117,22 @@ type ChatCompletionRequest struct {
 	ResponseFormat   *ResponseFormat        `json:"response_format,omitempty"`
 	User             string                 `json:"user,omitempty"`
 	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+	Stream           bool                   `json:"stream,omitempty"` // Set by streaming clients
+	StreamOptions    *StreamOptions         `json:"stream_options,omitempty"`
 }
 
 // ResponseFormat specifies the format of the response.
 type ResponseFormat struct {
-	Type string `json:"type"` // "text" or "json_object"
+	Type       string            `json:"type"`                  // "text", "json_object" or "json_schema"
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"` // Required when Type is "json_schema"
+}
+
+// JSONSchemaFormat describes the schema of a "json_schema" response format.
+type JSONSchemaFormat struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+	Strict      bool            `json:"strict,omitempty"`
 }
 
 // ChatCompletionResponse represents a response from the chat completions endpoint.
@@ -143,7 +158,8 @@ type Usage struct {
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
 	// Provider specific fields
-	PromptTokensCached int `json:"prompt_tokens_cached,omitempty"`
+	PromptTokensCached     int `json:"prompt_tokens_cached,omitempty"`
+	PromptTokensCacheWrite int `json:"prompt_tokens_cache_write,omitempty"`
 }
 
 
@@ -211,6 +227,34 @@ type ModelInfo struct {
 	Parameters          *Parameters `json:"parameters,omitempty"`
 }
 
+// SupportsInputModality reports whether the model accepts the given input
+// modality, such as "image". Models without modality information are assumed
+// to accept text only.
+func (m *ModelInfo) SupportsInputModality(modality string) bool {
+	if modality == "text" {
+		return true
+	}
+	if m == nil {
+		return false
+	}
+	if m.Architecture != nil {
+		for _, im := range m.Architecture.InputModalities {
+			if im == modality {
+				return true
+			}
+		}
+		if len(m.Architecture.InputModalities) > 0 {
+			return false
+		}
+	}
+	for _, im := range m.Modality {
+		if im == modality {
+			return true
+		}
+	}
+	return false
+}
+
 // Pricing contains model pricing information from OpenRouter
 type Pricing struct {
 	Prompt            string `json:"prompt"`                       // Cost per input token
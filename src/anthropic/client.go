@@ -0,0 +1,246 @@
+// Package anthropic implements aisdk.Provider on top of the Anthropic Messages API.
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/orclient"
+)
+
+const (
+	defaultBaseURL   = "https://api.anthropic.com"
+	defaultVersion   = "2023-06-01"
+	defaultTimeout   = 5 * time.Minute
+	defaultMaxTokens = 8192
+	modelListTTL     = time.Hour
+)
+
+var _ aisdk.Provider = (*Client)(nil)
+
+// Client is the Anthropic Messages API client.
+type Client struct {
+	config       Config
+	httpClient   *http.Client
+	// streamClient has no overall timeout so long streams aren't cut off
+	streamClient *http.Client
+	logger       *slog.Logger
+
+	mu              sync.Mutex
+	models          []*aisdk.ModelInfo
+	modelsFetchedAt time.Time
+}
+
+// NewClient creates a new Anthropic API client.
+func NewClient(config Config) *Client {
+	if config.BaseURL == "" {
+		config.BaseURL = defaultBaseURL
+	}
+	if config.Version == "" {
+		config.Version = defaultVersion
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.RetryCount == 0 {
+		config.RetryCount = 3
+	}
+	if config.RetryDelay == 0 {
+		config.RetryDelay = time.Second
+	}
+	if config.MaxTokens == 0 {
+		config.MaxTokens = defaultMaxTokens
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.With("component", "anthropic_client")
+
+	return &Client{
+		config:       config,
+		httpClient:   &http.Client{Timeout: config.Timeout},
+		streamClient: &http.Client{},
+		logger:       logger,
+	}
+}
+
+// GetModels implements aisdk.Provider.GetModels
+func (c *Client) GetModels(ctx context.Context) ([]*aisdk.ModelInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.models != nil && time.Since(c.modelsFetchedAt) < modelListTTL {
+		return c.models, nil
+	}
+
+	models, err := c.listModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.models = models
+	c.modelsFetchedAt = time.Now()
+	return models, nil
+}
+
+// Model creates a ModelClient bound to the specified model
+func (c *Client) Model(ctx context.Context, modelName string) (aisdk.ModelClient, error) {
+	models, err := c.GetModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model info for %s: %w", modelName, err)
+	}
+
+	for _, model := range models {
+		if model.ID == modelName {
+			return &ModelClient{client: c, model: model}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("model %s not found", modelName)
+}
+
+// modelListResponse is the response of GET /v1/models
+type modelListResponse struct {
+	Data []struct {
+		ID          string    `json:"id"`
+		DisplayName string    `json:"display_name"`
+		CreatedAt   time.Time `json:"created_at"`
+	} `json:"data"`
+	HasMore bool   `json:"has_more"`
+	LastID  string `json:"last_id"`
+}
+
+// listModels fetches every page of the model list
+func (c *Client) listModels(ctx context.Context) ([]*aisdk.ModelInfo, error) {
+	var models []*aisdk.ModelInfo
+
+	afterID := ""
+	for {
+		path := "/v1/models?limit=100"
+		if afterID != "" {
+			path += "&after_id=" + afterID
+		}
+
+		httpReq, err := c.newRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.doRequestWithRetry(c.httpClient, httpReq)
+		if err != nil {
+			return nil, err
+		}
+
+		var page modelListResponse
+		if resp.StatusCode != http.StatusOK {
+			err = c.handleError(resp)
+		} else if decodeErr := json.NewDecoder(resp.Body).Decode(&page); decodeErr != nil {
+			err = fmt.Errorf("failed to decode response: %w", decodeErr)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range page.Data {
+			models = append(models, newModelInfo(m.ID, m.DisplayName, m.CreatedAt))
+		}
+
+		if !page.HasMore || page.LastID == "" {
+			return models, nil
+		}
+		afterID = page.LastID
+	}
+}
+
+// newModelInfo builds model info for an Anthropic model. The models endpoint
+// doesn't report limits, so the documented defaults for current models are used.
+func newModelInfo(id, name string, created time.Time) *aisdk.ModelInfo {
+	return &aisdk.ModelInfo{
+		ID:            id,
+		CanonicalSlug: id,
+		Name:          name,
+		Created:       created.Unix(),
+		ContextLength: 200000,
+		Architecture: &aisdk.Architecture{
+			InputModalities:  []string{"text", "image"},
+			OutputModalities: []string{"text"},
+			Tokenizer:        "Claude",
+			Modality:         "text+image->text",
+		},
+		TopProvider: &aisdk.TopProvider{
+			ContextLength: 200000,
+		},
+		SupportedParameters: []string{"tools", "tool_choice", "max_tokens", "temperature", "top_p", "stop"},
+	}
+}
+
+// newRequest creates a new HTTP request with the appropriate headers.
+func (c *Client) newRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	url := c.config.BaseURL + path
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("x-api-key", c.config.APIKey)
+	req.Header.Set("anthropic-version", c.config.Version)
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
+
+// doRequestWithRetry performs an HTTP request with retry logic.
+func (c *Client) doRequestWithRetry(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	return orclient.DoWithRetry(httpClient, req, c.config.RetryCount, c.config.RetryDelay, c.logger)
+}
+
+// errorResponse is the Anthropic error format: {"type":"error","error":{"type":"...","message":"..."}}
+type errorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// handleError processes error responses from the API.
+func (c *Client) handleError(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read error response: %w", err)
+	}
+
+	apiErr := &orclient.APIError{
+		StatusCode: resp.StatusCode,
+		Message:    string(body),
+		RequestID:  resp.Header.Get("request-id"),
+	}
+
+	var errResp errorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		apiErr.Type = errResp.Error.Type
+		apiErr.Code = errResp.Error.Type
+		apiErr.Message = errResp.Error.Message
+	}
+
+	if retryAfter := resp.Header.Get("retry-after"); retryAfter != "" {
+		apiErr.Details = map[string]interface{}{"retry_after": retryAfter}
+	}
+
+	return apiErr
+}
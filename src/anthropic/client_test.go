@@ -0,0 +1,276 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/orclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const modelsPage = `{"data":[{"type":"model","id":"claude-sonnet-4-20250514","display_name":"Claude Sonnet 4","created_at":"2025-05-22T00:00:00Z"}],"has_more":false,"last_id":"claude-sonnet-4-20250514"}`
+
+// newTestServer serves the model list and hands /v1/messages to the given handler
+func newTestServer(t *testing.T, messages http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		fmt.Fprint(w, modelsPage)
+	})
+	mux.HandleFunc("/v1/messages", messages)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestModel(t *testing.T, server *httptest.Server) aisdk.ModelClient {
+	t.Helper()
+	client := NewClient(Config{APIKey: "test-key", BaseURL: server.URL, RetryCount: 1})
+	model, err := client.Model(context.Background(), "claude-sonnet-4-20250514")
+	require.NoError(t, err)
+	return model
+}
+
+func TestCreateChatCompletion(t *testing.T) {
+	var got map[string]interface{}
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, defaultVersion, r.Header.Get("anthropic-version"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		fmt.Fprint(w, `{
+			"id": "msg_1",
+			"type": "message",
+			"role": "assistant",
+			"model": "claude-sonnet-4-20250514",
+			"content": [
+				{"type": "text", "text": "Let me look."},
+				{"type": "tool_use", "id": "toolu_2", "name": "list_directory", "input": {"path": "."}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 10, "output_tokens": 20, "cache_creation_input_tokens": 100, "cache_read_input_tokens": 1000}
+		}`)
+	})
+	model := newTestModel(t, server)
+
+	resp, err := model.CreateChatCompletion(context.Background(), &aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{
+			{Role: "system", Content: "You are helpful.", CacheControl: &aisdk.CacheControl{Type: "ephemeral"}},
+			{Role: "user", Content: "read a.txt and b.txt"},
+			{Role: "assistant", ToolCalls: []aisdk.ToolCall{
+				{ID: "toolu_a", Type: "function", Function: aisdk.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"path":"a.txt"}`)}},
+				{ID: "toolu_b", Type: "function", Function: aisdk.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"path":"b.txt"}`)}},
+			}},
+			{Role: "tool", ToolCallID: "toolu_a", Name: "read_file", Content: "A"},
+			{Role: "tool", ToolCallID: "toolu_b", Name: "read_file", Content: "B"},
+		},
+		Tools: []*aisdk.ChatTool{{
+			Type:     "function",
+			Function: aisdk.ChatToolFunction{Name: "read_file", Description: "Read a file"},
+		}},
+	})
+	require.NoError(t, err)
+
+	// Request shape
+	assert.Equal(t, "claude-sonnet-4-20250514", got["model"])
+	assert.EqualValues(t, defaultMaxTokens, got["max_tokens"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"type": "text", "text": "You are helpful.", "cache_control": map[string]interface{}{"type": "ephemeral"}},
+	}, got["system"])
+
+	msgs := got["messages"].([]interface{})
+	require.Len(t, msgs, 3, "tool results should be merged into a single user turn")
+	assistant := msgs[1].(map[string]interface{})
+	assert.Equal(t, "assistant", assistant["role"])
+	assert.Equal(t, map[string]interface{}{
+		"type": "tool_use", "id": "toolu_a", "name": "read_file", "input": map[string]interface{}{"path": "a.txt"},
+	}, assistant["content"].([]interface{})[0])
+
+	results := msgs[2].(map[string]interface{})
+	assert.Equal(t, "user", results["role"])
+	require.Len(t, results["content"], 2)
+	assert.Equal(t, map[string]interface{}{
+		"type": "tool_result", "tool_use_id": "toolu_b", "content": []interface{}{map[string]interface{}{"type": "text", "text": "B"}},
+	}, results["content"].([]interface{})[1])
+
+	tools := got["tools"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}, tools[0].(map[string]interface{})["input_schema"])
+	assert.Equal(t, map[string]interface{}{"type": "auto"}, got["tool_choice"])
+
+	// Response conversion
+	choice := resp.Choices[0]
+	assert.Equal(t, "tool_calls", choice.FinishReason)
+	assert.Equal(t, "Let me look.", choice.Message.Content)
+	require.Len(t, choice.Message.ToolCalls, 1)
+	assert.Equal(t, "toolu_2", choice.Message.ToolCalls[0].ID)
+	assert.JSONEq(t, `{"path":"."}`, string(choice.Message.ToolCalls[0].Function.Arguments))
+
+	assert.Equal(t, aisdk.Usage{
+		PromptTokens:           1110,
+		CompletionTokens:       20,
+		TotalTokens:            1130,
+		PromptTokensCached:     1000,
+		PromptTokensCacheWrite: 100,
+	}, resp.Usage)
+}
+
+func TestBuildRequestImages(t *testing.T) {
+	content := &aisdk.MultimodalContent{}
+	content.AddImage("jpg", "aGVsbG8=", "cat.jpg", 5)
+	toolResult := &aisdk.Message{Role: "tool", ToolCallID: "toolu_a", Name: "read_file"}
+	toolResult.SetMultimodalContent(content)
+
+	req := buildRequest(&aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{
+			{Role: "user", Content: "what is in cat.jpg?"},
+			{Role: "assistant", ToolCalls: []aisdk.ToolCall{
+				{ID: "toolu_a", Type: "function", Function: aisdk.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"path":"cat.jpg"}`)}},
+			}},
+			toolResult,
+		},
+	}, defaultMaxTokens)
+
+	require.Len(t, req.Messages, 3)
+	result := req.Messages[2].Content[0]
+	assert.Equal(t, "tool_result", result.Type)
+	require.Len(t, result.Content, 1)
+	assert.Equal(t, contentBlock{
+		Type:   "image",
+		Source: &imageSource{Type: "base64", MediaType: "image/jpeg", Data: "aGVsbG8="},
+	}, result.Content[0])
+}
+
+func TestBuildRequestToolError(t *testing.T) {
+	req := buildRequest(&aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{
+			{Role: "user", Content: "read a.txt and b.txt"},
+			{Role: "assistant", ToolCalls: []aisdk.ToolCall{
+				{ID: "toolu_a", Type: "function", Function: aisdk.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"path":"a.txt"}`)}},
+				{ID: "toolu_b", Type: "function", Function: aisdk.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"path":"b.txt"}`)}},
+			}},
+			{Role: "tool", ToolCallID: "toolu_a", Name: "read_file", Content: "a"},
+			{Role: "tool", ToolCallID: "toolu_b", Name: "read_file", Content: "Error: file not found", IsError: true},
+		},
+	}, defaultMaxTokens)
+
+	require.Len(t, req.Messages, 3)
+	results := req.Messages[2].Content
+	require.Len(t, results, 2)
+	assert.False(t, results[0].IsError)
+	assert.True(t, results[1].IsError)
+}
+
+func TestCreateChatCompletionStructured(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Tools      []tool      `json:"tools"`
+			ToolChoice *toolChoice `json:"tool_choice"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Len(t, body.Tools, 1)
+		assert.Equal(t, "triage_report", body.Tools[0].Name)
+		assert.Equal(t, map[string]interface{}{"type": "object", "required": []interface{}{"severity"}}, body.Tools[0].InputSchema)
+		assert.Equal(t, &toolChoice{Type: "tool", Name: "triage_report"}, body.ToolChoice)
+
+		fmt.Fprint(w, `{"id":"msg_1","model":"claude-sonnet-4-20250514","content":[{"type":"tool_use","id":"toolu_1","name":"triage_report","input":{"severity":"high"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`)
+	})
+	model := newTestModel(t, server)
+
+	resp, err := model.CreateChatCompletion(context.Background(), &aisdk.ChatCompletionRequest{
+		Messages:       []*aisdk.Message{{Role: "user", Content: "triage"}},
+		ResponseFormat: aisdk.NewJSONSchemaResponseFormat("triage.report", json.RawMessage(`{"type":"object","required":["severity"]}`)),
+	})
+	require.NoError(t, err)
+
+	choice := resp.Choices[0]
+	assert.Equal(t, `{"severity":"high"}`, choice.Message.Content)
+	assert.Empty(t, choice.Message.ToolCalls)
+	assert.Equal(t, "stop", choice.FinishReason)
+}
+
+func TestCreateChatCompletionStream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-20250514","usage":{"input_tokens":5,"output_tokens":1,"cache_read_input_tokens":50}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"ping"}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"there"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"read_file","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"a.txt\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":12}}`,
+		`{"type":"message_stop"}`,
+	}
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, true, body["stream"])
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			var typed struct{ Type string }
+			json.Unmarshal([]byte(event), &typed)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, event)
+		}
+	})
+	model := newTestModel(t, server).(aisdk.StreamingModelClient)
+
+	var text string
+	resp, err := model.CreateChatCompletionStream(context.Background(), &aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{{Role: "user", Content: "hi"}},
+	}, func(chunk *aisdk.StreamChunk) error {
+		for _, choice := range chunk.Choices {
+			text += choice.Delta.Content
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Hi there", text)
+	assert.Equal(t, "msg_1", resp.ID)
+	choice := resp.Choices[0]
+	assert.Equal(t, "Hi there", choice.Message.Content)
+	assert.Equal(t, "tool_calls", choice.FinishReason)
+	require.Len(t, choice.Message.ToolCalls, 1)
+	assert.Equal(t, "toolu_1", choice.Message.ToolCalls[0].ID)
+	assert.Equal(t, "read_file", choice.Message.ToolCalls[0].Function.Name)
+	assert.Equal(t, `{"path":"a.txt"}`, string(choice.Message.ToolCalls[0].Function.Arguments))
+	assert.Equal(t, 55, resp.Usage.PromptTokens)
+	assert.Equal(t, 50, resp.Usage.PromptTokensCached)
+	assert.Equal(t, 12, resp.Usage.CompletionTokens)
+}
+
+func TestCreateChatCompletionError(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+	})
+	model := newTestModel(t, server)
+
+	_, err := model.CreateChatCompletion(context.Background(), &aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{{Role: "user", Content: "hi"}},
+	})
+
+	var apiErr *orclient.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "slow down", apiErr.Message)
+	assert.True(t, apiErr.IsRateLimit())
+}
+
+func TestModelNotFound(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected messages request")
+	})
+	client := NewClient(Config{APIKey: "test-key", BaseURL: server.URL, RetryCount: 1})
+
+	_, err := client.Model(context.Background(), "claude-nope")
+	assert.Error(t, err)
+}
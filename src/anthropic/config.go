@@ -0,0 +1,18 @@
+package anthropic
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Config holds configuration for the Anthropic client
+type Config struct {
+	APIKey     string        // Anthropic API key
+	BaseURL    string        // Base URL for the Anthropic API
+	Version    string        // Value of the anthropic-version header
+	Logger     *slog.Logger  // Logger for debugging
+	Timeout    time.Duration // HTTP timeout
+	RetryCount int           // Number of retries for failed requests
+	RetryDelay time.Duration // Delay between retries
+	MaxTokens  int           // Default max_tokens when the request doesn't set one
+}
@@ -0,0 +1,371 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+// messagesRequest is the body of POST /v1/messages
+type messagesRequest struct {
+	Model         string         `json:"model"`
+	MaxTokens     int            `json:"max_tokens"`
+	System        []contentBlock `json:"system,omitempty"`
+	Messages      []message      `json:"messages"`
+	Tools         []tool         `json:"tools,omitempty"`
+	ToolChoice    *toolChoice    `json:"tool_choice,omitempty"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+	TopP          *float64       `json:"top_p,omitempty"`
+	StopSequences []string       `json:"stop_sequences,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+
+	// structuredTool is the name of the tool that emulates a json_schema
+	// response format, empty if the request has none
+	structuredTool string
+}
+
+// message is a single turn in the Messages API. Roles are only "user" and "assistant".
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// contentBlock covers the text, image, tool_use and tool_result block types
+type contentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// image
+	Source *imageSource `json:"source,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string         `json:"tool_use_id,omitempty"`
+	Content   []contentBlock `json:"content,omitempty"`
+	IsError   bool           `json:"is_error,omitempty"`
+
+	CacheControl *aisdk.CacheControl `json:"cache_control,omitempty"`
+}
+
+// imageSource is the source of an image block
+type imageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// tool is a tool definition in the Messages API
+type tool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema"`
+}
+
+// toolChoice controls how the model uses tools
+type toolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// messagesResponse is the response of POST /v1/messages
+type messagesResponse struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Role       string         `json:"role"`
+	Model      string         `json:"model"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      usage          `json:"usage"`
+}
+
+// usage is the native Anthropic token usage. InputTokens excludes cached tokens.
+type usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// toUsage converts native usage into aisdk usage. PromptTokens counts every
+// input token, including those read from or written to the cache.
+func (u usage) toUsage() aisdk.Usage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return aisdk.Usage{
+		PromptTokens:           prompt,
+		CompletionTokens:       u.OutputTokens,
+		TotalTokens:            prompt + u.OutputTokens,
+		PromptTokensCached:     u.CacheReadInputTokens,
+		PromptTokensCacheWrite: u.CacheCreationInputTokens,
+	}
+}
+
+// buildRequest converts an aisdk request into a Messages API request
+func buildRequest(req *aisdk.ChatCompletionRequest, defaultMaxTokens int) *messagesRequest {
+	out := &messagesRequest{
+		Model:         req.Model,
+		MaxTokens:     defaultMaxTokens,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: req.Stop,
+		Stream:        req.Stream,
+	}
+	if req.MaxTokens != nil {
+		out.MaxTokens = *req.MaxTokens
+	}
+
+	for _, msg := range req.Messages {
+		if msg == nil {
+			continue
+		}
+
+		// System messages become top-level system blocks
+		if msg.Role == "system" {
+			if text := msg.GetContent(); text != "" {
+				out.System = append(out.System, contentBlock{
+					Type:         "text",
+					Text:         text,
+					CacheControl: msg.CacheControl,
+				})
+			}
+			continue
+		}
+
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "assistant"
+		}
+
+		blocks := messageBlocks(msg)
+		if len(blocks) == 0 {
+			continue
+		}
+		if msg.CacheControl != nil {
+			blocks[len(blocks)-1].CacheControl = msg.CacheControl
+		}
+
+		// Consecutive messages with the same role are merged, which also groups
+		// the results of parallel tool calls into a single user turn.
+		if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == role {
+			out.Messages[n-1].Content = append(out.Messages[n-1].Content, blocks...)
+			continue
+		}
+		out.Messages = append(out.Messages, message{Role: role, Content: blocks})
+	}
+
+	for _, t := range req.Tools {
+		if t == nil {
+			continue
+		}
+		var schema interface{} = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		if t.Function.Parameters != nil {
+			schema = t.Function.Parameters
+		}
+		out.Tools = append(out.Tools, tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	if len(out.Tools) > 0 {
+		out.ToolChoice = convertToolChoice(req.ToolChoice)
+	}
+
+	// The Messages API has no response_format. A json_schema format is
+	// emulated with a tool whose input schema is the response schema; its
+	// input is returned as the text content of the response.
+	if format := req.ResponseFormat; format != nil && format.Type == "json_schema" && format.JSONSchema != nil {
+		out.structuredTool = structuredToolName(format.JSONSchema.Name)
+		out.ToolChoice = &toolChoice{Type: "tool", Name: out.structuredTool}
+		if len(out.Tools) > 0 {
+			// Let the model keep using the other tools until it answers
+			out.ToolChoice = &toolChoice{Type: "any"}
+		}
+		out.Tools = append(out.Tools, tool{
+			Name:        out.structuredTool,
+			Description: "Respond with the final answer. The input must match the " + format.JSONSchema.Name + " schema.",
+			InputSchema: format.JSONSchema.Schema,
+		})
+	}
+
+	return out
+}
+
+// structuredToolName returns a valid tool name for a response schema name
+func structuredToolName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "structured_response"
+	}
+	if b.Len() > 64 {
+		return b.String()[:64]
+	}
+	return b.String()
+}
+
+// messageBlocks converts the content of a single message into content blocks
+func messageBlocks(msg *aisdk.Message) []contentBlock {
+	var blocks []contentBlock
+
+	if msg.Role == "tool" {
+		result := contentBlock{
+			Type:      "tool_result",
+			ToolUseID: msg.ToolCallID,
+			IsError:   msg.IsError,
+		}
+		if msg.HasImages() {
+			result.Content = multimodalBlocks(msg.MultimodalContent)
+		} else if text := msg.GetContent(); text != "" {
+			result.Content = []contentBlock{{Type: "text", Text: text}}
+		}
+		return append(blocks, result)
+	}
+
+	if msg.HasImages() {
+		blocks = append(blocks, multimodalBlocks(msg.MultimodalContent)...)
+	} else if text := msg.GetContent(); text != "" {
+		blocks = append(blocks, contentBlock{Type: "text", Text: text})
+	}
+
+	for _, tc := range msg.ToolCalls {
+		input := tc.Function.Arguments
+		if len(strings.TrimSpace(string(input))) == 0 || string(input) == "null" {
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, contentBlock{
+			Type:  "tool_use",
+			ID:    tc.ID,
+			Name:  tc.Function.Name,
+			Input: input,
+		})
+	}
+
+	return blocks
+}
+
+// multimodalBlocks converts multimodal content into text and base64 image blocks
+func multimodalBlocks(content *aisdk.MultimodalContent) []contentBlock {
+	var blocks []contentBlock
+	for _, part := range content.ContentParts() {
+		switch part.Type {
+		case "text":
+			blocks = append(blocks, contentBlock{Type: "text", Text: part.Text})
+		case "image_url":
+			mediaType, data, ok := parseDataURI(part.ImageURL.URL)
+			if !ok {
+				continue
+			}
+			blocks = append(blocks, contentBlock{
+				Type:   "image",
+				Source: &imageSource{Type: "base64", MediaType: mediaType, Data: data},
+			})
+		}
+	}
+	return blocks
+}
+
+// parseDataURI splits a base64 data URI into its media type and data
+func parseDataURI(uri string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(uri, "data:")
+	if !ok {
+		return "", "", false
+	}
+	mediaType, data, ok := strings.Cut(rest, ";base64,")
+	return mediaType, data, ok
+}
+
+// convertToolChoice maps OpenAI style tool_choice values onto Anthropic's format
+func convertToolChoice(choice string) *toolChoice {
+	switch choice {
+	case "", "auto":
+		return &toolChoice{Type: "auto"}
+	case "none":
+		return &toolChoice{Type: "none"}
+	case "required", "any":
+		return &toolChoice{Type: "any"}
+	default:
+		return &toolChoice{Type: "tool", Name: choice}
+	}
+}
+
+// convertResponse converts a Messages API response into an aisdk response.
+// The input of structuredTool becomes the content of the message.
+func convertResponse(resp *messagesResponse, structuredTool string) *aisdk.ChatCompletionResponse {
+	msg := aisdk.Message{Role: "assistant"}
+
+	var text strings.Builder
+	var structured json.RawMessage
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			if structuredTool != "" && block.Name == structuredTool {
+				structured = block.Input
+				continue
+			}
+			args := block.Input
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			}
+			msg.ToolCalls = append(msg.ToolCalls, aisdk.ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: aisdk.FunctionCall{
+					Name:      block.Name,
+					Arguments: args,
+				},
+			})
+		}
+	}
+	msg.Content = text.String()
+
+	finishReason := convertStopReason(resp.StopReason)
+	if structured != nil {
+		msg.Content = string(structured)
+		if len(msg.ToolCalls) == 0 {
+			finishReason = "stop"
+		}
+	}
+
+	return &aisdk.ChatCompletionResponse{
+		ID:     resp.ID,
+		Object: "chat.completion",
+		Model:  resp.Model,
+		Choices: []aisdk.Choice{{
+			Index:        0,
+			Message:      msg,
+			FinishReason: finishReason,
+		}},
+		Usage: resp.Usage.toUsage(),
+	}
+}
+
+// convertStopReason maps Anthropic stop reasons onto OpenAI finish reasons
+func convertStopReason(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	default:
+		return reason
+	}
+}
@@ -0,0 +1,104 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+var (
+	_ aisdk.ModelClient          = (*ModelClient)(nil)
+	_ aisdk.StreamingModelClient = (*ModelClient)(nil)
+)
+
+// ModelClient represents a client bound to a specific model
+type ModelClient struct {
+	client *Client
+	model  *aisdk.ModelInfo
+}
+
+// CreateChatCompletion creates a chat completion with the bound model
+func (mc *ModelClient) CreateChatCompletion(ctx context.Context, req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionResponse, error) {
+	req.Model = mc.model.ID
+	req.Messages = aisdk.PrepareMessagesForModel(req.Messages, mc.model)
+	return mc.client.createMessage(ctx, req)
+}
+
+// CreateChatCompletionStream streams a chat completion with the bound model
+func (mc *ModelClient) CreateChatCompletionStream(ctx context.Context, req *aisdk.ChatCompletionRequest, handler aisdk.StreamHandler) (*aisdk.ChatCompletionResponse, error) {
+	req.Model = mc.model.ID
+	req.Messages = aisdk.PrepareMessagesForModel(req.Messages, mc.model)
+	return mc.client.createMessageStream(ctx, req, handler)
+}
+
+// GetModelInfo returns the model information
+func (mc *ModelClient) GetModelInfo() *aisdk.ModelInfo {
+	return mc.model
+}
+
+// createMessage sends a request to the Messages API (internal method).
+func (c *Client) createMessage(ctx context.Context, req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionResponse, error) {
+	logger := c.logger.With("method", "CreateMessage", "model", req.Model)
+	logger.Debug("sending messages request")
+
+	body := buildRequest(req, c.config.MaxTokens)
+	resp, err := c.postMessages(ctx, logger, body, c.httpClient)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result messagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		logger.Error("failed to decode response", "error", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	converted := convertResponse(&result, body.structuredTool)
+	logger.Info("messages request successful",
+		"usage_total", converted.Usage.TotalTokens,
+		"usage_cache_read", converted.Usage.PromptTokensCached,
+		"usage_cache_write", converted.Usage.PromptTokensCacheWrite)
+	return converted, nil
+}
+
+// postMessages sends the request body and returns the response if it succeeded
+func (c *Client) postMessages(ctx context.Context, logger *slog.Logger, body *messagesRequest, httpClient *http.Client) (*http.Response, error) {
+	if c.logger.Enabled(ctx, slog.LevelDebug) {
+		if debugBody, err := json.MarshalIndent(body, "", "  "); err == nil {
+			logger.Debug("formatted request", "body", string(debugBody))
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		logger.Error("failed to marshal request", "error", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := c.newRequest(ctx, "POST", "/v1/messages", data)
+	if err != nil {
+		return nil, err
+	}
+	if body.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := c.doRequestWithRetry(httpClient, httpReq)
+	if err != nil {
+		logger.Error("request failed", "error", err)
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		logger.Error("received error response", "status_code", resp.StatusCode)
+		return nil, c.handleError(resp)
+	}
+
+	return resp, nil
+}
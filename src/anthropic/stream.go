@@ -0,0 +1,177 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/orclient"
+)
+
+// streamEvent is the payload of a Messages API server-sent event
+type streamEvent struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+
+	// message_start
+	Message *messagesResponse `json:"message,omitempty"`
+
+	// content_block_start
+	ContentBlock *contentBlock `json:"content_block,omitempty"`
+
+	// content_block_delta and message_delta
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+
+	// message_delta
+	Usage *usage `json:"usage,omitempty"`
+
+	// error
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// createMessageStream sends a streaming request to the Messages API (internal method).
+// Events are translated into aisdk stream chunks so callers see the same shape as other providers.
+func (c *Client) createMessageStream(ctx context.Context, req *aisdk.ChatCompletionRequest, handler aisdk.StreamHandler) (*aisdk.ChatCompletionResponse, error) {
+	logger := c.logger.With("method", "CreateMessageStream", "model", req.Model)
+	logger.Debug("sending streaming messages request")
+
+	body := buildRequest(req, c.config.MaxTokens)
+	body.Stream = true
+
+	resp, err := c.postMessages(ctx, logger, body, c.streamClient)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	acc := aisdk.NewStreamAccumulator()
+	emit := func(chunk *aisdk.StreamChunk) error {
+		acc.Add(chunk)
+		if handler != nil {
+			return handler(chunk)
+		}
+		return nil
+	}
+
+	var (
+		id         string
+		model      string
+		totalUsage usage
+		// toolIndex maps content block indexes to tool call indexes
+		toolIndex = make(map[int]int)
+		// structuredBlocks are the blocks of the structured output tool,
+		// whose input is streamed as text
+		structuredBlocks = make(map[int]bool)
+	)
+
+	err = aisdk.ReadSSE(resp.Body, func(data []byte) error {
+		var event streamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("failed to decode stream event: %w", err)
+		}
+
+		chunk := &aisdk.StreamChunk{ID: id, Model: model}
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				id = event.Message.ID
+				model = event.Message.Model
+				totalUsage = event.Message.Usage
+			}
+			chunk.ID, chunk.Model = id, model
+			chunk.Choices = []aisdk.StreamChoice{{Delta: aisdk.StreamDelta{Role: "assistant"}}}
+
+		case "content_block_start":
+			if event.ContentBlock == nil || event.ContentBlock.Type != "tool_use" {
+				return nil
+			}
+			if body.structuredTool != "" && event.ContentBlock.Name == body.structuredTool {
+				structuredBlocks[event.Index] = true
+				return nil
+			}
+			idx := len(toolIndex)
+			toolIndex[event.Index] = idx
+			chunk.Choices = []aisdk.StreamChoice{{Delta: aisdk.StreamDelta{
+				ToolCalls: []aisdk.ToolCallDelta{{
+					Index:    idx,
+					ID:       event.ContentBlock.ID,
+					Type:     "function",
+					Function: aisdk.FunctionCallDelta{Name: event.ContentBlock.Name},
+				}},
+			}}}
+
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				chunk.Choices = []aisdk.StreamChoice{{Delta: aisdk.StreamDelta{Content: event.Delta.Text}}}
+			case "input_json_delta":
+				if structuredBlocks[event.Index] {
+					chunk.Choices = []aisdk.StreamChoice{{Delta: aisdk.StreamDelta{Content: event.Delta.PartialJSON}}}
+					break
+				}
+				idx, ok := toolIndex[event.Index]
+				if !ok {
+					return nil
+				}
+				chunk.Choices = []aisdk.StreamChoice{{Delta: aisdk.StreamDelta{
+					ToolCalls: []aisdk.ToolCallDelta{{
+						Index:    idx,
+						Function: aisdk.FunctionCallDelta{Arguments: event.Delta.PartialJSON},
+					}},
+				}}}
+			default:
+				return nil
+			}
+
+		case "message_delta":
+			if event.Usage != nil {
+				totalUsage.OutputTokens = event.Usage.OutputTokens
+			}
+			u := totalUsage.toUsage()
+			chunk.Usage = &u
+			finishReason := convertStopReason(event.Delta.StopReason)
+			if len(structuredBlocks) > 0 && len(toolIndex) == 0 {
+				finishReason = "stop"
+			}
+			chunk.Choices = []aisdk.StreamChoice{{FinishReason: finishReason}}
+
+		case "error":
+			apiErr := &orclient.APIError{StatusCode: resp.StatusCode}
+			if event.Error != nil {
+				apiErr.Type = event.Error.Type
+				apiErr.Code = event.Error.Type
+				apiErr.Message = event.Error.Message
+				if event.Error.Type == "overloaded_error" {
+					apiErr.StatusCode = 529
+				}
+			}
+			return apiErr
+
+		default:
+			// ping, content_block_stop and message_stop carry nothing we need
+			return nil
+		}
+
+		return emit(chunk)
+	})
+	if err != nil {
+		logger.Error("stream failed", "error", err)
+		return nil, err
+	}
+
+	result := acc.Response()
+	logger.Info("messages stream successful",
+		"usage_total", result.Usage.TotalTokens,
+		"usage_cache_read", result.Usage.PromptTokensCached,
+		"usage_cache_write", result.Usage.PromptTokensCacheWrite)
+	return result, nil
+}
@@ -7,13 +7,14 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/elee1766/gofer/src/orclient"
+	"github.com/elee1766/gofer/src/config"
+	"github.com/elee1766/gofer/src/redact"
 	"github.com/elee1766/gofer/src/storage"
 )
 
 // App represents the main application with all services
 type App struct {
-	ModelProvider *orclient.Client
+	ModelProvider *ProviderRegistry
 	Store         *storage.DB
 	ProjectDir    string
 	Logger        *slog.Logger
@@ -29,6 +30,50 @@ type AppConfig struct {
 	EnableTools  bool
 	Logger       *slog.Logger
 	ProjectDir   string
+
+	// Providers from config.Config.Providers
+	Providers map[string]config.ProviderConfig
+	// DefaultProvider handles model references without a provider prefix
+	DefaultProvider string
+	// AnthropicAPIKey enables the anthropic provider without a config entry
+	AnthropicAPIKey string
+
+	// ContextStrategy and ContextReserveTokens from config.AgentConfig
+	ContextStrategy      string
+	ContextReserveTokens int
+
+	// MaxParallelTools from config.AgentConfig
+	MaxParallelTools int
+
+	// FallbackModels from config.AgentConfig
+	FallbackModels []string
+
+	// Tools holds per-tool settings from config.Config.Tools
+	Tools map[string]config.ToolConfig
+
+	// AutoCompact and CompactThreshold from config.Config
+	AutoCompact      bool
+	CompactThreshold float64
+
+	// SubAgent configures the task tool, from config.Config
+	SubAgent config.SubAgentConfig
+
+	// Hooks from config.Config
+	Hooks config.HooksConfig
+
+	// Permissions decide whether tool calls may run, from config.Config
+	Permissions *config.PermissionsConfig
+
+	// AuditLog from config.SecurityConfig
+	AuditLog config.AuditLogConfig
+
+	// Redaction from config.SecurityConfig
+	Redaction config.RedactionConfig
+
+	// Redactor is shared by the providers and the runs of the app, so a
+	// secret gets the same placeholder in logs and tool results. New
+	// creates it from Redaction.
+	Redactor *redact.Redactor
 }
 
 // New creates a new App instance with all services initialized
@@ -55,15 +100,25 @@ func New(ctx context.Context, cfg AppConfig) (*App, error) {
 		return nil, fmt.Errorf("failed to open storage: %w", err)
 	}
 
-	// Initialize AI client with OpenRouter provider
-	provider := orclient.NewClient(orclient.Config{
-		APIKey:  cfg.APIKey,
-		BaseURL: cfg.BaseURL,
-		Logger:  logger,
-	})
+	// Initialize the redactor
+	if cfg.Redactor == nil && cfg.Redaction.Enabled {
+		cfg.Redactor, err = redact.New(cfg.Redaction, projectDir, logger)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("failed to create redactor: %w", err)
+		}
+	}
+
+	// Initialize model providers
+	cfg.Logger = logger
+	providers, err := NewProviderRegistryFromConfig(cfg)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to initialize providers: %w", err)
+	}
 
 	return &App{
-		ModelProvider: provider,
+		ModelProvider: providers,
 		Store:         store,
 		ProjectDir:    projectDir,
 		Logger:        logger,
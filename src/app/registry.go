@@ -0,0 +1,301 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/anthropic"
+	"github.com/elee1766/gofer/src/config"
+	"github.com/elee1766/gofer/src/openaicompat"
+	"github.com/elee1766/gofer/src/orclient"
+	"github.com/elee1766/gofer/src/redact"
+)
+
+// Provider types understood by the registry
+const (
+	ProviderTypeOpenRouter       = "openrouter"
+	ProviderTypeAnthropic        = "anthropic"
+	ProviderTypeOpenAICompatible = "openai-compatible"
+)
+
+var _ aisdk.Provider = (*ProviderRegistry)(nil)
+
+// ProviderRegistry routes model references of the form "provider:model" to
+// the named provider. References without a known provider prefix go to the
+// default provider, so "google/gemini-2.5-flash" keeps working with OpenRouter.
+type ProviderRegistry struct {
+	providers       map[string]aisdk.Provider
+	defaultProvider string
+	logger          *slog.Logger
+}
+
+// NewProviderRegistry creates an empty registry
+func NewProviderRegistry(defaultProvider string, logger *slog.Logger) *ProviderRegistry {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &ProviderRegistry{
+		providers:       make(map[string]aisdk.Provider),
+		defaultProvider: defaultProvider,
+		logger:          logger.With("component", "provider_registry"),
+	}
+}
+
+// Register adds a provider under the given name
+func (r *ProviderRegistry) Register(name string, provider aisdk.Provider) error {
+	if name == "" {
+		return fmt.Errorf("provider name is required")
+	}
+	if strings.Contains(name, ":") {
+		return fmt.Errorf("provider name %q must not contain ':'", name)
+	}
+	if _, exists := r.providers[name]; exists {
+		return fmt.Errorf("provider %s already registered", name)
+	}
+	r.providers[name] = provider
+	return nil
+}
+
+// Get returns the provider registered under name
+func (r *ProviderRegistry) Get(name string) (aisdk.Provider, bool) {
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Names returns the names of all registered providers in sorted order
+func (r *ProviderRegistry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultProvider returns the name of the provider used for unprefixed references
+func (r *ProviderRegistry) DefaultProvider() string {
+	return r.defaultProvider
+}
+
+// Resolve splits a model reference into a provider name and model name.
+// Only a prefix naming a registered provider is treated as a provider, so
+// model names containing ':' (such as Ollama tags) are left intact.
+func (r *ProviderRegistry) Resolve(ref string) (string, string, error) {
+	if name, model, ok := strings.Cut(ref, ":"); ok {
+		if _, exists := r.providers[name]; exists {
+			return name, model, nil
+		}
+	}
+
+	if _, exists := r.providers[r.defaultProvider]; !exists {
+		return "", "", fmt.Errorf("no provider for model %q and default provider %q is not configured", ref, r.defaultProvider)
+	}
+	return r.defaultProvider, ref, nil
+}
+
+// Model implements aisdk.Provider.Model by routing the reference to its provider
+func (r *ProviderRegistry) Model(ctx context.Context, ref string) (aisdk.ModelClient, error) {
+	name, model, err := r.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	client, err := r.providers[name].Model(ctx, model)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return withProviderName(client, name), nil
+}
+
+// namedModel is a model client of the registry, which records the name of
+// its provider with the messages it answers
+type namedModel struct {
+	aisdk.ModelClient
+	provider string
+}
+
+// ProviderName implements aisdk.NamedModelClient
+func (m namedModel) ProviderName() string {
+	return m.provider
+}
+
+// namedStreamingModel is a namedModel whose client streams
+type namedStreamingModel struct {
+	namedModel
+	streamer aisdk.StreamingModelClient
+}
+
+// CreateChatCompletionStream implements aisdk.StreamingModelClient
+func (m namedStreamingModel) CreateChatCompletionStream(ctx context.Context, req *aisdk.ChatCompletionRequest, handler aisdk.StreamHandler) (*aisdk.ChatCompletionResponse, error) {
+	return m.streamer.CreateChatCompletionStream(ctx, req, handler)
+}
+
+// withProviderName wraps a client of the named provider, keeping whether it streams
+func withProviderName(client aisdk.ModelClient, name string) aisdk.ModelClient {
+	named := namedModel{ModelClient: client, provider: name}
+	if streamer, ok := client.(aisdk.StreamingModelClient); ok {
+		return namedStreamingModel{namedModel: named, streamer: streamer}
+	}
+	return named
+}
+
+// GetModels implements aisdk.Provider.GetModels by aggregating every provider.
+// Model IDs are returned as "provider:model" references. Providers that fail
+// are logged and skipped; an error is only returned if all of them fail.
+func (r *ProviderRegistry) GetModels(ctx context.Context) ([]*aisdk.ModelInfo, error) {
+	var all []*aisdk.ModelInfo
+	var errs []error
+
+	for _, name := range r.Names() {
+		models, err := r.providers[name].GetModels(ctx)
+		if err != nil {
+			r.logger.Warn("failed to list models", "provider", name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		for _, model := range models {
+			prefixed := *model
+			prefixed.ID = name + ":" + model.ID
+			all = append(all, &prefixed)
+		}
+	}
+
+	if len(errs) > 0 && len(errs) == len(r.providers) {
+		return nil, errors.Join(errs...)
+	}
+	return all, nil
+}
+
+// NewProviderRegistryFromConfig builds a registry from the app configuration.
+// Entries of Providers are registered when enabled. OpenRouter is also
+// registered when the CLI key is given, and Anthropic when an Anthropic API
+// key is given directly. It fails when no provider is registered; a default
+// provider that is not registered only fails references without a provider.
+func NewProviderRegistryFromConfig(cfg AppConfig) (*ProviderRegistry, error) {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	// Providers log their requests at debug level, so their secrets are
+	// redacted from the logs, by the redactor of the app when it has one.
+	// A redactor of the registry alone has no store of its own.
+	redactor := cfg.Redactor
+	if redactor == nil && cfg.Redaction.Enabled {
+		redaction := cfg.Redaction
+		redaction.SecretStore.Enabled = false
+		var err error
+		redactor, err = redact.New(redaction, cfg.ProjectDir, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create redactor: %w", err)
+		}
+	}
+	providerLogger := logger
+	if redactor != nil {
+		providerLogger = redactor.Logger(logger)
+	}
+
+	defaultProvider := cfg.DefaultProvider
+	if defaultProvider == "" {
+		defaultProvider = ProviderTypeOpenRouter
+	}
+	registry := NewProviderRegistry(defaultProvider, logger)
+
+	providers := make(map[string]config.ProviderConfig, len(cfg.Providers)+2)
+	for name, pc := range cfg.Providers {
+		if pc.Enabled {
+			providers[name] = pc
+		}
+	}
+
+	if cfg.APIKey != "" {
+		pc, ok := providers[ProviderTypeOpenRouter]
+		if !ok {
+			pc = cfg.Providers[ProviderTypeOpenRouter]
+		}
+		pc.APIKey = cfg.APIKey
+		providers[ProviderTypeOpenRouter] = pc
+	}
+	if pc, ok := providers[ProviderTypeOpenRouter]; ok && cfg.BaseURL != "" {
+		pc.BaseURL = cfg.BaseURL
+		providers[ProviderTypeOpenRouter] = pc
+	}
+
+	if cfg.AnthropicAPIKey != "" {
+		pc, ok := providers[ProviderTypeAnthropic]
+		if !ok {
+			pc = cfg.Providers[ProviderTypeAnthropic]
+		}
+		if pc.APIKey == "" {
+			pc.APIKey = cfg.AnthropicAPIKey
+		}
+		providers[ProviderTypeAnthropic] = pc
+	}
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		provider, err := newProvider(name, providers[name], providerLogger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create provider %s: %w", name, err)
+		}
+		if err := registry.Register(name, provider); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no provider is configured, default provider %s needs an API key or an enabled entry", defaultProvider)
+	}
+
+	return registry, nil
+}
+
+// newProvider creates a provider from its configuration. The type defaults to
+// the provider name for OpenRouter and Anthropic, and to OpenAI-compatible otherwise.
+func newProvider(name string, pc config.ProviderConfig, logger *slog.Logger) (aisdk.Provider, error) {
+	providerType := pc.Type
+	if providerType == "" {
+		switch name {
+		case ProviderTypeOpenRouter, ProviderTypeAnthropic:
+			providerType = name
+		default:
+			providerType = ProviderTypeOpenAICompatible
+		}
+	}
+
+	switch providerType {
+	case ProviderTypeOpenRouter:
+		return orclient.NewClient(orclient.Config{
+			APIKey:  pc.APIKey,
+			BaseURL: pc.BaseURL,
+			Logger:  logger,
+		}), nil
+	case ProviderTypeAnthropic:
+		if pc.APIKey == "" {
+			return nil, fmt.Errorf("api key is required")
+		}
+		return anthropic.NewClient(anthropic.Config{
+			APIKey:  pc.APIKey,
+			BaseURL: pc.BaseURL,
+			Logger:  logger,
+		}), nil
+	case ProviderTypeOpenAICompatible:
+		return openaicompat.NewClient(openaicompat.Config{
+			Name:    name,
+			APIKey:  pc.APIKey,
+			BaseURL: pc.BaseURL,
+			Logger:  logger,
+		})
+	default:
+		return nil, fmt.Errorf("unknown provider type %q", providerType)
+	}
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubModelClient struct {
+	info *aisdk.ModelInfo
+}
+
+func (s *stubModelClient) CreateChatCompletion(ctx context.Context, req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *stubModelClient) GetModelInfo() *aisdk.ModelInfo {
+	return s.info
+}
+
+type stubProvider struct {
+	models []string
+	err    error
+}
+
+func (s *stubProvider) GetModels(ctx context.Context) ([]*aisdk.ModelInfo, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	var out []*aisdk.ModelInfo
+	for _, id := range s.models {
+		out = append(out, &aisdk.ModelInfo{ID: id})
+	}
+	return out, nil
+}
+
+func (s *stubProvider) Model(ctx context.Context, name string) (aisdk.ModelClient, error) {
+	for _, id := range s.models {
+		if id == name {
+			return &stubModelClient{info: &aisdk.ModelInfo{ID: id}}, nil
+		}
+	}
+	return nil, errors.New("model not found")
+}
+
+func TestProviderRegistryResolve(t *testing.T) {
+	registry := NewProviderRegistry("openrouter", nil)
+	require.NoError(t, registry.Register("openrouter", &stubProvider{models: []string{"google/gemini-2.5-flash"}}))
+	require.NoError(t, registry.Register("local", &stubProvider{models: []string{"qwen2.5-coder:7b"}}))
+
+	tests := []struct {
+		ref      string
+		provider string
+		model    string
+	}{
+		{"google/gemini-2.5-flash", "openrouter", "google/gemini-2.5-flash"},
+		{"openrouter:google/gemini-2.5-flash", "openrouter", "google/gemini-2.5-flash"},
+		{"local:qwen2.5-coder:7b", "local", "qwen2.5-coder:7b"},
+		// An unknown prefix is part of the model name
+		{"qwen2.5-coder:7b", "openrouter", "qwen2.5-coder:7b"},
+	}
+	for _, tt := range tests {
+		provider, model, err := registry.Resolve(tt.ref)
+		require.NoError(t, err, tt.ref)
+		assert.Equal(t, tt.provider, provider, tt.ref)
+		assert.Equal(t, tt.model, model, tt.ref)
+	}
+
+	client, err := registry.Model(context.Background(), "local:qwen2.5-coder:7b")
+	require.NoError(t, err)
+	assert.Equal(t, "qwen2.5-coder:7b", client.GetModelInfo().ID)
+	assert.Equal(t, "local", aisdk.ProviderName(client))
+	_, streams := client.(aisdk.StreamingModelClient)
+	assert.False(t, streams, "a client that cannot stream must not claim to")
+
+	assert.Error(t, registry.Register("local", &stubProvider{}))
+	assert.Error(t, registry.Register("bad:name", &stubProvider{}))
+}
+
+func TestProviderRegistryGetModels(t *testing.T) {
+	registry := NewProviderRegistry("openrouter", nil)
+	require.NoError(t, registry.Register("openrouter", &stubProvider{models: []string{"google/gemini-2.5-flash"}}))
+	require.NoError(t, registry.Register("local", &stubProvider{models: []string{"llama3.1"}}))
+	require.NoError(t, registry.Register("offline", &stubProvider{err: errors.New("connection refused")}))
+
+	models, err := registry.GetModels(context.Background())
+	require.NoError(t, err)
+
+	var ids []string
+	for _, m := range models {
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, []string{"local:llama3.1", "openrouter:google/gemini-2.5-flash"}, ids)
+
+	failing := NewProviderRegistry("offline", nil)
+	require.NoError(t, failing.Register("offline", &stubProvider{err: errors.New("connection refused")}))
+	_, err = failing.GetModels(context.Background())
+	assert.Error(t, err)
+}
+
+func TestNewProviderRegistryFromConfig(t *testing.T) {
+	registry, err := NewProviderRegistryFromConfig(AppConfig{
+		APIKey: "or-key",
+		Providers: map[string]config.ProviderConfig{
+			"local":    {BaseURL: "http://localhost:11434/v1", Enabled: true},
+			"disabled": {BaseURL: "http://localhost:1/v1"},
+		},
+		AnthropicAPIKey: "ant-key",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"anthropic", "local", "openrouter"}, registry.Names())
+	assert.Equal(t, "openrouter", registry.DefaultProvider())
+
+	_, err = NewProviderRegistryFromConfig(AppConfig{
+		Providers: map[string]config.ProviderConfig{
+			"custom": {Type: "carrier-pigeon", Enabled: true},
+		},
+	})
+	assert.Error(t, err)
+
+	_, err = NewProviderRegistryFromConfig(AppConfig{DefaultProvider: "local"})
+	assert.Error(t, err)
+
+	// OpenRouter needs a key or an enabled entry, and some provider must be
+	// registered
+	_, err = NewProviderRegistryFromConfig(AppConfig{})
+	assert.ErrorContains(t, err, "no provider is configured")
+
+	registry, err = NewProviderRegistryFromConfig(AppConfig{
+		DefaultProvider: "local",
+		Providers: map[string]config.ProviderConfig{
+			"openrouter": {APIKey: "or-key"},
+			"local":      {BaseURL: "http://localhost:11434/v1", Enabled: true},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"local"}, registry.Names())
+
+	// Without the default provider, only prefixed references resolve
+	registry, err = NewProviderRegistryFromConfig(AppConfig{
+		DefaultProvider: "google",
+		APIKey:          "or-key",
+		Providers: map[string]config.ProviderConfig{
+			"google": {BaseURL: "https://generativelanguage.googleapis.com/v1beta/openai"},
+		},
+	})
+	require.NoError(t, err)
+	_, _, err = registry.Resolve("gemini-pro")
+	assert.ErrorContains(t, err, `default provider "google" is not configured`)
+	provider, model, err := registry.Resolve("openrouter:openai/gpt-4o")
+	require.NoError(t, err)
+	assert.Equal(t, "openrouter", provider)
+	assert.Equal(t, "openai/gpt-4o", model)
+}
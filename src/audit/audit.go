@@ -0,0 +1,603 @@
+// Package audit writes a tamper-evident audit log of what runs do: tool
+// calls, permission decisions, file changes, commands and model calls.
+//
+// Each entry holds the hash of the entry before it and is hashed itself, so
+// an entry that is edited, removed or reordered breaks the chain. A head
+// file next to the log records the last entry, so entries removed from the
+// end are noticed too. The log rotates to numbered backups, and the chain
+// goes on across them. Each rotation starts the new log with an entry
+// recording the first entry the backups still hold, so backups or entries
+// removed from the start are noticed. Verify checks a log and its backups.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elee1766/gofer/src/config"
+)
+
+// Entry types
+const (
+	TypeToolCall   = "tool_call"
+	TypePermission = "permission"
+	TypeFileChange = "file_change"
+	TypeCommand    = "command"
+	TypeModelCall  = "model_call"
+	TypeRotation   = "rotation"
+)
+
+// Log formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// genesisHash is the previous hash of the first entry of a log
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+// Entry is an entry of the audit log
+type Entry struct {
+	Seq            int64
+	Time           time.Time
+	PrevHash       string
+	Type           string
+	ConversationID string
+	Fields         map[string]any // Only read back from JSON logs
+	Hash           string
+}
+
+// jsonEntry is the JSON encoding of an entry without its hash, which is
+// appended as the last field
+type jsonEntry struct {
+	Seq            int64          `json:"seq"`
+	Time           string         `json:"time"`
+	PrevHash       string         `json:"prev_hash"`
+	Type           string         `json:"type"`
+	ConversationID string         `json:"conversation_id,omitempty"`
+	Fields         map[string]any `json:"fields,omitempty"`
+}
+
+// Log appends entries to an audit log. The methods of a nil *Log do
+// nothing, so callers don't need to check whether auditing is enabled.
+type Log struct {
+	path       string
+	format     string
+	maxSize    int64
+	maxBackups int
+	logger     *slog.Logger
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	seq      int64
+	lastHash string
+	now      func() time.Time
+}
+
+// Open opens the audit log of the configuration, continuing the chain of the
+// entries it has. It fails when the log does not end at its head, as
+// happens when entries were removed from its end. A log that ends one entry
+// past its head, as it does when a run stopped between writing an entry and
+// its head, moves its head to that entry.
+func Open(cfg config.AuditLogConfig, logger *slog.Logger) (*Log, error) {
+	path, err := expandHome(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	format := cfg.Format
+	if format == "" {
+		format = FormatJSON
+	}
+	if format != FormatJSON && format != FormatText {
+		return nil, fmt.Errorf("unknown audit log format %q", format)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	l := &Log{
+		path:       path,
+		format:     format,
+		maxSize:    cfg.MaxSize,
+		maxBackups: cfg.MaxBackups,
+		logger:     logger,
+		lastHash:   genesisHash,
+		now:        time.Now,
+	}
+	last, err := lastEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		l.seq, l.lastHash = last.Seq, last.Hash
+	}
+	if head, err := readHead(path); err != nil {
+		return nil, err
+	} else if head != nil && !head.at(last) {
+		if !head.before(last) {
+			return nil, fmt.Errorf("audit log %s ends at entry %d, but its head is at entry %d; run \"gofer audit verify\"", path, l.seq, head.Seq)
+		}
+		logger.Warn("Moving the audit log head to the entry written after it", "path", path, "seq", last.Seq)
+		if err := writeHead(path, last); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Path returns the path of the log
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Close closes the log
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Record appends an entry. Errors are logged as well as returned.
+func (l *Log) Record(typ, conversationID string, fields map[string]any) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.record(typ, conversationID, fields); err != nil {
+		l.logger.Error("Failed to write audit log", "path", l.path, "type", typ, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (l *Log) record(typ, conversationID string, fields map[string]any) error {
+	if l.file == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+	entry, line, err := l.encode(typ, conversationID, fields)
+	if err != nil {
+		return err
+	}
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+		// The entry follows the rotation entry
+		entry, line, err = l.encode(typ, conversationID, fields)
+		if err != nil {
+			return err
+		}
+	}
+	return l.write(entry, line)
+}
+
+// encode encodes the next entry of the chain
+func (l *Log) encode(typ, conversationID string, fields map[string]any) (*Entry, []byte, error) {
+	entry := &Entry{
+		Seq:            l.seq + 1,
+		Time:           l.now().UTC(),
+		PrevHash:       l.lastHash,
+		Type:           typ,
+		ConversationID: conversationID,
+		Fields:         fields,
+	}
+	line, err := encodeEntry(entry, l.format)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, line, nil
+}
+
+// write appends an encoded entry and moves the head to it
+func (l *Log) write(entry *Entry, line []byte) error {
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write entry: %w", err)
+	}
+	l.seq, l.lastHash = entry.Seq, entry.Hash
+	return writeHead(l.path, entry)
+}
+
+func (l *Log) openFile() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// rotate moves the log to the first backup, shifting the older backups and
+// removing those past max_backups, and starts a new log with a rotation
+// entry recording the first entry of the oldest backup
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	l.file = nil
+
+	n := 1
+	for fileExists(backupPath(l.path, n)) {
+		n++
+	}
+	for i := n - 1; i >= 1; i-- {
+		if l.maxBackups > 0 && i >= l.maxBackups {
+			if err := os.Remove(backupPath(l.path, i)); err != nil {
+				return fmt.Errorf("failed to remove audit log backup: %w", err)
+			}
+			continue
+		}
+		if err := os.Rename(backupPath(l.path, i), backupPath(l.path, i+1)); err != nil {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+	if err := os.Rename(l.path, backupPath(l.path, 1)); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	if err := l.openFile(); err != nil {
+		return err
+	}
+
+	backups := backupPaths(l.path)
+	first, err := firstEntry(backups[len(backups)-1])
+	if err != nil {
+		return err
+	}
+	firstSeq := l.seq + 1
+	if first != nil {
+		firstSeq = first.Seq
+	}
+	entry, line, err := l.encode(TypeRotation, "", map[string]any{"first_seq": firstSeq})
+	if err != nil {
+		return err
+	}
+	return l.write(entry, line)
+}
+
+// encodeEntry encodes an entry as a line and sets its hash, which is the
+// SHA-256 of the line without the hash
+func encodeEntry(e *Entry, format string) ([]byte, error) {
+	var body []byte
+	switch format {
+	case FormatText:
+		body = []byte(encodeText(e))
+	default:
+		var err error
+		body, err = json.Marshal(jsonEntry{
+			Seq:            e.Seq,
+			Time:           e.Time.Format(time.RFC3339Nano),
+			PrevHash:       e.PrevHash,
+			Type:           e.Type,
+			ConversationID: e.ConversationID,
+			Fields:         e.Fields,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode entry: %w", err)
+		}
+	}
+	e.Hash = hashBody(body)
+
+	if format == FormatText {
+		return []byte(string(body) + " hash=" + e.Hash + "\n"), nil
+	}
+	return []byte(string(body[:len(body)-1]) + `,"hash":"` + e.Hash + "\"}\n"), nil
+}
+
+// encodeText encodes an entry without its hash as key=value pairs
+func encodeText(e *Entry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "seq=%d time=%s prev_hash=%s type=%s", e.Seq, e.Time.Format(time.RFC3339Nano), e.PrevHash, e.Type)
+	if e.ConversationID != "" {
+		b.WriteString(" conversation_id=" + textValue(e.ConversationID))
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString(" " + key + "=" + textValue(e.Fields[key]))
+	}
+	return b.String()
+}
+
+// textValue formats a field value, quoting strings that need it and
+// encoding other values as JSON
+func textValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		if v == "" || strings.ContainsAny(v, " \t\n\"=\\") || strconv.Quote(v) != `"`+v+`"` {
+			return strconv.Quote(v)
+		}
+		return v
+	case int, int64, bool:
+		return fmt.Sprint(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return strconv.Quote(fmt.Sprint(v))
+		}
+		return strconv.Quote(string(data))
+	}
+}
+
+// parseLine decodes a line of a log of either format and checks its hash
+func parseLine(line string) (*Entry, error) {
+	var body, hash string
+	if strings.HasPrefix(line, "{") {
+		i := strings.LastIndex(line, `,"hash":"`)
+		if i < 0 || !strings.HasSuffix(line, `"}`) {
+			return nil, errors.New("entry has no hash")
+		}
+		body = line[:i] + "}"
+		hash = line[i+len(`,"hash":"`) : len(line)-2]
+	} else {
+		i := strings.LastIndex(line, " hash=")
+		if i < 0 {
+			return nil, errors.New("entry has no hash")
+		}
+		body = line[:i]
+		hash = line[i+len(" hash="):]
+	}
+	if hashBody([]byte(body)) != hash {
+		return nil, errors.New("entry does not match its hash")
+	}
+
+	entry := &Entry{Hash: hash}
+	var timestamp string
+	if strings.HasPrefix(line, "{") {
+		var decoded jsonEntry
+		if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+			return nil, fmt.Errorf("invalid entry: %w", err)
+		}
+		entry.Seq = decoded.Seq
+		entry.PrevHash = decoded.PrevHash
+		entry.Type = decoded.Type
+		entry.ConversationID = decoded.ConversationID
+		entry.Fields = decoded.Fields
+		timestamp = decoded.Time
+	} else {
+		header := strings.SplitN(body, " ", 5)
+		if len(header) < 4 {
+			return nil, errors.New("invalid entry")
+		}
+		values := make(map[string]string, 4)
+		for _, pair := range header[:4] {
+			key, value, _ := strings.Cut(pair, "=")
+			values[key] = value
+		}
+		seq, err := strconv.ParseInt(values["seq"], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry number: %w", err)
+		}
+		entry.Seq = seq
+		entry.PrevHash = values["prev_hash"]
+		entry.Type = values["type"]
+		timestamp = values["time"]
+
+		// The fields of rotation entries are numbers, so they are read
+		// back for Verify
+		if entry.Type == TypeRotation && len(header) == 5 {
+			entry.Fields = make(map[string]any)
+			for _, pair := range strings.Fields(header[4]) {
+				key, value, _ := strings.Cut(pair, "=")
+				if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+					entry.Fields[key] = n
+				}
+			}
+		}
+	}
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entry time: %w", err)
+	}
+	entry.Time = t
+	return entry, nil
+}
+
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// lastEntry returns the last entry of the log, or of its newest backup when
+// the log is empty, or nil for a new log
+func lastEntry(path string) (*Entry, error) {
+	for _, file := range append([]string{path}, backupPaths(path)...) {
+		line, err := lastLine(file)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		if line == "" {
+			continue
+		}
+		entry, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("last entry of audit log %s is invalid: %v; run \"gofer audit verify\"", file, err)
+		}
+		return entry, nil
+	}
+	return nil, nil
+}
+
+// firstEntry returns the first entry of a file, or nil if it is empty
+func firstEntry(path string) (*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			entry, err := parseLine(line)
+			if err != nil {
+				return nil, fmt.Errorf("first entry of audit log %s is invalid: %v; run \"gofer audit verify\"", path, err)
+			}
+			return entry, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return nil, nil
+}
+
+// rotationFirstSeq returns the first entry a rotation entry records
+func rotationFirstSeq(e *Entry) (int64, bool) {
+	switch v := e.Fields["first_seq"].(type) {
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// lastLine returns the last line of a file
+func lastLine(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var last string
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimSuffix(line, "\n"); line != "" {
+			last = line
+		}
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// head is the last entry of a log, as recorded in its head file
+type head struct {
+	Seq  int64
+	Hash string
+}
+
+// at reports whether the head is at an entry, which is nil for an empty log
+func (h *head) at(e *Entry) bool {
+	return e != nil && h.Seq == e.Seq && h.Hash == e.Hash
+}
+
+// before reports whether an entry directly follows the head
+func (h *head) before(e *Entry) bool {
+	return e != nil && e.Seq == h.Seq+1 && e.PrevHash == h.Hash
+}
+
+func headPath(path string) string {
+	return path + ".head"
+}
+
+// readHead reads the head file of a log, or returns nil if it has none
+func readHead(path string) (*head, error) {
+	data, err := os.ReadFile(headPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log head: %w", err)
+	}
+	var h head
+	if _, err := fmt.Sscanf(string(data), "%d %s", &h.Seq, &h.Hash); err != nil {
+		return nil, fmt.Errorf("invalid audit log head %s: %w", headPath(path), err)
+	}
+	return &h, nil
+}
+
+// writeHead replaces the head file of a log
+func writeHead(path string, e *Entry) error {
+	tmp := headPath(path) + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %s\n", e.Seq, e.Hash)), 0o600); err != nil {
+		return fmt.Errorf("failed to write audit log head: %w", err)
+	}
+	if err := os.Rename(tmp, headPath(path)); err != nil {
+		return fmt.Errorf("failed to write audit log head: %w", err)
+	}
+	return nil
+}
+
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// backupPaths returns the backups of a log, newest first
+func backupPaths(path string) []string {
+	var paths []string
+	for n := 1; fileExists(backupPath(path, n)); n++ {
+		paths = append(paths, backupPath(path, n))
+	}
+	return paths
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// expandHome expands a leading ~ to the home directory
+func expandHome(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("audit log path is not set")
+	}
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
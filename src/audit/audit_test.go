@@ -0,0 +1,242 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestLog opens a log in a temporary directory
+func openTestLog(t *testing.T, cfg config.AuditLogConfig) *Log {
+	t.Helper()
+	if cfg.Path == "" {
+		cfg.Path = filepath.Join(t.TempDir(), "audit.log")
+	}
+	l, err := Open(cfg, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// writeEntries writes entries of every type
+func writeEntries(l *Log) {
+	call := aisdk.ToolCall{ID: "call_1"}
+	call.Function.Name = "write_file"
+	call.Function.Arguments = json.RawMessage(`{"path":"a.txt","content":"` + strings.Repeat("x", 2000) + `"}`)
+
+	l.Permission("conv", "write_file", "call_1", "allow", "", false)
+	l.FileChange("conv", "write_file", "call_1", "/tmp/a.txt", digestAbsent, digest([]byte("x")))
+	l.ToolCall("conv", call, StatusOK, "done", time.Second)
+	l.Command("conv", "call_2", "ls -la", "/tmp", 0, false, StatusOK)
+	l.ModelCall("conv", "fake:tiny", aisdk.Usage{PromptTokens: 10, CompletionTokens: 5})
+}
+
+func TestLogVerifies(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatText} {
+		t.Run(format, func(t *testing.T) {
+			l := openTestLog(t, config.AuditLogConfig{Format: format})
+			writeEntries(l)
+			require.NoError(t, l.Close())
+
+			result, err := Verify(l.Path())
+			require.NoError(t, err)
+			assert.Equal(t, 5, result.Entries)
+			assert.Equal(t, int64(1), result.FirstSeq)
+			assert.Equal(t, int64(5), result.LastSeq)
+			assert.False(t, result.Truncated)
+
+			// Reopening the log continues the chain
+			l, err = Open(config.AuditLogConfig{Path: l.Path(), Format: format}, nil)
+			require.NoError(t, err)
+			l.ModelCall("conv", "fake:tiny", aisdk.Usage{})
+			require.NoError(t, l.Close())
+
+			result, err = Verify(l.Path())
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), result.LastSeq)
+		})
+	}
+}
+
+func TestLogDigestsLongArguments(t *testing.T) {
+	l := openTestLog(t, config.AuditLogConfig{})
+	writeEntries(l)
+
+	data, err := os.ReadFile(l.Path())
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), strings.Repeat("x", 2000))
+	assert.Contains(t, string(data), "(2000 bytes)")
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+		line   int
+	}{
+		{"edited entry", func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], "/tmp/a.txt", "/tmp/b.txt", 1)
+			return lines
+		}, 2},
+		{"removed entry", func(lines []string) []string {
+			return append(lines[:2], lines[3:]...)
+		}, 3},
+		{"reordered entries", func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}, 2},
+		{"removed last entry", func(lines []string) []string {
+			return lines[:len(lines)-1]
+		}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := openTestLog(t, config.AuditLogConfig{})
+			writeEntries(l)
+			require.NoError(t, l.Close())
+
+			data, err := os.ReadFile(l.Path())
+			require.NoError(t, err)
+			lines := tt.tamper(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+			require.NoError(t, os.WriteFile(l.Path(), []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+			_, err = Verify(l.Path())
+			var verifyErr *VerifyError
+			require.ErrorAs(t, err, &verifyErr)
+			assert.Equal(t, tt.line, verifyErr.Line)
+
+			// The log refuses to continue a chain whose end was removed
+			if tt.line == 0 {
+				_, err := Open(config.AuditLogConfig{Path: l.Path()}, nil)
+				assert.ErrorContains(t, err, "gofer audit verify")
+			}
+		})
+	}
+}
+
+func TestLogRotates(t *testing.T) {
+	l := openTestLog(t, config.AuditLogConfig{MaxSize: 1024, MaxBackups: 2})
+	for i := 0; i < 20; i++ {
+		l.Command("", "call", "echo hello", "/tmp", 0, false, StatusOK)
+	}
+	require.NoError(t, l.Close())
+
+	assert.FileExists(t, l.Path()+".1")
+	assert.FileExists(t, l.Path()+".2")
+	assert.NoFileExists(t, l.Path()+".3")
+
+	// The chain goes on across the backups left, with a rotation entry
+	// starting each log
+	result, err := Verify(l.Path())
+	require.NoError(t, err)
+	assert.Len(t, result.Files, 3)
+	assert.True(t, result.Truncated)
+	assert.Equal(t, int(result.LastSeq-result.FirstSeq+1), result.Entries)
+	first, err := firstEntry(l.Path())
+	require.NoError(t, err)
+	assert.Equal(t, TypeRotation, first.Type)
+
+	// Removing the oldest backup, or entries from its start, is noticed
+	oldest := l.Path() + ".2"
+	data, err := os.ReadFile(oldest)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(data), "\n")
+	require.NoError(t, os.WriteFile(oldest, []byte(strings.Join(lines[1:], "")), 0o600))
+	_, err = Verify(l.Path())
+	assert.ErrorContains(t, err, "older entries were removed")
+
+	require.NoError(t, os.Remove(oldest))
+	_, err = Verify(l.Path())
+	assert.ErrorContains(t, err, "older entries were removed")
+}
+
+func TestLogRotatesText(t *testing.T) {
+	l := openTestLog(t, config.AuditLogConfig{Format: FormatText, MaxSize: 512, MaxBackups: 1})
+	for i := 0; i < 10; i++ {
+		l.Command("", "call", "echo hello", "/tmp", 0, false, StatusOK)
+	}
+	require.NoError(t, l.Close())
+
+	result, err := Verify(l.Path())
+	require.NoError(t, err)
+	assert.True(t, result.Truncated)
+
+	require.NoError(t, os.Remove(l.Path()+".1"))
+	_, err = Verify(l.Path())
+	assert.ErrorContains(t, err, "older entries were removed")
+}
+
+func TestOpenMovesHeadPastCrash(t *testing.T) {
+	l := openTestLog(t, config.AuditLogConfig{})
+	writeEntries(l)
+	require.NoError(t, l.Close())
+
+	// A run stopped after writing the last entry, before its head
+	data, err := os.ReadFile(l.Path())
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	previous, err := parseLine(lines[len(lines)-2])
+	require.NoError(t, err)
+	require.NoError(t, writeHead(l.Path(), previous))
+
+	result, err := Verify(l.Path())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), result.LastSeq)
+
+	l, err = Open(config.AuditLogConfig{Path: l.Path()}, nil)
+	require.NoError(t, err)
+	l.ModelCall("conv", "fake:tiny", aisdk.Usage{})
+	require.NoError(t, l.Close())
+
+	result, err = Verify(l.Path())
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), result.LastSeq)
+}
+
+func TestMiddlewareRecordsChanges(t *testing.T) {
+	l := openTestLog(t, config.AuditLogConfig{})
+	path := filepath.Join(t.TempDir(), "file.txt")
+
+	write := func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+		return aisdk.NewTextToolResponse("ok"), os.WriteFile(path, []byte("content"), 0o600)
+	}
+	args, _ := json.Marshal(map[string]string{"path": path, "content": "content"})
+	call := &aisdk.ToolCall{ID: "call_1"}
+	call.Function.Name = "write_file"
+	call.Function.Arguments = args
+
+	conversationID := func(ctx context.Context) string { return "conv_1" }
+	_, err := l.Middleware(conversationID)(write)(context.Background(), call)
+	require.NoError(t, err)
+	// Writing the same content again changes nothing
+	_, err = l.Middleware(conversationID)(write)(context.Background(), call)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	data, err := os.ReadFile(l.Path())
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+
+	var entry struct {
+		Type           string            `json:"type"`
+		ConversationID string            `json:"conversation_id"`
+		Fields         map[string]string `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, TypeFileChange, entry.Type)
+	assert.Equal(t, "conv_1", entry.ConversationID)
+	assert.Equal(t, path, entry.Fields["path"])
+	assert.Equal(t, digestAbsent, entry.Fields["before"])
+	assert.Equal(t, digest([]byte("content")), entry.Fields["after"])
+}
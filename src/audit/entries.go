@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+// Tool call statuses
+const (
+	StatusOK        = "ok"
+	StatusError     = "error"
+	StatusDenied    = "denied"
+	StatusInvalid   = "invalid"
+	StatusCancelled = "cancelled"
+)
+
+// maxArgumentLength is the length past which string arguments are logged
+// as their digest, so file contents don't fill the log
+const maxArgumentLength = 1024
+
+// ToolCall records a tool call with its arguments and the digest of its
+// result
+func (l *Log) ToolCall(conversationID string, call aisdk.ToolCall, status, result string, duration time.Duration) {
+	if l == nil {
+		return
+	}
+	l.Record(TypeToolCall, conversationID, map[string]any{
+		"tool":          call.Function.Name,
+		"call_id":       call.ID,
+		"arguments":     digestArguments(call.Function.Arguments),
+		"status":        status,
+		"result_digest": digest([]byte(result)),
+		"result_bytes":  len(result),
+		"duration_ms":   duration.Milliseconds(),
+	})
+}
+
+// Permission records a permission decision about a tool call
+func (l *Log) Permission(conversationID, tool, callID, decision, reason string, prompted bool) {
+	if l == nil {
+		return
+	}
+	l.Record(TypePermission, conversationID, map[string]any{
+		"tool":     tool,
+		"call_id":  callID,
+		"decision": decision,
+		"reason":   reason,
+		"prompted": prompted,
+	})
+}
+
+// FileChange records a change of a file by a tool call. Before and after
+// are the digests of fileDigest.
+func (l *Log) FileChange(conversationID, tool, callID, path, before, after string) {
+	if l == nil {
+		return
+	}
+	l.Record(TypeFileChange, conversationID, map[string]any{
+		"tool":    tool,
+		"call_id": callID,
+		"path":    path,
+		"before":  before,
+		"after":   after,
+	})
+}
+
+// Command records a command run by a tool call
+func (l *Log) Command(conversationID, callID, command, workingDir string, exitCode int, timedOut bool, status string) {
+	if l == nil {
+		return
+	}
+	l.Record(TypeCommand, conversationID, map[string]any{
+		"call_id":     callID,
+		"command":     command,
+		"working_dir": workingDir,
+		"exit_code":   exitCode,
+		"timed_out":   timedOut,
+		"status":      status,
+	})
+}
+
+// ModelCall records a model call with its token usage
+func (l *Log) ModelCall(conversationID, model string, usage aisdk.Usage) {
+	if l == nil {
+		return
+	}
+	l.Record(TypeModelCall, conversationID, map[string]any{
+		"model":              model,
+		"prompt_tokens":      usage.PromptTokens,
+		"completion_tokens":  usage.CompletionTokens,
+		"cached_tokens":      usage.PromptTokensCached,
+		"cache_write_tokens": usage.PromptTokensCacheWrite,
+	})
+}
+
+// digest returns the SHA-256 digest of data as "sha256:<hex>"
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// digestArguments decodes the arguments of a call, replacing long strings
+// with their digest. Arguments that are not valid JSON are logged as a
+// string.
+func digestArguments(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	var args any
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return digestValue(string(raw))
+	}
+	return digestValue(args)
+}
+
+func digestValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		if len(v) > maxArgumentLength {
+			return fmt.Sprintf("%s (%d bytes)", digest([]byte(v)), len(v))
+		}
+		return v
+	case map[string]any:
+		for key, item := range v {
+			v[key] = digestValue(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = digestValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/goferagent/tools"
+)
+
+// Digests of paths that are not regular files
+const (
+	digestAbsent    = "absent"
+	digestDirectory = "directory"
+)
+
+// Middleware records the files tool calls change, with their digests
+// before and after the call, and the commands of run_command calls. The
+// entries are recorded for the conversation conversationID returns for the
+// context of the call, which may be nil.
+func (l *Log) Middleware(conversationID func(ctx context.Context) string) agent.ToolMiddleware {
+	return func(next agent.ToolExecutor) agent.ToolExecutor {
+		if l == nil {
+			return next
+		}
+		return func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+			name := call.Function.Name
+			var conversation string
+			if conversationID != nil {
+				conversation = conversationID(ctx)
+			}
+			paths := mutatedPaths(call)
+			before := make([]string, len(paths))
+			for i, path := range paths {
+				before[i] = fileDigest(path)
+			}
+
+			result, err := next(ctx, call)
+
+			for i, path := range paths {
+				if after := fileDigest(path); after != before[i] {
+					l.FileChange(conversation, name, call.ID, path, before[i], after)
+				}
+			}
+			if name == tools.RunCommandName {
+				l.command(conversation, call, result, err)
+			}
+			return result, err
+		}
+	}
+}
+
+// command records the command of a run_command call
+func (l *Log) command(conversationID string, call *aisdk.ToolCall, result *aisdk.ToolResponse, err error) {
+	var input struct {
+		Command    string `json:"command"`
+		WorkingDir string `json:"working_dir"`
+	}
+	json.Unmarshal(call.Function.Arguments, &input)
+
+	var output struct {
+		ExitCode   int    `json:"exit_code"`
+		WorkingDir string `json:"working_dir"`
+		Timeout    bool   `json:"timeout"`
+	}
+	status := StatusOK
+	if err != nil || result == nil || result.IsError {
+		status = StatusError
+	} else if json.Unmarshal(result.Content, &output) == nil && output.WorkingDir != "" {
+		input.WorkingDir = output.WorkingDir
+	}
+	l.Command(conversationID, call.ID, input.Command, input.WorkingDir, output.ExitCode, output.Timeout, status)
+}
+
+// mutatedPaths returns the absolute paths a call may change
+func mutatedPaths(call *aisdk.ToolCall) []string {
+	var args map[string]interface{}
+	if json.Unmarshal(call.Function.Arguments, &args) != nil {
+		return nil
+	}
+
+	_, paths := tools.FilePaths(call.Function.Name, args)
+	var abs []string
+	for _, path := range paths {
+		if p, err := filepath.Abs(path); err == nil {
+			abs = append(abs, p)
+		}
+	}
+	return abs
+}
+
+// fileDigest returns the digest of the file at a path, or says that there
+// is no file or that it is a directory
+func fileDigest(path string) string {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return digestAbsent
+	}
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	if info.IsDir() {
+		return digestDirectory
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "error: " + err.Error()
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
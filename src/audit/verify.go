@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// VerifyError is an entry that breaks the chain of a log
+type VerifyError struct {
+	File   string
+	Line   int   // 0 when the error is not about a line
+	Seq    int64 // The entry expected at the line
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Reason)
+	}
+	return fmt.Sprintf("%s:%d: entry %d: %s", e.File, e.Line, e.Seq, e.Reason)
+}
+
+// VerifyResult describes a verified log
+type VerifyResult struct {
+	Files    []string // Oldest first
+	Entries  int
+	FirstSeq int64
+	LastSeq  int64
+
+	// Truncated is set when the oldest entries were rotated out, so the
+	// chain starts after the first entry
+	Truncated bool
+
+	// keptSeq is the first entry the last rotation entry says the
+	// backups hold
+	keptSeq int64
+}
+
+// Verify checks the chain of a log and its backups: that every entry
+// matches its hash and follows the entry before it, that the chain starts
+// at its first entry or where the last rotation entry says it does, and
+// that the log ends at its head, or one entry past it when a run stopped
+// before writing the head. The error is a *VerifyError for a broken chain.
+func Verify(path string) (*VerifyResult, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	backups := backupPaths(path)
+	result := &VerifyResult{}
+	for i := len(backups) - 1; i >= 0; i-- {
+		result.Files = append(result.Files, backups[i])
+	}
+	if fileExists(path) {
+		result.Files = append(result.Files, path)
+	}
+	if len(result.Files) == 0 {
+		return nil, fmt.Errorf("audit log %s does not exist", path)
+	}
+
+	var last *Entry
+	result.keptSeq = 1
+	for _, file := range result.Files {
+		if err := verifyFile(file, result, &last); err != nil {
+			return result, err
+		}
+	}
+	if last != nil && result.FirstSeq != result.keptSeq {
+		return result, &VerifyError{File: result.Files[0], Reason: fmt.Sprintf("log starts at entry %d, but the last rotation kept the entries from %d; older entries were removed", result.FirstSeq, result.keptSeq)}
+	}
+
+	h, err := readHead(path)
+	if err != nil {
+		return result, err
+	}
+	switch {
+	case h == nil && last != nil:
+		return result, &VerifyError{File: headPath(path), Reason: "head file is missing"}
+	case h != nil && last == nil:
+		return result, &VerifyError{File: path, Reason: fmt.Sprintf("log is empty, but its head is at entry %d", h.Seq)}
+	case h != nil && !h.at(last) && !h.before(last):
+		return result, &VerifyError{File: path, Reason: fmt.Sprintf("log ends at entry %d, but its head is at entry %d; entries were removed from its end", last.Seq, h.Seq)}
+	}
+	return result, nil
+}
+
+// verifyFile checks the entries of a file, following the last entry of the
+// files before it
+func verifyFile(file string, result *VerifyResult, last **Entry) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if text == "" {
+			continue
+		}
+		seq := int64(1)
+		if *last != nil {
+			seq = (*last).Seq + 1
+		}
+		fail := func(reason string) error {
+			return &VerifyError{File: file, Line: line, Seq: seq, Reason: reason}
+		}
+
+		entry, err := parseLine(text)
+		if err != nil {
+			return fail(err.Error())
+		}
+		if *last == nil {
+			// The first entry starts the chain, or follows entries that
+			// were rotated out
+			if entry.Seq == 1 && entry.PrevHash != genesisHash {
+				return fail("first entry does not start the chain")
+			}
+			result.FirstSeq = entry.Seq
+			result.Truncated = entry.Seq != 1
+		} else {
+			if entry.Seq != seq {
+				return fail(fmt.Sprintf("found entry %d", entry.Seq))
+			}
+			if entry.PrevHash != (*last).Hash {
+				return fail("previous hash does not match the entry before it")
+			}
+		}
+		if entry.Type == TypeRotation {
+			kept, ok := rotationFirstSeq(entry)
+			if !ok || kept > entry.Seq {
+				return fail("rotation entry has no valid first entry")
+			}
+			result.keptSeq = kept
+		}
+		*last = entry
+		result.Entries++
+		result.LastSeq = entry.Seq
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return nil
+}
+
+// String summarizes the result
+func (r *VerifyResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d entries in %d files", r.Entries, len(r.Files))
+	if r.Entries > 0 {
+		fmt.Fprintf(&b, ", entries %d to %d", r.FirstSeq, r.LastSeq)
+	}
+	if r.Truncated {
+		b.WriteString("; older entries were rotated out")
+	}
+	return b.String()
+}
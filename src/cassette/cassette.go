@@ -0,0 +1,141 @@
+// Package cassette records model interactions to a JSONL file and replays
+// them, so agent runs can be reproduced without network access or an API key.
+//
+// The first line of a cassette holds the model info; every following line is
+// one request with its response or error.
+package cassette
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+// Entry is a single line of a cassette file
+type Entry struct {
+	ModelInfo *aisdk.ModelInfo              `json:"model_info,omitempty"`
+	Request   *aisdk.ChatCompletionRequest  `json:"request,omitempty"`
+	Response  *aisdk.ChatCompletionResponse `json:"response,omitempty"`
+	Error     string                        `json:"error,omitempty"`
+}
+
+// NormalizeRequest returns a copy of the request with the fields that vary
+// between otherwise identical runs cleared: streaming options, message
+// timestamps, tool order and the content of system messages, which embeds the
+// date and working directory.
+func NormalizeRequest(req *aisdk.ChatCompletionRequest) *aisdk.ChatCompletionRequest {
+	out := *req
+	out.Stream = false
+	out.StreamOptions = nil
+	out.Tools = append([]*aisdk.ChatTool(nil), req.Tools...)
+	sort.SliceStable(out.Tools, func(i, j int) bool {
+		return toolName(out.Tools[i]) < toolName(out.Tools[j])
+	})
+	out.Messages = make([]*aisdk.Message, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		if msg == nil {
+			continue
+		}
+		m := *msg
+		m.CreatedAt = time.Time{}
+		if m.Role == "system" {
+			m.Content = ""
+			m.MultimodalContent = nil
+		}
+		out.Messages = append(out.Messages, &m)
+	}
+	return &out
+}
+
+// toolName returns the name of a tool, tolerating nil entries
+func toolName(t *aisdk.ChatTool) string {
+	if t == nil {
+		return ""
+	}
+	return t.Function.Name
+}
+
+// requestKey returns the key requests are matched by
+func requestKey(req *aisdk.ChatCompletionRequest) (string, error) {
+	data, err := json.Marshal(NormalizeRequest(req))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+	return string(data), nil
+}
+
+// Load reads all entries from a cassette file
+func Load(path string) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cassette: %w", err)
+	}
+	defer f.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s line %d: %w", path, line, err)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	return entries, nil
+}
+
+// responseChunks splits a response into the stream chunks a streaming
+// handler would have seen, so recorded responses can be replayed as streams.
+func responseChunks(resp *aisdk.ChatCompletionResponse) []*aisdk.StreamChunk {
+	var chunks []*aisdk.StreamChunk
+	for i, choice := range resp.Choices {
+		delta := aisdk.StreamDelta{
+			Role:    choice.Message.Role,
+			Content: choice.Message.Content,
+		}
+		for j, tc := range choice.Message.ToolCalls {
+			delta.ToolCalls = append(delta.ToolCalls, aisdk.ToolCallDelta{
+				Index: j,
+				ID:    tc.ID,
+				Type:  tc.Type,
+				Function: aisdk.FunctionCallDelta{
+					Name:      tc.Function.Name,
+					Arguments: string(tc.Function.Arguments),
+				},
+			})
+		}
+		chunks = append(chunks, &aisdk.StreamChunk{
+			ID:      resp.ID,
+			Object:  "chat.completion.chunk",
+			Created: resp.Created,
+			Model:   resp.Model,
+			Choices: []aisdk.StreamChoice{{
+				Index:        i,
+				Delta:        delta,
+				FinishReason: choice.FinishReason,
+			}},
+		})
+	}
+
+	usage := resp.Usage
+	chunks = append(chunks, &aisdk.StreamChunk{
+		ID:      resp.ID,
+		Object:  "chat.completion.chunk",
+		Created: resp.Created,
+		Model:   resp.Model,
+		Usage:   &usage,
+	})
+	return chunks
+}
@@ -0,0 +1,146 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jsonschema "github.com/swaggest/jsonschema-go"
+)
+
+// scriptedModel returns its responses in order
+type scriptedModel struct {
+	responses []*aisdk.ChatCompletionResponse
+	calls     int
+}
+
+func (m *scriptedModel) CreateChatCompletion(ctx context.Context, req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionResponse, error) {
+	if m.calls >= len(m.responses) {
+		return nil, errors.New("rate limited")
+	}
+	resp := m.responses[m.calls]
+	m.calls++
+	return resp, nil
+}
+
+func (m *scriptedModel) GetModelInfo() *aisdk.ModelInfo {
+	return &aisdk.ModelInfo{ID: "test/model", ContextLength: 1000}
+}
+
+func testTools(t *testing.T) []*aisdk.ChatTool {
+	var reflector jsonschema.Reflector
+	schema, err := reflector.Reflect(struct {
+		Path string `json:"path" required:"true" description:"File to read"`
+	}{})
+	require.NoError(t, err)
+	return []*aisdk.ChatTool{{Type: "function", Function: aisdk.ChatToolFunction{Name: "read_file", Parameters: &schema}}}
+}
+
+// conversation builds the requests of a two step tool using run
+func conversation(t *testing.T, systemPrompt string, createdAt time.Time) (*aisdk.ChatCompletionRequest, *aisdk.ChatCompletionRequest) {
+	first := &aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{
+			{Role: "system", Content: systemPrompt, CreatedAt: createdAt},
+			{Role: "user", Content: "read a.txt", CreatedAt: createdAt},
+		},
+		Tools: testTools(t),
+	}
+	second := &aisdk.ChatCompletionRequest{
+		Messages: append(append([]*aisdk.Message{}, first.Messages...),
+			&aisdk.Message{Role: "assistant", ToolCalls: []aisdk.ToolCall{
+				{ID: "call_1", Type: "function", Function: aisdk.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"path": "a.txt"}`)}},
+			}},
+			&aisdk.Message{Role: "tool", Name: "read_file", ToolCallID: "call_1", Content: "hello"},
+		),
+		Tools: testTools(t),
+	}
+	return first, second
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	ctx := context.Background()
+
+	model := &scriptedModel{responses: []*aisdk.ChatCompletionResponse{
+		{ID: "r1", Choices: []aisdk.Choice{{Message: aisdk.Message{Role: "assistant", ToolCalls: []aisdk.ToolCall{
+			{ID: "call_1", Type: "function", Function: aisdk.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"path":"a.txt"}`)}},
+		}}, FinishReason: "tool_calls"}}},
+		{ID: "r2", Choices: []aisdk.Choice{{Message: aisdk.Message{Role: "assistant", Content: "a.txt says hello"}, FinishReason: "stop"}}, Usage: aisdk.Usage{TotalTokens: 42}},
+	}}
+
+	recorder, err := NewRecorder(model, path)
+	require.NoError(t, err)
+	first, second := conversation(t, "Today's date: 2025-01-01", time.Now())
+	_, err = recorder.CreateChatCompletion(ctx, first)
+	require.NoError(t, err)
+	_, err = recorder.CreateChatCompletion(ctx, second)
+	require.NoError(t, err)
+	_, err = recorder.CreateChatCompletion(ctx, second)
+	require.Error(t, err)
+	require.NoError(t, recorder.Close())
+
+	replayer, err := NewReplayer(path)
+	require.NoError(t, err)
+	assert.Equal(t, "test/model", replayer.GetModelInfo().ID)
+	assert.Equal(t, 3, replayer.Remaining())
+
+	// The system prompt and timestamps differ, which must not affect matching
+	first, second = conversation(t, "Today's date: 2025-06-30", time.Now().Add(time.Hour))
+	resp, err := replayer.CreateChatCompletion(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, "r1", resp.ID)
+	assert.Equal(t, "read_file", resp.Choices[0].Message.ToolCalls[0].Function.Name)
+
+	var streamed string
+	resp, err = replayer.CreateChatCompletionStream(ctx, second, func(chunk *aisdk.StreamChunk) error {
+		for _, choice := range chunk.Choices {
+			streamed += choice.Delta.Content
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt says hello", streamed)
+	assert.Equal(t, 42, resp.Usage.TotalTokens)
+
+	// Recorded errors are replayed
+	_, err = replayer.CreateChatCompletion(ctx, second)
+	assert.EqualError(t, err, "rate limited")
+
+	// Nothing is left to serve
+	_, err = replayer.CreateChatCompletion(ctx, first)
+	var mismatch *MismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, 3, mismatch.Index)
+	assert.Contains(t, mismatch.Reason, "have been used")
+}
+
+func TestReplayMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	ctx := context.Background()
+
+	recorder, err := NewRecorder(&scriptedModel{responses: []*aisdk.ChatCompletionResponse{{ID: "r1"}}}, path)
+	require.NoError(t, err)
+	first, _ := conversation(t, "system", time.Now())
+	_, err = recorder.CreateChatCompletion(ctx, first)
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+
+	replayer, err := NewReplayer(path)
+	require.NoError(t, err)
+
+	changed, _ := conversation(t, "system", time.Now())
+	changed.Messages[1].Content = "read b.txt"
+	_, err = replayer.CreateChatCompletion(ctx, changed)
+
+	var mismatch *MismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, 0, mismatch.Index)
+	assert.Contains(t, mismatch.Reason, "message 1 (user)")
+	assert.Contains(t, mismatch.Error(), "read b.txt")
+}
@@ -0,0 +1,140 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+var (
+	_ aisdk.ModelClient          = (*Recorder)(nil)
+	_ aisdk.StreamingModelClient = (*Recorder)(nil)
+)
+
+// Recorder wraps a ModelClient and writes every request and its response to
+// a cassette file.
+type Recorder struct {
+	model aisdk.ModelClient
+	path  string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRecorder creates a cassette at path, replacing any existing file, and
+// records the interactions of model into it.
+func NewRecorder(model aisdk.ModelClient, path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cassette: %w", err)
+	}
+
+	r := &Recorder{model: model, path: path, file: file}
+	if err := r.write(&Entry{ModelInfo: model.GetModelInfo()}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// CreateChatCompletion implements aisdk.ModelClient
+func (r *Recorder) CreateChatCompletion(ctx context.Context, req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionResponse, error) {
+	recorded, err := r.snapshot(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.model.CreateChatCompletion(ctx, req)
+	return resp, r.record(recorded, resp, err)
+}
+
+// CreateChatCompletionStream implements aisdk.StreamingModelClient. If the
+// wrapped client cannot stream, the full response is delivered as chunks.
+func (r *Recorder) CreateChatCompletionStream(ctx context.Context, req *aisdk.ChatCompletionRequest, handler aisdk.StreamHandler) (*aisdk.ChatCompletionResponse, error) {
+	recorded, err := r.snapshot(req)
+	if err != nil {
+		return nil, err
+	}
+
+	streamer, ok := r.model.(aisdk.StreamingModelClient)
+	if ok {
+		resp, err := streamer.CreateChatCompletionStream(ctx, req, handler)
+		return resp, r.record(recorded, resp, err)
+	}
+
+	resp, err := r.model.CreateChatCompletion(ctx, req)
+	if err == nil {
+		for _, chunk := range responseChunks(resp) {
+			if err = handler(chunk); err != nil {
+				break
+			}
+		}
+	}
+	return resp, r.record(recorded, resp, err)
+}
+
+// GetModelInfo implements aisdk.ModelClient
+func (r *Recorder) GetModelInfo() *aisdk.ModelInfo {
+	return r.model.GetModelInfo()
+}
+
+// ProviderName implements aisdk.NamedModelClient
+func (r *Recorder) ProviderName() string {
+	return aisdk.ProviderName(r.model)
+}
+
+// Close closes the cassette file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// snapshot copies the request as the caller sent it, before the wrapped client
+// adapts it, so replay sees exactly the same request.
+func (r *Recorder) snapshot(req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionRequest, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	var recorded aisdk.ChatCompletionRequest
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("failed to decode request: %w", err)
+	}
+	if info := r.model.GetModelInfo(); info != nil {
+		recorded.Model = info.ID
+	}
+	return &recorded, nil
+}
+
+// record writes an interaction and returns the original error, or the write
+// error if the interaction could not be saved.
+func (r *Recorder) record(req *aisdk.ChatCompletionRequest, resp *aisdk.ChatCompletionResponse, callErr error) error {
+	entry := &Entry{Request: req, Response: resp}
+	if callErr != nil {
+		entry.Response = nil
+		entry.Error = callErr.Error()
+	}
+	if err := r.write(entry); err != nil && callErr == nil {
+		return err
+	}
+	return callErr
+}
+
+// write appends an entry to the cassette
+func (r *Recorder) write(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette entry: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write cassette %s: %w", r.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+var (
+	_ aisdk.ModelClient          = (*Replayer)(nil)
+	_ aisdk.StreamingModelClient = (*Replayer)(nil)
+)
+
+// MismatchError is returned when a replayed request matches no unused recording
+type MismatchError struct {
+	Path   string
+	Index  int    // zero based index of the request in this run
+	Reason string // how the request differs from the next unused recording
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("cassette %s: request %d does not match any recorded request: %s", e.Path, e.Index, e.Reason)
+}
+
+// interaction is a recorded request with its normalized key
+type interaction struct {
+	entry *Entry
+	key   string
+	used  bool
+}
+
+// Replayer is a ModelClient that serves responses from a cassette. Each
+// recording is served at most once, in recorded order among equal requests.
+type Replayer struct {
+	path string
+	info *aisdk.ModelInfo
+
+	mu           sync.Mutex
+	interactions []*interaction
+	served       int
+}
+
+// NewReplayer loads the cassette at path
+func NewReplayer(path string) (*Replayer, error) {
+	entries, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Replayer{path: path}
+	for _, entry := range entries {
+		if entry.ModelInfo != nil {
+			r.info = entry.ModelInfo
+		}
+		if entry.Request == nil {
+			continue
+		}
+		key, err := requestKey(entry.Request)
+		if err != nil {
+			return nil, err
+		}
+		r.interactions = append(r.interactions, &interaction{entry: entry, key: key})
+	}
+	if r.info == nil {
+		return nil, fmt.Errorf("cassette %s has no model info", path)
+	}
+	return r, nil
+}
+
+// CreateChatCompletion implements aisdk.ModelClient
+func (r *Replayer) CreateChatCompletion(ctx context.Context, req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionResponse, error) {
+	entry, err := r.match(req)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Error != "" {
+		return nil, errors.New(entry.Error)
+	}
+	return entry.Response, nil
+}
+
+// CreateChatCompletionStream implements aisdk.StreamingModelClient by
+// delivering the recorded response as stream chunks.
+func (r *Replayer) CreateChatCompletionStream(ctx context.Context, req *aisdk.ChatCompletionRequest, handler aisdk.StreamHandler) (*aisdk.ChatCompletionResponse, error) {
+	resp, err := r.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range responseChunks(resp) {
+		if err := handler(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// GetModelInfo returns the model info recorded in the cassette
+func (r *Replayer) GetModelInfo() *aisdk.ModelInfo {
+	return r.info
+}
+
+// Remaining returns the number of recordings that have not been served
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, it := range r.interactions {
+		if !it.used {
+			n++
+		}
+	}
+	return n
+}
+
+// match finds the first unused recording for the request
+func (r *Replayer) match(req *aisdk.ChatCompletionRequest) (*Entry, error) {
+	withModel := *req
+	withModel.Model = r.info.ID
+	key, err := requestKey(&withModel)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	index := r.served
+	r.served++
+
+	var next *interaction
+	for _, it := range r.interactions {
+		if it.used {
+			continue
+		}
+		if it.key == key {
+			it.used = true
+			return it.entry, nil
+		}
+		if next == nil {
+			next = it
+		}
+	}
+
+	reason := fmt.Sprintf("all %d recorded requests have been used", len(r.interactions))
+	if len(r.interactions) == 0 {
+		reason = "the cassette has no recorded requests"
+	} else if next != nil {
+		reason = describeMismatch(next.entry.Request, &withModel)
+	}
+	return nil, &MismatchError{Path: r.path, Index: index, Reason: reason}
+}
+
+// describeMismatch explains the first difference between a recorded request and an actual one
+func describeMismatch(recorded, actual *aisdk.ChatCompletionRequest) string {
+	want := NormalizeRequest(recorded)
+	got := NormalizeRequest(actual)
+
+	if want.Model != got.Model {
+		return fmt.Sprintf("model is %q, recorded %q", got.Model, want.Model)
+	}
+	for i := 0; i < len(want.Messages) && i < len(got.Messages); i++ {
+		w, g := encode(want.Messages[i]), encode(got.Messages[i])
+		if w != g {
+			at := firstDifference(w, g)
+			return fmt.Sprintf("message %d (%s) has %s, recorded %s", i, got.Messages[i].Role, excerpt(g, at), excerpt(w, at))
+		}
+	}
+	if len(want.Messages) != len(got.Messages) {
+		return fmt.Sprintf("request has %d messages, recorded %d", len(got.Messages), len(want.Messages))
+	}
+	if len(want.Tools) != len(got.Tools) {
+		return fmt.Sprintf("request has %d tools, recorded %d", len(got.Tools), len(want.Tools))
+	}
+	for i := range want.Tools {
+		if encode(want.Tools[i]) != encode(got.Tools[i]) {
+			return fmt.Sprintf("tool %s differs from the recorded %s", toolName(got.Tools[i]), toolName(want.Tools[i]))
+		}
+	}
+	return "request options differ from the next recording"
+}
+
+// encode returns v as JSON without HTML escaping, for comparison and error messages
+func encode(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// firstDifference returns the byte offset where a and b first differ
+func firstDifference(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// excerpt returns the part of s around offset, for error messages
+func excerpt(s string, offset int) string {
+	const context = 60
+	start, end := offset-context, offset+context
+	prefix, suffix := "...", "..."
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(s) {
+		end, suffix = len(s), ""
+	}
+	return "`" + prefix + s[start:end] + suffix + "`"
+}
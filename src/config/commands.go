@@ -0,0 +1,520 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/elee1766/gofer/src/shellparse"
+)
+
+// Command policy decisions
+const (
+	CommandAllow   = "allow"
+	CommandConfirm = "confirm"
+	CommandDeny    = "deny"
+)
+
+// maxCommandDepth limits how deeply commands run by other commands, like
+// bash -c scripts, are followed
+const maxCommandDepth = 8
+
+// shellCommands run a script given with -c, or read one from their input
+var shellCommands = map[string]bool{
+	"sh": true, "bash": true, "dash": true, "zsh": true, "ksh": true, "fish": true,
+}
+
+// wrapperCommands run the command given as their arguments. The values are
+// the options that take a separate value, and "#" for each positional
+// argument before the command.
+var wrapperCommands = map[string][]string{
+	"sudo":    {"-u", "-g", "-C", "-D", "-h", "-p", "-r", "-t", "-U"},
+	"doas":    {"-u", "-C"},
+	"env":     {"-u", "-C", "-S"},
+	"nice":    {"-n"},
+	"nohup":   nil,
+	"time":    {"-f", "-o"},
+	"timeout": {"-s", "-k", "#"},
+	"xargs":   {"-I", "-n", "-P", "-d", "-E", "-L", "-s", "-a"},
+	"exec":    {"-a"},
+	"command": nil,
+	"builtin": nil,
+	"stdbuf":  {"-i", "-o", "-e"},
+	"ionice":  {"-c", "-n", "-p"},
+	"setsid":  nil,
+	"chroot":  {"#"},
+}
+
+// findExecActions are the find actions that run a command up to ";" or "+"
+var findExecActions = map[string]bool{"-exec": true, "-execdir": true, "-ok": true, "-okdir": true}
+
+// wordOptionCommands have options that are words after a single dash, like
+// find's -delete, so their options are not split into letters
+var wordOptionCommands = map[string]bool{"find": true, "go": true, "java": true}
+
+// optionAliases are options that mean the same as another option of a
+// command, so rules match either
+var optionAliases = map[string]map[string]string{
+	"rm": {"-R": "-r", "--recursive": "-r", "--force": "-f"},
+}
+
+// writeRedirects are the redirection operators that write their target
+var writeRedirects = map[string]bool{">": true, ">>": true, ">|": true, "&>": true, "&>>": true, "<>": true}
+
+// deviceTargets are the files redirections may write under /dev
+var deviceTargets = map[string]bool{"/dev/null": true, "/dev/stdout": true, "/dev/stderr": true, "/dev/tty": true}
+
+// CommandSegment is a simple command of a command line and the rule that
+// decided it
+type CommandSegment struct {
+	// Text is the simple command, or the command a wrapper like sudo or
+	// bash -c runs
+	Text string
+
+	// Decision is CommandAllow, CommandConfirm or CommandDeny, or "" when
+	// no rule matched
+	Decision string
+
+	// Reason explains the decision, e.g. `matches denied command "dd"`
+	Reason string
+}
+
+// CommandEvaluation is the decision of the command policy about a command
+// line, which is the strictest decision of its segments
+type CommandEvaluation struct {
+	Command string
+
+	// Decision is CommandDeny or CommandConfirm when a segment has that
+	// decision, CommandAllow when every segment is allowed, or "" when
+	// no rule decided
+	Decision string
+
+	Segments []CommandSegment
+
+	// Err is the syntax error of a command line that cannot be parsed,
+	// which is denied
+	Err error
+}
+
+// Explain describes the segments that decided the evaluation
+func (e *CommandEvaluation) Explain() string {
+	if e.Err != nil {
+		return fmt.Sprintf("cannot parse command: %v", e.Err)
+	}
+	var parts []string
+	seen := make(map[string]bool)
+	for _, segment := range e.Segments {
+		if segment.Decision != e.Decision || segment.Reason == "" {
+			continue
+		}
+		part := fmt.Sprintf("%q %s", segment.Text, segment.Reason)
+		if !seen[part] {
+			seen[part] = true
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *CommandEvaluation) add(segment CommandSegment) {
+	e.Segments = append(e.Segments, segment)
+}
+
+// decide sets the decision from the segments
+func (e *CommandEvaluation) decide() {
+	allowed := len(e.Segments) > 0
+	for _, segment := range e.Segments {
+		switch segment.Decision {
+		case CommandDeny:
+			e.Decision = CommandDeny
+			return
+		case CommandConfirm:
+			e.Decision = CommandConfirm
+		case "":
+			allowed = false
+		}
+	}
+	if e.Decision == "" && allowed {
+		e.Decision = CommandAllow
+	}
+}
+
+// EvaluateCommand checks a shell command line against the command
+// permissions. The line is parsed into its simple commands, including those
+// of pipelines, lists, subshells and command substitutions, and the
+// commands run by wrappers like sudo, xargs, find -exec, eval and bash -c.
+// Each is matched against the rules in this order: denied commands and
+// patterns, sudo unless require_sudo is set, confirm commands and patterns,
+// then allowed commands and patterns. When allowed commands or patterns are
+// set, commands matching none of them are denied.
+//
+// A rule matches a command with the same name, compared without its
+// directory, whose operands start with the rule's operands and which has
+// the rule's options in any order, so "git push" matches "git push origin
+// main" but not "git status", and "rm -rf /" matches "rm -r -f /". Commands
+// run by xargs require confirmation when the arguments it adds could
+// complete a denied command. Patterns are regular expressions matched
+// against the text of each simple command; denied and confirm patterns are
+// also matched against the whole line. Commands whose name or script is
+// only known when they run require confirmation. Files that output is
+// redirected to are checked against the file system write permissions.
+func (p *PermissionChecker) EvaluateCommand(command string) *CommandEvaluation {
+	eval := &CommandEvaluation{Command: command}
+	script, err := shellparse.Parse(command)
+	if err != nil {
+		eval.Err = err
+		eval.Decision = CommandDeny
+		return eval
+	}
+
+	// Denied and confirm patterns also match the whole line, for patterns
+	// spanning commands like "curl ... | sh"
+	cfg := p.config.Commands
+	if pattern, ok := matchCommandPattern(command, cfg.DeniedPatterns); ok {
+		eval.add(CommandSegment{Text: command, Decision: CommandDeny, Reason: fmt.Sprintf("matches denied pattern `%s`", pattern)})
+	} else if pattern, ok := matchCommandPattern(command, cfg.ConfirmPatterns); ok {
+		eval.add(CommandSegment{Text: command, Decision: CommandConfirm, Reason: fmt.Sprintf("matches confirm pattern `%s`", pattern)})
+	}
+
+	p.evaluateScript(eval, script, 0)
+	eval.decide()
+	return eval
+}
+
+// CommandTimeout limits the timeout of a command to the configured maximum
+func (p *PermissionChecker) CommandTimeout(timeout time.Duration) time.Duration {
+	if max := p.config.Commands.MaxTimeout; max > 0 && (timeout <= 0 || timeout > max) {
+		return max
+	}
+	return timeout
+}
+
+func (p *PermissionChecker) evaluateScript(eval *CommandEvaluation, script *shellparse.Script, depth int) {
+	script.Walk(func(cmd *shellparse.Command) {
+		p.evaluateRedirects(eval, cmd)
+		p.evaluateWords(eval, cmd.Args, cmd.Text, depth)
+	})
+}
+
+// evaluateRedirects checks the files a command redirects its output to
+// against the file system permissions. Denied files deny the command, and
+// files only known when it runs require confirmation.
+func (p *PermissionChecker) evaluateRedirects(eval *CommandEvaluation, cmd *shellparse.Command) {
+	for _, r := range cmd.Redirects {
+		if !writeRedirects[r.Op] {
+			continue
+		}
+		target := r.Target.Value
+		if !r.Target.Literal {
+			eval.add(CommandSegment{Text: cmd.Text, Decision: CommandConfirm, Reason: "redirects to a file that is only known when it runs"})
+			continue
+		}
+		if deviceTargets[target] || strings.HasPrefix(target, "/dev/fd/") {
+			continue
+		}
+		result, err := p.CheckFileWritePermission(target)
+		if err == nil && !result.Allowed && !result.RequiresConfirmation {
+			eval.add(CommandSegment{Text: cmd.Text, Decision: CommandDeny, Reason: fmt.Sprintf("redirects to %s: %s", target, result.Reason)})
+		}
+	}
+}
+
+// evaluateWords evaluates a simple command, and the commands it runs
+func (p *PermissionChecker) evaluateWords(eval *CommandEvaluation, words []shellparse.Word, text string, depth int) {
+	if depth > maxCommandDepth {
+		eval.add(CommandSegment{Text: text, Decision: CommandConfirm, Reason: "nests commands too deeply to check"})
+		return
+	}
+	if len(words) == 0 {
+		// Assignments and redirections only match patterns
+		if segment := p.evaluateArgs(nil, text); segment.Decision != "" {
+			eval.add(segment)
+		}
+		return
+	}
+	if !words[0].Literal {
+		eval.add(CommandSegment{Text: text, Decision: CommandConfirm, Reason: "has a command name that is only known when it runs"})
+		return
+	}
+
+	args := wordValues(words)
+	eval.add(p.evaluateArgs(args, text))
+
+	name := filepath.Base(args[0])
+	switch {
+	case shellCommands[name]:
+		p.evaluateShell(eval, words, text, depth)
+	case name == "eval":
+		if len(words) == 1 {
+			return
+		}
+		for _, w := range words[1:] {
+			if !w.Literal {
+				eval.add(CommandSegment{Text: text, Decision: CommandConfirm, Reason: "runs a script that is only known when it runs"})
+				return
+			}
+		}
+		p.evaluateNested(eval, strings.Join(args[1:], " "), depth)
+	case name == "find":
+		for i := 1; i < len(words); i++ {
+			if !findExecActions[args[i]] {
+				continue
+			}
+			end := i + 1
+			for end < len(words) && args[end] != ";" && args[end] != "+" {
+				end++
+			}
+			if end > i+1 {
+				p.evaluateWords(eval, words[i+1:end], strings.Join(args[i+1:end], " "), depth+1)
+			}
+			i = end
+		}
+	default:
+		if options, ok := wrapperCommands[name]; ok {
+			if inner := unwrapCommand(words[1:], options); len(inner) > 0 {
+				innerText := strings.Join(wordValues(inner), " ")
+				p.evaluateWords(eval, inner, innerText, depth+1)
+
+				// xargs adds arguments read from its input, which could
+				// complete a denied command
+				if name == "xargs" {
+					if rule, ok := matchOpenCommandRule(wordValues(inner), p.config.Commands.DeniedCommands); ok {
+						eval.add(CommandSegment{Text: innerText, Decision: CommandConfirm, Reason: fmt.Sprintf("could match denied command %q with the arguments xargs adds", rule)})
+					}
+				}
+			}
+		}
+	}
+}
+
+// evaluateShell evaluates the script a shell runs with -c. Shells without
+// a script argument read the script from their input.
+func (p *PermissionChecker) evaluateShell(eval *CommandEvaluation, words []shellparse.Word, text string, depth int) {
+	scriptAt := -1
+	for i := 1; i < len(words); i++ {
+		value := words[i].Value
+		if strings.HasPrefix(value, "-") && !strings.HasPrefix(value, "--") && strings.Contains(value, "c") {
+			scriptAt = i + 1
+			break
+		}
+		if !strings.HasPrefix(value, "-") && !strings.HasPrefix(value, "+") {
+			// A script file, which is not checked
+			return
+		}
+	}
+	if scriptAt < 0 {
+		eval.add(CommandSegment{Text: text, Decision: CommandConfirm, Reason: "runs a script read from its input"})
+		return
+	}
+	if scriptAt >= len(words) {
+		return
+	}
+	if !words[scriptAt].Literal {
+		eval.add(CommandSegment{Text: text, Decision: CommandConfirm, Reason: "runs a script that is only known when it runs"})
+		return
+	}
+	p.evaluateNested(eval, words[scriptAt].Value, depth)
+}
+
+// evaluateNested evaluates a script run by a command
+func (p *PermissionChecker) evaluateNested(eval *CommandEvaluation, source string, depth int) {
+	script, err := shellparse.Parse(source)
+	if err != nil {
+		eval.add(CommandSegment{Text: source, Decision: CommandDeny, Reason: fmt.Sprintf("cannot be parsed: %v", err)})
+		return
+	}
+	p.evaluateScript(eval, script, depth+1)
+}
+
+// evaluateArgs matches a simple command against the rules. args is nil for
+// commands without a name, which only match patterns.
+func (p *PermissionChecker) evaluateArgs(args []string, text string) CommandSegment {
+	cfg := p.config.Commands
+	segment := CommandSegment{Text: text}
+	decide := func(decision, reason string) CommandSegment {
+		segment.Decision = decision
+		segment.Reason = reason
+		return segment
+	}
+
+	if rule, ok := matchCommandRule(args, cfg.DeniedCommands); ok {
+		return decide(CommandDeny, fmt.Sprintf("matches denied command %q", rule))
+	}
+	if pattern, ok := matchCommandPattern(text, cfg.DeniedPatterns); ok {
+		return decide(CommandDeny, fmt.Sprintf("matches denied pattern `%s`", pattern))
+	}
+	if len(args) > 0 && filepath.Base(args[0]) == "sudo" && !cfg.RequireSudo {
+		return decide(CommandDeny, "uses sudo, which is not allowed")
+	}
+	if rule, ok := matchCommandRule(args, cfg.ConfirmCommands); ok {
+		return decide(CommandConfirm, fmt.Sprintf("matches confirm command %q", rule))
+	}
+	if pattern, ok := matchCommandPattern(text, cfg.ConfirmPatterns); ok {
+		return decide(CommandConfirm, fmt.Sprintf("matches confirm pattern `%s`", pattern))
+	}
+	if rule, ok := matchCommandRule(args, cfg.AllowedCommands); ok {
+		return decide(CommandAllow, fmt.Sprintf("matches allowed command %q", rule))
+	}
+	if pattern, ok := matchCommandPattern(text, cfg.AllowedPatterns); ok {
+		return decide(CommandAllow, fmt.Sprintf("matches allowed pattern `%s`", pattern))
+	}
+	if len(args) > 0 && len(cfg.AllowedCommands)+len(cfg.AllowedPatterns) > 0 {
+		return decide(CommandDeny, "is not in the allowed commands")
+	}
+	return segment
+}
+
+// matchCommandRule returns the first rule matching the command. A rule
+// matches when its operands start the operands of the command and the
+// command has each of its options, in any order.
+func matchCommandRule(args []string, rules []string) (string, bool) {
+	for _, rule := range rules {
+		if ruleMatches(args, rule, false) {
+			return rule, true
+		}
+	}
+	return "", false
+}
+
+// matchOpenCommandRule returns the first rule the command could match once
+// more operands are added to it
+func matchOpenCommandRule(args []string, rules []string) (string, bool) {
+	for _, rule := range rules {
+		if ruleMatches(args, rule, true) {
+			return rule, true
+		}
+	}
+	return "", false
+}
+
+// ruleMatches matches a command against a rule. With open, the operands of
+// the command only have to start those of the rule.
+func ruleMatches(args []string, rule string, open bool) bool {
+	fields := strings.Fields(rule)
+	if len(args) == 0 || len(fields) == 0 {
+		return false
+	}
+	if fields[0] != args[0] && fields[0] != filepath.Base(args[0]) {
+		return false
+	}
+	name := filepath.Base(args[0])
+	ruleOptions, ruleOperands := splitOptions(name, fields[1:])
+	options, operands := splitOptions(name, args[1:])
+
+	if len(ruleOperands) > len(operands) {
+		if !open {
+			return false
+		}
+		ruleOperands = ruleOperands[:len(operands)]
+	}
+	for i, operand := range ruleOperands {
+		if operands[i] != operand {
+			return false
+		}
+	}
+	for option := range ruleOptions {
+		if !options[option] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitOptions splits the arguments of a command into its options and its
+// operands. Short options given together, like -rf, are split into -r and
+// -f, and arguments after "--" are operands.
+func splitOptions(name string, args []string) (map[string]bool, []string) {
+	options := make(map[string]bool)
+	var operands []string
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return options, append(operands, args[i+1:]...)
+		case strings.HasPrefix(arg, "--"):
+			options[optionAlias(name, arg)] = true
+		case strings.HasPrefix(arg, "-") && len(arg) > 1 && !wordOptionCommands[name]:
+			for _, c := range arg[1:] {
+				options[optionAlias(name, "-"+string(c))] = true
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			options[arg] = true
+		default:
+			operands = append(operands, arg)
+		}
+	}
+	return options, operands
+}
+
+// optionAlias returns the option an option of a command is an alias of
+func optionAlias(name, option string) string {
+	if alias, ok := optionAliases[name][option]; ok {
+		return alias
+	}
+	return option
+}
+
+// matchCommandPattern returns the first pattern matching the command text.
+// Invalid patterns are rejected when the configuration is validated.
+func matchCommandPattern(text string, patterns []string) (string, bool) {
+	for _, pattern := range patterns {
+		if matched, _ := regexp.MatchString(pattern, text); matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// unwrapCommand returns the command a wrapper runs, skipping the wrapper's
+// options, their values and variable assignments
+func unwrapCommand(words []shellparse.Word, options []string) []shellparse.Word {
+	positional := 0
+	for _, option := range options {
+		if option == "#" {
+			positional++
+		}
+	}
+	for i := 0; i < len(words); i++ {
+		value := words[i].Value
+		switch {
+		case value == "--":
+			continue
+		case strings.HasPrefix(value, "-") && len(value) > 1:
+			for _, option := range options {
+				if value == option {
+					i++
+					break
+				}
+			}
+		case strings.Contains(value, "=") && !strings.HasPrefix(value, "="):
+			// env NAME=value
+		case positional > 0:
+			positional--
+		default:
+			return words[i:]
+		}
+	}
+	return nil
+}
+
+func wordValues(words []shellparse.Word) []string {
+	values := make([]string, len(words))
+	for i, w := range words {
+		values[i] = w.Value
+	}
+	return values
+}
+
+// quoteArgs joins a command and its arguments into a command line, quoting
+// the arguments that need it
+func quoteArgs(command string, args []string) string {
+	parts := []string{command}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`;&|()<>*?[]{}~#!") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
@@ -51,10 +51,14 @@ func DefaultConfig() *Config {
 			Tools: ToolPermissions{
 				Allow: []string{
 					"read_file*",
-					"write_file*",
 					"list_directory*",
-					"execute_command*",
-					"web_search*",
+					"get_file_info*",
+					"search_files*",
+					"grep_files*",
+					"write_file*",
+					"edit_file*",
+					"create_directory*",
+					"run_command*",
 					"web_fetch*",
 				},
 				Deny: []string{
@@ -62,7 +66,7 @@ func DefaultConfig() *Config {
 					"admin_*",
 				},
 				RequireConfirmation: []string{
-					"execute_command*",
+					"run_command*",
 					"write_file*",
 					"delete_*",
 				},
@@ -93,6 +97,7 @@ func DefaultConfig() *Config {
 			Commands: CommandPermissions{
 				DeniedCommands: []string{
 					"rm -rf /",
+					"find / -delete",
 					"format",
 					"fdisk",
 					"dd",
@@ -103,12 +108,36 @@ func DefaultConfig() *Config {
 					"poweroff",
 					"systemctl",
 					"service",
+					"su",
+					"useradd",
+					"userdel",
+					"usermod",
+					"passwd",
 				},
 				DeniedPatterns: []string{
 					`.*\brm\s+-rf\s+/.*`,
 					`.*\bsudo\s+rm.*`,
 					`.*\b(curl|wget).*\|\s*(bash|sh).*`,
 				},
+				ConfirmCommands: []string{
+					"curl",
+					"wget",
+					"ssh",
+					"scp",
+					"rsync",
+					"nc",
+					"netcat",
+					"telnet",
+					"chmod",
+					"chown",
+					"kill",
+					"pkill",
+					"killall",
+					"crontab",
+					"mount",
+					"umount",
+					"git push",
+				},
 				MaxTimeout: 5 * time.Minute,
 				FilterEnvVars: []string{
 					"AWS_SECRET_ACCESS_KEY",
@@ -144,6 +173,16 @@ func DefaultConfig() *Config {
 				MaxBackups: 5,
 				Format:     "json",
 			},
+			Redaction: RedactionConfig{
+				Enabled:          false,
+				Entropy:          false,
+				EntropyThreshold: 4.0,
+				SecretStore: SecretStoreConfig{
+					Enabled: false,
+					Path:    ".gofer/secrets.enc",
+					KeyPath: "~/.local/share/gofer/secrets.key",
+				},
+			},
 			Encryption: EncryptionConfig{
 				EncryptConfig: false,
 				EncryptLogs:   false,
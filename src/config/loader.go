@@ -179,6 +179,37 @@ func (l *Loader) mergeConfigs(base, override *Config) *Config {
 		result.MCPServers = override.MCPServers
 	}
 
+	// Merge compaction settings
+	if override.AutoCompact {
+		result.AutoCompact = true
+	}
+	if override.CompactThreshold != 0 {
+		result.CompactThreshold = override.CompactThreshold
+	}
+
+	// Merge sub-agent settings
+	result.SubAgent = l.mergeSubAgentConfig(result.SubAgent, override.SubAgent)
+
+	// Merge hooks, the hooks of every config file run
+	result.Hooks = HooksConfig{
+		PreToolUse:   mergeHooks(base.Hooks.PreToolUse, override.Hooks.PreToolUse),
+		PostToolUse:  mergeHooks(base.Hooks.PostToolUse, override.Hooks.PostToolUse),
+		PromptSubmit: mergeHooks(base.Hooks.PromptSubmit, override.Hooks.PromptSubmit),
+		Stop:         mergeHooks(base.Hooks.Stop, override.Hooks.Stop),
+	}
+
+	// Merge Providers
+	if len(override.Providers) > 0 {
+		providers := make(map[string]ProviderConfig, len(result.Providers)+len(override.Providers))
+		for k, v := range result.Providers {
+			providers[k] = v
+		}
+		for k, v := range override.Providers {
+			providers[k] = v
+		}
+		result.Providers = providers
+	}
+
 	return &result
 }
 
@@ -204,14 +235,62 @@ func (l *Loader) mergeAgentConfig(base, override AgentConfig) AgentConfig {
 	if override.RetryDelay != 0 {
 		result.RetryDelay = override.RetryDelay
 	}
+	if override.ContextStrategy != "" {
+		result.ContextStrategy = override.ContextStrategy
+	}
+	if override.ContextReserveTokens != 0 {
+		result.ContextReserveTokens = override.ContextReserveTokens
+	}
+	if override.MaxParallelTools != 0 {
+		result.MaxParallelTools = override.MaxParallelTools
+	}
+	if len(override.FallbackModels) > 0 {
+		result.FallbackModels = override.FallbackModels
+	}
 
 	return result
 }
 
+// mergeSubAgentConfig merges sub-agent configurations
+func (l *Loader) mergeSubAgentConfig(base, override SubAgentConfig) SubAgentConfig {
+	result := base
+
+	if override.Enabled {
+		result.Enabled = true
+	}
+	if override.Model != "" {
+		result.Model = override.Model
+	}
+	if len(override.Tools) > 0 {
+		result.Tools = override.Tools
+	}
+	if override.MaxTurns != 0 {
+		result.MaxTurns = override.MaxTurns
+	}
+	if override.SystemPrompt != "" {
+		result.SystemPrompt = override.SystemPrompt
+	}
+
+	return result
+}
+
+// mergeHooks returns the hooks of both configurations, base first
+func mergeHooks(base, override []HookConfig) []HookConfig {
+	if len(override) == 0 {
+		return base
+	}
+	merged := make([]HookConfig, 0, len(base)+len(override))
+	merged = append(merged, base...)
+	return append(merged, override...)
+}
+
 // mergePermissions merges permission configurations
 func (l *Loader) mergePermissions(base, override PermissionsConfig) PermissionsConfig {
 	result := base
 
+	if override.Confirm {
+		result.Confirm = true
+	}
 	if override.DefaultMode != "" {
 		result.DefaultMode = override.DefaultMode
 	}
@@ -252,6 +331,21 @@ func (l *Loader) mergePermissions(base, override PermissionsConfig) PermissionsC
 	if len(override.Commands.DeniedCommands) > 0 {
 		result.Commands.DeniedCommands = override.Commands.DeniedCommands
 	}
+	if len(override.Commands.AllowedPatterns) > 0 {
+		result.Commands.AllowedPatterns = override.Commands.AllowedPatterns
+	}
+	if len(override.Commands.DeniedPatterns) > 0 {
+		result.Commands.DeniedPatterns = override.Commands.DeniedPatterns
+	}
+	if len(override.Commands.ConfirmCommands) > 0 {
+		result.Commands.ConfirmCommands = override.Commands.ConfirmCommands
+	}
+	if len(override.Commands.ConfirmPatterns) > 0 {
+		result.Commands.ConfirmPatterns = override.Commands.ConfirmPatterns
+	}
+	if override.Commands.RequireSudo {
+		result.Commands.RequireSudo = true
+	}
 	if override.Commands.MaxTimeout != 0 {
 		result.Commands.MaxTimeout = override.Commands.MaxTimeout
 	}
@@ -263,6 +357,15 @@ func (l *Loader) mergePermissions(base, override PermissionsConfig) PermissionsC
 	if len(override.Network.DeniedDomains) > 0 {
 		result.Network.DeniedDomains = override.Network.DeniedDomains
 	}
+	if override.Network.AllowLocalhost {
+		result.Network.AllowLocalhost = true
+	}
+	if override.Network.AllowPrivateNetworks {
+		result.Network.AllowPrivateNetworks = true
+	}
+	if override.Network.MaxRequestSize != 0 {
+		result.Network.MaxRequestSize = override.Network.MaxRequestSize
+	}
 
 	return result
 }
@@ -284,6 +387,32 @@ func (l *Loader) mergeSecurity(base, override SecurityConfig) SecurityConfig {
 		result.AuditLog = override.AuditLog
 	}
 
+	// Merge redaction config
+	if override.Redaction.Enabled {
+		result.Redaction.Enabled = true
+	}
+	if override.Redaction.Entropy {
+		result.Redaction.Entropy = true
+	}
+	if override.Redaction.EntropyThreshold != 0 {
+		result.Redaction.EntropyThreshold = override.Redaction.EntropyThreshold
+	}
+	if len(override.Redaction.Patterns) > 0 {
+		result.Redaction.Patterns = override.Redaction.Patterns
+	}
+	if len(override.Redaction.Allow) > 0 {
+		result.Redaction.Allow = override.Redaction.Allow
+	}
+	if override.Redaction.SecretStore.Enabled {
+		result.Redaction.SecretStore.Enabled = true
+	}
+	if override.Redaction.SecretStore.Path != "" {
+		result.Redaction.SecretStore.Path = override.Redaction.SecretStore.Path
+	}
+	if override.Redaction.SecretStore.KeyPath != "" {
+		result.Redaction.SecretStore.KeyPath = override.Redaction.SecretStore.KeyPath
+	}
+
 	return result
 }
 
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -253,76 +254,33 @@ func (p *PermissionChecker) CheckFileWritePermission(path string) (PermissionRes
 	}
 }
 
-// CheckCommandPermission checks if executing a command is allowed
+// CheckCommandPermission checks if executing a command is allowed. The
+// command and its arguments are evaluated as a command line by
+// EvaluateCommand.
 func (p *PermissionChecker) CheckCommandPermission(command string, args []string) (PermissionResult, error) {
-	fullCommand := command
-	if len(args) > 0 {
-		fullCommand = fmt.Sprintf("%s %s", command, strings.Join(args, " "))
-	}
-
-	// Check denied commands first
-	for _, denied := range p.config.Commands.DeniedCommands {
-		if strings.Contains(fullCommand, denied) {
-			return PermissionResult{
-				Allowed: false,
-				Reason:  fmt.Sprintf("Command contains denied pattern: %s", denied),
-			}, nil
-		}
-	}
-
-	// Check denied patterns
-	for _, pattern := range p.config.Commands.DeniedPatterns {
-		if matched, _ := regexp.MatchString(pattern, fullCommand); matched {
-			return PermissionResult{
-				Allowed: false,
-				Reason:  fmt.Sprintf("Command matches denied pattern: %s", pattern),
-			}, nil
-		}
-	}
+	fullCommand := quoteArgs(command, args)
+	eval := p.EvaluateCommand(fullCommand)
 
-	// Check if sudo is required but not allowed
-	if strings.HasPrefix(fullCommand, "sudo") && !p.config.Commands.RequireSudo {
+	switch eval.Decision {
+	case CommandDeny:
 		return PermissionResult{
 			Allowed: false,
-			Reason:  "Sudo commands are not allowed",
+			Reason:  eval.Explain(),
+		}, nil
+	case CommandConfirm:
+		return PermissionResult{
+			Allowed:              true,
+			RequiresConfirmation: true,
+			ConfirmationMessage:  fmt.Sprintf("Execute command: %s? (%s)", fullCommand, eval.Explain()),
+		}, nil
+	case CommandAllow:
+		return PermissionResult{
+			Allowed: true,
+			Reason:  eval.Explain(),
 		}, nil
 	}
 
-	// Check allowed commands
-	if len(p.config.Commands.AllowedCommands) > 0 {
-		allowed := false
-		for _, allowedCmd := range p.config.Commands.AllowedCommands {
-			if command == allowedCmd || strings.HasPrefix(fullCommand, allowedCmd) {
-				allowed = true
-				break
-			}
-		}
-		if !allowed {
-			return PermissionResult{
-				Allowed: false,
-				Reason:  "Command not in allowed list",
-			}, nil
-		}
-	}
-
-	// Check allowed patterns
-	if len(p.config.Commands.AllowedPatterns) > 0 {
-		allowed := false
-		for _, pattern := range p.config.Commands.AllowedPatterns {
-			if matched, _ := regexp.MatchString(pattern, fullCommand); matched {
-				allowed = true
-				break
-			}
-		}
-		if !allowed {
-			return PermissionResult{
-				Allowed: false,
-				Reason:  "Command does not match any allowed pattern",
-			}, nil
-		}
-	}
-
-	// Default behavior - always require confirmation for commands
+	// Default behavior - require confirmation for commands no rule decided
 	return PermissionResult{
 		Allowed:              true,
 		RequiresConfirmation: true,
@@ -409,8 +367,40 @@ func (p *PermissionChecker) matchPattern(str, pattern string) (bool, error) {
 		return regexp.MatchString(regex, str)
 	}
 
-	// Otherwise treat as glob pattern
-	return filepath.Match(pattern, str)
+	// Otherwise treat as glob pattern, where * also matches the slashes of
+	// paths and URLs in arguments
+	return regexp.MatchString(globToRegexp(pattern), str)
+}
+
+// globToRegexp converts a glob pattern with *, ? and [...] to an anchored
+// regular expression
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString(`$`)
+	return b.String()
 }
 
 // evaluateConditions evaluates custom rule conditions
@@ -450,7 +440,11 @@ func (p *PermissionChecker) isPathUnder(path, parent string) bool {
 
 // extractDomain extracts domain from URL
 func (p *PermissionChecker) extractDomain(urlStr string) string {
-	// Simple domain extraction - in production use proper URL parsing
+	if u, err := url.Parse(urlStr); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+
+	// Simple domain extraction for URLs without a scheme
 	urlStr = strings.TrimPrefix(urlStr, "http://")
 	urlStr = strings.TrimPrefix(urlStr, "https://")
 	parts := strings.Split(urlStr, "/")
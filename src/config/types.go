@@ -28,7 +28,7 @@ type Config struct {
 	Project ProjectConfig `json:"project"`
 
 	// Tool-specific configurations
-	Tools map[string]ToolConfig `json:"tools,omitempty"`
+	Tools map[string]ToolConfig `json:"tools,omitempty" validate:"dive"`
 
 	// MCP server configurations
 	MCPServers []MCPServerConfig `json:"mcp_servers,omitempty"`
@@ -56,6 +56,69 @@ type Config struct {
 
 	// AutoCompact configuration for automatic session compaction
 	AutoCompact bool `json:"auto_compact,omitempty"`
+
+	// CompactThreshold is the fraction of the model's context a request may
+	// use before it is compacted (default 0.8)
+	CompactThreshold float64 `json:"compact_threshold,omitempty" validate:"min=0,max=1"`
+
+	// SubAgent configures the task tool, which delegates subtasks to a
+	// sub-agent
+	SubAgent SubAgentConfig `json:"sub_agent,omitempty"`
+
+	// Hooks run commands at points of a run
+	Hooks HooksConfig `json:"hooks,omitempty"`
+}
+
+// HooksConfig defines the commands run at points of a run. Each command
+// gets the event as JSON on stdin and may answer with a JSON decision on
+// stdout.
+type HooksConfig struct {
+	// PreToolUse hooks run before a tool call and may block it or rewrite
+	// its arguments
+	PreToolUse []HookConfig `json:"pre_tool_use,omitempty" validate:"dive"`
+
+	// PostToolUse hooks run after a tool call and may add feedback to its
+	// result
+	PostToolUse []HookConfig `json:"post_tool_use,omitempty" validate:"dive"`
+
+	// PromptSubmit hooks run when a prompt is submitted and may block or
+	// rewrite it
+	PromptSubmit []HookConfig `json:"prompt_submit,omitempty" validate:"dive"`
+
+	// Stop hooks run when a run ends
+	Stop []HookConfig `json:"stop,omitempty" validate:"dive"`
+}
+
+// HookConfig defines a hook command
+type HookConfig struct {
+	// Command is run with sh -c in the project directory
+	Command string `json:"command" validate:"required"`
+
+	// Tools limits tool hooks to tools matching these glob patterns, all
+	// tools when empty
+	Tools []string `json:"tools,omitempty"`
+
+	// Timeout fails the hook when it runs longer (default 60s)
+	Timeout time.Duration `json:"timeout,omitempty" validate:"min=0"`
+}
+
+// SubAgentConfig defines the sub-agent of the task tool
+type SubAgentConfig struct {
+	// Enabled offers the task tool to the agent
+	Enabled bool `json:"enabled"`
+
+	// Model of the sub-agent, defaults to the model of the prompt
+	Model string `json:"model,omitempty"`
+
+	// Tools the sub-agent may use. Defaults to the read-only tools, the
+	// ones that may run in parallel.
+	Tools []string `json:"tools,omitempty"`
+
+	// MaxTurns is the turn budget of each sub-agent run (default 10)
+	MaxTurns int `json:"max_turns,omitempty" validate:"min=0"`
+
+	// SystemPrompt replaces the default system prompt of the sub-agent
+	SystemPrompt string `json:"system_prompt,omitempty"`
 }
 
 // LSPConfig defines LSP configuration
@@ -87,6 +150,11 @@ type DataConfig struct {
 
 // ProviderConfig defines configuration for a model provider
 type ProviderConfig struct {
+	// Type of provider: "openrouter", "anthropic" or "openai-compatible".
+	// Defaults to the provider name for openrouter and anthropic, and to
+	// "openai-compatible" for any other name
+	Type string `json:"type,omitempty"`
+
 	// APIKey for the provider
 	APIKey string `json:"api_key,omitempty"`
 
@@ -181,6 +249,10 @@ type RateLimitConfig struct {
 
 // PermissionsConfig defines tool and file system permissions
 type PermissionsConfig struct {
+	// Confirm asks about tool calls the permissions require to be
+	// confirmed. Without it they run; denied calls never do.
+	Confirm bool `json:"confirm"`
+
 	// DefaultMode sets the default permission mode ("allow", "deny", "prompt")
 	DefaultMode string `json:"default_mode" validate:"permission_mode"`
 
@@ -245,10 +317,16 @@ type CommandPermissions struct {
 	DeniedCommands []string `json:"denied_commands,omitempty"`
 
 	// AllowedPatterns lists allowed command patterns (regex)
-	AllowedPatterns []string `json:"allowed_patterns,omitempty"`
+	AllowedPatterns []string `json:"allowed_patterns,omitempty" validate:"dive,command_pattern"`
 
 	// DeniedPatterns lists denied command patterns (regex)
-	DeniedPatterns []string `json:"denied_patterns,omitempty"`
+	DeniedPatterns []string `json:"denied_patterns,omitempty" validate:"dive,command_pattern"`
+
+	// ConfirmCommands lists commands that require confirmation
+	ConfirmCommands []string `json:"confirm_commands,omitempty"`
+
+	// ConfirmPatterns lists command patterns (regex) that require confirmation
+	ConfirmPatterns []string `json:"confirm_patterns,omitempty" validate:"dive,command_pattern"`
 
 	// RequireSudo controls whether sudo commands are allowed
 	RequireSudo bool `json:"require_sudo"`
@@ -310,6 +388,9 @@ type SecurityConfig struct {
 	// AuditLog configuration
 	AuditLog AuditLogConfig `json:"audit_log,omitempty"`
 
+	// Redaction of secrets from tool results and logs
+	Redaction RedactionConfig `json:"redaction,omitempty"`
+
 	// Encryption settings
 	Encryption EncryptionConfig `json:"encryption,omitempty"`
 
@@ -326,6 +407,44 @@ type AuditLogConfig struct {
 	Format     string `json:"format"` // "json" or "text"
 }
 
+// RedactionConfig defines how secrets are replaced with placeholders in tool
+// results before they are sent to the model, saved or logged
+type RedactionConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Entropy also redacts random-looking tokens that no detector matches,
+	// with at least EntropyThreshold bits of entropy per character
+	Entropy          bool    `json:"entropy,omitempty"`
+	EntropyThreshold float64 `json:"entropy_threshold,omitempty" validate:"gte=0,lte=8"`
+
+	// Patterns are regular expressions of further secrets
+	Patterns []RedactionPattern `json:"patterns,omitempty" validate:"dive"`
+
+	// Allow holds regular expressions of values that are never redacted
+	Allow []string `json:"allow,omitempty" validate:"dive,regexp"`
+
+	// SecretStore keeps the redacted values so they can be written to files
+	SecretStore SecretStoreConfig `json:"secret_store,omitempty"`
+}
+
+// RedactionPattern is a regular expression of secrets. Its first group is
+// redacted if it has one, else the whole match.
+type RedactionPattern struct {
+	Name    string `json:"name" validate:"required"`
+	Pattern string `json:"pattern" validate:"required,regexp"`
+}
+
+// SecretStoreConfig defines the encrypted store of redacted values
+type SecretStoreConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Path of the store, relative to the project directory if not absolute
+	Path string `json:"path,omitempty"`
+
+	// KeyPath is the key file, created when missing
+	KeyPath string `json:"key_path,omitempty"`
+}
+
 // EncryptionConfig defines encryption settings
 type EncryptionConfig struct {
 	// EncryptConfig controls whether to encrypt the config file
@@ -456,6 +575,22 @@ type ToolConfig struct {
 
 	// Permissions overrides for this tool
 	Permissions *ToolPermissions `json:"permissions,omitempty"`
+
+	// Timeout fails calls that run longer
+	Timeout time.Duration `json:"timeout,omitempty" validate:"min=0"`
+
+	// MaxOutputBytes truncates longer tool output, keeping its beginning and end
+	MaxOutputBytes int `json:"max_output_bytes,omitempty" validate:"min=0"`
+
+	// Retries is how often failed calls are retried. Only parallel-safe
+	// tools, which have no side effects, are retried.
+	Retries int `json:"retries,omitempty" validate:"min=0"`
+
+	// RetryDelay is the wait between retries
+	RetryDelay time.Duration `json:"retry_delay,omitempty" validate:"min=0"`
+
+	// LogArguments logs the arguments, duration and outcome of each call
+	LogArguments bool `json:"log_arguments,omitempty"`
 }
 
 // ConfigPrecedence defines the order of configuration loading
@@ -513,6 +648,23 @@ type AgentConfig struct {
 	SystemPrompt string  `json:"system_prompt"`
 	MaxRetries   int     `json:"max_retries"`
 	RetryDelay   int     `json:"retry_delay"`
+
+	// ContextStrategy is applied when a conversation does not fit in the
+	// model's context window: "truncate_tool_results" (default),
+	// "drop_tool_outputs" or "refuse"
+	ContextStrategy string `json:"context_strategy,omitempty" validate:"context_strategy"`
+
+	// ContextReserveTokens are kept free for the response when checking the
+	// context window. Defaults to the model's maximum completion tokens.
+	ContextReserveTokens int `json:"context_reserve_tokens,omitempty" validate:"min=0"`
+
+	// MaxParallelTools limits how many read-only tool calls of one response
+	// run at once. Tools with side effects always run one at a time.
+	MaxParallelTools int `json:"max_parallel_tools,omitempty" validate:"min=0"`
+
+	// FallbackModels are tried in order when a model call fails with a rate
+	// limit, overload or context length error
+	FallbackModels []string `json:"fallback_models,omitempty"`
 }
 
 // MCPServerConfig holds MCP server configuration
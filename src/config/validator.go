@@ -29,7 +29,10 @@ func NewValidator() *Validator {
 	v.RegisterValidation("domain_pattern", validateDomainPattern)
 	v.RegisterValidation("glob_pattern", validateGlobPattern)
 	v.RegisterValidation("regex_pattern", validateRegexPattern)
+	v.RegisterValidation("command_pattern", validateCommandPattern)
+	v.RegisterValidation("regexp", validateRegexp)
 	v.RegisterValidation("abs_or_rel_path", validateAbsOrRelPath)
+	v.RegisterValidation("context_strategy", validateContextStrategy)
 	
 	return &Validator{
 		validate: v,
@@ -123,6 +126,16 @@ func validateKeyDerivation(fl validator.FieldLevel) bool {
 	return contains(validMethods, value)
 }
 
+// validateContextStrategy validates context window strategy values
+func validateContextStrategy(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	if value == "" {
+		return true
+	}
+	validStrategies := []string{"truncate_tool_results", "drop_tool_outputs", "refuse"}
+	return contains(validStrategies, value)
+}
+
 // validateLogFormat validates log format values
 func validateLogFormat(fl validator.FieldLevel) bool {
 	value := fl.Field().String()
@@ -181,6 +194,19 @@ func validateRegexPattern(fl validator.FieldLevel) bool {
 	return true
 }
 
+// validateCommandPattern validates command patterns, which are regular
+// expressions
+func validateCommandPattern(fl validator.FieldLevel) bool {
+	_, err := regexp.Compile(fl.Field().String())
+	return err == nil
+}
+
+// validateRegexp validates regular expressions
+func validateRegexp(fl validator.FieldLevel) bool {
+	_, err := regexp.Compile(fl.Field().String())
+	return err == nil
+}
+
 // validateAbsOrRelPath validates that path is absolute or relative to current directory
 func validateAbsOrRelPath(fl validator.FieldLevel) bool {
 	path := fl.Field().String()
@@ -0,0 +1,204 @@
+// Package egress enforces the network permissions on the HTTP requests of
+// tools. A request's host is checked by name, then resolved once, and the
+// connection is made to a resolved address that was checked, so the host
+// cannot resolve to another address between the check and the connection.
+// Redirects are requests of their own and are checked the same way.
+package egress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/elee1766/gofer/src/config"
+)
+
+// DeniedError is returned for requests the network permissions deny. It is
+// wrapped in the *url.Error of http.Client.
+type DeniedError struct {
+	URL     string
+	Host    string
+	Address string // The resolved address that was denied, if any
+	Reason  string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("network access to %s denied: %s", e.Host, e.Reason)
+}
+
+// Resolver resolves host names. net.DefaultResolver implements it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which is private
+// though net.IP.IsPrivate does not report it
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Transport is an http.RoundTripper that only sends the requests the
+// network permissions allow, and limits the size of requests and responses
+// to max_request_size
+type Transport struct {
+	network  config.NetworkPermissions
+	checker  *config.PermissionChecker
+	resolver Resolver
+	base     *http.Transport
+}
+
+// NewTransport creates a transport enforcing the network permissions
+func NewTransport(network config.NetworkPermissions) *Transport {
+	t := &Transport{
+		network:  network,
+		checker:  config.NewPermissionChecker(&config.PermissionsConfig{Network: network}),
+		resolver: net.DefaultResolver,
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.base = &http.Transport{
+		// A proxy would connect to hosts that were not checked
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return t.dial(ctx, dialer, network, addr)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return t
+}
+
+// RoundTrip checks the request's host and size, sends it, and limits the
+// size of the response
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	denied := func(reason string) error {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return &DeniedError{URL: req.URL.String(), Host: req.URL.Hostname(), Reason: reason}
+	}
+
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, denied(fmt.Sprintf("scheme %q is not allowed", req.URL.Scheme))
+	}
+	result, err := t.checker.CheckNetworkPermission(req.URL.String())
+	if err != nil {
+		return nil, err
+	}
+	if !result.Allowed {
+		return nil, denied(result.Reason)
+	}
+	max := t.network.MaxRequestSize
+	if max > 0 && req.ContentLength > max {
+		return nil, denied(fmt.Sprintf("request of %d bytes exceeds max_request_size of %d bytes", req.ContentLength, max))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if max > 0 {
+		tooLarge := &DeniedError{
+			URL:    req.URL.String(),
+			Host:   req.URL.Hostname(),
+			Reason: fmt.Sprintf("response exceeds max_request_size of %d bytes", max),
+		}
+		if resp.ContentLength > max {
+			resp.Body.Close()
+			return nil, tooLarge
+		}
+		resp.Body = &limitedBody{body: resp.Body, remaining: max, err: tooLarge}
+	}
+	return resp, nil
+}
+
+// dial resolves the host once, checks every address it resolves to and
+// connects to the first of them that accepts the connection
+func (t *Transport) dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := t.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+
+	// A host resolving to any denied address is denied, since which of
+	// its addresses is used is up to the resolver
+	for _, ip := range ips {
+		if reason := t.checkAddress(ip); reason != "" {
+			return nil, &DeniedError{Host: host, Address: ip.String(), Reason: fmt.Sprintf("address %s %s", ip, reason)}
+		}
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// checkAddress returns why connecting to an address is not allowed, or ""
+func (t *Transport) checkAddress(ip net.IP) string {
+	switch {
+	case ip.IsLoopback() || ip.IsUnspecified():
+		if !t.network.AllowLocalhost {
+			return "is a loopback address, and allow_localhost is off"
+		}
+	case ip.IsPrivate() || ip.IsLinkLocalUnicast() || sharedAddressSpace.Contains(ip):
+		if !t.network.AllowPrivateNetworks {
+			return "is a private or link-local address, and allow_private_networks is off"
+		}
+	case ip.IsMulticast() || ip.IsInterfaceLocalMulticast():
+		return "is a multicast address"
+	}
+	return ""
+}
+
+// limitedBody fails reads past the size limit of a response
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.err
+	}
+	// Read one byte past the limit to tell a response that ends at the
+	// limit from one that exceeds it
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), b.err
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,148 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elee1766/gofer/src/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticResolver resolves host names from a map
+type staticResolver map[string]string
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ip, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return []net.IPAddr{{IP: net.ParseIP(ip)}}, nil
+}
+
+// newTestClient creates a client whose transport resolves with the resolver
+func newTestClient(network config.NetworkPermissions, resolver staticResolver) *http.Client {
+	transport := NewTransport(network)
+	transport.resolver = resolver
+	return &http.Client{Transport: transport, Timeout: 5 * time.Second}
+}
+
+// serverPort returns the port of a test server
+func serverPort(t *testing.T, server *httptest.Server) string {
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	return u.Port()
+}
+
+func TestTransportDeniesAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	port := serverPort(t, server)
+
+	resolver := staticResolver{
+		"rebind.example":   "127.0.0.1",
+		"metadata.example": "169.254.169.254",
+		"intranet.example": "10.1.2.3",
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		network config.NetworkPermissions
+		address string
+	}{
+		{"localhost by name", "http://localhost:" + port, config.NetworkPermissions{}, ""},
+		{"loopback by address", server.URL, config.NetworkPermissions{}, ""},
+		{"name resolving to loopback", "http://rebind.example:" + port, config.NetworkPermissions{}, "127.0.0.1"},
+		{"link-local metadata", "http://metadata.example/latest", config.NetworkPermissions{AllowLocalhost: true}, "169.254.169.254"},
+		{"private network", "http://intranet.example/", config.NetworkPermissions{AllowLocalhost: true}, "10.1.2.3"},
+		{"denied domain", "http://rebind.example:" + port, config.NetworkPermissions{AllowLocalhost: true, DeniedDomains: []string{"*.example"}}, ""},
+		{"domain not allowed", "http://rebind.example:" + port, config.NetworkPermissions{AllowLocalhost: true, AllowedDomains: []string{"docs.example"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestClient(tt.network, resolver).Get(tt.url)
+			var denied *DeniedError
+			require.ErrorAs(t, err, &denied)
+			assert.Equal(t, tt.address, denied.Address)
+			assert.NotEmpty(t, denied.Reason)
+		})
+	}
+
+	// The same host is reachable when the permissions allow it
+	resp, err := newTestClient(config.NetworkPermissions{AllowLocalhost: true}, resolver).Get("http://rebind.example:" + port)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+}
+
+func TestTransportChecksRedirects(t *testing.T) {
+	var port string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "public.example:"+port {
+			http.Redirect(w, r, "http://internal.example:"+port+"/secret", http.StatusFound)
+			return
+		}
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+	port = serverPort(t, server)
+
+	network := config.NetworkPermissions{AllowLocalhost: true, DeniedDomains: []string{"internal.example"}}
+	resolver := staticResolver{"public.example": "127.0.0.1", "internal.example": "127.0.0.1"}
+
+	_, err := newTestClient(network, resolver).Get("http://public.example:" + port)
+	var denied *DeniedError
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, "internal.example", denied.Host)
+	assert.Contains(t, denied.URL, "/secret")
+}
+
+func TestTransportLimitsResponseSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/length" {
+			w.Header().Set("Content-Length", "100")
+		}
+		w.Write([]byte(strings.Repeat("x", 50)))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("x", 50)))
+	}))
+	defer server.Close()
+
+	client := newTestClient(config.NetworkPermissions{AllowLocalhost: true, MaxRequestSize: 64}, nil)
+
+	// A response announcing its size is denied before it is read
+	_, err := client.Get(server.URL + "/length")
+	var denied *DeniedError
+	require.ErrorAs(t, err, &denied)
+
+	// A streamed response fails once it passes the limit
+	resp, err := client.Get(server.URL + "/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.True(t, errors.As(err, &denied), "expected a denial, got %v", err)
+	assert.Len(t, body, 64)
+
+	// Responses within the limit are read in full
+	client = newTestClient(config.NetworkPermissions{AllowLocalhost: true, MaxRequestSize: 100}, nil)
+	resp, err = client.Get(server.URL + "/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Len(t, body, 100)
+}
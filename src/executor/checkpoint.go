@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/storage"
+)
+
+// PendingAction is what to do with the tool calls an interrupted run did not finish
+type PendingAction string
+
+const (
+	// PendingRerun executes the calls again
+	PendingRerun PendingAction = "rerun"
+	// PendingCancel tells the model the calls were cancelled by the user
+	PendingCancel PendingAction = "cancel"
+)
+
+// cancelledToolResult is the result of pending calls that are not rerun
+const cancelledToolResult = "Tool call was cancelled by the user before it finished"
+
+// InterruptedRun is a run that stopped while executing tool calls
+type InterruptedRun struct {
+	Checkpoint *storage.RunCheckpoint
+
+	// Pending are the calls without a saved result
+	Pending []aisdk.ToolCall
+}
+
+// TurnsRemaining returns the number of turns the interrupted run had left
+func (r *InterruptedRun) TurnsRemaining() int {
+	return r.Checkpoint.MaxTurns - r.Checkpoint.Turn
+}
+
+// InterruptedRun returns the interrupted run of a conversation, or nil if its
+// last run finished its tool calls
+func (s *Service) InterruptedRun(ctx context.Context, conversationID string) (*InterruptedRun, error) {
+	checkpoint, err := storage.GetOpenRunCheckpoint(ctx, s.database, conversationID)
+	if err != nil || checkpoint == nil {
+		return nil, err
+	}
+
+	var toolCalls []aisdk.ToolCall
+	if err := json.Unmarshal([]byte(checkpoint.ToolCalls), &toolCalls); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint tool calls: %w", err)
+	}
+
+	// The saved results are the tool messages following the assistant
+	// message that made the calls
+	messages, err := storage.GetMessagesByConversationID(ctx, s.database, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	finished := make(map[string]bool)
+	if checkpoint.MessageID != nil {
+		for i, msg := range messages {
+			if msg.ID != *checkpoint.MessageID {
+				continue
+			}
+			for _, result := range messages[i+1:] {
+				if result.Role != "tool" {
+					break
+				}
+				finished[result.ToolCallID] = true
+			}
+			break
+		}
+	}
+
+	run := &InterruptedRun{Checkpoint: checkpoint}
+	for _, tc := range toolCalls {
+		if !finished[tc.ID] {
+			run.Pending = append(run.Pending, tc)
+		}
+	}
+	if len(run.Pending) == 0 {
+		checkpoint.Status = storage.CheckpointCompleted
+		return nil, storage.UpdateRunCheckpoint(ctx, s.database, checkpoint)
+	}
+	return run, nil
+}
+
+// ResumeRequest resolves the pending tool calls of an interrupted run
+type ResumeRequest struct {
+	Run            *InterruptedRun
+	Action         PendingAction
+	ConversationID string
+
+	// Used to rerun the calls
+	Toolbox   *agent.DefaultToolbox
+	Model     string
+	Provider  string
+	Callbacks *Callbacks
+	EventSink EventSink
+}
+
+// ResumeInterruptedRun reruns or cancels the pending tool calls of an
+// interrupted run and saves their results, so the conversation can continue.
+// It returns the results in call order.
+func (s *Service) ResumeInterruptedRun(ctx context.Context, req *ResumeRequest) ([]*aisdk.Message, error) {
+	checkpoint := req.Run.Checkpoint
+	var messageID string
+	if checkpoint.MessageID != nil {
+		messageID = *checkpoint.MessageID
+	}
+
+	var results []*aisdk.Message
+	switch req.Action {
+	case PendingRerun:
+		result, err := s.ExecuteToolCalls(ctx, &ToolExecutionRequest{
+			ToolCalls:      req.Run.Pending,
+			Toolbox:        req.Toolbox,
+			ConversationID: req.ConversationID,
+			MessageID:      messageID,
+			Model:          req.Model,
+			Provider:       req.Provider,
+			Callbacks:      req.Callbacks,
+			EventSink:      req.EventSink,
+			TurnNumber:     checkpoint.Turn,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.State == StateError {
+			return nil, result.Error
+		}
+		results = result.ToolResults
+		checkpoint.Status = storage.CheckpointRerun
+
+	case PendingCancel:
+		for _, tc := range req.Run.Pending {
+			msg := &aisdk.Message{
+				Role:       "tool",
+				Content:    cancelledToolResult,
+				Name:       tc.Function.Name,
+				ToolCallID: tc.ID,
+				IsError:    true,
+			}
+			if err := s.saveToolMessage(ctx, req.ConversationID, req.Provider, req.Model, msg); err != nil {
+				return nil, fmt.Errorf("failed to save tool message: %w", err)
+			}
+			results = append(results, msg)
+		}
+		checkpoint.Status = storage.CheckpointCancelled
+
+	default:
+		return nil, fmt.Errorf("unknown pending action %q", req.Action)
+	}
+
+	for _, msg := range results {
+		checkpoint.FinishedToolCallIDs = append(checkpoint.FinishedToolCallIDs, msg.ToolCallID)
+	}
+	if err := storage.UpdateRunCheckpoint(ctx, s.database, checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to update run checkpoint: %w", err)
+	}
+	return results, nil
+}
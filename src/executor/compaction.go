@@ -0,0 +1,361 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/goferagent/tools"
+	"github.com/elee1766/gofer/src/storage"
+)
+
+// CompactTrigger is what started a compaction
+type CompactTrigger string
+
+const (
+	CompactAuto   CompactTrigger = "auto"
+	CompactManual CompactTrigger = "manual"
+)
+
+const (
+	// DefaultCompactThreshold is the fraction of the model's context a
+	// request may use before it is compacted automatically
+	DefaultCompactThreshold = 0.8
+
+	// compactKeepFraction is the fraction of the context the most recent
+	// messages may use and still be kept as they are
+	compactKeepFraction = 0.25
+
+	// Longest tool result and message text included in the summary request
+	maxTranscriptToolResult = 2000
+	maxTranscriptMessage    = 8000
+
+	summaryOpenTag  = "<conversation-summary>"
+	summaryCloseTag = "</conversation-summary>"
+
+	// summaryRole is the role of summary messages, so the model does not
+	// take the summary for something the user said
+	summaryRole = "system"
+)
+
+// ErrNothingToCompact is returned when a conversation has no older messages
+// that could be summarized
+var ErrNothingToCompact = errors.New("nothing to compact")
+
+const compactionPrompt = `You summarize the earlier part of a conversation between a user and a coding agent, so the agent can continue the work without the original messages.
+
+Write a concise summary with these sections:
+- Task: what the user asked for and any constraints they gave
+- Files: the files read and edited, and what changed in each
+- Decisions: approaches chosen or rejected, and why
+- Open TODOs: work that was planned or started but not finished
+- Current state: where the work stands at the end of the transcript
+
+Keep exact file paths, identifiers, commands and error messages. Leave out pleasantries and anything the agent no longer needs.`
+
+// CompactRequest asks to summarize the older messages of a conversation
+type CompactRequest struct {
+	Conversation   *aisdk.Conversation
+	ConversationID string // Records the compaction when set
+	ModelClient    aisdk.ModelClient
+	Trigger        CompactTrigger
+	EventSink      EventSink
+	TurnNumber     int
+}
+
+// CompactResult is a compacted conversation
+type CompactResult struct {
+	// Conversation has the older messages replaced by a summary message
+	Conversation *aisdk.Conversation
+	Compaction   *storage.Compaction
+}
+
+// Compact replaces the older messages of a conversation with a summary
+// written by the model. System messages and the most recent messages are
+// kept. The saved messages are not changed; the compaction records which of
+// them the summary covers.
+func (s *Service) Compact(ctx context.Context, req *CompactRequest) (*CompactResult, error) {
+	if req.ModelClient == nil {
+		return nil, ErrModelClientRequired
+	}
+	model := req.ModelClient.GetModelInfo()
+	messages := req.Conversation.Messages
+	estimator := aisdk.EstimatorFor(model)
+
+	start, cut := compactionRange(estimator, messages, int(float64(aisdk.ContextLimit(model))*compactKeepFraction))
+	if cut <= start || (cut-start == 1 && isSummaryMessage(messages[start])) {
+		return nil, ErrNothingToCompact
+	}
+	compacted := messages[start:cut]
+
+	response, err := req.ModelClient.CreateChatCompletion(ctx, &aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{
+			{Role: "system", Content: compactionPrompt},
+			{Role: "user", Content: compactionTranscript(compacted)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	if len(response.Choices) == 0 || strings.TrimSpace(response.Choices[0].Message.Content) == "" {
+		return nil, fmt.Errorf("failed to summarize conversation: empty response")
+	}
+	s.audit.ModelCall(req.ConversationID, model.ID, response.Usage)
+	summary := summaryMessage(compacted, response.Choices[0].Message.Content)
+
+	out := &aisdk.Conversation{
+		ID:           req.Conversation.ID,
+		SystemPrompt: req.Conversation.SystemPrompt,
+		CreatedAt:    req.Conversation.CreatedAt,
+	}
+	out.Messages = append(out.Messages, messages[:start]...)
+	out.Messages = append(out.Messages, summary)
+	out.Messages = append(out.Messages, messages[cut:]...)
+
+	compaction := &storage.Compaction{
+		ConversationID: req.ConversationID,
+		MessageCount:   len(compacted),
+		Summary:        summary.Content,
+		Trigger:        string(req.Trigger),
+		Model:          model.ID,
+		TokensBefore:   agent.Breakdown(estimator, messages, nil).Total(),
+		TokensAfter:    agent.Breakdown(estimator, out.Messages, nil).Total(),
+	}
+	if req.ConversationID != "" {
+		if err := s.saveCompaction(ctx, compaction, compacted); err != nil {
+			return nil, fmt.Errorf("failed to save compaction: %w", err)
+		}
+		if err := s.saveUsage(ctx, req.ConversationID, "", model, response.Usage); err != nil {
+			s.logger.Error("Failed to save usage", "error", err)
+		}
+	}
+
+	s.logger.Info("Compacted conversation", "trigger", req.Trigger, "messages", len(compacted),
+		"tokens_before", compaction.TokensBefore, "tokens_after", compaction.TokensAfter)
+	if req.EventSink != nil {
+		NewEventEmitter(req.EventSink, req.ConversationID, req.TurnNumber).EmitSystemMessage(
+			fmt.Sprintf("Compacted %d earlier messages into a summary (about %d → %d tokens)",
+				len(compacted), compaction.TokensBefore, compaction.TokensAfter), "info")
+	}
+
+	return &CompactResult{Conversation: out, Compaction: compaction}, nil
+}
+
+// maybeCompact compacts the conversation of a step when the request nears the
+// model's context length. Failures are logged and the conversation is sent
+// as it is, leaving it to the context window check.
+func (s *Service) maybeCompact(ctx context.Context, req *StepRequest) *aisdk.Conversation {
+	messages := req.Conversation.Messages
+	if req.Message != nil {
+		messages = append(messages[:len(messages):len(messages)], req.Message)
+	}
+	var tools []*aisdk.ChatTool
+	if req.Toolbox != nil {
+		tools = agent.ToChatTools(req.Toolbox.Tools())
+	}
+	if !s.needsCompaction(req.ModelClient.GetModelInfo(), messages, tools) {
+		return req.Conversation
+	}
+
+	result, err := s.Compact(ctx, &CompactRequest{
+		Conversation:   req.Conversation,
+		ConversationID: req.ConversationID,
+		ModelClient:    req.ModelClient,
+		Trigger:        CompactAuto,
+		EventSink:      req.EventSink,
+		TurnNumber:     req.TurnNumber,
+	})
+	if err != nil {
+		s.logger.Warn("Failed to compact conversation", "error", err)
+		return req.Conversation
+	}
+	return result.Conversation
+}
+
+// needsCompaction reports whether a request uses more of the model's context
+// than the compaction threshold allows
+func (s *Service) needsCompaction(model *aisdk.ModelInfo, messages []*aisdk.Message, tools []*aisdk.ChatTool) bool {
+	limit := aisdk.ContextLimit(model)
+	if limit == 0 {
+		return false
+	}
+	tokens := agent.Breakdown(aisdk.EstimatorFor(model), messages, tools).Total()
+	return float64(tokens) >= s.compactThreshold*float64(limit)
+}
+
+// compactionRange returns the messages to summarize as messages[start:cut].
+// Leading system messages are kept, but not summaries, which the next
+// compaction summarizes again. The most recent messages that fit in
+// keepTokens are kept too, or at least the last one. Tool results stay with
+// the assistant message that called them.
+func compactionRange(estimator aisdk.TokenEstimator, messages []*aisdk.Message, keepTokens int) (int, int) {
+	start := 0
+	for start < len(messages) && messages[start] != nil && messages[start].Role == "system" && !isSummaryMessage(messages[start]) {
+		start++
+	}
+
+	cut := len(messages)
+	kept := 0
+	for i := len(messages) - 1; i > start; i-- {
+		kept += estimator.Message(messages[i])
+		if kept > keepTokens && cut < len(messages) {
+			break
+		}
+		if messages[i] != nil && messages[i].Role != "tool" {
+			cut = i
+		}
+	}
+	if cut == len(messages) {
+		return start, start
+	}
+	return start, cut
+}
+
+// compactionTranscript renders messages as text for the summary request
+func compactionTranscript(messages []*aisdk.Message) string {
+	var b strings.Builder
+	b.WriteString("Summarize this conversation transcript:\n\n")
+	for _, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		switch msg.Role {
+		case "tool":
+			fmt.Fprintf(&b, "[tool result %s]\n%s\n\n", msg.Name, truncateText(msg.GetContent(), maxTranscriptToolResult))
+		case "assistant":
+			if content := msg.GetContent(); content != "" {
+				fmt.Fprintf(&b, "[assistant]\n%s\n\n", truncateText(content, maxTranscriptMessage))
+			}
+			for _, tc := range msg.ToolCalls {
+				fmt.Fprintf(&b, "[assistant called %s] %s\n\n", tc.Function.Name, truncateText(string(tc.Function.Arguments), maxTranscriptToolResult))
+			}
+		default:
+			fmt.Fprintf(&b, "[%s]\n%s\n\n", msg.Role, truncateText(msg.GetContent(), maxTranscriptMessage))
+		}
+	}
+	return b.String()
+}
+
+// summaryMessage wraps the model's summary in the message that replaces the
+// compacted messages. The files the tools touched are listed from the tool
+// calls, so they survive even if the model leaves them out.
+func summaryMessage(compacted []*aisdk.Message, summary string) *aisdk.Message {
+	read, edited := touchedFiles(compacted)
+
+	var b strings.Builder
+	b.WriteString(summaryOpenTag + "\n")
+	b.WriteString("Earlier messages of this conversation were replaced by this summary.\n\n")
+	if len(read) > 0 {
+		fmt.Fprintf(&b, "Files read: %s\n", strings.Join(read, ", "))
+	}
+	if len(edited) > 0 {
+		fmt.Fprintf(&b, "Files edited: %s\n", strings.Join(edited, ", "))
+	}
+	if len(read) > 0 || len(edited) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.TrimSpace(summary))
+	b.WriteString("\n" + summaryCloseTag)
+	return &aisdk.Message{Role: summaryRole, Content: b.String()}
+}
+
+// isSummaryMessage reports whether a message is a compaction summary
+func isSummaryMessage(msg *aisdk.Message) bool {
+	return msg != nil && msg.Role == summaryRole && strings.HasPrefix(msg.Content, summaryOpenTag)
+}
+
+// touchedFiles returns the sorted paths the file tools called in the
+// messages read and edited. Files of earlier summaries are carried over.
+func touchedFiles(messages []*aisdk.Message) (read, edited []string) {
+	readSet := make(map[string]bool)
+	editedSet := make(map[string]bool)
+	for _, msg := range messages {
+		if isSummaryMessage(msg) {
+			for _, line := range strings.Split(msg.Content, "\n") {
+				if rest, ok := strings.CutPrefix(line, "Files read: "); ok {
+					addPaths(readSet, strings.Split(rest, ", "))
+				} else if rest, ok := strings.CutPrefix(line, "Files edited: "); ok {
+					addPaths(editedSet, strings.Split(rest, ", "))
+				}
+			}
+			continue
+		}
+		for _, tc := range msg.ToolCalls {
+			var args map[string]interface{}
+			if err := json.Unmarshal(tc.Function.Arguments, &args); err != nil {
+				continue
+			}
+			read, edited := tools.FilePaths(tc.Function.Name, args)
+			addPaths(readSet, read)
+			addPaths(editedSet, edited)
+		}
+	}
+	// Edited files were usually read first; list them once
+	for p := range editedSet {
+		delete(readSet, p)
+	}
+	return sortedKeys(readSet), sortedKeys(editedSet)
+}
+
+func addPaths(set map[string]bool, paths []string) {
+	for _, p := range paths {
+		// The current directory of searches is not a file worth listing
+		if p = strings.TrimSpace(p); p != "" && p != "." {
+			set[p] = true
+		}
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// truncateText shortens s to maxLen bytes, noting how much was removed
+func truncateText(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	for maxLen > 0 && !utf8.RuneStart(s[maxLen]) {
+		maxLen--
+	}
+	return s[:maxLen] + fmt.Sprintf("\n[... %d bytes omitted ...]", len(s)-maxLen)
+}
+
+// saveCompaction records a compaction with the range of saved messages it
+// covers, from the first to the last compacted message that was saved
+func (s *Service) saveCompaction(ctx context.Context, compaction *storage.Compaction, compacted []*aisdk.Message) error {
+	for _, msg := range compacted {
+		if msg == nil || msg.ID == "" {
+			continue
+		}
+		if compaction.FirstMessageID == nil {
+			compaction.FirstMessageID = &msg.ID
+		}
+		compaction.LastMessageID = &msg.ID
+	}
+	return storage.CreateCompaction(ctx, s.database, compaction)
+}
+
+// afterCompaction returns the messages after the last one a compaction covers
+func afterCompaction(messages []storage.Message, compaction *storage.Compaction) []storage.Message {
+	if compaction == nil || compaction.LastMessageID == nil {
+		return messages
+	}
+	for i, msg := range messages {
+		if msg.ID == *compaction.LastMessageID {
+			return messages[i+1:]
+		}
+	}
+	return messages
+}
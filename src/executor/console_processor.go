@@ -21,6 +21,9 @@ type ConsoleProcessorConfig struct {
 // ConsoleEventProcessor processes events and outputs to console
 type ConsoleEventProcessor struct {
 	config ConsoleProcessorConfig
+
+	// streamed is set once chunks of the current assistant message were printed
+	streamed bool
 }
 
 // NewConsoleEventProcessor creates a new console event processor
@@ -54,10 +57,19 @@ func (p *ConsoleEventProcessor) Process(event ConversationEvent) error {
 		// No output needed for stream start
 		
 	case *AssistantStreamChunkEvent:
-		// Streaming is disabled, no output
+		// Whether a message is intermediate is only known once it is
+		// complete, so without intermediate messages nothing is streamed
+		// and the final message is printed when it arrives
+		if p.config.ShowIntermediateAI {
+			fmt.Print(e.Content)
+			p.streamed = true
+		}
 		
 	case *AssistantStreamEndEvent:
-		// No output needed for stream end
+		// Only set when chunks were printed
+		if p.streamed {
+			fmt.Println()
+		}
 		
 	case *AssistantMessageEvent:
 		p.processAssistantMessage(e)
@@ -71,6 +83,12 @@ func (p *ConsoleEventProcessor) Process(event ConversationEvent) error {
 	case *ToolCallErrorEvent:
 		p.processToolCallError(e)
 		
+	case *SubAgentEvent:
+		p.processSubAgentEvent(e)
+		
+	case *PermissionDecisionEvent:
+		// Denied calls are shown as tool errors
+		
 	case *SystemMessageEvent:
 		p.processSystemMessage(e)
 		
@@ -104,6 +122,12 @@ func (p *ConsoleEventProcessor) processUserMessage(e *UserMessageEvent) {
 
 // processAssistantMessage handles assistant message events
 func (p *ConsoleEventProcessor) processAssistantMessage(e *AssistantMessageEvent) {
+	// Content was already printed as it streamed in
+	if p.streamed {
+		p.streamed = false
+		return
+	}
+
 	// Only show intermediate AI responses if configured
 	if len(e.ToolCalls) > 0 && p.config.ShowIntermediateAI && e.Content != "" {
 		fmt.Printf("\n💭 Assistant: %s\n", e.Content)
@@ -160,6 +184,23 @@ func (p *ConsoleEventProcessor) processToolCallError(e *ToolCallErrorEvent) {
 	fmt.Println()
 }
 
+// processSubAgentEvent prints the progress of a sub-agent indented under the
+// tool call that runs it. Its final answer is shown as the tool's result.
+func (p *ConsoleEventProcessor) processSubAgentEvent(e *SubAgentEvent) {
+	switch inner := e.Event.(type) {
+	case *ToolCallRequestEvent:
+		fmt.Printf("   ↳ %s: %s %s\n", e.Description, inner.ToolCall.Function.Name, string(inner.ToolCall.Function.Arguments))
+	case *ToolCallErrorEvent:
+		fmt.Printf("   ↳ %s: ❌ %s failed: %v\n", e.Description, inner.ToolName, inner.Error)
+	case *AssistantMessageEvent:
+		if len(inner.ToolCalls) > 0 && p.config.ShowIntermediateAI && inner.Content != "" {
+			fmt.Printf("   ↳ %s: 💭 %s\n", e.Description, inner.Content)
+		}
+	case *ErrorEvent:
+		fmt.Printf("   ↳ %s: ❌ Error in %s: %v\n", e.Description, inner.Context, inner.Error)
+	}
+}
+
 // processSystemMessage handles system message events
 func (p *ConsoleEventProcessor) processSystemMessage(e *SystemMessageEvent) {
 	// Only show certain system messages
@@ -154,6 +154,24 @@ func (e *EventEmitter) EmitToolCallError(toolName, toolID string, err error, dur
 	return e.sink.Send(event)
 }
 
+// EmitPermissionDecision emits the permission decision of a tool call
+func (e *EventEmitter) EmitPermissionDecision(toolName, toolID, decision, reason string, prompted bool) error {
+	if e.sink == nil {
+		return nil
+	}
+	
+	event := &PermissionDecisionEvent{
+		BaseEvent: e.createBaseEvent(EventPermissionDecision),
+		ToolName:  toolName,
+		ToolID:    toolID,
+		Decision:  decision,
+		Reason:    reason,
+		Prompted:  prompted,
+	}
+	
+	return e.sink.Send(event)
+}
+
 // EmitSystemMessage emits a system message
 func (e *EventEmitter) EmitSystemMessage(message, purpose string) error {
 	if e.sink == nil {
@@ -21,9 +21,11 @@ const (
 	EventAssistantMessage     EventType = "assistant_message"
 	
 	// Tool events
-	EventToolCallRequest  EventType = "tool_call_request"
-	EventToolCallResponse EventType = "tool_call_response"
-	EventToolCallError    EventType = "tool_call_error"
+	EventToolCallRequest    EventType = "tool_call_request"
+	EventToolCallResponse   EventType = "tool_call_response"
+	EventToolCallError      EventType = "tool_call_error"
+	EventSubAgent           EventType = "sub_agent"
+	EventPermissionDecision EventType = "permission_decision"
 	
 	// System events
 	EventSystemMessage EventType = "system_message"
@@ -111,6 +113,25 @@ type ToolCallErrorEvent struct {
 	Duration time.Duration `json:"duration"`
 }
 
+// SubAgentEvent carries an event of a sub-agent run by a tool call, so the
+// sub-agent's progress is nested in the parent's event stream
+type SubAgentEvent struct {
+	BaseEvent
+	ToolCallID  string            `json:"tool_call_id"` // Call of the parent that runs the sub-agent
+	Description string            `json:"description"`
+	Event       ConversationEvent `json:"event"`
+}
+
+// PermissionDecisionEvent reports whether the permissions let a tool call run
+type PermissionDecisionEvent struct {
+	BaseEvent
+	ToolName string `json:"tool_name"`
+	ToolID   string `json:"tool_id"`
+	Decision string `json:"decision"` // "allow" or "deny"
+	Reason   string `json:"reason,omitempty"`
+	Prompted bool   `json:"prompted"` // Whether the confirmer was asked
+}
+
 // SystemMessageEvent represents system messages (like continuation prompts)
 type SystemMessageEvent struct {
 	BaseEvent
@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/orclient"
+	"github.com/elee1766/gofer/src/storage"
+)
+
+// Reasons for switching to a fallback model
+const (
+	FallbackRateLimit     = "rate_limit"
+	FallbackOverloaded    = "overloaded"
+	FallbackContextLength = "context_length"
+)
+
+// fallbackReason returns why a failed model call should be retried on the
+// next model, or false if another model would fail the same way
+func fallbackReason(err error) (string, bool) {
+	var windowErr *agent.ContextWindowError
+	if errors.As(err, &windowErr) {
+		return FallbackContextLength, true
+	}
+	var apiErr *orclient.APIError
+	if !errors.As(err, &apiErr) {
+		return "", false
+	}
+	switch {
+	case apiErr.IsRateLimit():
+		return FallbackRateLimit, true
+	case apiErr.IsContextLength():
+		return FallbackContextLength, true
+	case apiErr.IsOverloaded():
+		return FallbackOverloaded, true
+	}
+	return "", false
+}
+
+// complete sends the conversation of a step to a model
+func (s *Service) complete(ctx context.Context, req *StepRequest, conversation *aisdk.Conversation, modelClient aisdk.ModelClient, emitter *EventEmitter) (*aisdk.ChatCompletionResponse, error) {
+	agent := &agent.Agent{
+		SystemPrompt:   s.systemPrompt,
+		Model:          modelClient,
+		Toolbox:        req.Toolbox,
+		Logger:         s.logger,
+		ResponseFormat: req.ResponseFormat,
+		ContextWindow:  s.contextWindow,
+	}
+
+	// Forward content deltas as stream events. The stream start is emitted lazily
+	// so responses that only contain tool calls don't produce empty streams.
+	streamStarted := false
+	if req.Stream && emitter != nil {
+		model := modelClient.GetModelInfo().ID
+		agent.OnStreamChunk = func(chunk *aisdk.StreamChunk) error {
+			for _, choice := range chunk.Choices {
+				if choice.Delta.Content == "" {
+					continue
+				}
+				if !streamStarted {
+					emitter.EmitAssistantStreamStart(model)
+					streamStarted = true
+				}
+				emitter.EmitAssistantStreamChunk(choice.Delta.Content)
+			}
+			return nil
+		}
+	}
+
+	completion, err := agent.Complete(ctx, conversation, req.Message)
+	if streamStarted {
+		emitter.EmitAssistantStreamEnd()
+	}
+	return completion, err
+}
+
+// recordFallback announces the switch to the next model and saves it with
+// the conversation. Failing to save it does not fail the step.
+func (s *Service) recordFallback(ctx context.Context, req *StepRequest, emitter *EventEmitter, from, to aisdk.ModelClient, reason string, err error) {
+	fromModel, toModel := from.GetModelInfo().ID, to.GetModelInfo().ID
+	s.logger.Warn("Model call failed, switching to fallback model", "from", fromModel, "to", toModel, "reason", reason, "error", err)
+	if emitter != nil {
+		emitter.EmitSystemMessage(fmt.Sprintf("%s failed (%s), switching to %s", fromModel, reason, toModel), "warning")
+	}
+
+	if req.ConversationID == "" {
+		return
+	}
+	fallback := &storage.ModelFallback{
+		ConversationID: req.ConversationID,
+		Turn:           req.TurnNumber,
+		FromModel:      fromModel,
+		ToModel:        toModel,
+		Reason:         reason,
+		Error:          err.Error(),
+	}
+	if err := storage.CreateModelFallback(context.WithoutCancel(ctx), s.database, fallback); err != nil {
+		s.logger.Error("Failed to save model fallback", "error", err)
+	}
+}
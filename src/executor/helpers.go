@@ -3,26 +3,30 @@ package executor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/elee1766/gofer/src/agent"
 	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/audit"
 	"github.com/elee1766/gofer/src/storage"
-	"github.com/google/uuid"
 )
 
-// saveAssistantMessage saves an assistant message to the database
-func (s *Service) saveAssistantMessage(ctx context.Context, conversationID, model string, response *Response) error {
+// saveAssistantMessage saves an assistant message of the model of the named
+// provider to the database and returns its ID, or "" if there was nothing to
+// save
+func (s *Service) saveAssistantMessage(ctx context.Context, conversationID, provider, model string, response *Response) (string, error) {
 	// Don't save if both content and tool calls are empty
 	if response.Content == "" && len(response.ToolCalls) == 0 {
-		return nil
+		return "", nil
 	}
 
 	assistantMsg := &storage.Message{
 		ConversationID: conversationID,
 		Role:           "assistant",
-		Provider:       "openrouter",
+		Provider:       provider,
 		Model:          model,
 		Content:        response.Content,
 	}
@@ -31,118 +35,386 @@ func (s *Service) saveAssistantMessage(ctx context.Context, conversationID, mode
 	if len(response.ToolCalls) > 0 {
 		toolCallsJSON, err := json.Marshal(response.ToolCalls)
 		if err != nil {
-			return fmt.Errorf("failed to marshal tool calls: %w", err)
+			return "", fmt.Errorf("failed to marshal tool calls: %w", err)
 		}
 		toolCallsStr := string(toolCallsJSON)
 		assistantMsg.ToolCalls = &toolCallsStr
 	}
 
-	return storage.CreateMessage(ctx, s.database, assistantMsg)
+	if err := storage.CreateMessage(ctx, s.database, assistantMsg); err != nil {
+		return "", err
+	}
+	return assistantMsg.ID, nil
 }
 
-// executeTools executes the given tool calls and returns the results
-func (s *Service) executeTools(ctx context.Context, toolbox *agent.DefaultToolbox, conversationID, model string, callbacks *Callbacks, toolCalls []aisdk.ToolCall, emitter *EventEmitter) ([]*aisdk.Message, error) {
-	var toolResults []*aisdk.Message
+// saveUsage records the token usage and cost of a model call. The cost is
+// left empty when the model has no pricing.
+func (s *Service) saveUsage(ctx context.Context, conversationID, messageID string, model *aisdk.ModelInfo, usage aisdk.Usage) error {
+	record := &storage.ModelUsage{
+		ConversationID:   conversationID,
+		Model:            model.ID,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		CachedTokens:     usage.PromptTokensCached,
+		CacheWriteTokens: usage.PromptTokensCacheWrite,
+	}
+	if messageID != "" {
+		record.MessageID = &messageID
+	}
+	if cost, ok := aisdk.EstimateCost(model.Pricing, usage); ok {
+		record.PromptCost = &cost.PromptCost
+		record.CompletionCost = &cost.CompletionCost
+		record.TotalCost = &cost.TotalCost
+		record.Currency = cost.Currency
+	}
+	return storage.CreateModelUsage(ctx, s.database, record)
+}
 
-	for _, toolCall := range toolCalls {
-		s.logger.Debug("Executing tool", "name", toolCall.Function.Name, "id", toolCall.ID)
+// executeTools executes the given tool calls and returns the results in call
+// order. Consecutive parallel-safe calls run concurrently, up to the
+// service's worker limit; every other call waits for the calls before it to
+// finish and runs alone. When the context is cancelled no further calls are
+// started, and the results of calls that did not finish are nil.
+func (s *Service) executeTools(ctx context.Context, toolbox *agent.DefaultToolbox, conversationID, messageID, provider, model string, callbacks *Callbacks, toolCalls []aisdk.ToolCall, emitter *EventEmitter) ([]*aisdk.Message, error) {
+	toolResults := make([]*aisdk.Message, len(toolCalls))
+	errs := make([]error, len(toolCalls))
 
-		// Emit tool call request event
-		if emitter != nil {
-			emitter.EmitToolCallRequest(toolCall)
+	// mu serializes callbacks and database writes between concurrent calls
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, s.maxParallelTools)
+
+	for i, toolCall := range toolCalls {
+		if ctx.Err() != nil {
+			break
 		}
+		if !isParallelSafe(toolbox, toolCall) {
+			wg.Wait()
+			if err := firstError(errs); err != nil {
+				return nil, err
+			}
+			toolResults[i], errs[i] = s.executeTool(ctx, toolbox, conversationID, messageID, provider, model, callbacks, toolCall, emitter, &mu)
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			continue
+		}
+
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(i int, toolCall aisdk.ToolCall) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			toolResults[i], errs[i] = s.executeTool(ctx, toolbox, conversationID, messageID, provider, model, callbacks, toolCall, emitter, &mu)
+		}(i, toolCall)
+	}
+	wg.Wait()
 
-		// Legacy callback support
-		if callbacks != nil {
-			if err := callbacks.ToolCall(toolCall); err != nil {
-				return nil, fmt.Errorf("tool call callback failed: %w", err)
+	if err := firstError(errs); err != nil {
+		return nil, err
+	}
+
+	// Save the results in call order, so the conversation can be rebuilt
+	// as it was sent. Finished calls are saved even if the run was cancelled.
+	if conversationID != "" {
+		for _, toolMsg := range toolResults {
+			if toolMsg == nil {
+				continue
+			}
+			if err := s.saveToolMessage(context.WithoutCancel(ctx), conversationID, provider, model, toolMsg); err != nil {
+				s.logger.Error("Failed to save tool message", "error", err)
 			}
 		}
+	}
+	return toolResults, nil
+}
 
-		// Check if toolbox is available
-		if toolbox == nil {
-			// No toolbox, create error result
-			toolResults = append(toolResults, &aisdk.Message{
-				Role:       "tool",
-				Content:    "Tool execution not available: no toolbox configured",
-				Name:       toolCall.Function.Name,
-				ToolCallID: toolCall.ID,
-			})
-			continue
+// auditStatus returns the audit log status of an executed tool call
+func auditStatus(result *aisdk.ToolResponse, err error, cancelled bool) string {
+	switch {
+	case cancelled:
+		return audit.StatusCancelled
+	case err != nil || result == nil || result.IsError:
+		return audit.StatusError
+	}
+	return audit.StatusOK
+}
+
+// saveToolMessage saves a tool result message to the database
+func (s *Service) saveToolMessage(ctx context.Context, conversationID, provider, model string, toolMsg *aisdk.Message) error {
+	msg := &storage.Message{
+		ConversationID: conversationID,
+		Role:           "tool",
+		Provider:       provider,
+		Model:          model,
+		Content:        toolMsg.Content,
+		ToolCallID:     toolMsg.ToolCallID,
+		Name:           toolMsg.Name,
+	}
+	if toolMsg.MultimodalContent != nil {
+		data, err := json.Marshal(toolMsg.MultimodalContent)
+		if err != nil {
+			return fmt.Errorf("failed to marshal tool result content: %w", err)
 		}
+		content := string(data)
+		msg.MultimodalContent = &content
+	}
+	if err := storage.CreateMessage(ctx, s.database, msg); err != nil {
+		return err
+	}
+	toolMsg.ID = msg.ID
+	return nil
+}
 
-		// Find the tool
-		tool, found := toolbox.GetTool(toolCall.Function.Name)
-		if !found {
-			// Tool not found, create error result
-			toolResults = append(toolResults, &aisdk.Message{
-				Role:       "tool",
-				Content:    fmt.Sprintf("Tool not found: %s", toolCall.Function.Name),
-				Name:       toolCall.Function.Name,
-				ToolCallID: toolCall.ID,
-			})
-			continue
+// isParallelSafe reports whether a tool call can run concurrently with other
+// calls. Calls to unknown tools only produce an error result and are safe.
+func isParallelSafe(toolbox *agent.DefaultToolbox, toolCall aisdk.ToolCall) bool {
+	if toolbox == nil {
+		return true
+	}
+	tool, found := toolbox.GetTool(toolCall.Function.Name)
+	return !found || agent.IsParallelSafe(tool)
+}
+
+// firstError returns the first non-nil error
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		// Execute the tool
-		startTime := time.Now()
-		result, execErr := tool.Execute(ctx, &toolCall)
-		duration := time.Since(startTime)
+// toolCallScopeKey is the context key of the tool call a tool runs for
+type toolCallScopeKey struct{}
 
-		// Save tool execution to database
-		var output, errorStr string
-		if execErr != nil {
-			errorStr = execErr.Error()
-			output = fmt.Sprintf("Error: %s", execErr.Error())
-		} else if result != nil {
-			output = string(result.Content)
+// toolCallScope describes the tool call a tool runs for, so tools of this
+// package can act on behalf of the parent's run
+type toolCallScope struct {
+	conversationID string
+	messageID      string
+	toolCall       aisdk.ToolCall // The call as it runs, after middleware rewrote it
+	emitter        *EventEmitter  // Without a sink when the run has none
+
+	// recheck validates and checks the permissions of a rewritten call
+	recheck func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error)
+}
+
+// withToolCallScope returns a context for executing a tool call
+func withToolCallScope(ctx context.Context, scope *toolCallScope) context.Context {
+	return context.WithValue(ctx, toolCallScopeKey{}, scope)
+}
+
+// ToolCallInfo describes the tool call a tool is executed for
+type ToolCallInfo struct {
+	ConversationID string
+	MessageID      string // Saved assistant message that made the call
+	TurnNumber     int
+	ToolCall       aisdk.ToolCall
+}
+
+// ToolCallInfoFromContext returns the tool call a tool or toolbox middleware
+// is executed for, when the executor runs it
+func ToolCallInfoFromContext(ctx context.Context) (ToolCallInfo, bool) {
+	scope, ok := ctx.Value(toolCallScopeKey{}).(*toolCallScope)
+	if !ok {
+		return ToolCallInfo{}, false
+	}
+	return ToolCallInfo{
+		ConversationID: scope.conversationID,
+		MessageID:      scope.messageID,
+		TurnNumber:     scope.emitter.turnNumber,
+		ToolCall:       scope.toolCall,
+	}, true
+}
+
+// CheckRewrittenToolCall validates a call whose arguments toolbox middleware
+// rewrote and checks its permissions, as the executor did for the original
+// call. It returns the result of a call that must not run, or nil. The
+// executor records the rewritten call as the call that ran.
+func CheckRewrittenToolCall(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+	scope, ok := ctx.Value(toolCallScopeKey{}).(*toolCallScope)
+	if !ok || scope.recheck == nil {
+		return nil, nil
+	}
+	return scope.recheck(ctx, call)
+}
+
+// executeTool executes a single tool call and returns its result message, or
+// nil if the context was cancelled before the call finished
+func (s *Service) executeTool(ctx context.Context, toolbox *agent.DefaultToolbox, conversationID, messageID, provider, model string, callbacks *Callbacks, toolCall aisdk.ToolCall, emitter *EventEmitter, mu *sync.Mutex) (*aisdk.Message, error) {
+	s.logger.Debug("Executing tool", "name", toolCall.Function.Name, "id", toolCall.ID)
+
+	mu.Lock()
+	// Emit tool call request event
+	if emitter != nil {
+		emitter.EmitToolCallRequest(toolCall)
+	}
+
+	// Legacy callback support
+	err := callbacks.ToolCall(toolCall)
+	mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("tool call callback failed: %w", err)
+	}
+
+	// Check if toolbox is available
+	if toolbox == nil {
+		// No toolbox, create error result
+		return &aisdk.Message{
+			Role:       "tool",
+			Content:    "Tool execution not available: no toolbox configured",
+			Name:       toolCall.Function.Name,
+			ToolCallID: toolCall.ID,
+			IsError:    true,
+		}, nil
+	}
+
+	// Reject calls to unknown tools and calls with invalid arguments before
+	// they reach the tool. They are not tool executions: the model gets the
+	// error as the result and can retry.
+	if invalid := s.validateToolCall(toolbox, toolCall); invalid != nil {
+		s.logger.Debug("Rejected invalid tool call", "name", toolCall.Function.Name, "id", toolCall.ID, "error", invalid)
+		s.audit.ToolCall(conversationID, toolCall, audit.StatusInvalid, invalid.content(), 0)
+		mu.Lock()
+		if emitter != nil {
+			emitter.EmitToolCallError(toolCall.Function.Name, toolCall.ID, invalid, 0)
 		}
+		mu.Unlock()
+		return &aisdk.Message{
+			Role:       "tool",
+			Content:    invalid.content(),
+			Name:       toolCall.Function.Name,
+			ToolCallID: toolCall.ID,
+			IsError:    true,
+		}, nil
+	}
 
-		// Emit tool response/error event
+	// Calls the permissions deny are not tool executions either
+	denied, err := s.checkToolPermission(ctx, conversationID, toolCall, emitter, mu)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if denied != nil {
+		s.audit.ToolCall(conversationID, toolCall, audit.StatusDenied, denied.Error(), 0)
+		mu.Lock()
 		if emitter != nil {
-			if execErr != nil {
-				emitter.EmitToolCallError(toolCall.Function.Name, toolCall.ID, execErr, duration)
-			} else {
-				emitter.EmitToolCallResponse(toolCall.Function.Name, toolCall.ID, result, duration)
-			}
+			emitter.EmitToolCallError(toolCall.Function.Name, toolCall.ID, denied, 0)
 		}
+		mu.Unlock()
+		return &aisdk.Message{
+			Role:       "tool",
+			Content:    denied.Error(),
+			Name:       toolCall.Function.Name,
+			ToolCallID: toolCall.ID,
+			IsError:    true,
+		}, nil
+	}
 
-		// Get the assistant message ID from the database
-		// This is a bit hacky, but we need it for the tool execution record
-		assistantMsgID := uuid.New().String() // For now, generate a new ID
+	// Execute the tool through the toolbox middleware
+	scope := &toolCallScope{conversationID: conversationID, messageID: messageID, toolCall: toolCall, emitter: emitter}
+	scope.recheck = func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+		scope.toolCall = *call
+		if invalid := s.validateToolCall(toolbox, *call); invalid != nil {
+			s.logger.Debug("Rejected invalid rewritten tool call", "name", call.Function.Name, "id", call.ID, "error", invalid)
+			return aisdk.NewErrorToolResponse(invalid.content()), nil
+		}
+		denied, err := s.checkToolPermission(ctx, conversationID, *call, emitter, mu)
+		if err != nil {
+			return nil, err
+		}
+		if denied != nil {
+			return aisdk.NewErrorToolResponse(denied.Error()), nil
+		}
+		return nil, nil
+	}
+	startTime := time.Now()
+	result, execErr := toolbox.ExecuteTool(withToolCallScope(ctx, scope), &toolCall)
+	duration := time.Since(startTime)
+	// The call is recorded with the arguments it ran with
+	ran := scope.toolCall
 
+	// Save tool execution to database
+	var output, errorStr string
+	if execErr != nil {
+		errorStr = execErr.Error()
+		output = fmt.Sprintf("Error: %s", execErr.Error())
+	} else if result != nil {
+		output = string(result.Content)
+	}
+
+	// A call that returns after cancellation did not finish, whatever it returned
+	cancelled := ctx.Err() != nil
+	if cancelled {
+		execErr = ctx.Err()
+		errorStr = execErr.Error()
+		output = fmt.Sprintf("Error: %s", errorStr)
+	}
+
+	// Nothing past this point sees the secrets of the result
+	output = s.redactor.Redact(output)
+	result = s.redactor.RedactResponse(result)
+	if redacted := s.redactor.Redact(errorStr); redacted != errorStr {
+		errorStr = redacted
+		execErr = errors.New(redacted)
+	}
+	s.audit.ToolCall(conversationID, ran, auditStatus(result, execErr, cancelled), output, duration)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Emit tool response/error event
+	if emitter != nil {
+		if execErr != nil {
+			emitter.EmitToolCallError(toolCall.Function.Name, toolCall.ID, execErr, duration)
+		} else {
+			emitter.EmitToolCallResponse(toolCall.Function.Name, toolCall.ID, result, duration)
+		}
+	}
+
+	if conversationID != "" {
 		toolExec := &storage.ToolExecution{
-			MessageID:      assistantMsgID,
+			ToolCallID:     toolCall.ID,
 			ConversationID: conversationID,
-			Provider:       "openrouter",
+			Provider:       provider,
 			Model:          model,
 			ToolName:       toolCall.Function.Name,
-			Input:          string(toolCall.Function.Arguments),
+			Input:          string(ran.Function.Arguments),
 			Output:         output,
 			Error:          errorStr,
 			DurationMs:     duration.Milliseconds(),
 		}
-		err := storage.CreateToolExecution(ctx, s.database, toolExec)
-		if err != nil {
-			s.logger.Error("Failed to save tool execution", "error", err)
+		if messageID != "" {
+			toolExec.MessageID = &messageID
 		}
-
-		// Callback after tool execution
-		if callbacks != nil {
-			if err := callbacks.ToolResult(toolCall.Function.Name, result, execErr); err != nil {
-				return nil, fmt.Errorf("tool result callback failed: %w", err)
-			}
+		if err := storage.CreateToolExecution(context.WithoutCancel(ctx), s.database, toolExec); err != nil {
+			s.logger.Error("Failed to save tool execution", "error", err)
 		}
+	}
+	if cancelled {
+		return nil, nil
+	}
 
-		// Create tool result message
-		toolResults = append(toolResults, &aisdk.Message{
-			Role:       "tool",
-			Content:    output,
-			Name:       toolCall.Function.Name,
-			ToolCallID: toolCall.ID,
-		})
+	// Callback after tool execution
+	if err := callbacks.ToolResult(toolCall.Function.Name, result, execErr); err != nil {
+		return nil, fmt.Errorf("tool result callback failed: %w", err)
 	}
 
-	return toolResults, nil
+	// Create tool result message, keeping multimodal content such as images
+	// so providers can send it in their native format
+	toolMsg := &aisdk.Message{
+		Role:       "tool",
+		Content:    output,
+		Name:       toolCall.Function.Name,
+		ToolCallID: toolCall.ID,
+		IsError:    execErr != nil || (result != nil && result.IsError),
+	}
+	if execErr == nil && result != nil && result.MultimodalContent != nil && result.MultimodalContent.HasImages() {
+		toolMsg.MultimodalContent = result.MultimodalContent
+	}
+	return toolMsg, nil
 }
-
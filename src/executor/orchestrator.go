@@ -20,6 +20,10 @@ type StepRequest struct {
 	// Model client to use
 	ModelClient aisdk.ModelClient
 
+	// FallbackModels are tried in order when a model call fails with a
+	// rate limit, overload or context length error
+	FallbackModels []aisdk.ModelClient
+
 	// Session and conversation IDs for persistence
 	SessionID      string
 	ConversationID string
@@ -35,6 +39,12 @@ type StepRequest struct {
 	
 	// Current turn number
 	TurnNumber int
+
+	// Stream the response and emit chunk events as content arrives
+	Stream bool
+
+	// Optional response format, e.g. a JSON schema for structured output
+	ResponseFormat *aisdk.ResponseFormat
 }
 
 // StepResult represents the result of a single execution step
@@ -51,11 +61,22 @@ type StepResult struct {
 	// Tool results to send back to model (if State == StateToolCallsCompleted)
 	ToolResults []*aisdk.Message
 
+	// Tool calls that did not finish because the context was cancelled. The
+	// results of the calls that finished are in ToolResults.
+	PendingToolCalls []aisdk.ToolCall
+
 	// Error information (if State == StateError)
 	Error error
 
 	// Updated conversation with new messages added
 	UpdatedConversation *aisdk.Conversation
+
+	// ID of the saved assistant message, if it was saved
+	MessageID string
+
+	// ModelClient that answered, one of the fallback models if the
+	// request's model failed
+	ModelClient aisdk.ModelClient
 }
 
 // Step executes a single conversation step and returns the immediate result
@@ -81,49 +102,73 @@ func (s *Service) Step(ctx context.Context, req *StepRequest) (*StepResult, erro
 		emitter.EmitUserMessage(req.Message.Content, false, "", 0)
 	}
 
-	// Create agent
-	agent := &agent.Agent{
-		SystemPrompt: s.systemPrompt,
-		Model:        req.ModelClient,
-		Toolbox:      req.Toolbox,
-		Logger:       s.logger,
+	// Summarize older messages before the conversation outgrows the context
+	conversation := req.Conversation
+	if s.autoCompact {
+		conversation = s.maybeCompact(ctx, req)
 	}
 
-	// Send message and get response
-	assistantMsg, err := agent.SendMessage(ctx, req.Conversation, req.Message)
+	// Send message and get response, switching to the next fallback model
+	// while the calls fail in ways another model may not
+	var modelClient aisdk.ModelClient
+	models := append([]aisdk.ModelClient{req.ModelClient}, req.FallbackModels...)
+	var completion *aisdk.ChatCompletionResponse
+	var err error
+	for i := range models {
+		modelClient = models[i]
+		completion, err = s.complete(ctx, req, conversation, modelClient, emitter)
+		if err == nil || i == len(models)-1 || ctx.Err() != nil {
+			break
+		}
+		reason, ok := fallbackReason(err)
+		if !ok {
+			break
+		}
+		s.recordFallback(ctx, req, emitter, modelClient, models[i+1], reason, err)
+	}
 	if err != nil {
 		if emitter != nil {
 			emitter.EmitError(err, "agent.SendMessage")
 		}
 		return &StepResult{State: StateError, Error: err}, nil
 	}
+	assistantMsg := &completion.Choices[0].Message
 	
 	// Convert to our Response type
 	response := &Response{
 		Content:   assistantMsg.Content,
 		ToolCalls: assistantMsg.ToolCalls,
+		Usage:     completion.Usage,
 	}
+	s.audit.ModelCall(req.ConversationID, modelClient.GetModelInfo().ID, completion.Usage)
 
 
 	// Emit assistant message event
 	if emitter != nil {
-		emitter.EmitAssistantMessage(response.Content, response.ToolCalls, req.ModelClient.GetModelInfo().ID)
+		emitter.EmitAssistantMessage(response.Content, response.ToolCalls, modelClient.GetModelInfo().ID)
 	}
 
-	// Save assistant response if we have session info
+	// Save assistant response and its usage if we have session info
+	var messageID string
 	if req.ConversationID != "" {
-		model := req.ModelClient.GetModelInfo().ID
-		if err := s.saveAssistantMessage(ctx, req.ConversationID, model, response); err != nil {
+		modelInfo := modelClient.GetModelInfo()
+		var err error
+		messageID, err = s.saveAssistantMessage(ctx, req.ConversationID, aisdk.ProviderName(modelClient), modelInfo.ID, response)
+		if err != nil {
 			s.logger.Error("Failed to save assistant message", "error", err)
 			// Don't fail the whole operation, just log the error
 		}
+		assistantMsg.ID = messageID
+		if err := s.saveUsage(ctx, req.ConversationID, messageID, modelInfo, response.Usage); err != nil {
+			s.logger.Error("Failed to save usage", "error", err)
+		}
 	}
 
 	// Update conversation with new messages
 	updatedConv := &aisdk.Conversation{
-		Messages: make([]*aisdk.Message, len(req.Conversation.Messages)),
+		Messages: make([]*aisdk.Message, len(conversation.Messages)),
 	}
-	copy(updatedConv.Messages, req.Conversation.Messages)
+	copy(updatedConv.Messages, conversation.Messages)
 
 	// Add user message if provided
 	if req.Message != nil {
@@ -140,6 +185,8 @@ func (s *Service) Step(ctx context.Context, req *StepRequest) (*StepResult, erro
 			Response:            response,
 			ToolCalls:           response.ToolCalls,
 			UpdatedConversation: updatedConv,
+			MessageID:           messageID,
+			ModelClient:         modelClient,
 		}, nil
 	}
 
@@ -147,23 +194,35 @@ func (s *Service) Step(ctx context.Context, req *StepRequest) (*StepResult, erro
 		State:               StateTextResponse,
 		Response:            response,
 		UpdatedConversation: updatedConv,
+		MessageID:           messageID,
+		ModelClient:         modelClient,
 	}, nil
 }
 
 // ExecuteToolCalls executes the given tool calls and returns results ready to send back
 func (s *Service) ExecuteToolCalls(ctx context.Context, req *ToolExecutionRequest) (*StepResult, error) {
-	// Create event emitter if we have an event sink
-	var emitter *EventEmitter
-	if req.EventSink != nil {
-		emitter = NewEventEmitter(req.EventSink, req.ConversationID, req.TurnNumber)
-	}
+	// The emitter also carries the turn to the tools, it only emits events
+	// when there is a sink
+	emitter := NewEventEmitter(req.EventSink, req.ConversationID, req.TurnNumber)
 
 	// Execute tools using updated helper
-	toolResults, err := s.executeTools(ctx, req.Toolbox, req.ConversationID, req.Model, req.Callbacks, req.ToolCalls, emitter)
+	toolResults, err := s.executeTools(ctx, req.Toolbox, req.ConversationID, req.MessageID, req.Provider, req.Model, req.Callbacks, req.ToolCalls, emitter)
 	if err != nil {
 		return &StepResult{State: StateError, Error: err}, nil
 	}
 
+	if ctx.Err() != nil {
+		result := &StepResult{State: StateError, Error: ctx.Err()}
+		for i, toolMsg := range toolResults {
+			if toolMsg == nil {
+				result.PendingToolCalls = append(result.PendingToolCalls, req.ToolCalls[i])
+			} else {
+				result.ToolResults = append(result.ToolResults, toolMsg)
+			}
+		}
+		return result, nil
+	}
+
 	return &StepResult{
 		State:       StateToolCallsCompleted,
 		ToolResults: toolResults,
@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/config"
+)
+
+// Permission decisions
+const (
+	PermissionAllow = "allow"
+	PermissionDeny  = "deny"
+)
+
+// PermissionChecker decides whether a tool call may run.
+// config.PermissionChecker implements it.
+type PermissionChecker interface {
+	CheckToolPermission(toolName string, args map[string]interface{}) (config.PermissionResult, error)
+}
+
+// ConfirmationRequest asks whether a tool call that requires confirmation
+// may run
+type ConfirmationRequest struct {
+	ConversationID string
+	ToolCall       aisdk.ToolCall
+	Message        string // Confirmation message of the matching permission rule
+}
+
+// Confirmer asks whether a tool call may run when the permissions require
+// confirmation. Calls are only confirmed one at a time.
+type Confirmer interface {
+	Confirm(ctx context.Context, req *ConfirmationRequest) (bool, error)
+}
+
+// ConfirmerFunc adapts a function, such as a callback of an application
+// embedding the executor, to a Confirmer
+type ConfirmerFunc func(ctx context.Context, req *ConfirmationRequest) (bool, error)
+
+// Confirm calls f
+func (f ConfirmerFunc) Confirm(ctx context.Context, req *ConfirmationRequest) (bool, error) {
+	return f(ctx, req)
+}
+
+// DenyConfirmer denies every call that requires confirmation, for runs
+// without anyone to ask
+type DenyConfirmer struct{}
+
+// Confirm denies the call
+func (DenyConfirmer) Confirm(ctx context.Context, req *ConfirmationRequest) (bool, error) {
+	return false, nil
+}
+
+// PermissionDeniedError is the result of a tool call the permissions denied.
+// It is sent to the model as the result of the call.
+type PermissionDeniedError struct {
+	Tool   string
+	Reason string
+}
+
+func (e *PermissionDeniedError) Error() string {
+	return fmt.Sprintf("Permission denied for %s: %s", e.Tool, e.Reason)
+}
+
+// checkToolPermission evaluates a tool call against the service's
+// permissions, asking the confirmer when they require confirmation, and
+// emits the decision. It returns nil when the call may run. Without
+// permissions every call may run.
+func (s *Service) checkToolPermission(ctx context.Context, conversationID string, toolCall aisdk.ToolCall, emitter *EventEmitter, mu *sync.Mutex) (*PermissionDeniedError, error) {
+	if s.permissions == nil {
+		return nil, nil
+	}
+	name := toolCall.Function.Name
+
+	// The arguments were validated, so they are an object if present
+	var args map[string]interface{}
+	if len(bytes.TrimSpace(toolCall.Function.Arguments)) > 0 {
+		if err := json.Unmarshal(toolCall.Function.Arguments, &args); err != nil {
+			return nil, fmt.Errorf("failed to decode arguments of %s: %w", name, err)
+		}
+	}
+	result, err := s.permissions.CheckToolPermission(name, args)
+	if err != nil {
+		result = config.PermissionResult{Reason: fmt.Sprintf("permission check failed: %v", err)}
+	}
+
+	// Rules with the "prompt" action are not allowed but require
+	// confirmation, so confirmation decides whatever Allowed says
+	prompted := result.RequiresConfirmation
+	if prompted {
+		confirmed, err := s.confirm(ctx, &ConfirmationRequest{
+			ConversationID: conversationID,
+			ToolCall:       toolCall,
+			Message:        result.ConfirmationMessage,
+		})
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		switch {
+		case err != nil:
+			result = config.PermissionResult{Reason: fmt.Sprintf("confirmation failed: %v", err)}
+		case !confirmed:
+			result = config.PermissionResult{Reason: "the call was not confirmed"}
+		default:
+			result = config.PermissionResult{Allowed: true, Reason: "confirmed"}
+		}
+	}
+
+	decision := PermissionAllow
+	if !result.Allowed {
+		decision = PermissionDeny
+		s.logger.Info("Tool call denied", "name", name, "id", toolCall.ID, "reason", result.Reason, "prompted", prompted)
+	}
+	s.audit.Permission(conversationID, name, toolCall.ID, decision, result.Reason, prompted)
+	mu.Lock()
+	if emitter != nil {
+		emitter.EmitPermissionDecision(name, toolCall.ID, decision, result.Reason, prompted)
+	}
+	mu.Unlock()
+
+	if !result.Allowed {
+		return &PermissionDeniedError{Tool: name, Reason: result.Reason}, nil
+	}
+	return nil, nil
+}
+
+// confirm asks the confirmer about a call, one call at a time
+func (s *Service) confirm(ctx context.Context, req *ConfirmationRequest) (bool, error) {
+	s.confirmMu.Lock()
+	defer s.confirmMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.confirmer.Confirm(ctx, req)
+}
@@ -32,8 +32,13 @@ type ToolExecutionRequest struct {
 	SessionID      string
 	ConversationID string
 
-	// Model info for logging
-	Model string
+	// ID of the saved assistant message that made the calls
+	// (StepResult.MessageID), linked from the saved tool executions
+	MessageID string
+
+	// Model that made the calls, and the name of its provider
+	Model    string
+	Provider string
 
 	// Optional callbacks (deprecated - use EventSink)
 	Callbacks *Callbacks
@@ -11,10 +11,12 @@ import (
 type Response struct {
 	Content   string
 	ToolCalls []aisdk.ToolCall
+	Usage     aisdk.Usage
 }
 
-// buildAISDKConversation creates an aisdk.Conversation from storage messages
-func buildAISDKConversation(conversation *storage.Conversation, messages []storage.Message, systemPrompt string) *aisdk.Conversation {
+// buildAISDKConversation creates an aisdk.Conversation from storage messages.
+// The messages covered by the compaction, if any, are replaced by its summary.
+func buildAISDKConversation(conversation *storage.Conversation, messages []storage.Message, systemPrompt string, compaction *storage.Compaction) *aisdk.Conversation {
 	aisdkConv := &aisdk.Conversation{
 		ID:           conversation.ID,
 		SystemPrompt: systemPrompt,
@@ -22,8 +24,16 @@ func buildAISDKConversation(conversation *storage.Conversation, messages []stora
 		CreatedAt:    conversation.CreatedAt,
 	}
 
+	if compaction != nil {
+		messages = afterCompaction(messages, compaction)
+		aisdkConv.Messages = append(aisdkConv.Messages, &aisdk.Message{
+			Role:    summaryRole,
+			Content: compaction.Summary,
+		})
+	}
+
 	// Add system prompt if no messages exist
-	if len(messages) == 0 && systemPrompt != "" {
+	if len(messages) == 0 && compaction == nil && systemPrompt != "" {
 		aisdkConv.Messages = append(aisdkConv.Messages, &aisdk.Message{
 			Role:    "system",
 			Content: systemPrompt,
@@ -32,8 +42,11 @@ func buildAISDKConversation(conversation *storage.Conversation, messages []stora
 		// Convert existing messages
 		for _, msg := range messages {
 			aisdkMsg := &aisdk.Message{
-				Role:    msg.Role,
-				Content: msg.Content,
+				Role:       msg.Role,
+				Content:    msg.Content,
+				Name:       msg.Name,
+				ToolCallID: msg.ToolCallID,
+				ID:         msg.ID,
 			}
 			
 			// Parse tool calls if present
@@ -43,10 +56,63 @@ func buildAISDKConversation(conversation *storage.Conversation, messages []stora
 					aisdkMsg.ToolCalls = toolCalls
 				}
 			}
+
+			// Restore tool results with images
+			if msg.MultimodalContent != nil && *msg.MultimodalContent != "" {
+				var content aisdk.MultimodalContent
+				if err := json.Unmarshal([]byte(*msg.MultimodalContent), &content); err == nil {
+					aisdkMsg.MultimodalContent = &content
+				}
+			}
 			
 			aisdkConv.Messages = append(aisdkConv.Messages, aisdkMsg)
 		}
 	}
 
+	aisdkConv.Messages = pairToolResults(aisdkConv.Messages)
+
 	return aisdkConv
-}
\ No newline at end of file
+}
+
+// interruptedToolResult is the result of tool calls that have no saved result,
+// such as calls of a run that was interrupted
+const interruptedToolResult = "Tool call was interrupted before it returned a result"
+
+// pairToolResults makes a rebuilt conversation valid for providers, which
+// require a result after every tool call of an assistant message and no
+// other tool messages. Calls without a saved result get an error result and
+// results that answer no preceding call are dropped.
+func pairToolResults(messages []*aisdk.Message) []*aisdk.Message {
+	paired := make([]*aisdk.Message, 0, len(messages))
+	for i := 0; i < len(messages); i++ {
+		msg := messages[i]
+		if msg.Role == "tool" {
+			// Results that follow no assistant message with calls
+			continue
+		}
+		paired = append(paired, msg)
+		if msg.Role != "assistant" || len(msg.ToolCalls) == 0 {
+			continue
+		}
+
+		results := make(map[string]*aisdk.Message)
+		for i+1 < len(messages) && messages[i+1].Role == "tool" {
+			i++
+			results[messages[i].ToolCallID] = messages[i]
+		}
+		for _, tc := range msg.ToolCalls {
+			result, ok := results[tc.ID]
+			if !ok {
+				result = &aisdk.Message{
+					Role:       "tool",
+					Content:    interruptedToolResult,
+					Name:       tc.Function.Name,
+					ToolCallID: tc.ID,
+					IsError:    true,
+				}
+			}
+			paired = append(paired, result)
+		}
+	}
+	return paired
+}
@@ -0,0 +1,494 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/storage"
+)
+
+// StopReason is why a run ended
+type StopReason string
+
+const (
+	// StopCompleted means the model answered without calling tools
+	StopCompleted StopReason = "task_complete"
+	// StopMaxTurns means the run used up its turns
+	StopMaxTurns StopReason = "max_turns"
+	// StopTokenBudget means the run's model calls used up the token budget
+	StopTokenBudget StopReason = "token_budget"
+	// StopInterrupted means the run was interrupted or its context cancelled
+	StopInterrupted StopReason = "interrupted"
+)
+
+// RunState is the progress of a run, passed to stop conditions and hooks
+type RunState struct {
+	// Turns used so far; structured output repairs don't use turns
+	Turn     int
+	MaxTurns int
+
+	// Conversation as sent to the model so far
+	Conversation *aisdk.Conversation
+
+	// LastStep is the result of the latest step, nil before the first
+	LastStep *StepResult
+
+	// Usage is the total token usage of the run's model calls
+	Usage aisdk.Usage
+}
+
+// TurnsRemaining returns the number of turns the run has left
+func (s *RunState) TurnsRemaining() int {
+	return s.MaxTurns - s.Turn
+}
+
+// StopCondition is checked after every step. It returns true and the reason
+// when the run should end even though the model wants to continue.
+type StopCondition func(state *RunState) (StopReason, bool)
+
+// TokenBudget stops the run once its model calls used more than tokens
+func TokenBudget(tokens int) StopCondition {
+	return func(state *RunState) (StopReason, bool) {
+		used := state.Usage.TotalTokens
+		if used == 0 {
+			used = state.Usage.PromptTokens + state.Usage.CompletionTokens
+		}
+		return StopTokenBudget, used >= tokens
+	}
+}
+
+// StopWhen stops the run with the given reason once fn returns true
+func StopWhen(reason StopReason, fn func(state *RunState) bool) StopCondition {
+	return func(state *RunState) (StopReason, bool) {
+		return reason, fn(state)
+	}
+}
+
+// RunPolicy controls how long a run goes on and what the model returns
+type RunPolicy struct {
+	// MaxTurns is the number of model steps the run may use, defaults to
+	// the service's MaxTurns. A step and the tool calls it asks for are
+	// one turn.
+	MaxTurns int
+
+	// StopConditions can end the run early. The run always ends when the
+	// model answers without tool calls, when it runs out of turns, and
+	// when it is interrupted.
+	StopConditions []StopCondition
+
+	// Stream the responses and emit chunk events as content arrives
+	Stream bool
+
+	// ResponseFormat requests structured output. Responses that don't
+	// match a JSON schema are sent back for repair up to MaxRepairs times
+	// (default aisdk.DefaultStructuredRepairs); repairs don't use turns.
+	ResponseFormat *aisdk.ResponseFormat
+	MaxRepairs     int
+}
+
+// RunHooks are called at points of a run. An error returned by a hook ends
+// the run with that error.
+type RunHooks struct {
+	// OnPromptSubmit is called with the prompt before it is saved and
+	// sent, and may rewrite it
+	OnPromptSubmit func(ctx context.Context, prompt string) (string, error)
+
+	// BeforeStep is called before each model request
+	BeforeStep func(ctx context.Context, state *RunState) error
+
+	// AfterStep is called with the result of each model request
+	AfterStep func(ctx context.Context, state *RunState, result *StepResult) error
+
+	// BeforeToolCalls is called with the tool calls of a step before they run
+	BeforeToolCalls func(ctx context.Context, state *RunState, toolCalls []aisdk.ToolCall) error
+
+	// AfterToolCalls is called with the tool results of a step
+	AfterToolCalls func(ctx context.Context, state *RunState, results []*aisdk.Message) error
+
+	// OnStop is called when the run ends, also when it ends with an error
+	OnStop func(ctx context.Context, result *RunResult, err error)
+}
+
+// RunnerConfig holds the configuration of a Runner
+type RunnerConfig struct {
+	ModelClient aisdk.ModelClient
+
+	// FallbackModels are tried in order when a model call fails with a
+	// rate limit, overload or context length error. Once a fallback model
+	// answers, the rest of the run uses it.
+	FallbackModels []aisdk.ModelClient
+
+	// Toolbox is optional; without it the model is offered no tools
+	Toolbox *agent.DefaultToolbox
+
+	// EventSink receives the events of the run, optional
+	EventSink EventSink
+
+	// Callbacks are passed to the tool calls (deprecated - use EventSink)
+	Callbacks *Callbacks
+
+	Policy RunPolicy
+	Hooks  RunHooks
+}
+
+// RunRequest is a prompt to run in a conversation
+type RunRequest struct {
+	Prompt string
+
+	// Session and Conversation the run is saved to. Without a conversation
+	// nothing is saved.
+	Session      *storage.Session
+	Conversation *storage.Conversation
+
+	// History is the conversation so far. When nil it is built from the
+	// saved messages of Conversation.
+	History *aisdk.Conversation
+}
+
+// RunResult is the outcome of a run
+type RunResult struct {
+	StopReason StopReason
+
+	// Response is the last response of the model
+	Response *Response
+
+	// Structured is the validated JSON response when the policy has a
+	// JSON schema response format
+	Structured []byte
+
+	// Conversation with the messages of the run added
+	Conversation *aisdk.Conversation
+
+	// Turns used and total token usage of the run
+	Turns int
+	Usage aisdk.Usage
+}
+
+// Runner runs the agent turn loop: it sends a prompt, executes the tool
+// calls the model asks for and sends their results back until a stop
+// condition is met. It is the loop frontends embed instead of driving
+// Step and ExecuteToolCalls themselves.
+type Runner struct {
+	service     *Service
+	config      RunnerConfig
+	interrupted atomic.Bool
+}
+
+// NewRunner creates a runner that executes steps with the service
+func NewRunner(service *Service, config RunnerConfig) *Runner {
+	if config.Policy.MaxTurns <= 0 {
+		config.Policy.MaxTurns = service.maxTurns
+	}
+	if config.Policy.MaxRepairs <= 0 {
+		config.Policy.MaxRepairs = aisdk.DefaultStructuredRepairs
+	}
+	return &Runner{service: service, config: config}
+}
+
+// Interrupt ends the current run after the step or tool calls in progress,
+// with StopInterrupted. To stop in-flight requests and tools as well, cancel
+// the run's context instead.
+func (r *Runner) Interrupt() {
+	r.interrupted.Store(true)
+}
+
+// Run runs a prompt until a stop condition is met
+func (r *Runner) Run(ctx context.Context, req *RunRequest) (result *RunResult, err error) {
+	r.interrupted.Store(false)
+	hooks := r.config.Hooks
+	policy := r.config.Policy
+
+	state := &RunState{MaxTurns: policy.MaxTurns}
+	result = &RunResult{}
+	defer func() {
+		result.Turns = state.Turn
+		result.Usage = state.Usage
+		result.Conversation = state.Conversation
+		if hooks.OnStop != nil {
+			hooks.OnStop(ctx, result, err)
+		}
+	}()
+
+	var sessionID, conversationID string
+	if req.Session != nil {
+		sessionID = req.Session.ID
+	}
+	if req.Conversation != nil {
+		conversationID = req.Conversation.ID
+	}
+
+	state.Conversation = req.History
+	if state.Conversation == nil {
+		state.Conversation = &aisdk.Conversation{}
+		if req.Conversation != nil {
+			state.Conversation, err = r.service.BuildConversationFromDB(ctx, req.Conversation, r.service.systemPrompt)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// Without a prompt the run continues the conversation, for example
+	// after the results of an interrupted run's tool calls
+	var message *aisdk.Message
+	if req.Prompt != "" {
+		prompt := req.Prompt
+		if hooks.OnPromptSubmit != nil {
+			if prompt, err = hooks.OnPromptSubmit(ctx, prompt); err != nil {
+				return result, err
+			}
+		}
+		id, err := r.saveUserMessage(ctx, conversationID, prompt)
+		if err != nil {
+			return result, err
+		}
+
+		// The first message carries the turn budget and tool guidance
+		message = &aisdk.Message{
+			Role:    "user",
+			Content: WrapFirstMessage(prompt, policy.MaxTurns, r.config.Toolbox != nil),
+			ID:      id,
+		}
+	}
+	repairs := 0
+
+	// models is the fallback chain, starting with the model in use
+	models := append([]aisdk.ModelClient{r.config.ModelClient}, r.config.FallbackModels...)
+
+	for {
+		if reason, stop := r.stopBeforeStep(ctx, state); stop {
+			result.StopReason = reason
+			break
+		}
+
+		if hooks.BeforeStep != nil {
+			if err := hooks.BeforeStep(ctx, state); err != nil {
+				return result, err
+			}
+		}
+
+		step, err := r.service.Step(ctx, &StepRequest{
+			Conversation:   state.Conversation,
+			Message:        message,
+			ModelClient:    models[0],
+			FallbackModels: models[1:],
+			SessionID:      sessionID,
+			ConversationID: conversationID,
+			Toolbox:        r.config.Toolbox,
+			Callbacks:      r.config.Callbacks,
+			EventSink:      r.config.EventSink,
+			TurnNumber:     state.Turn + 1,
+			Stream:         policy.Stream,
+			ResponseFormat: policy.ResponseFormat,
+		})
+		if err != nil {
+			return result, err
+		}
+		if step.State == StateError {
+			if ctx.Err() != nil {
+				result.StopReason = StopInterrupted
+			}
+			return result, step.Error
+		}
+		models = remainingModels(models, step.ModelClient)
+		message = nil
+		state.Conversation = step.UpdatedConversation
+		state.LastStep = step
+		addUsage(&state.Usage, step.Response.Usage)
+		result.Response = step.Response
+
+		if hooks.AfterStep != nil {
+			if err := hooks.AfterStep(ctx, state, step); err != nil {
+				return result, err
+			}
+		}
+
+		if step.State == StateTextResponse {
+			if policy.ResponseFormat == nil {
+				state.Turn++
+				result.StopReason = StopCompleted
+				break
+			}
+
+			// Validate the structured response, asking the model to repair
+			// it if it does not match the schema
+			data, err := aisdk.ParseStructuredContent(step.Response.Content, policy.ResponseFormat)
+			if err == nil {
+				state.Turn++
+				result.Structured = data
+				result.StopReason = StopCompleted
+				break
+			}
+			if repairs >= policy.MaxRepairs {
+				state.Turn++
+				return result, &aisdk.StructuredOutputError{Content: step.Response.Content, Attempts: repairs + 1, Err: err}
+			}
+			repairs++
+			r.service.logger.Warn("Response does not match the schema, asking for a repair", "attempt", repairs, "error", err)
+			message = aisdk.StructuredRepairMessage(err)
+			if message.ID, err = r.saveUserMessage(ctx, conversationID, message.Content); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		if hooks.BeforeToolCalls != nil {
+			if err := hooks.BeforeToolCalls(ctx, state, step.ToolCalls); err != nil {
+				return result, err
+			}
+		}
+		checkpoint := r.createCheckpoint(ctx, conversationID, step, state)
+		toolResult, err := r.service.ExecuteToolCalls(ctx, &ToolExecutionRequest{
+			ToolCalls:      step.ToolCalls,
+			Toolbox:        r.config.Toolbox,
+			SessionID:      sessionID,
+			ConversationID: conversationID,
+			MessageID:      step.MessageID,
+			Model:          step.ModelClient.GetModelInfo().ID,
+			Provider:       aisdk.ProviderName(step.ModelClient),
+			Callbacks:      r.config.Callbacks,
+			EventSink:      r.config.EventSink,
+			TurnNumber:     state.Turn + 1,
+		})
+		if err != nil {
+			return result, err
+		}
+		if toolResult.State == StateError {
+			if ctx.Err() == nil {
+				return result, toolResult.Error
+			}
+			// Keep the finished results and record the calls that did not
+			// finish, so the run can be resumed
+			state.Conversation.Messages = append(state.Conversation.Messages, toolResult.ToolResults...)
+			state.Turn++
+			r.updateCheckpoint(ctx, checkpoint, storage.CheckpointInterrupted, toolResult.ToolResults)
+			result.StopReason = StopInterrupted
+			return result, ctx.Err()
+		}
+		state.Conversation.Messages = append(state.Conversation.Messages, toolResult.ToolResults...)
+		state.Turn++
+		r.updateCheckpoint(ctx, checkpoint, storage.CheckpointCompleted, toolResult.ToolResults)
+
+		if hooks.AfterToolCalls != nil {
+			if err := hooks.AfterToolCalls(ctx, state, toolResult.ToolResults); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if r.config.EventSink != nil {
+		NewEventEmitter(r.config.EventSink, conversationID, state.Turn).
+			EmitConversationComplete(string(result.StopReason), state.Turn, state.TurnsRemaining())
+	}
+	if result.StopReason == StopMaxTurns {
+		r.service.logger.Warn("Max turns reached", "turns", state.MaxTurns)
+	}
+
+	if result.StopReason == StopInterrupted && ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	if policy.ResponseFormat != nil && result.Structured == nil {
+		return result, fmt.Errorf("no structured response within %d turns (%s)", state.MaxTurns, result.StopReason)
+	}
+	return result, nil
+}
+
+// stopBeforeStep checks whether the run ends before its next step
+func (r *Runner) stopBeforeStep(ctx context.Context, state *RunState) (StopReason, bool) {
+	if ctx.Err() != nil || r.interrupted.Load() {
+		return StopInterrupted, true
+	}
+	if state.TurnsRemaining() <= 0 {
+		return StopMaxTurns, true
+	}
+	if state.LastStep == nil {
+		return "", false
+	}
+	for _, condition := range r.config.Policy.StopConditions {
+		if reason, stop := condition(state); stop {
+			return reason, true
+		}
+	}
+	return "", false
+}
+
+// createCheckpoint records the turn state before the tool calls of a step
+// run. It returns nil when the run has no conversation or the checkpoint
+// could not be saved; the run goes on without one.
+func (r *Runner) createCheckpoint(ctx context.Context, conversationID string, step *StepResult, state *RunState) *storage.RunCheckpoint {
+	if conversationID == "" {
+		return nil
+	}
+	toolCalls, err := json.Marshal(step.ToolCalls)
+	if err != nil {
+		r.service.logger.Error("Failed to marshal tool calls", "error", err)
+		return nil
+	}
+	checkpoint := &storage.RunCheckpoint{
+		ConversationID: conversationID,
+		Turn:           state.Turn + 1,
+		MaxTurns:       state.MaxTurns,
+		ToolCalls:      string(toolCalls),
+	}
+	if step.MessageID != "" {
+		checkpoint.MessageID = &step.MessageID
+	}
+	if err := storage.CreateRunCheckpoint(ctx, r.service.database, checkpoint); err != nil {
+		r.service.logger.Error("Failed to save run checkpoint", "error", err)
+		return nil
+	}
+	return checkpoint
+}
+
+// updateCheckpoint records the status of a checkpoint and the calls that
+// finished. It is saved even when the run was cancelled.
+func (r *Runner) updateCheckpoint(ctx context.Context, checkpoint *storage.RunCheckpoint, status string, finished []*aisdk.Message) {
+	if checkpoint == nil {
+		return
+	}
+	checkpoint.Status = status
+	checkpoint.FinishedToolCallIDs = storage.JSONStringArray{}
+	for _, msg := range finished {
+		checkpoint.FinishedToolCallIDs = append(checkpoint.FinishedToolCallIDs, msg.ToolCallID)
+	}
+	if err := storage.UpdateRunCheckpoint(context.WithoutCancel(ctx), r.service.database, checkpoint); err != nil {
+		r.service.logger.Error("Failed to update run checkpoint", "error", err)
+	}
+}
+
+// saveUserMessage saves a user message of the run when it has a conversation
+// and returns its ID
+func (r *Runner) saveUserMessage(ctx context.Context, conversationID, content string) (string, error) {
+	if conversationID == "" {
+		return "", nil
+	}
+	id, err := r.service.SaveUserMessage(ctx, conversationID, content)
+	if err != nil {
+		return "", fmt.Errorf("failed to save user message: %w", err)
+	}
+	return id, nil
+}
+
+// remainingModels returns the fallback chain starting at the model that
+// answered the last step
+func remainingModels(models []aisdk.ModelClient, answered aisdk.ModelClient) []aisdk.ModelClient {
+	for i, model := range models {
+		if model == answered {
+			return models[i:]
+		}
+	}
+	return models
+}
+
+// addUsage adds the usage of a model call to a total
+func addUsage(total *aisdk.Usage, usage aisdk.Usage) {
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	total.PromptTokensCached += usage.PromptTokensCached
+	total.PromptTokensCacheWrite += usage.PromptTokensCacheWrite
+}
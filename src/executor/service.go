@@ -5,18 +5,38 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"sync"
 
+	"github.com/elee1766/gofer/src/agent"
 	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/audit"
+	"github.com/elee1766/gofer/src/redact"
 	"github.com/elee1766/gofer/src/storage"
 )
 
+// defaultMaxParallelTools is the default limit of concurrent tool calls
+const defaultMaxParallelTools = 4
+
 // Service handles prompt execution with all necessary dependencies
 type Service struct {
-	database     *sql.DB
-	projectDir   string
-	logger       *slog.Logger
-	systemPrompt string
-	maxTurns     int
+	database      *sql.DB
+	projectDir    string
+	logger        *slog.Logger
+	systemPrompt  string
+	maxTurns      int
+	contextWindow *agent.ContextWindow
+
+	maxParallelTools int
+
+	autoCompact      bool
+	compactThreshold float64
+
+	permissions PermissionChecker
+	confirmer   Confirmer
+	confirmMu   sync.Mutex // Serializes confirmations of concurrent calls
+
+	audit    *audit.Log
+	redactor *redact.Redactor
 }
 
 // ServiceConfig holds configuration for creating a new Service
@@ -26,6 +46,35 @@ type ServiceConfig struct {
 	SystemPrompt string
 	MaxTurns     int
 	Logger       *slog.Logger
+
+	// ContextWindow checks requests against the model's context length,
+	// the default strategy is used when nil
+	ContextWindow *agent.ContextWindow
+
+	// MaxParallelTools limits how many parallel-safe tool calls run at
+	// once. Defaults to 4; 1 runs every call in sequence.
+	MaxParallelTools int
+
+	// AutoCompact summarizes older messages when a request uses more than
+	// CompactThreshold of the model's context (default 0.8)
+	AutoCompact      bool
+	CompactThreshold float64
+
+	// Permissions decide whether each tool call may run; all calls may
+	// run when nil. Denied calls return the reason to the model.
+	Permissions PermissionChecker
+
+	// Confirmer is asked about calls the permissions require to be
+	// confirmed. Defaults to DenyConfirmer.
+	Confirmer Confirmer
+
+	// AuditLog records tool calls, permission decisions and model calls
+	// when set
+	AuditLog *audit.Log
+
+	// Redactor replaces the secrets of tool results before they are sent,
+	// saved or audited, when set
+	Redactor *redact.Redactor
 }
 
 // NewService creates a new prompt service
@@ -39,12 +88,36 @@ func NewService(config ServiceConfig) *Service {
 		config.MaxTurns = 3
 	}
 
+	if config.MaxParallelTools <= 0 {
+		config.MaxParallelTools = defaultMaxParallelTools
+	}
+
+	if config.CompactThreshold <= 0 || config.CompactThreshold > 1 {
+		config.CompactThreshold = DefaultCompactThreshold
+	}
+
+	if config.Confirmer == nil {
+		config.Confirmer = DenyConfirmer{}
+	}
+
 	return &Service{
-		database:     config.Database,
-		projectDir:   config.ProjectDir,
-		logger:       config.Logger,
-		systemPrompt: config.SystemPrompt,
-		maxTurns:     config.MaxTurns,
+		database:      config.Database,
+		projectDir:    config.ProjectDir,
+		logger:        config.Logger,
+		systemPrompt:  config.SystemPrompt,
+		maxTurns:      config.MaxTurns,
+		contextWindow: config.ContextWindow,
+
+		maxParallelTools: config.MaxParallelTools,
+
+		autoCompact:      config.AutoCompact,
+		compactThreshold: config.CompactThreshold,
+
+		permissions: config.Permissions,
+		confirmer:   config.Confirmer,
+
+		audit:    config.AuditLog,
+		redactor: config.Redactor,
 	}
 }
 
@@ -132,18 +205,28 @@ func (s *Service) BuildConversationFromDB(ctx context.Context, conversation *sto
 		return nil, fmt.Errorf("failed to get messages: %w", err)
 	}
 
+	latest, err := storage.GetLatestCompaction(ctx, s.database, conversation.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get compaction: %w", err)
+	}
+
 	// Build conversation
-	return buildAISDKConversation(conversation, messages, systemPrompt), nil
+	return buildAISDKConversation(conversation, messages, systemPrompt, latest), nil
 }
 
-// SaveUserMessage saves a user message to the database
-func (s *Service) SaveUserMessage(ctx context.Context, conversationID, content string) error {
+// SaveUserMessage saves a user message to the database and returns its ID.
+// Callers set it as the ID of the message they send, so compactions can
+// find the saved message.
+func (s *Service) SaveUserMessage(ctx context.Context, conversationID, content string) (string, error) {
 	userMsg := &storage.Message{
 		ConversationID: conversationID,
 		Role:           "user",
 		Content:        content,
 	}
-	return storage.CreateMessage(ctx, s.database, userMsg)
+	if err := storage.CreateMessage(ctx, s.database, userMsg); err != nil {
+		return "", err
+	}
+	return userMsg.ID, nil
 }
 
 
@@ -0,0 +1,163 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+// TaskToolName is the name of the tool that delegates subtasks to a sub-agent
+const TaskToolName = "task"
+
+// defaultTaskMaxTurns is the turn budget of a sub-agent
+const defaultTaskMaxTurns = 10
+
+const taskToolDescription = `Delegate a self-contained subtask to a sub-agent, for example a search through a large codebase.
+
+The sub-agent starts without the conversation so far, so the prompt must describe the task completely: what to look for or do, where, and what to report back. It works on its own with a restricted set of tools and answers with a summary, which is returned as the result of this tool. Only the summary is added to the conversation.
+
+Use it for tasks that need many tool calls whose output you don't need to see, not for a single file read or search.`
+
+// TaskToolConfig configures the task tool
+type TaskToolConfig struct {
+	// ModelClient runs the sub-agent, usually the parent's model
+	ModelClient aisdk.ModelClient
+
+	// FallbackModels are tried in order when a model call of the sub-agent
+	// fails with a rate limit, overload or context length error
+	FallbackModels []aisdk.ModelClient
+
+	// Toolbox of the sub-agent, usually a read-only subset of the parent's.
+	// It must not contain the task tool itself.
+	Toolbox *agent.DefaultToolbox
+
+	// SystemPrompt of the sub-agent
+	SystemPrompt string
+
+	// MaxTurns is the turn budget of each sub-agent run (default 10)
+	MaxTurns int
+}
+
+type taskInput struct {
+	Description string `json:"description" required:"true" description:"A short (3-5 word) description of the task"`
+	Prompt      string `json:"prompt" required:"true" description:"The complete task for the sub-agent, including what to report back"`
+}
+
+type taskOutput struct {
+	Summary    string     `json:"summary" description:"The final answer of the sub-agent"`
+	StopReason StopReason `json:"stop_reason" description:"Why the sub-agent stopped, task_complete when it answered"`
+	Turns      int        `json:"turns" description:"Turns the sub-agent used"`
+}
+
+// TaskTool creates the task tool, which runs a sub-agent with the service in
+// a fresh conversation and returns its final answer. The sub-agent's events
+// are nested in the event stream of the parent's tool call, and its usage is
+// recorded on the parent's conversation; its messages are not saved. The tool
+// is parallel-safe when every tool of the sub-agent is.
+func (s *Service) TaskTool(config TaskToolConfig) (agent.Tool, error) {
+	if config.ModelClient == nil {
+		return nil, fmt.Errorf("task tool requires a model client")
+	}
+	if config.Toolbox != nil && config.Toolbox.HasTool(TaskToolName) {
+		return nil, fmt.Errorf("the toolbox of a sub-agent cannot contain the %s tool", TaskToolName)
+	}
+	if config.MaxTurns <= 0 {
+		config.MaxTurns = defaultTaskMaxTurns
+	}
+
+	handler := func(ctx context.Context, input taskInput) (taskOutput, error) {
+		return s.runTask(ctx, config, input)
+	}
+
+	parallel := true
+	if config.Toolbox != nil {
+		for _, tool := range config.Toolbox.Tools() {
+			parallel = parallel && agent.IsParallelSafe(tool)
+		}
+	}
+	if parallel {
+		return agent.NewParallelSafeTool(TaskToolName, taskToolDescription, handler)
+	}
+	return agent.NewGenericTool(TaskToolName, taskToolDescription, handler)
+}
+
+// runTask runs the sub-agent of one task tool call
+func (s *Service) runTask(ctx context.Context, config TaskToolConfig, input taskInput) (taskOutput, error) {
+	if input.Prompt == "" {
+		return taskOutput{}, fmt.Errorf("prompt is required")
+	}
+	parent, _ := ctx.Value(toolCallScopeKey{}).(*toolCallScope)
+
+	runnerConfig := RunnerConfig{
+		ModelClient:    config.ModelClient,
+		FallbackModels: config.FallbackModels,
+		Toolbox:        config.Toolbox,
+		Policy:         RunPolicy{MaxTurns: config.MaxTurns},
+	}
+	if parent != nil {
+		if parent.emitter != nil && parent.emitter.sink != nil {
+			runnerConfig.EventSink = &subAgentSink{parent: parent, description: input.Description}
+		}
+		if parent.conversationID != "" {
+			runnerConfig.Hooks.AfterStep = func(ctx context.Context, state *RunState, step *StepResult) error {
+				s.saveTaskUsage(ctx, parent, step.ModelClient.GetModelInfo(), step.Response.Usage)
+				return nil
+			}
+		}
+	}
+
+	history := &aisdk.Conversation{}
+	if config.SystemPrompt != "" {
+		history.Messages = append(history.Messages, &aisdk.Message{Role: "system", Content: config.SystemPrompt})
+	}
+	result, err := NewRunner(s, runnerConfig).Run(ctx, &RunRequest{Prompt: input.Prompt, History: history})
+	if err != nil {
+		return taskOutput{}, fmt.Errorf("sub-agent failed after %d turns: %w", result.Turns, err)
+	}
+
+	output := taskOutput{StopReason: result.StopReason, Turns: result.Turns}
+	if result.Response != nil {
+		output.Summary = result.Response.Content
+	}
+	if result.StopReason != StopCompleted && output.Summary == "" {
+		output.Summary = fmt.Sprintf("The sub-agent stopped (%s) before it answered", result.StopReason)
+	}
+	return output, nil
+}
+
+// saveTaskUsage records the usage of a sub-agent step on the parent's
+// conversation and the message that called the task tool
+func (s *Service) saveTaskUsage(ctx context.Context, parent *toolCallScope, model *aisdk.ModelInfo, usage aisdk.Usage) {
+	if err := s.saveUsage(context.WithoutCancel(ctx), parent.conversationID, parent.messageID, model, usage); err != nil {
+		s.logger.Error("Failed to save sub-agent usage", "error", err)
+	}
+}
+
+// subAgentSink wraps the events of a sub-agent into SubAgentEvents of the
+// parent's tool call. The parent owns the underlying sink.
+type subAgentSink struct {
+	parent      *toolCallScope
+	description string
+}
+
+// Send sends a sub-agent event to the parent's sink
+func (s *subAgentSink) Send(event ConversationEvent) error {
+	emitter := s.parent.emitter
+	return emitter.sink.Send(&SubAgentEvent{
+		BaseEvent: BaseEvent{
+			Type:           EventSubAgent,
+			Timestamp:      time.Now(),
+			ConversationID: emitter.conversationID,
+			TurnNumber:     emitter.turnNumber,
+		},
+		ToolCallID:  s.parent.toolCall.ID,
+		Description: s.description,
+		Event:       event,
+	})
+}
+
+// Close does nothing, the parent's sink is closed by its owner
+func (s *subAgentSink) Close() error { return nil }
@@ -0,0 +1,154 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/jsonvalidate"
+)
+
+// Kinds of invalid tool calls
+const (
+	InvalidToolUnknown   = "unknown_tool"
+	InvalidToolArguments = "invalid_arguments"
+)
+
+// InvalidToolCallError describes a tool call the executor rejected before
+// running the tool. It is sent to the model as the JSON result of the call,
+// so the model can correct the call and retry.
+type InvalidToolCallError struct {
+	Kind    string `json:"error"`
+	Tool    string `json:"tool"`
+	Message string `json:"message"`
+
+	// Violations of the tool's parameter schema (invalid_arguments)
+	Violations []jsonvalidate.FieldError `json:"violations,omitempty"`
+
+	// Suggestion is the closest tool name, if any is close (unknown_tool)
+	Suggestion string `json:"suggestion,omitempty"`
+
+	// AvailableTools lists the tools the model can call (unknown_tool)
+	AvailableTools []string `json:"available_tools,omitempty"`
+}
+
+func (e *InvalidToolCallError) Error() string {
+	if len(e.Violations) == 0 {
+		return e.Message
+	}
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.String())
+	}
+	return fmt.Sprintf("invalid arguments for %s: %s", e.Tool, strings.Join(msgs, "; "))
+}
+
+// content returns the error as the content of the tool result
+func (e *InvalidToolCallError) content() string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return e.Error()
+	}
+	return string(data)
+}
+
+// validateToolCall checks that the called tool exists and that the arguments
+// match its parameter schema. Unknown properties are rejected, since the
+// generated schemas don't close their objects.
+func (s *Service) validateToolCall(toolbox *agent.DefaultToolbox, toolCall aisdk.ToolCall) *InvalidToolCallError {
+	name := toolCall.Function.Name
+	tool, found := toolbox.GetTool(name)
+	if !found {
+		names := make([]string, 0, len(toolbox.Tools()))
+		for _, t := range toolbox.Tools() {
+			names = append(names, t.GetName())
+		}
+		sort.Strings(names)
+
+		invalid := &InvalidToolCallError{
+			Kind:           InvalidToolUnknown,
+			Tool:           name,
+			Message:        fmt.Sprintf("Tool not found: %s", name),
+			Suggestion:     closestName(name, names),
+			AvailableTools: names,
+		}
+		if invalid.Suggestion != "" {
+			invalid.Message += fmt.Sprintf(". Did you mean %s?", invalid.Suggestion)
+		}
+		return invalid
+	}
+
+	params := tool.GetParameters()
+	if params == nil {
+		return nil
+	}
+	schema, err := jsonvalidate.Compile(params, jsonvalidate.DisallowUnknownProperties())
+	if err != nil {
+		s.logger.Warn("Skipping argument validation of tool with invalid schema", "tool", name, "error", err)
+		return nil
+	}
+
+	// Models may send no arguments for tools without required parameters
+	args := toolCall.Function.Arguments
+	if len(strings.TrimSpace(string(args))) == 0 {
+		args = []byte("{}")
+	}
+	err = schema.ValidateJSON(args)
+	if err == nil {
+		return nil
+	}
+	invalid := &InvalidToolCallError{
+		Kind:    InvalidToolArguments,
+		Tool:    name,
+		Message: "The arguments do not match the tool's parameter schema. Fix them and call the tool again.",
+	}
+	if verr, ok := err.(*jsonvalidate.ValidationError); ok {
+		invalid.Violations = verr.Errors
+	} else {
+		invalid.Violations = []jsonvalidate.FieldError{{Message: err.Error()}}
+	}
+	return invalid
+}
+
+// closestName returns the name closest to the unknown one by edit distance,
+// or "" if none is close enough to be a likely typo
+func closestName(unknown string, names []string) string {
+	best, bestDistance := "", -1
+	for _, name := range names {
+		distance := editDistance(strings.ToLower(unknown), strings.ToLower(name))
+		limit := max(2, max(len(unknown), len(name))/3)
+		related := unknown != "" && (strings.Contains(name, unknown) || strings.Contains(unknown, name))
+		if distance > limit && !related {
+			continue
+		}
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,334 @@
+package fakeprovider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+const (
+	defaultModel         = "fake-model"
+	defaultContextLength = 128000
+)
+
+var (
+	_ aisdk.Provider    = (*Provider)(nil)
+	_ aisdk.ModelClient = (*ModelClient)(nil)
+)
+
+// ErrScriptExhausted is returned when a request arrives after the last turn
+var ErrScriptExhausted = errors.New("fake provider script exhausted")
+
+// ExpectationError is returned when a request does not meet a turn's expectations
+type ExpectationError struct {
+	Turn    int // zero based index of the turn
+	Message string
+}
+
+func (e *ExpectationError) Error() string {
+	return fmt.Sprintf("fake provider turn %d: %s", e.Turn, e.Message)
+}
+
+// Provider serves a script. All models of a provider share the script, so a
+// run that switches models still consumes the turns in order.
+type Provider struct {
+	script *Script
+	info   *aisdk.ModelInfo
+
+	mu       sync.Mutex
+	next     int
+	requests []*aisdk.ChatCompletionRequest
+}
+
+// New creates a provider that serves the script
+func New(script *Script) *Provider {
+	model := script.Model
+	if model == "" {
+		model = defaultModel
+	}
+	contextLength := script.ContextLength
+	if contextLength == 0 {
+		contextLength = defaultContextLength
+	}
+
+	return &Provider{
+		script: script,
+		info: &aisdk.ModelInfo{
+			ID:            model,
+			Name:          model,
+			Description:   "Scripted model for tests",
+			ContextLength: contextLength,
+			Architecture: &aisdk.Architecture{
+				InputModalities:  []string{"text", "image"},
+				OutputModalities: []string{"text"},
+			},
+			Pricing:             &aisdk.Pricing{Prompt: "0", Completion: "0"},
+			SupportedParameters: []string{"tools", "tool_choice"},
+		},
+	}
+}
+
+// NewFromFile creates a provider that serves the JSON script at path
+func NewFromFile(path string) (*Provider, error) {
+	script, err := LoadScript(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(script), nil
+}
+
+// GetModels implements aisdk.Provider
+func (p *Provider) GetModels(ctx context.Context) ([]*aisdk.ModelInfo, error) {
+	return []*aisdk.ModelInfo{p.info}, nil
+}
+
+// Model implements aisdk.Provider. Any model name is accepted.
+func (p *Provider) Model(ctx context.Context, name string) (aisdk.ModelClient, error) {
+	return &ModelClient{provider: p}, nil
+}
+
+// Requests returns the requests received so far
+func (p *Provider) Requests() []*aisdk.ChatCompletionRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*aisdk.ChatCompletionRequest(nil), p.requests...)
+}
+
+// Done returns an error if some turns of the script were not used
+func (p *Provider) Done() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if remaining := len(p.script.Turns) - p.next; remaining > 0 {
+		return fmt.Errorf("fake provider script has %d unused turns", remaining)
+	}
+	return nil
+}
+
+// ModelClient is a client for the scripted model
+type ModelClient struct {
+	provider *Provider
+}
+
+// CreateChatCompletion implements aisdk.ModelClient by serving the next turn
+func (mc *ModelClient) CreateChatCompletion(ctx context.Context, req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p := mc.provider
+	p.mu.Lock()
+	index := p.next
+	p.requests = append(p.requests, req)
+	if index >= len(p.script.Turns) {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("%w after %d turns", ErrScriptExhausted, index)
+	}
+	p.next++
+	turn := p.script.Turns[index]
+	p.mu.Unlock()
+
+	if turn.Expect != nil {
+		if err := turn.Expect.check(req); err != nil {
+			return nil, &ExpectationError{Turn: index, Message: err.Error()}
+		}
+	}
+	if turn.Check != nil {
+		if err := turn.Check(req); err != nil {
+			return nil, &ExpectationError{Turn: index, Message: err.Error()}
+		}
+	}
+	if turn.Error != "" {
+		return nil, errors.New(turn.Error)
+	}
+
+	msg, err := turn.respond(index, req)
+	if err != nil {
+		return nil, fmt.Errorf("fake provider turn %d: %w", index, err)
+	}
+
+	finishReason := "stop"
+	if len(msg.ToolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+	resp := &aisdk.ChatCompletionResponse{
+		ID:      fmt.Sprintf("fake-%d", index),
+		Object:  "chat.completion",
+		Model:   p.info.ID,
+		Choices: []aisdk.Choice{{Message: *msg, FinishReason: finishReason}},
+	}
+	if turn.Usage != nil {
+		resp.Usage = *turn.Usage
+	}
+	return resp, nil
+}
+
+// GetModelInfo implements aisdk.ModelClient
+func (mc *ModelClient) GetModelInfo() *aisdk.ModelInfo {
+	return mc.provider.info
+}
+
+// respond builds the assistant message of a turn
+func (t *Turn) respond(index int, req *aisdk.ChatCompletionRequest) (*aisdk.Message, error) {
+	if t.Respond != nil {
+		return t.Respond(req)
+	}
+
+	text, err := renderText(t.Text, req)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &aisdk.Message{Role: "assistant", Content: text}
+	for i, call := range t.ToolCalls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d_%d", index, i)
+		}
+		args := call.Arguments
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		msg.ToolCalls = append(msg.ToolCalls, aisdk.ToolCall{
+			ID:       id,
+			Type:     "function",
+			Function: aisdk.FunctionCall{Name: call.Name, Arguments: args},
+		})
+	}
+	return msg, nil
+}
+
+// check verifies the request against the expectation
+func (e *Expectation) check(req *aisdk.ChatCompletionRequest) error {
+	if e.MessageCount != 0 && len(req.Messages) != e.MessageCount {
+		return fmt.Errorf("expected %d messages, got %d", e.MessageCount, len(req.Messages))
+	}
+
+	if len(e.Contains) > 0 {
+		if len(req.Messages) == 0 {
+			return fmt.Errorf("expected messages, got none")
+		}
+		last := req.Messages[len(req.Messages)-1].GetContent()
+		for _, s := range e.Contains {
+			if !strings.Contains(last, s) {
+				return fmt.Errorf("expected last message to contain %q, got %q", s, last)
+			}
+		}
+	}
+
+	for _, name := range e.Tools {
+		found := false
+		for _, t := range req.Tools {
+			if t != nil && t.Function.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("expected tool %s to be offered", name)
+		}
+	}
+
+	results := trailingToolResults(req.Messages)
+	for _, want := range e.ToolResults {
+		if !matchesAny(want, results) {
+			return fmt.Errorf("expected tool result %+v, got %s", want, describeResults(results))
+		}
+	}
+	return nil
+}
+
+// trailingToolResults returns the tool messages after the last assistant message
+func trailingToolResults(messages []*aisdk.Message) []*aisdk.Message {
+	var results []*aisdk.Message
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg == nil {
+			continue
+		}
+		if msg.Role == "assistant" {
+			break
+		}
+		if msg.Role == "tool" {
+			results = append([]*aisdk.Message{msg}, results...)
+		}
+	}
+	return results
+}
+
+// matchesAny reports whether any of the tool messages matches the expectation
+func matchesAny(want ExpectedToolResult, results []*aisdk.Message) bool {
+	for _, msg := range results {
+		if want.Name != "" && msg.Name != want.Name {
+			continue
+		}
+		if want.ToolCallID != "" && msg.ToolCallID != want.ToolCallID {
+			continue
+		}
+		if !strings.Contains(msg.GetContent(), want.Contains) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// describeResults summarizes tool messages for error messages
+func describeResults(results []*aisdk.Message) string {
+	if len(results) == 0 {
+		return "no tool results"
+	}
+	parts := make([]string, 0, len(results))
+	for _, msg := range results {
+		content := msg.GetContent()
+		if len(content) > 100 {
+			content = content[:100] + "..."
+		}
+		parts = append(parts, fmt.Sprintf("%s(%s): %q", msg.Name, msg.ToolCallID, content))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// renderText renders a turn's text template against the request
+func renderText(text string, req *aisdk.ChatCompletionRequest) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	funcs := template.FuncMap{
+		"toolResult": func(name string) string {
+			for i := len(req.Messages) - 1; i >= 0; i-- {
+				if msg := req.Messages[i]; msg != nil && msg.Role == "tool" && msg.Name == name {
+					return msg.GetContent()
+				}
+			}
+			return ""
+		},
+		"lastMessage": func() string {
+			if len(req.Messages) == 0 || req.Messages[len(req.Messages)-1] == nil {
+				return ""
+			}
+			return req.Messages[len(req.Messages)-1].GetContent()
+		},
+		"firstLine": func(s string) string {
+			line, _, _ := strings.Cut(s, "\n")
+			return line
+		},
+		"trim": strings.TrimSpace,
+	}
+
+	tmpl, err := template.New("turn").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid text template: %w", err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, req); err != nil {
+		return "", fmt.Errorf("failed to render text: %w", err)
+	}
+	return out.String(), nil
+}
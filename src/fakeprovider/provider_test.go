@@ -0,0 +1,45 @@
+package fakeprovider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderScript(t *testing.T) {
+	ctx := context.Background()
+	provider := New(&Script{Turns: []Turn{
+		RespondToolCalls(Call("list_directory", map[string]string{"path": "."})),
+		{Error: "overloaded"},
+		RespondText("you said: {{ lastMessage | trim }}"),
+	}})
+	model, err := provider.Model(ctx, "anything")
+	require.NoError(t, err)
+	assert.Equal(t, "fake-model", model.GetModelInfo().ID)
+
+	resp, err := model.CreateChatCompletion(ctx, &aisdk.ChatCompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "tool_calls", resp.Choices[0].FinishReason)
+	call := resp.Choices[0].Message.ToolCalls[0]
+	assert.Equal(t, "call_0_0", call.ID)
+	assert.JSONEq(t, `{"path":"."}`, string(call.Function.Arguments))
+
+	_, err = model.CreateChatCompletion(ctx, &aisdk.ChatCompletionRequest{})
+	assert.EqualError(t, err, "overloaded")
+	assert.Error(t, provider.Done())
+
+	resp, err = model.CreateChatCompletion(ctx, &aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{{Role: "user", Content: " hi \n"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "you said: hi", resp.Choices[0].Message.Content)
+	assert.NoError(t, provider.Done())
+
+	_, err = model.CreateChatCompletion(ctx, &aisdk.ChatCompletionRequest{})
+	assert.True(t, errors.Is(err, ErrScriptExhausted))
+	assert.Len(t, provider.Requests(), 4)
+}
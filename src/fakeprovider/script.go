@@ -0,0 +1,128 @@
+// Package fakeprovider implements an in-process aisdk.Provider that answers
+// from a script instead of a model, so tool flows can be tested end to end.
+//
+// A script is a list of turns. Each model request consumes the next turn,
+// checks the turn's expectations against the request and returns the turn's
+// text, tool calls or error. Scripts can be built in Go or loaded from JSON:
+//
+//	{
+//	  "turns": [
+//	    {"tool_calls": [{"name": "read_file", "arguments": {"path": "x"}}]},
+//	    {
+//	      "expect": {"tool_results": [{"name": "read_file", "contains": "hello"}]},
+//	      "text": "The first line is {{ toolResult \"read_file\" | firstLine }}"
+//	    }
+//	  ]
+//	}
+//
+// Text is rendered as a text/template with the request as data. Besides the
+// request fields, templates can use toolResult NAME (content of the latest
+// result of that tool), lastMessage, firstLine and trim.
+package fakeprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+// Script describes the responses of the fake model
+type Script struct {
+	Model         string `json:"model,omitempty"`          // Model ID, defaults to "fake-model"
+	ContextLength int    `json:"context_length,omitempty"` // Reported context length, defaults to 128000
+	Turns         []Turn `json:"turns"`
+}
+
+// Turn is the response to a single model request
+type Turn struct {
+	// Expect is checked against the request before responding
+	Expect *Expectation `json:"expect,omitempty"`
+
+	// Text is the assistant content. It is a text/template rendered with the
+	// request, see the package documentation for the available functions.
+	Text string `json:"text,omitempty"`
+
+	// ToolCalls are returned as the assistant's tool calls
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// Error makes the request fail with this message
+	Error string `json:"error,omitempty"`
+
+	// Usage is reported for the response
+	Usage *aisdk.Usage `json:"usage,omitempty"`
+
+	// Check is an additional assertion for scripts built in Go
+	Check func(req *aisdk.ChatCompletionRequest) error `json:"-"`
+
+	// Respond replaces the scripted response for scripts built in Go
+	Respond func(req *aisdk.ChatCompletionRequest) (*aisdk.Message, error) `json:"-"`
+}
+
+// ToolCall is a tool call returned by a turn
+type ToolCall struct {
+	ID        string          `json:"id,omitempty"` // Defaults to call_<turn>_<index>
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// Expectation describes what a request must contain
+type Expectation struct {
+	// ToolResults must be among the tool messages sent after the last assistant message
+	ToolResults []ExpectedToolResult `json:"tool_results,omitempty"`
+
+	// Contains are substrings the last message must contain
+	Contains []string `json:"contains,omitempty"`
+
+	// Tools are the names of tools that must be offered
+	Tools []string `json:"tools,omitempty"`
+
+	// MessageCount is the exact number of messages, if not zero
+	MessageCount int `json:"message_count,omitempty"`
+}
+
+// ExpectedToolResult matches a tool result message
+type ExpectedToolResult struct {
+	Name       string `json:"name,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Contains   string `json:"contains,omitempty"`
+}
+
+// LoadScript reads a JSON script from a file
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse script %s: %w", path, err)
+	}
+	return &script, nil
+}
+
+// RespondText returns a turn that answers with text
+func RespondText(text string) Turn {
+	return Turn{Text: text}
+}
+
+// RespondToolCalls returns a turn that answers with tool calls
+func RespondToolCalls(calls ...ToolCall) Turn {
+	return Turn{ToolCalls: calls}
+}
+
+// Call returns a tool call with the arguments encoded as JSON
+func Call(name string, args interface{}) ToolCall {
+	data, err := json.Marshal(args)
+	if err != nil {
+		panic(fmt.Sprintf("fakeprovider: failed to encode arguments of %s: %v", name, err))
+	}
+	return ToolCall{Name: name, Arguments: data}
+}
+
+// Expecting returns a copy of the turn with the expectation set
+func (t Turn) Expecting(expect Expectation) Turn {
+	t.Expect = &expect
+	return t
+}
@@ -113,6 +113,14 @@ NEVER commit changes unless the user explicitly asks you to. It is VERY IMPORTAN
 
 You MUST answer concisely with fewer than 4 lines of text (not including tool use or code generation), unless user asks for detail.`
 
+	subAgentPromptTemplate = `You are a sub-agent of Gofer, a CLI tool for using LLMs. Another agent delegated a task to you.
+
+Work on the task on your own with the tools available to you; there is no user to ask questions. Search and read as much as the task needs, but do not change anything unless the task asks you to.
+
+When you are done, answer with a concise summary of what you found or did. Your answer is the only thing the other agent sees, so include the file paths, line numbers and details it needs, and say so if you could not complete the task.
+
+IMPORTANT: Assist with defensive security tasks only. Refuse to create, modify, or improve code that may be used maliciously.`
+
 	finalInstructionsSection = `IMPORTANT: Assist with defensive security tasks only. Refuse to create, modify, or improve code that may be used maliciously. Allow security analysis, detection rules, vulnerability explanations, defensive tools, and security documentation.
 
 
@@ -316,6 +324,24 @@ func GenerateSystemPrompt(toolbox *agent.DefaultToolbox) string {
 		getToolDefinitions(toolbox),
 	}
 
+	return joinSections(sections)
+}
+
+// GenerateSubAgentSystemPrompt assembles the system prompt of a sub-agent
+// that works on a task delegated by the main agent
+func GenerateSubAgentSystemPrompt(toolbox *agent.DefaultToolbox) string {
+	sections := []string{
+		subAgentPromptTemplate,
+		"\n\n",
+		getEnvironmentInfo(),
+		"\n\n",
+		getToolDefinitions(toolbox),
+	}
+	return joinSections(sections)
+}
+
+// joinSections joins prompt sections with blank lines
+func joinSections(sections []string) string {
 	result := ""
 	for _, section := range sections {
 		if result != "" && section != "\n\n" {
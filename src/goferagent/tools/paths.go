@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"os"
+	"strings"
+)
+
+// FilePaths returns the paths a call of a file tool reads and writes, from
+// its arguments. Tools that search a directory default to the current one.
+// A patch without a file path writes the files named in its headers, without
+// the a/ and b/ prefixes of git diffs when the paths with them do not exist.
+func FilePaths(toolName string, args map[string]interface{}) (read, write []string) {
+	arg := func(name string) string {
+		s, _ := args[name].(string)
+		return s
+	}
+	orCurrent := func(path string) string {
+		if path == "" {
+			return "."
+		}
+		return path
+	}
+
+	switch toolName {
+	case ReadFileName, GetFileInfoName:
+		read = append(read, arg("path"))
+	case ListDirectoryName, SearchFilesName, GrepFilesName:
+		read = append(read, orCurrent(arg("path")))
+	case WriteFileName, EditFileName, DeleteFileName, CreateDirectoryName:
+		write = append(write, arg("path"))
+	case CopyFileName:
+		read = append(read, arg("source"))
+		write = append(write, arg("destination"))
+	case MoveFileName:
+		write = append(write, arg("source"), arg("destination"))
+	case PatchName:
+		if path := arg("file_path"); path != "" {
+			write = append(write, path)
+		} else {
+			write = append(write, patchPaths(arg("patch"))...)
+		}
+	}
+	return nonEmpty(read), nonEmpty(write)
+}
+
+// patchPaths returns the file names in the headers of a unified diff
+func patchPaths(patch string) []string {
+	var paths []string
+	for _, line := range strings.Split(patch, "\n") {
+		if !strings.HasPrefix(line, "--- ") && !strings.HasPrefix(line, "+++ ") {
+			continue
+		}
+		// A tab separates the name from the timestamp
+		name, _, _ := strings.Cut(line[4:], "\t")
+		name = strings.TrimSpace(name)
+		if name == "" || name == "/dev/null" {
+			continue
+		}
+		for _, prefix := range []string{"a/", "b/"} {
+			if rest, ok := strings.CutPrefix(name, prefix); ok && !exists(name) {
+				name = rest
+			}
+		}
+		if !containsPath(paths, name) {
+			paths = append(paths, name)
+		}
+	}
+	return paths
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func nonEmpty(paths []string) []string {
+	var result []string
+	for _, path := range paths {
+		if path != "" {
+			result = append(result, path)
+		}
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilePaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		tool  string
+		args  map[string]interface{}
+		read  []string
+		write []string
+	}{
+		{
+			name: "read file",
+			tool: ReadFileName,
+			args: map[string]interface{}{"path": "/etc/shadow"},
+			read: []string{"/etc/shadow"},
+		},
+		{
+			name: "grep defaults to the current directory",
+			tool: GrepFilesName,
+			args: map[string]interface{}{"pattern": "x"},
+			read: []string{"."},
+		},
+		{
+			name:  "edit file",
+			tool:  EditFileName,
+			args:  map[string]interface{}{"path": "main.go"},
+			write: []string{"main.go"},
+		},
+		{
+			name:  "copy reads the source and writes the destination",
+			tool:  CopyFileName,
+			args:  map[string]interface{}{"source": "a", "destination": "/root/b"},
+			read:  []string{"a"},
+			write: []string{"/root/b"},
+		},
+		{
+			name:  "move writes both",
+			tool:  MoveFileName,
+			args:  map[string]interface{}{"source": "a", "destination": "b"},
+			write: []string{"a", "b"},
+		},
+		{
+			name:  "patch with a file path",
+			tool:  PatchName,
+			args:  map[string]interface{}{"patch": "--- x\n+++ y\n", "file_path": "z"},
+			write: []string{"z"},
+		},
+		{
+			name:  "patch headers",
+			tool:  PatchName,
+			args:  map[string]interface{}{"patch": "--- /dev/null\n+++ /etc/passwd\t2024-01-01\n@@ -0,0 +1 @@\n+x\n"},
+			write: []string{"/etc/passwd"},
+		},
+		{
+			name:  "git diff headers",
+			tool:  PatchName,
+			args:  map[string]interface{}{"patch": "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-x\n+y\n"},
+			write: []string{"main.go"},
+		},
+		{
+			name: "other tools",
+			tool: RunCommandName,
+			args: map[string]interface{}{"command": "cat /etc/shadow"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read, write := FilePaths(tt.tool, tt.args)
+			assert.Equal(t, tt.read, read)
+			assert.Equal(t, tt.write, write)
+		})
+	}
+}
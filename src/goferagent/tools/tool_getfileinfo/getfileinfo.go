@@ -46,7 +46,7 @@ type GetFileInfoOutput struct {
 
 // Tool returns the get_file_info tool definition using GenericTool
 func Tool(fs afero.Fs) (agent.Tool, error) {
-	return agent.NewGenericTool(Name, getFileInfoPrompt, makeGetFileInfoHandler(fs))
+	return agent.NewParallelSafeTool(Name, getFileInfoPrompt, makeGetFileInfoHandler(fs))
 }
 
 // makeGetFileInfoHandler creates a type-safe handler for the get_file_info tool
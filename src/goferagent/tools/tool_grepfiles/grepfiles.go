@@ -57,7 +57,7 @@ type GrepFilesOutput struct {
 
 // Tool returns the grep_files tool definition using GenericTool
 func Tool(fs afero.Fs) (agent.Tool, error) {
-	return agent.NewGenericTool(Name, grepFilesPrompt, makeGrepFilesHandler(fs))
+	return agent.NewParallelSafeTool(Name, grepFilesPrompt, makeGrepFilesHandler(fs))
 }
 
 // makeGrepFilesHandler creates a type-safe handler for the grep_files tool
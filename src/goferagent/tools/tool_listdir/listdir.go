@@ -118,7 +118,7 @@ func makeListDirectoryHandler(fs afero.Fs) func(context.Context, ListDirectoryIn
 
 // Tool returns the list_directory tool definition using GenericTool
 func Tool(fs afero.Fs) (agent.Tool, error) {
-	return agent.NewGenericTool(Name, listDirectoryPrompt, makeListDirectoryHandler(fs))
+	return agent.NewParallelSafeTool(Name, listDirectoryPrompt, makeListDirectoryHandler(fs))
 }
 
 
@@ -9,6 +9,7 @@ import (
 	"github.com/elee1766/gofer/src/aisdk"
 	"github.com/elee1766/gofer/src/goferagent/toolsutil"
 	"github.com/spf13/afero"
+	"github.com/swaggest/jsonschema-go"
 )
 
 // Tool name constant
@@ -45,19 +46,25 @@ type ReadFileOutput struct {
 
 // Tool returns the read_file tool definition using GenericTool
 func Tool(fs afero.Fs) (agent.Tool, error) {
-	return agent.NewGenericTool(Name, readFilePrompt, makeReadFileHandlerV2(fs))
+	return agent.NewParallelSafeTool(Name, readFilePrompt, makeReadFileHandlerV2(fs))
 }
 
 // ToolMultimodal returns the read_file tool definition with multimodal support
 func ToolMultimodal(fs afero.Fs) (agent.Tool, error) {
+	reflector := jsonschema.Reflector{}
+	schema, err := reflector.Reflect(ReadFileInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate schema: %w", err)
+	}
 	return &agent.LegacyTool{
 		Type: "function",
 		Function: aisdk.ToolFunction{
 			Name:        Name,
 			Description: readFilePrompt,
-			Parameters:  nil, // Will be set via reflection if needed
+			Parameters:  &schema,
 		},
 		Executor: makeReadFileHandlerMultimodal(fs),
+		Parallel: true,
 	}, nil
 }
 
@@ -48,7 +48,7 @@ type SearchFilesOutput struct {
 
 // Tool returns the search_files tool definition using GenericTool
 func Tool(fs afero.Fs) (agent.Tool, error) {
-	return agent.NewGenericTool(Name, searchFilesPrompt, makeSearchFilesHandler(fs))
+	return agent.NewParallelSafeTool(Name, searchFilesPrompt, makeSearchFilesHandler(fs))
 }
 
 // makeSearchFilesHandler creates a type-safe handler for the search_files tool
@@ -2,6 +2,7 @@ package tool_webfetch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,8 @@ import (
 	md "github.com/JohannesKaufmann/html-to-markdown"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/config"
+	"github.com/elee1766/gofer/src/egress"
 	"github.com/elee1766/gofer/src/goferagent/toolsutil"
 )
 
@@ -38,6 +41,7 @@ FEATURES:
 LIMITATIONS:
 - Maximum response size is 5MB
 - Only supports HTTP and HTTPS protocols
+- Hosts the network permissions deny, such as localhost and private networks, cannot be fetched
 - Cannot handle authentication or cookies
 - Some websites may block automated requests
 
@@ -63,17 +67,32 @@ type WebFetchOutput struct {
 	ContentType string            `json:"content_type,omitempty" description:"Content-Type header from the response"`
 }
 
-// Tool returns the web_fetch tool definition using GenericTool
+// Tool returns the web_fetch tool definition using GenericTool. It may fetch
+// any URL; use ToolWithPolicy to restrict where it connects.
 func Tool() (agent.Tool, error) {
-	return agent.NewGenericTool(Name, webFetchPrompt, webFetchHandler)
+	return agent.NewParallelSafeTool(Name, webFetchPrompt, makeWebFetchHandler(nil))
+}
+
+// ToolWithPolicy returns the web_fetch tool with its requests, including
+// redirects, checked against the network permissions
+func ToolWithPolicy(network config.NetworkPermissions) (agent.Tool, error) {
+	return agent.NewParallelSafeTool(Name, webFetchPrompt, makeWebFetchHandler(egress.NewTransport(network)))
 }
 
 // Legacy types for backward compatibility
 type Params = WebFetchInput
 type Response = WebFetchOutput
 
-// webFetchHandler creates a type-safe handler for the web_fetch tool
-func webFetchHandler(ctx context.Context, input WebFetchInput) (WebFetchOutput, error) {
+// makeWebFetchHandler creates a type-safe handler for the web_fetch tool
+// sending its requests with transport, or the default transport if nil
+func makeWebFetchHandler(transport http.RoundTripper) func(ctx context.Context, input WebFetchInput) (WebFetchOutput, error) {
+	return func(ctx context.Context, input WebFetchInput) (WebFetchOutput, error) {
+		return webFetch(ctx, transport, input)
+	}
+}
+
+// webFetch fetches a URL
+func webFetch(ctx context.Context, transport http.RoundTripper, input WebFetchInput) (WebFetchOutput, error) {
 	// Check for cancellation
 	select {
 	case <-ctx.Done():
@@ -106,7 +125,8 @@ func webFetchHandler(ctx context.Context, input WebFetchInput) (WebFetchOutput,
 
 	// Create HTTP client with timeout
 	client := &http.Client{
-		Timeout: time.Duration(input.Timeout) * time.Second,
+		Transport: transport,
+		Timeout:   time.Duration(input.Timeout) * time.Second,
 		// Follow redirects
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 10 {
@@ -137,6 +157,10 @@ func webFetchHandler(ctx context.Context, input WebFetchInput) (WebFetchOutput,
 	// Make request
 	resp, err := client.Do(req)
 	if err != nil {
+		var denied *egress.DeniedError
+		if errors.As(err, &denied) {
+			return WebFetchOutput{}, denied
+		}
 		return WebFetchOutput{}, fmt.Errorf("failed to fetch URL: %v", err)
 	}
 	defer resp.Body.Close()
@@ -150,6 +174,10 @@ func webFetchHandler(ctx context.Context, input WebFetchInput) (WebFetchOutput,
 	const maxSize = 5 * 1024 * 1024 // 5MB
 	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize))
 	if err != nil {
+		var denied *egress.DeniedError
+		if errors.As(err, &denied) {
+			return WebFetchOutput{}, denied
+		}
 		return WebFetchOutput{}, fmt.Errorf("failed to read response: %v", err)
 	}
 
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -445,4 +446,37 @@ func TestWebFetchTimeoutLimits(t *testing.T) {
 			assert.Equal(t, "OK", response.Content)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestWebFetchNetworkPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	paramsJSON, _ := json.Marshal(map[string]interface{}{
+		"url":    server.URL,
+		"format": "text",
+	})
+	call := &aisdk.ToolCall{
+		Function: aisdk.FunctionCall{
+			Arguments: paramsJSON,
+		},
+	}
+
+	// The default permissions deny localhost
+	tool, err := ToolWithPolicy(config.DefaultConfig().Permissions.Network)
+	require.NoError(t, err)
+	resp, err := tool.Execute(context.Background(), call)
+	require.NoError(t, err)
+	assert.True(t, resp.IsError, "Expected localhost to be denied")
+	assert.Contains(t, string(resp.Content), "network access to 127.0.0.1 denied")
+
+	tool, err = ToolWithPolicy(config.NetworkPermissions{AllowLocalhost: true})
+	require.NoError(t, err)
+	resp, err = tool.Execute(context.Background(), call)
+	require.NoError(t, err)
+	assert.False(t, resp.IsError, "Expected localhost to be allowed: %s", resp.Content)
+	assert.Contains(t, string(resp.Content), "internal")
+}
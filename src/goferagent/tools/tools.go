@@ -6,6 +6,7 @@ package tools
 
 import (
 	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/config"
 	"github.com/elee1766/gofer/src/shell"
 	tool_copyfile "github.com/elee1766/gofer/src/goferagent/tools/tool_copyfile"
 	tool_createdir "github.com/elee1766/gofer/src/goferagent/tools/tool_createdir"
@@ -63,7 +64,9 @@ func CopyFileTool(fs afero.Fs) (agent.Tool, error) { return tool_copyfile.Tool(f
 func GetFileInfoTool(fs afero.Fs) (agent.Tool, error) { return tool_getfileinfo.Tool(fs) }
 func SearchFilesTool(fs afero.Fs) (agent.Tool, error) { return tool_searchfiles.Tool(fs) }
 func GrepFilesTool(fs afero.Fs) (agent.Tool, error) { return tool_grepfiles.Tool(fs) }
-func WebFetchTool() (agent.Tool, error) { return tool_webfetch.Tool() }
+func WebFetchTool(network config.NetworkPermissions) (agent.Tool, error) {
+	return tool_webfetch.ToolWithPolicy(network)
+}
 
 // Tools that require a shell manager
 func RunCommandTool(shellManager *shell.ShellManager) agent.Tool { return tool_runcommand.Tool(shellManager) }
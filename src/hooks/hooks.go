@@ -0,0 +1,158 @@
+// Package hooks runs user-configured commands at points of a run: before and
+// after tool calls, when a prompt is submitted and when the run stops.
+//
+// A hook command is run with sh -c in the project directory. It gets the
+// event as JSON on stdin, derived from the executor's event types with a
+// "hook" field naming the hook point, and may print a JSON Output on stdout.
+// Exiting with status 2 blocks the tool call or prompt with stderr as the
+// reason; any other failure is an error.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/elee1766/gofer/src/config"
+)
+
+// Hook points
+const (
+	PreToolUse   = "pre_tool_use"
+	PostToolUse  = "post_tool_use"
+	PromptSubmit = "prompt_submit"
+	Stop         = "stop"
+)
+
+// DecisionBlock is the decision that blocks a tool call or prompt
+const DecisionBlock = "block"
+
+// blockExitCode is the exit status that blocks with stderr as the reason
+const blockExitCode = 2
+
+// defaultTimeout is the time a hook may run unless configured
+const defaultTimeout = 60 * time.Second
+
+// Output is the decision a hook prints on stdout. Empty output allows the
+// tool call or prompt unchanged.
+type Output struct {
+	// Decision is "block" to stop the tool call or prompt, anything else
+	// allows it
+	Decision string `json:"decision,omitempty"`
+
+	// Reason explains a block. For tool calls it is sent to the model as
+	// the result.
+	Reason string `json:"reason,omitempty"`
+
+	// Arguments replace the arguments of the tool call (pre_tool_use)
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+
+	// Prompt replaces the submitted prompt (prompt_submit)
+	Prompt string `json:"prompt,omitempty"`
+
+	// Feedback is appended to the result of the tool call (post_tool_use)
+	Feedback string `json:"feedback,omitempty"`
+}
+
+// BlockedError is returned when a hook blocks a prompt
+type BlockedError struct {
+	Hook   string
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("blocked by %s hook: %s", e.Hook, e.Reason)
+}
+
+// Hooks runs the configured hook commands
+type Hooks struct {
+	config config.HooksConfig
+	dir    string
+	logger *slog.Logger
+}
+
+// New creates hooks that run their commands in dir
+func New(cfg config.HooksConfig, dir string, logger *slog.Logger) *Hooks {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Hooks{config: cfg, dir: dir, logger: logger}
+}
+
+// Empty reports whether no hooks are configured
+func (h *Hooks) Empty() bool {
+	return len(h.config.PreToolUse) == 0 && len(h.config.PostToolUse) == 0 &&
+		len(h.config.PromptSubmit) == 0 && len(h.config.Stop) == 0
+}
+
+// matches reports whether a tool hook applies to the tool
+func matches(hook config.HookConfig, tool string) bool {
+	if len(hook.Tools) == 0 {
+		return true
+	}
+	for _, pattern := range hook.Tools {
+		if ok, _ := path.Match(pattern, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs a hook command with the input and returns its decision
+func (h *Hooks) run(ctx context.Context, point string, hook config.HookConfig, input any) (*Output, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s hook input: %w", point, err)
+	}
+
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	cmd.Dir = h.dir
+	cmd.Env = append(os.Environ(), "GOFER_HOOK="+point, "GOFER_PROJECT_DIR="+h.dir)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait for background processes of a killed hook holding the pipes
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	h.logger.Debug("ran hook", "hook", point, "command", hook.Command, "duration", time.Since(start), "error", err)
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == blockExitCode:
+		reason := strings.TrimSpace(stderr.String())
+		if reason == "" {
+			reason = fmt.Sprintf("%q exited with status %d", hook.Command, blockExitCode)
+		}
+		return &Output{Decision: DecisionBlock, Reason: reason}, nil
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("%s hook %q timed out after %s", point, hook.Command, timeout)
+	case err != nil:
+		return nil, fmt.Errorf("%s hook %q failed: %w: %s", point, hook.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	output := &Output{}
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err := json.Unmarshal(out, output); err != nil {
+			return nil, fmt.Errorf("%s hook %q printed invalid JSON: %w", point, hook.Command, err)
+		}
+	}
+	return output, nil
+}
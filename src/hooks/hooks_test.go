@@ -0,0 +1,210 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/config"
+	"github.com/elee1766/gofer/src/executor"
+	"github.com/elee1766/gofer/src/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoToolbox returns a toolbox whose tools return their arguments, with the
+// hooks middleware registered
+func echoToolbox(t *testing.T, h *Hooks, names ...string) *agent.DefaultToolbox {
+	t.Helper()
+	toolbox := agent.NewToolbox[agent.Tool]()
+	for _, name := range names {
+		require.NoError(t, toolbox.RegisterTool(&agent.LegacyTool{Type: "function", Function: aisdk.ToolFunction{Name: name}, Executor: func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+			return &aisdk.ToolResponse{Type: "success", Content: call.Function.Arguments}, nil
+		}}))
+	}
+	toolbox.RegisterMiddleware(h.Middleware())
+	return toolbox
+}
+
+func call(name, args string) *aisdk.ToolCall {
+	return &aisdk.ToolCall{ID: "call_0", Type: "function", Function: aisdk.FunctionCall{Name: name, Arguments: []byte(args)}}
+}
+
+// readInput decodes the hook input a test hook saved
+func readInput(t *testing.T, path string) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var input map[string]any
+	require.NoError(t, json.Unmarshal(data, &input))
+	return input
+}
+
+func TestPreToolUse(t *testing.T) {
+	dir := t.TempDir()
+	h := New(config.HooksConfig{PreToolUse: []config.HookConfig{
+		{Command: `cat > input.json; echo '{"arguments": {"path": "rewritten.txt"}}'`, Tools: []string{"write_*"}},
+		{Command: `grep -q prod.yaml && { echo "production configs are off limits" >&2; exit 2; }; true`, Tools: []string{"run_command"}},
+		{Command: `exit 1`, Tools: []string{"broken"}},
+	}}, dir, nil)
+	toolbox := echoToolbox(t, h, "write_file", "run_command", "broken", "read_file")
+
+	// Arguments are rewritten, and the hook sees the call as an event
+	result, err := toolbox.ExecuteTool(context.Background(), call("write_file", `{"path": "a.txt"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"path": "rewritten.txt"}`, string(result.Content))
+	input := readInput(t, filepath.Join(dir, "input.json"))
+	assert.Equal(t, PreToolUse, input["hook"])
+	assert.Equal(t, string(executor.EventToolCallRequest), input["type"])
+	assert.Equal(t, map[string]any{"path": "a.txt"}, input["tool_call"].(map[string]any)["function"].(map[string]any)["arguments"])
+
+	// Exit status 2 blocks the call with stderr as the reason
+	result, err = toolbox.ExecuteTool(context.Background(), call("run_command", `{"command": "cat deploy/prod.yaml"}`))
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Contains(t, string(result.Content), "production configs are off limits")
+
+	result, err = toolbox.ExecuteTool(context.Background(), call("run_command", `{"command": "ls"}`))
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+
+	// A failing guard fails the call
+	_, err = toolbox.ExecuteTool(context.Background(), call("broken", `{}`))
+	assert.ErrorContains(t, err, "pre_tool_use hook")
+
+	// Hooks only run for matching tools
+	result, err = toolbox.ExecuteTool(context.Background(), call("read_file", `{"path": "a.txt"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"path": "a.txt"}`, string(result.Content))
+}
+
+func TestPreToolUseRewriteThroughExecutor(t *testing.T) {
+	dir := t.TempDir()
+	h := New(config.HooksConfig{PreToolUse: []config.HookConfig{
+		{Command: `grep -q a.txt && echo '{"arguments": {"path": "secret.txt"}}' || echo '{"arguments": {"path": "b.txt"}}'`},
+	}}, dir, nil)
+	toolbox := echoToolbox(t, h, "write_file")
+
+	db, err := storage.Open(filepath.Join(dir, "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	conversation := &storage.Conversation{Title: "hooks"}
+	require.NoError(t, storage.CreateConversation(context.Background(), db.DB(), conversation))
+	message := &storage.Message{ConversationID: conversation.ID, Role: "assistant"}
+	require.NoError(t, storage.CreateMessage(context.Background(), db.DB(), message))
+	service := executor.NewService(executor.ServiceConfig{
+		Database: db.DB(),
+		Permissions: config.NewPermissionChecker(&config.PermissionsConfig{
+			DefaultMode: "allow",
+			Tools:       config.ToolPermissions{Deny: []string{"write_file(secret*)"}},
+		}),
+	})
+
+	first, second := call("write_file", `{"path": "a.txt"}`), call("write_file", `{"path": "c.txt"}`)
+	second.ID = "call_1"
+	result, err := service.ExecuteToolCalls(context.Background(), &executor.ToolExecutionRequest{
+		ToolCalls:      []aisdk.ToolCall{*first, *second},
+		Toolbox:        toolbox,
+		ConversationID: conversation.ID,
+		MessageID:      message.ID,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.ToolResults, 2)
+
+	// A call rewritten into a denied one does not run
+	assert.True(t, result.ToolResults[0].IsError)
+	assert.Contains(t, result.ToolResults[0].Content, "deny pattern: write_file(secret*)")
+	assert.JSONEq(t, `{"path": "b.txt"}`, result.ToolResults[1].Content)
+
+	// The executions record the arguments the calls ran with
+	executions, err := storage.GetToolExecutionsByMessageID(context.Background(), db.DB(), message.ID)
+	require.NoError(t, err)
+	inputs := make(map[string]string)
+	for _, execution := range executions {
+		inputs[execution.ToolCallID] = execution.Input
+	}
+	assert.JSONEq(t, `{"path": "secret.txt"}`, inputs["call_0"])
+	assert.JSONEq(t, `{"path": "b.txt"}`, inputs["call_1"])
+}
+
+func TestPostToolUseThroughExecutor(t *testing.T) {
+	dir := t.TempDir()
+	h := New(config.HooksConfig{PostToolUse: []config.HookConfig{
+		{Command: `cat > input.json; echo '{"feedback": "gofmt rewrote main.go"}'`, Tools: []string{"edit_file"}},
+		{Command: `echo 'not json'`},
+	}}, dir, nil)
+	toolbox := echoToolbox(t, h, "edit_file")
+
+	db, err := storage.Open(filepath.Join(dir, "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	conversation := &storage.Conversation{Title: "hooks"}
+	require.NoError(t, storage.CreateConversation(context.Background(), db.DB(), conversation))
+	service := executor.NewService(executor.ServiceConfig{Database: db.DB()})
+
+	result, err := service.ExecuteToolCalls(context.Background(), &executor.ToolExecutionRequest{
+		ToolCalls:      []aisdk.ToolCall{*call("edit_file", `{"path": "main.go"}`)},
+		Toolbox:        toolbox,
+		ConversationID: conversation.ID,
+		TurnNumber:     3,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.ToolResults, 1)
+
+	// The feedback is appended to the result; the hook printing invalid
+	// JSON is skipped
+	content := result.ToolResults[0].Content
+	assert.Contains(t, content, `{"path": "main.go"}`)
+	assert.Contains(t, content, "<hook-feedback>\ngofmt rewrote main.go\n</hook-feedback>")
+
+	input := readInput(t, filepath.Join(dir, "input.json"))
+	assert.Equal(t, PostToolUse, input["hook"])
+	assert.Equal(t, conversation.ID, input["conversation_id"])
+	assert.Equal(t, 3.0, input["turn_number"])
+	assert.Equal(t, "edit_file", input["tool_name"])
+	assert.Equal(t, map[string]any{"content": `{"path": "main.go"}`, "is_error": false}, input["response"])
+}
+
+func TestRunHooks(t *testing.T) {
+	dir := t.TempDir()
+	h := New(config.HooksConfig{
+		PromptSubmit: []config.HookConfig{
+			{Command: `grep -q secret && { echo "no secrets" >&2; exit 2; }; echo '{"prompt": "rewritten"}'`},
+			{Command: `cat > prompt.json`},
+		},
+		Stop: []config.HookConfig{
+			{Command: `cat > stop.json`},
+			{Command: `exit 1`},
+		},
+	}, dir, nil)
+	runHooks := h.RunHooks("conv-1")
+
+	prompt, err := runHooks.OnPromptSubmit(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "rewritten", prompt)
+	input := readInput(t, filepath.Join(dir, "prompt.json"))
+	assert.Equal(t, "rewritten", input["message"])
+	assert.Equal(t, "hello", input["original_text"])
+	assert.Equal(t, "conv-1", input["conversation_id"])
+
+	_, err = runHooks.OnPromptSubmit(context.Background(), "my secret")
+	var blocked *BlockedError
+	require.True(t, errors.As(err, &blocked), "got %v", err)
+	assert.Equal(t, "no secrets", blocked.Reason)
+
+	// Stop hooks run with a cancelled context, and failures are only logged
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runHooks.OnStop(ctx, &executor.RunResult{StopReason: executor.StopInterrupted, Turns: 2, Response: &executor.Response{Content: "partial"}}, context.Canceled)
+	input = readInput(t, filepath.Join(dir, "stop.json"))
+	assert.Equal(t, Stop, input["hook"])
+	assert.Equal(t, "interrupted", input["reason"])
+	assert.Equal(t, 2.0, input["total_turns"])
+	assert.Equal(t, "partial", input["response"])
+	assert.Equal(t, "context canceled", input["error"])
+}
@@ -0,0 +1,102 @@
+package hooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/elee1766/gofer/src/executor"
+)
+
+// promptSubmitInput is the input of prompt_submit hooks
+type promptSubmitInput struct {
+	Hook string `json:"hook"`
+	*executor.UserMessageEvent
+}
+
+// stopInput is the input of stop hooks
+type stopInput struct {
+	Hook string `json:"hook"`
+	*executor.ConversationCompleteEvent
+
+	// Response is the last response of the model
+	Response string `json:"response"`
+
+	// Error ends the run, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// RunHooks returns the run hooks that run the prompt_submit and stop hooks
+// for a run in the conversation
+func (h *Hooks) RunHooks(conversationID string) executor.RunHooks {
+	var hooks executor.RunHooks
+	if len(h.config.PromptSubmit) > 0 {
+		hooks.OnPromptSubmit = func(ctx context.Context, prompt string) (string, error) {
+			return h.promptSubmit(ctx, conversationID, prompt)
+		}
+	}
+	if len(h.config.Stop) > 0 {
+		hooks.OnStop = func(ctx context.Context, result *executor.RunResult, err error) {
+			h.stop(ctx, conversationID, result, err)
+		}
+	}
+	return hooks
+}
+
+// promptSubmit runs the prompt_submit hooks in order and returns the prompt
+// they rewrote, or a BlockedError
+func (h *Hooks) promptSubmit(ctx context.Context, conversationID, prompt string) (string, error) {
+	original := prompt
+	for _, hook := range h.config.PromptSubmit {
+		event := &executor.UserMessageEvent{
+			BaseEvent: executor.BaseEvent{
+				Type:           executor.EventUserMessage,
+				Timestamp:      time.Now(),
+				ConversationID: conversationID,
+			},
+			Message:      prompt,
+			IsWrapped:    prompt != original,
+			OriginalText: original,
+		}
+		output, err := h.run(ctx, PromptSubmit, hook, promptSubmitInput{Hook: PromptSubmit, UserMessageEvent: event})
+		if err != nil {
+			return "", err
+		}
+		if output.Decision == DecisionBlock {
+			return "", &BlockedError{Hook: PromptSubmit, Reason: output.Reason}
+		}
+		if output.Prompt != "" {
+			prompt = output.Prompt
+		}
+	}
+	return prompt, nil
+}
+
+// stop runs the stop hooks. They also run when the run was interrupted, and
+// their failures are only logged.
+func (h *Hooks) stop(ctx context.Context, conversationID string, result *executor.RunResult, runErr error) {
+	ctx = context.WithoutCancel(ctx)
+	input := stopInput{
+		Hook: Stop,
+		ConversationCompleteEvent: &executor.ConversationCompleteEvent{
+			BaseEvent: executor.BaseEvent{
+				Type:           executor.EventConversationComplete,
+				Timestamp:      time.Now(),
+				ConversationID: conversationID,
+				TurnNumber:     result.Turns,
+			},
+			Reason:     string(result.StopReason),
+			TotalTurns: result.Turns,
+		},
+	}
+	if result.Response != nil {
+		input.Response = result.Response.Content
+	}
+	if runErr != nil {
+		input.Error = runErr.Error()
+	}
+	for _, hook := range h.config.Stop {
+		if _, err := h.run(ctx, Stop, hook, input); err != nil {
+			h.logger.Warn("stop hook failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/executor"
+)
+
+// preToolUseInput is the input of pre_tool_use hooks
+type preToolUseInput struct {
+	Hook string `json:"hook"`
+	*executor.ToolCallRequestEvent
+}
+
+// postToolUseInput is the input of post_tool_use hooks
+type postToolUseInput struct {
+	Hook string `json:"hook"`
+	*executor.ToolCallResponseEvent
+	ToolCall aisdk.ToolCall `json:"tool_call"`
+
+	// Response replaces the event's response, whose content would be
+	// encoded as base64
+	Response toolResult `json:"response"`
+}
+
+// toolResult is the result of a tool call as hooks see it
+type toolResult struct {
+	Content string `json:"content"`
+	IsError bool   `json:"is_error"`
+}
+
+// Middleware runs the pre_tool_use hooks before each tool call and the
+// post_tool_use hooks after it. A pre_tool_use hook that fails fails the
+// call, so broken guards don't let calls through. Calls a hook rewrote must
+// pass the executor's validation and permission checks again.
+func (h *Hooks) Middleware() agent.ToolMiddleware {
+	return func(next agent.ToolExecutor) agent.ToolExecutor {
+		return func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+			info, _ := executor.ToolCallInfoFromContext(ctx)
+
+			rewritten, blocked, err := h.preToolUse(ctx, info, call)
+			if err != nil {
+				return nil, err
+			}
+			if blocked != nil {
+				return blocked, nil
+			}
+			// The executor checked the original arguments, so rewritten
+			// ones are validated and checked again
+			if rewritten != call {
+				if rejected, err := executor.CheckRewrittenToolCall(ctx, rewritten); err != nil || rejected != nil {
+					return rejected, err
+				}
+				call = rewritten
+			}
+
+			start := time.Now()
+			result, err := next(ctx, call)
+			if err != nil || result == nil {
+				return result, err
+			}
+			return h.postToolUse(ctx, info, call, result, time.Since(start)), nil
+		}
+	}
+}
+
+// preToolUse runs the pre_tool_use hooks of a call in order. It returns the
+// call with the arguments the hooks rewrote, or the result of a blocked call.
+func (h *Hooks) preToolUse(ctx context.Context, info executor.ToolCallInfo, call *aisdk.ToolCall) (*aisdk.ToolCall, *aisdk.ToolResponse, error) {
+	for _, hook := range h.config.PreToolUse {
+		if !matches(hook, call.Function.Name) {
+			continue
+		}
+		event := &executor.ToolCallRequestEvent{
+			BaseEvent: baseEvent(executor.EventToolCallRequest, info),
+			ToolCall:  hookToolCall(call),
+		}
+		output, err := h.run(ctx, PreToolUse, hook, preToolUseInput{Hook: PreToolUse, ToolCallRequestEvent: event})
+		if err != nil {
+			return nil, nil, err
+		}
+		if output.Decision == DecisionBlock {
+			h.logger.Info("tool call blocked by hook", "tool", call.Function.Name, "id", call.ID, "reason", output.Reason)
+			return nil, aisdk.NewErrorToolResponse(fmt.Sprintf("Tool call blocked by a %s hook: %s", PreToolUse, output.Reason)), nil
+		}
+		if len(output.Arguments) > 0 {
+			if !json.Valid(output.Arguments) {
+				return nil, nil, fmt.Errorf("%s hook %q returned invalid arguments", PreToolUse, hook.Command)
+			}
+			h.logger.Info("tool call arguments rewritten by hook", "tool", call.Function.Name, "id", call.ID, "arguments", string(output.Arguments))
+			rewritten := *call
+			rewritten.Function.Arguments = output.Arguments
+			call = &rewritten
+		}
+	}
+	return call, nil, nil
+}
+
+// postToolUse runs the post_tool_use hooks of a call and appends their
+// feedback to the result. Failing hooks are logged and skipped.
+func (h *Hooks) postToolUse(ctx context.Context, info executor.ToolCallInfo, call *aisdk.ToolCall, result *aisdk.ToolResponse, duration time.Duration) *aisdk.ToolResponse {
+	for _, hook := range h.config.PostToolUse {
+		if !matches(hook, call.Function.Name) {
+			continue
+		}
+		input := postToolUseInput{
+			Hook: PostToolUse,
+			ToolCallResponseEvent: &executor.ToolCallResponseEvent{
+				BaseEvent: baseEvent(executor.EventToolCallResponse, info),
+				ToolName:  call.Function.Name,
+				ToolID:    call.ID,
+				Duration:  duration,
+			},
+			ToolCall: hookToolCall(call),
+			Response: toolResult{Content: string(result.Content), IsError: result.IsError},
+		}
+		output, err := h.run(ctx, PostToolUse, hook, input)
+		if err != nil {
+			h.logger.Warn("post tool use hook failed", "tool", call.Function.Name, "error", err)
+			continue
+		}
+		if output.Feedback != "" {
+			result = withFeedback(result, output.Feedback)
+		}
+	}
+	return result
+}
+
+// withFeedback returns a copy of the result with hook feedback appended
+func withFeedback(result *aisdk.ToolResponse, feedback string) *aisdk.ToolResponse {
+	text := fmt.Sprintf("\n\n<hook-feedback>\n%s\n</hook-feedback>", feedback)
+	out := *result
+	out.Content = append(append([]byte{}, result.Content...), text...)
+	if result.MultimodalContent != nil {
+		content := &aisdk.MultimodalContent{Items: append([]aisdk.ContentItem{}, result.MultimodalContent.Items...)}
+		content.AddText(text)
+		out.MultimodalContent = content
+	}
+	return &out
+}
+
+// hookToolCall returns the call as hooks see it. Arguments that aren't
+// valid JSON are passed as a string so the input can still be encoded.
+func hookToolCall(call *aisdk.ToolCall) aisdk.ToolCall {
+	out := *call
+	if !json.Valid(out.Function.Arguments) {
+		out.Function.Arguments, _ = json.Marshal(string(call.Function.Arguments))
+	}
+	return out
+}
+
+// baseEvent returns the common event fields of a hook input
+func baseEvent(eventType executor.EventType, info executor.ToolCallInfo) executor.BaseEvent {
+	return executor.BaseEvent{
+		Type:           eventType,
+		Timestamp:      time.Now(),
+		ConversationID: info.ConversationID,
+		TurnNumber:     info.TurnNumber,
+	}
+}
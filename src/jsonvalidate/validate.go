@@ -0,0 +1,510 @@
+// Package jsonvalidate validates decoded JSON values against a JSON Schema.
+//
+// It supports the subset of JSON Schema that tool parameters and structured
+// outputs use: type, properties, required, additionalProperties, items, enum,
+// const, numeric and length bounds, pattern, allOf/anyOf/oneOf/not and local
+// $ref pointers into definitions or $defs. Unknown keywords are ignored.
+package jsonvalidate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is a single validation failure
+type FieldError struct {
+	Path    string `json:"path"` // JSON pointer to the invalid value, "" for the root
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return path + ": " + e.Message
+}
+
+// ValidationError lists every way a value fails its schema
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.String())
+	}
+	return "schema validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Schema is a compiled schema
+type Schema struct {
+	root     interface{}
+	patterns map[string]*regexp.Regexp
+
+	// closed rejects properties that an object schema does not list
+	closed bool
+}
+
+// Option configures a compiled schema
+type Option func(*Schema)
+
+// DisallowUnknownProperties rejects properties that are not listed in the
+// properties of an object schema without additionalProperties, as if it were
+// false. Generated schemas rarely set it, but callers such as models should
+// still not make up arguments.
+func DisallowUnknownProperties() Option {
+	return func(s *Schema) { s.closed = true }
+}
+
+// Compile parses a schema from JSON. Any value that encodes to a JSON Schema
+// is accepted, including json.RawMessage and *jsonschema.Schema.
+func Compile(schema interface{}, opts ...Option) (*Schema, error) {
+	var data []byte
+	switch s := schema.(type) {
+	case json.RawMessage:
+		data = s
+	case []byte:
+		data = s
+	default:
+		var err error
+		if data, err = json.Marshal(schema); err != nil {
+			return nil, fmt.Errorf("failed to encode schema: %w", err)
+		}
+	}
+
+	var root interface{}
+	if err := decode(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	compiled := &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	for _, opt := range opts {
+		opt(compiled)
+	}
+	return compiled, nil
+}
+
+// ValidateJSON decodes data and validates it
+func (s *Schema) ValidateJSON(data []byte) error {
+	var value interface{}
+	if err := decode(data, &value); err != nil {
+		return &ValidationError{Errors: []FieldError{{Message: "invalid JSON: " + err.Error()}}}
+	}
+	return s.validateDecoded(value)
+}
+
+// Validate validates any Go value by its JSON encoding
+func (s *Schema) Validate(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+	return s.ValidateJSON(data)
+}
+
+func (s *Schema) validateDecoded(value interface{}) error {
+	v := &validator{schema: s}
+	v.validate(s.root, value, "")
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+// decode unmarshals JSON keeping numbers exact
+func decode(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
+
+type validator struct {
+	schema *Schema
+	errors []FieldError
+	depth  int
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// validate checks value against a schema node, appending failures
+func (v *validator) validate(node interface{}, value interface{}, path string) {
+	switch n := node.(type) {
+	case bool:
+		if !n {
+			v.fail(path, "no value is allowed here")
+		}
+		return
+	case map[string]interface{}:
+		v.validateObjectSchema(n, value, path)
+	}
+}
+
+func (v *validator) validateObjectSchema(node map[string]interface{}, value interface{}, path string) {
+	if ref, ok := node["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			v.fail(path, "%v", err)
+			return
+		}
+		// Guard against self referencing schemas
+		if v.depth > 64 {
+			v.fail(path, "schema reference depth exceeded")
+			return
+		}
+		v.depth++
+		v.validate(target, value, path)
+		v.depth--
+	}
+
+	if t, ok := node["type"]; ok && !matchesType(t, value) {
+		v.fail(path, "expected %s, got %s", describeType(t), typeName(value))
+		return
+	}
+
+	if enum, ok := node["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if equal(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of %s", encode(enum))
+		}
+	}
+	if c, ok := node["const"]; ok && !equal(c, value) {
+		v.fail(path, "must be %s", encode(c))
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(node, val, path)
+	case []interface{}:
+		v.validateArray(node, val, path)
+	case string:
+		v.validateString(node, val, path)
+	case json.Number:
+		v.validateNumber(node, val, path)
+	}
+
+	if all, ok := node["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			v.validate(sub, value, path)
+		}
+	}
+	if anyOf, ok := node["anyOf"].([]interface{}); ok {
+		if v.countMatches(anyOf, value, path) == 0 {
+			v.fail(path, "must match at least one of the allowed schemas")
+		}
+	}
+	if oneOf, ok := node["oneOf"].([]interface{}); ok {
+		if n := v.countMatches(oneOf, value, path); n != 1 {
+			v.fail(path, "must match exactly one of the allowed schemas, matched %d", n)
+		}
+	}
+	if not, ok := node["not"]; ok {
+		if v.matches(not, value, path) {
+			v.fail(path, "must not match the excluded schema")
+		}
+	}
+}
+
+func (v *validator) validateObject(node map[string]interface{}, obj map[string]interface{}, path string) {
+	if required, ok := node["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, present := obj[name]; !present {
+				v.fail(path, "missing required property %q", name)
+			}
+		}
+	}
+
+	props, hasProps := node["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		childPath := path + "/" + escapePointer(k)
+		if prop, ok := props[k]; ok {
+			v.validate(prop, obj[k], childPath)
+			continue
+		}
+		if additional, ok := node["additionalProperties"]; ok {
+			if allowed, isBool := additional.(bool); isBool && !allowed {
+				v.fail(path, "unexpected property %q", k)
+				continue
+			}
+			v.validate(additional, obj[k], childPath)
+		} else if v.schema.closed && hasProps {
+			v.fail(path, "unexpected property %q", k)
+		}
+	}
+
+	if n, ok := intKeyword(node, "minProperties"); ok && len(obj) < n {
+		v.fail(path, "must have at least %d properties", n)
+	}
+	if n, ok := intKeyword(node, "maxProperties"); ok && len(obj) > n {
+		v.fail(path, "must have at most %d properties", n)
+	}
+}
+
+func (v *validator) validateArray(node map[string]interface{}, arr []interface{}, path string) {
+	if items, ok := node["items"]; ok {
+		if tuple, isTuple := items.([]interface{}); isTuple {
+			for i, sub := range tuple {
+				if i < len(arr) {
+					v.validate(sub, arr[i], path+"/"+strconv.Itoa(i))
+				}
+			}
+		} else {
+			for i, item := range arr {
+				v.validate(items, item, path+"/"+strconv.Itoa(i))
+			}
+		}
+	}
+	if n, ok := intKeyword(node, "minItems"); ok && len(arr) < n {
+		v.fail(path, "must have at least %d items", n)
+	}
+	if n, ok := intKeyword(node, "maxItems"); ok && len(arr) > n {
+		v.fail(path, "must have at most %d items", n)
+	}
+	if unique, _ := node["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if equal(arr[i], arr[j]) {
+					v.fail(path, "items %d and %d are equal", i, j)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) validateString(node map[string]interface{}, s string, path string) {
+	length := utf8.RuneCountInString(s)
+	if n, ok := intKeyword(node, "minLength"); ok && length < n {
+		v.fail(path, "must be at least %d characters", n)
+	}
+	if n, ok := intKeyword(node, "maxLength"); ok && length > n {
+		v.fail(path, "must be at most %d characters", n)
+	}
+	if pattern, ok := node["pattern"].(string); ok {
+		re, err := v.schema.compilePattern(pattern)
+		if err != nil {
+			v.fail(path, "invalid pattern %q in schema", pattern)
+		} else if !re.MatchString(s) {
+			v.fail(path, "must match pattern %q", pattern)
+		}
+	}
+}
+
+func (v *validator) validateNumber(node map[string]interface{}, num json.Number, path string) {
+	f, err := num.Float64()
+	if err != nil {
+		v.fail(path, "invalid number %s", num)
+		return
+	}
+	if min, ok := numberKeyword(node, "minimum"); ok && f < min {
+		v.fail(path, "must be >= %v", min)
+	}
+	if max, ok := numberKeyword(node, "maximum"); ok && f > max {
+		v.fail(path, "must be <= %v", max)
+	}
+	if min, ok := numberKeyword(node, "exclusiveMinimum"); ok && f <= min {
+		v.fail(path, "must be > %v", min)
+	}
+	if max, ok := numberKeyword(node, "exclusiveMaximum"); ok && f >= max {
+		v.fail(path, "must be < %v", max)
+	}
+	if m, ok := numberKeyword(node, "multipleOf"); ok && m > 0 {
+		if q := f / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(path, "must be a multiple of %v", m)
+		}
+	}
+}
+
+// matches reports whether value is valid against node without recording failures
+func (v *validator) matches(node interface{}, value interface{}, path string) bool {
+	sub := &validator{schema: v.schema, depth: v.depth}
+	sub.validate(node, value, path)
+	return len(sub.errors) == 0
+}
+
+func (v *validator) countMatches(nodes []interface{}, value interface{}, path string) int {
+	n := 0
+	for _, node := range nodes {
+		if v.matches(node, value, path) {
+			n++
+		}
+	}
+	return n
+}
+
+// resolve follows a local JSON pointer reference such as #/definitions/Item
+func (v *validator) resolve(ref string) (interface{}, error) {
+	if ref == "#" {
+		return v.schema.root, nil
+	}
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported schema reference %q", ref)
+	}
+
+	node := v.schema.root
+	for _, part := range strings.Split(pointer, "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %q", ref)
+		}
+		if node, ok = obj[part]; !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %q", ref)
+		}
+	}
+	return node, nil
+}
+
+func (s *Schema) compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := s.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	s.patterns[pattern] = re
+	return re, nil
+}
+
+// matchesType checks the type keyword, which is a name or a list of names
+func matchesType(t interface{}, value interface{}) bool {
+	switch tt := t.(type) {
+	case string:
+		return isType(tt, value)
+	case []interface{}:
+		for _, name := range tt {
+			if s, ok := name.(string); ok && isType(s, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(name string, value interface{}) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		num, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := num.Float64()
+		return err == nil && f == math.Trunc(f)
+	}
+	return true
+}
+
+func describeType(t interface{}) string {
+	if list, ok := t.([]interface{}); ok {
+		names := make([]string, 0, len(list))
+		for _, n := range list {
+			names = append(names, fmt.Sprint(n))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func typeName(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := val.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// equal compares decoded JSON values, treating numbers by value
+func equal(a, b interface{}) bool {
+	an, aNum := a.(json.Number)
+	bn, bNum := b.(json.Number)
+	if aNum && bNum {
+		af, err1 := an.Float64()
+		bf, err2 := bn.Float64()
+		return err1 == nil && err2 == nil && af == bf
+	}
+	return encode(a) == encode(b)
+}
+
+func encode(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func numberKeyword(node map[string]interface{}, key string) (float64, bool) {
+	num, ok := node[key].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := num.Float64()
+	return f, err == nil
+}
+
+func intKeyword(node map[string]interface{}, key string) (int, bool) {
+	f, ok := numberKeyword(node, key)
+	return int(f), ok
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
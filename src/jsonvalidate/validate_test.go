@@ -0,0 +1,107 @@
+package jsonvalidate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jsonschema "github.com/swaggest/jsonschema-go"
+)
+
+type finding struct {
+	File     string `json:"file" required:"true"`
+	Line     int    `json:"line" minimum:"1"`
+	Severity string `json:"severity" required:"true" enum:"low,medium,high"`
+}
+
+type report struct {
+	Summary  string    `json:"summary" required:"true" minLength:"1"`
+	Findings []finding `json:"findings" required:"true"`
+}
+
+func TestValidateReflectedSchema(t *testing.T) {
+	var reflector jsonschema.Reflector
+	s, err := reflector.Reflect(report{})
+	require.NoError(t, err)
+	schema, err := Compile(s)
+	require.NoError(t, err)
+
+	assert.NoError(t, schema.ValidateJSON([]byte(`{"summary":"ok","findings":[{"file":"a.go","line":3,"severity":"high"}]}`)))
+	assert.NoError(t, schema.Validate(report{Summary: "ok", Findings: []finding{{File: "a.go", Line: 1, Severity: "low"}}}))
+
+	err = schema.ValidateJSON([]byte(`{"summary":"","findings":[{"line":0,"severity":"critical"},{"file":1,"severity":"low"}]}`))
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	var got []string
+	for _, fe := range verr.Errors {
+		got = append(got, fe.String())
+	}
+	assert.ElementsMatch(t, []string{
+		"/summary: must be at least 1 characters",
+		`/findings/0: missing required property "file"`,
+		"/findings/0/line: must be >= 1",
+		`/findings/0/severity: must be one of ["low","medium","high"]`,
+		"/findings/1/file: expected string, got integer",
+	}, got)
+
+	assert.Error(t, schema.ValidateJSON([]byte(`not json`)))
+	assert.Error(t, schema.ValidateJSON([]byte(`[]`)))
+}
+
+func TestValidateKeywords(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		valid  bool
+	}{
+		{"integer", `{"type":"integer"}`, `1.5`, false},
+		{"integer whole", `{"type":"integer"}`, `2.0`, true},
+		{"nullable", `{"type":["string","null"]}`, `null`, true},
+		{"additional properties", `{"type":"object","properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"b":2}`, false},
+		{"additional schema", `{"type":"object","additionalProperties":{"type":"string"}}`, `{"a":"x","b":2}`, false},
+		{"pattern", `{"type":"string","pattern":"^[a-z]+$"}`, `"abc"`, true},
+		{"pattern mismatch", `{"type":"string","pattern":"^[a-z]+$"}`, `"ABC"`, false},
+		{"anyOf", `{"anyOf":[{"type":"string"},{"type":"integer"}]}`, `true`, false},
+		{"oneOf", `{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `1`, false},
+		{"not", `{"not":{"type":"null"}}`, `null`, false},
+		{"ref", `{"$defs":{"n":{"type":"number","maximum":3}},"type":"array","items":{"$ref":"#/$defs/n"}}`, `[1,2,4]`, false},
+		{"unique", `{"type":"array","uniqueItems":true}`, `[1,1.0]`, false},
+		{"const", `{"const":"x"}`, `"x"`, true},
+		{"false schema", `false`, `1`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := Compile([]byte(tt.schema))
+			require.NoError(t, err)
+			err = schema.ValidateJSON([]byte(tt.value))
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestDisallowUnknownProperties(t *testing.T) {
+	raw := []byte(`{"type":"object","properties":{"a":{"type":"object","properties":{"b":{}}},"m":{"type":"object"}}}`)
+	value := []byte(`{"a":{"b":1,"c":2},"m":{"any":1},"d":3}`)
+
+	open, err := Compile(raw)
+	require.NoError(t, err)
+	assert.NoError(t, open.ValidateJSON(value))
+
+	closed, err := Compile(raw, DisallowUnknownProperties())
+	require.NoError(t, err)
+	err = closed.ValidateJSON(value)
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	// Objects without properties, like maps, stay open
+	assert.Equal(t, []FieldError{
+		{Path: "/a", Message: `unexpected property "c"`},
+		{Path: "", Message: `unexpected property "d"`},
+	}, verr.Errors)
+}
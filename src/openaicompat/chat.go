@@ -0,0 +1,381 @@
+package openaicompat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/google/uuid"
+)
+
+var (
+	_ aisdk.ModelClient          = (*ModelClient)(nil)
+	_ aisdk.StreamingModelClient = (*ModelClient)(nil)
+)
+
+// ModelClient represents a client bound to a specific model
+type ModelClient struct {
+	client *Client
+	model  *aisdk.ModelInfo
+}
+
+// CreateChatCompletion creates a chat completion with the bound model
+func (mc *ModelClient) CreateChatCompletion(ctx context.Context, req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionResponse, error) {
+	req.Model = mc.model.ID
+	req.Messages = aisdk.PrepareMessagesForModel(req.Messages, mc.model)
+	return mc.client.createChatCompletion(ctx, req)
+}
+
+// CreateChatCompletionStream streams a chat completion with the bound model
+func (mc *ModelClient) CreateChatCompletionStream(ctx context.Context, req *aisdk.ChatCompletionRequest, handler aisdk.StreamHandler) (*aisdk.ChatCompletionResponse, error) {
+	req.Model = mc.model.ID
+	req.Messages = aisdk.PrepareMessagesForModel(req.Messages, mc.model)
+	return mc.client.createChatCompletionStream(ctx, req, handler)
+}
+
+// GetModelInfo returns the model information
+func (mc *ModelClient) GetModelInfo() *aisdk.ModelInfo {
+	return mc.model
+}
+
+// chatRequest is the body of POST /chat/completions. Only fields that every
+// supported server understands are sent.
+type chatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []chatMessage         `json:"messages"`
+	Temperature    *float64              `json:"temperature,omitempty"`
+	MaxTokens      *int                  `json:"max_tokens,omitempty"`
+	TopP           *float64              `json:"top_p,omitempty"`
+	Stop           []string              `json:"stop,omitempty"`
+	Tools          []*aisdk.ChatTool     `json:"tools,omitempty"`
+	ToolChoice     string                `json:"tool_choice,omitempty"`
+	ResponseFormat *aisdk.ResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *aisdk.StreamOptions  `json:"stream_options,omitempty"`
+}
+
+// chatMessage is a message in the OpenAI wire format
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    interface{}    `json:"content"` // string or []aisdk.ContentPart
+	Name       string         `json:"name,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+	ToolCalls  []wireToolCall `json:"tool_calls,omitempty"`
+}
+
+// wireToolCall is a tool call in the OpenAI wire format, where arguments are a JSON encoded string
+type wireToolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string          `json:"name,omitempty"`
+		Arguments json.RawMessage `json:"arguments,omitempty"`
+	} `json:"function"`
+}
+
+// chatResponse is the response of POST /chat/completions
+type chatResponse struct {
+	ID      string `json:"id"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role      string         `json:"role"`
+			Content   *string        `json:"content"`
+			ToolCalls []wireToolCall `json:"tool_calls"`
+		} `json:"message"`
+		Delta struct {
+			Role      string         `json:"role"`
+			Content   *string        `json:"content"`
+			ToolCalls []wireToolCall `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *wireUsage `json:"usage"`
+	Error *wireError `json:"error"`
+}
+
+// wireUsage is the OpenAI usage object, including cached prompt token details
+type wireUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+// toUsage converts wire usage into aisdk usage
+func (u *wireUsage) toUsage() aisdk.Usage {
+	usage := aisdk.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = u.PromptTokens + u.CompletionTokens
+	}
+	if u.PromptTokensDetails != nil {
+		usage.PromptTokensCached = u.PromptTokensDetails.CachedTokens
+	}
+	return usage
+}
+
+// buildRequest converts an aisdk request into the wire format
+func buildRequest(req *aisdk.ChatCompletionRequest) *chatRequest {
+	out := &chatRequest{
+		Model:          req.Model,
+		Temperature:    req.Temperature,
+		MaxTokens:      req.MaxTokens,
+		TopP:           req.TopP,
+		Stop:           req.Stop,
+		Tools:          req.Tools,
+		ToolChoice:     req.ToolChoice,
+		ResponseFormat: req.ResponseFormat,
+	}
+
+	for _, msg := range aisdk.HoistToolImages(req.Messages) {
+		wire := chatMessage{
+			Role:       msg.Role,
+			Content:    msg.OpenAIContent(),
+			Name:       msg.Name,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, tc := range msg.ToolCalls {
+			call := wireToolCall{ID: tc.ID, Type: "function"}
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = encodeArguments(tc.Function.Arguments)
+			wire.ToolCalls = append(wire.ToolCalls, call)
+		}
+		out.Messages = append(out.Messages, wire)
+	}
+
+	return out
+}
+
+// encodeArguments encodes raw JSON arguments as the JSON string the API expects
+func encodeArguments(args json.RawMessage) json.RawMessage {
+	if len(strings.TrimSpace(string(args))) == 0 || string(args) == "null" {
+		args = json.RawMessage("{}")
+	}
+	encoded, _ := json.Marshal(string(args))
+	return encoded
+}
+
+// decodeArguments accepts arguments either as a JSON encoded string (the OpenAI
+// format) or as a JSON object (as some local servers return them)
+func decodeArguments(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// convertToolCalls converts wire tool calls into aisdk tool calls, filling in missing IDs
+func convertToolCalls(calls []wireToolCall) []aisdk.ToolCall {
+	var out []aisdk.ToolCall
+	for _, call := range calls {
+		id := call.ID
+		if id == "" {
+			id = "call_" + uuid.New().String()
+		}
+		args := decodeArguments(call.Function.Arguments)
+		if strings.TrimSpace(args) == "" {
+			args = "{}"
+		}
+		out = append(out, aisdk.ToolCall{
+			ID:   id,
+			Type: "function",
+			Function: aisdk.FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: json.RawMessage(args),
+			},
+		})
+	}
+	return out
+}
+
+// createChatCompletion sends a chat completion request (internal method).
+func (c *Client) createChatCompletion(ctx context.Context, req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionResponse, error) {
+	logger := c.logger.With("method", "CreateChatCompletion", "model", req.Model)
+	logger.Debug("sending chat completion request")
+
+	resp, err := c.postChat(ctx, logger, buildRequest(req), c.httpClient)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var wire chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&wire); err != nil {
+		logger.Error("failed to decode response", "error", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if wire.Error != nil {
+		return nil, wire.Error.toAPIError(resp.StatusCode)
+	}
+
+	result := &aisdk.ChatCompletionResponse{
+		ID:      wire.ID,
+		Object:  "chat.completion",
+		Created: wire.Created,
+		Model:   wire.Model,
+	}
+	for _, choice := range wire.Choices {
+		msg := aisdk.Message{
+			Role:      choice.Message.Role,
+			ToolCalls: convertToolCalls(choice.Message.ToolCalls),
+		}
+		if msg.Role == "" {
+			msg.Role = "assistant"
+		}
+		if choice.Message.Content != nil {
+			msg.Content = *choice.Message.Content
+		}
+		converted := aisdk.Choice{Index: choice.Index, Message: msg}
+		if choice.FinishReason != nil {
+			converted.FinishReason = *choice.FinishReason
+		}
+		result.Choices = append(result.Choices, converted)
+	}
+	if wire.Usage != nil {
+		result.Usage = wire.Usage.toUsage()
+	}
+
+	logger.Info("chat completion successful",
+		"usage_total", result.Usage.TotalTokens,
+		"usage_cached", result.Usage.PromptTokensCached)
+	return result, nil
+}
+
+// createChatCompletionStream sends a streaming chat completion request (internal method).
+func (c *Client) createChatCompletionStream(ctx context.Context, req *aisdk.ChatCompletionRequest, handler aisdk.StreamHandler) (*aisdk.ChatCompletionResponse, error) {
+	logger := c.logger.With("method", "CreateChatCompletionStream", "model", req.Model)
+	logger.Debug("sending streaming chat completion request")
+
+	body := buildRequest(req)
+	body.Stream = true
+	body.StreamOptions = &aisdk.StreamOptions{IncludeUsage: true}
+
+	resp, err := c.postChat(ctx, logger, body, c.streamClient)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	acc := aisdk.NewStreamAccumulator()
+	err = aisdk.ReadSSE(resp.Body, func(data []byte) error {
+		var wire chatResponse
+		if err := json.Unmarshal(data, &wire); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if wire.Error != nil {
+			return wire.Error.toAPIError(resp.StatusCode)
+		}
+
+		chunk := &aisdk.StreamChunk{
+			ID:      wire.ID,
+			Created: wire.Created,
+			Model:   wire.Model,
+		}
+		if wire.Usage != nil {
+			usage := wire.Usage.toUsage()
+			chunk.Usage = &usage
+		}
+		for _, choice := range wire.Choices {
+			streamChoice := aisdk.StreamChoice{
+				Index: choice.Index,
+				Delta: aisdk.StreamDelta{Role: choice.Delta.Role},
+			}
+			if choice.Delta.Content != nil {
+				streamChoice.Delta.Content = *choice.Delta.Content
+			}
+			if choice.FinishReason != nil {
+				streamChoice.FinishReason = *choice.FinishReason
+			}
+			for i, call := range choice.Delta.ToolCalls {
+				index := i
+				if call.Index != nil {
+					index = *call.Index
+				}
+				streamChoice.Delta.ToolCalls = append(streamChoice.Delta.ToolCalls, aisdk.ToolCallDelta{
+					Index: index,
+					ID:    call.ID,
+					Type:  call.Type,
+					Function: aisdk.FunctionCallDelta{
+						Name:      call.Function.Name,
+						Arguments: decodeArguments(call.Function.Arguments),
+					},
+				})
+			}
+			chunk.Choices = append(chunk.Choices, streamChoice)
+		}
+
+		acc.Add(chunk)
+		if handler != nil {
+			return handler(chunk)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("stream failed", "error", err)
+		return nil, err
+	}
+
+	result := acc.Response()
+	// Some servers stream tool calls without IDs
+	for i := range result.Choices[0].Message.ToolCalls {
+		if result.Choices[0].Message.ToolCalls[i].ID == "" {
+			result.Choices[0].Message.ToolCalls[i].ID = "call_" + uuid.New().String()
+		}
+	}
+
+	logger.Info("chat completion stream successful",
+		"usage_total", result.Usage.TotalTokens,
+		"usage_cached", result.Usage.PromptTokensCached)
+	return result, nil
+}
+
+// postChat sends the request body and returns the response if it succeeded
+func (c *Client) postChat(ctx context.Context, logger *slog.Logger, body *chatRequest, httpClient *http.Client) (*http.Response, error) {
+	if c.logger.Enabled(ctx, slog.LevelDebug) {
+		if debugBody, err := json.MarshalIndent(body, "", "  "); err == nil {
+			logger.Debug("formatted request", "body", string(debugBody))
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		logger.Error("failed to marshal request", "error", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := c.newRequest(ctx, "POST", "/chat/completions", data)
+	if err != nil {
+		return nil, err
+	}
+	if body.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := c.doRequestWithRetry(httpClient, httpReq)
+	if err != nil {
+		logger.Error("request failed", "error", err)
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		logger.Error("received error response", "status_code", resp.StatusCode)
+		return nil, c.handleError(resp)
+	}
+
+	return resp, nil
+}
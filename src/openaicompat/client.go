@@ -0,0 +1,289 @@
+// Package openaicompat implements aisdk.Provider for servers that speak the
+// OpenAI chat completions API, such as Ollama, llama.cpp server, vLLM and LM Studio.
+package openaicompat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/orclient"
+)
+
+const (
+	// Local servers can take a long time to load a model on first use
+	defaultTimeout = 10 * time.Minute
+	modelListTTL   = time.Minute
+)
+
+var _ aisdk.Provider = (*Client)(nil)
+
+// DefaultBaseURLs are the default local addresses of well known servers,
+// used when a provider of that name is configured without a base URL.
+var DefaultBaseURLs = map[string]string{
+	"local":    "http://localhost:11434/v1",
+	"ollama":   "http://localhost:11434/v1",
+	"lmstudio": "http://localhost:1234/v1",
+	"llamacpp": "http://localhost:8080/v1",
+	"vllm":     "http://localhost:8000/v1",
+}
+
+// Config holds configuration for an OpenAI-compatible client
+type Config struct {
+	Name       string        // Provider name used in logs, e.g. "ollama"
+	BaseURL    string        // Base URL including the version prefix, e.g. http://localhost:11434/v1
+	APIKey     string        // Optional API key, sent as a bearer token
+	Logger     *slog.Logger  // Logger for debugging
+	Timeout    time.Duration // HTTP timeout
+	RetryCount int           // Number of retries for failed requests
+	RetryDelay time.Duration // Delay between retries
+}
+
+// Client is a client for an OpenAI-compatible server.
+type Client struct {
+	config     Config
+	httpClient *http.Client
+	// streamClient has no overall timeout so long streams aren't cut off
+	streamClient *http.Client
+	logger       *slog.Logger
+
+	mu              sync.Mutex
+	models          []*aisdk.ModelInfo
+	modelsFetchedAt time.Time
+}
+
+// NewClient creates a new OpenAI-compatible client.
+func NewClient(config Config) (*Client, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURLs[config.Name]
+	}
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("base URL is required for provider %q", config.Name)
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.Name == "" {
+		config.Name = "openai_compatible"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.RetryCount == 0 {
+		config.RetryCount = 3
+	}
+	if config.RetryDelay == 0 {
+		config.RetryDelay = time.Second
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.With("component", "openai_compatible_client", "provider", config.Name)
+
+	return &Client{
+		config:       config,
+		httpClient:   &http.Client{Timeout: config.Timeout},
+		streamClient: &http.Client{},
+		logger:       logger,
+	}, nil
+}
+
+// GetModels implements aisdk.Provider.GetModels
+func (c *Client) GetModels(ctx context.Context) ([]*aisdk.ModelInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.models != nil && time.Since(c.modelsFetchedAt) < modelListTTL {
+		return c.models, nil
+	}
+
+	models, err := c.listModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.models = models
+	c.modelsFetchedAt = time.Now()
+	return models, nil
+}
+
+// Model creates a ModelClient bound to the specified model
+func (c *Client) Model(ctx context.Context, modelName string) (aisdk.ModelClient, error) {
+	models, err := c.GetModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model info for %s: %w", modelName, err)
+	}
+
+	for _, model := range models {
+		if model.ID == modelName {
+			return &ModelClient{client: c, model: model}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("model %s not found on %s", modelName, c.config.Name)
+}
+
+// modelEntry is a model in the /models response. Besides the OpenAI fields,
+// vLLM reports max_model_len and llama.cpp reports the training context in meta.
+type modelEntry struct {
+	ID          string `json:"id"`
+	Created     int64  `json:"created"`
+	OwnedBy     string `json:"owned_by"`
+	MaxModelLen int    `json:"max_model_len"`
+	Meta        *struct {
+		NCtxTrain int `json:"n_ctx_train"`
+	} `json:"meta"`
+}
+
+// listModels fetches the model list from the server
+func (c *Client) listModels(ctx context.Context) ([]*aisdk.ModelInfo, error) {
+	httpReq, err := c.newRequest(ctx, "GET", "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doRequestWithRetry(c.httpClient, httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleError(resp)
+	}
+
+	var list struct {
+		Data []modelEntry `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	models := make([]*aisdk.ModelInfo, 0, len(list.Data))
+	for _, entry := range list.Data {
+		models = append(models, c.newModelInfo(entry))
+	}
+	return models, nil
+}
+
+// newModelInfo maps a /models entry into model info. Local models are free,
+// so pricing is reported as zero.
+func (c *Client) newModelInfo(entry modelEntry) *aisdk.ModelInfo {
+	contextLength := entry.MaxModelLen
+	if contextLength == 0 && entry.Meta != nil {
+		contextLength = entry.Meta.NCtxTrain
+	}
+
+	description := fmt.Sprintf("%s model served by %s", entry.ID, c.config.Name)
+	if entry.OwnedBy != "" {
+		description = fmt.Sprintf("%s model owned by %s, served by %s", entry.ID, entry.OwnedBy, c.config.Name)
+	}
+
+	return &aisdk.ModelInfo{
+		ID:            entry.ID,
+		CanonicalSlug: entry.ID,
+		Name:          entry.ID,
+		Created:       entry.Created,
+		Description:   description,
+		ContextLength: contextLength,
+		Architecture: &aisdk.Architecture{
+			InputModalities:  []string{"text"},
+			OutputModalities: []string{"text"},
+			Modality:         "text->text",
+		},
+		Pricing: &aisdk.Pricing{
+			Prompt:     "0",
+			Completion: "0",
+		},
+		TopProvider: &aisdk.TopProvider{
+			ContextLength: contextLength,
+		},
+		SupportedParameters: []string{"tools", "tool_choice", "max_tokens", "temperature", "top_p", "stop"},
+	}
+}
+
+// newRequest creates a new HTTP request with the appropriate headers.
+func (c *Client) newRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	url := c.config.BaseURL + path
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if c.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
+
+// doRequestWithRetry performs an HTTP request with retry logic.
+func (c *Client) doRequestWithRetry(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	return orclient.DoWithRetry(httpClient, req, c.config.RetryCount, c.config.RetryDelay, c.logger)
+}
+
+// wireError is the OpenAI error body. Servers disagree on whether code is a
+// string or a number, so it is decoded leniently.
+type wireError struct {
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Param   string          `json:"param"`
+	Code    json.RawMessage `json:"code"`
+}
+
+// toAPIError converts the wire error into an APIError with the given status
+func (e *wireError) toAPIError(status int) *orclient.APIError {
+	apiErr := &orclient.APIError{
+		StatusCode: status,
+		Type:       e.Type,
+		Message:    e.Message,
+		Param:      e.Param,
+	}
+	var code string
+	if err := json.Unmarshal(e.Code, &code); err == nil {
+		apiErr.Code = code
+	}
+	return apiErr
+}
+
+// handleError processes error responses from the API.
+func (c *Client) handleError(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read error response: %w", err)
+	}
+
+	var errResp struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errResp); err == nil && len(errResp.Error) > 0 {
+		var structured wireError
+		if err := json.Unmarshal(errResp.Error, &structured); err == nil && structured.Message != "" {
+			return structured.toAPIError(resp.StatusCode)
+		}
+		// Ollama and llama.cpp sometimes return {"error": "message"}
+		var message string
+		if err := json.Unmarshal(errResp.Error, &message); err == nil {
+			return &orclient.APIError{StatusCode: resp.StatusCode, Message: message}
+		}
+	}
+
+	return &orclient.APIError{
+		StatusCode: resp.StatusCode,
+		Message:    string(body),
+	}
+}
@@ -0,0 +1,230 @@
+package openaicompat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/orclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer serves a model list in the given format and hands /v1/chat/completions to the handler
+func newTestServer(t *testing.T, models string, chat http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, models)
+	})
+	if chat != nil {
+		mux.HandleFunc("/v1/chat/completions", chat)
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClient(t *testing.T, server *httptest.Server) *Client {
+	t.Helper()
+	client, err := NewClient(Config{Name: "test", BaseURL: server.URL + "/v1", RetryCount: 1})
+	require.NoError(t, err)
+	return client
+}
+
+func TestGetModels(t *testing.T) {
+	tests := []struct {
+		name          string
+		models        string
+		contextLength int
+	}{
+		{
+			name:   "ollama",
+			models: `{"object":"list","data":[{"id":"qwen2.5-coder:7b","object":"model","created":1718000000,"owned_by":"library"}]}`,
+		},
+		{
+			name:          "vllm",
+			models:        `{"object":"list","data":[{"id":"qwen2.5-coder:7b","object":"model","owned_by":"vllm","max_model_len":32768}]}`,
+			contextLength: 32768,
+		},
+		{
+			name:          "llama.cpp",
+			models:        `{"object":"list","data":[{"id":"qwen2.5-coder:7b","object":"model","meta":{"n_ctx_train":131072}}]}`,
+			contextLength: 131072,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, newTestServer(t, tt.models, nil))
+
+			models, err := client.GetModels(context.Background())
+			require.NoError(t, err)
+			require.Len(t, models, 1)
+			assert.Equal(t, "qwen2.5-coder:7b", models[0].ID)
+			assert.Equal(t, tt.contextLength, models[0].ContextLength)
+			assert.Equal(t, "0", models[0].Pricing.Prompt)
+			assert.Contains(t, models[0].SupportedParameters, "tools")
+
+			_, err = client.Model(context.Background(), "missing")
+			assert.Error(t, err)
+		})
+	}
+}
+
+const testModels = `{"data":[{"id":"llama3.1"}]}`
+
+func TestCreateChatCompletionWithTools(t *testing.T) {
+	var got map[string]interface{}
+	server := newTestServer(t, testModels, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		// Ollama returns tool calls without IDs and arguments as objects in some versions
+		fmt.Fprint(w, `{
+			"id": "chatcmpl-1",
+			"model": "llama3.1",
+			"choices": [{
+				"index": 0,
+				"message": {"role": "assistant", "content": "", "tool_calls": [
+					{"type": "function", "function": {"name": "read_file", "arguments": {"path": "a.txt"}}},
+					{"id": "call_2", "type": "function", "function": {"name": "list_directory", "arguments": "{\"path\":\".\"}"}}
+				]},
+				"finish_reason": "tool_calls"
+			}],
+			"usage": {"prompt_tokens": 30, "completion_tokens": 10, "total_tokens": 40}
+		}`)
+	})
+	client := newTestClient(t, server)
+	model, err := client.Model(context.Background(), "llama3.1")
+	require.NoError(t, err)
+
+	resp, err := model.CreateChatCompletion(context.Background(), &aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", ToolCalls: []aisdk.ToolCall{
+				{ID: "call_0", Type: "function", Function: aisdk.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"path":"x"}`)}},
+			}},
+			{Role: "tool", ToolCallID: "call_0", Name: "read_file", Content: "contents"},
+		},
+		Tools: []*aisdk.ChatTool{{Type: "function", Function: aisdk.ChatToolFunction{Name: "read_file"}}},
+	})
+	require.NoError(t, err)
+
+	// Arguments go over the wire as a JSON encoded string
+	msgs := got["messages"].([]interface{})
+	require.Len(t, msgs, 3)
+	call := msgs[1].(map[string]interface{})["tool_calls"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, `{"path":"x"}`, call["function"].(map[string]interface{})["arguments"])
+	assert.NotContains(t, msgs[0], "created_at")
+	assert.Len(t, got["tools"], 1)
+
+	calls := resp.Choices[0].Message.ToolCalls
+	require.Len(t, calls, 2)
+	assert.NotEmpty(t, calls[0].ID)
+	assert.JSONEq(t, `{"path":"a.txt"}`, string(calls[0].Function.Arguments))
+	assert.Equal(t, "call_2", calls[1].ID)
+	assert.JSONEq(t, `{"path":"."}`, string(calls[1].Function.Arguments))
+	assert.Equal(t, 40, resp.Usage.TotalTokens)
+}
+
+func TestCreateChatCompletionDropsImagesForTextModels(t *testing.T) {
+	var got struct {
+		Messages []struct {
+			Role    string      `json:"role"`
+			Content interface{} `json:"content"`
+		} `json:"messages"`
+	}
+	server := newTestServer(t, testModels, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		fmt.Fprint(w, `{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	})
+	client := newTestClient(t, server)
+	model, err := client.Model(context.Background(), "llama3.1")
+	require.NoError(t, err)
+
+	content := &aisdk.MultimodalContent{}
+	content.AddText("here is the screenshot")
+	content.AddImage("png", "aGVsbG8=", "screen.png", 5)
+	msg := &aisdk.Message{Role: "user"}
+	msg.SetMultimodalContent(content)
+
+	_, err = model.CreateChatCompletion(context.Background(), &aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{msg},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, got.Messages, 1)
+	text, ok := got.Messages[0].Content.(string)
+	require.True(t, ok, "content should be sent as a string, got %v", got.Messages[0].Content)
+	assert.Contains(t, text, "here is the screenshot")
+	assert.Contains(t, text, "omitted")
+	assert.True(t, msg.HasImages(), "the caller's message should not be modified")
+}
+
+func TestCreateChatCompletionStream(t *testing.T) {
+	server := newTestServer(t, testModels, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, line := range []string{
+			`{"id":"c1","model":"llama3.1","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}`,
+			`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"pa"}}]}}]}`,
+			`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"th\":\"a\"}"}}]},"finish_reason":"tool_calls"}]}`,
+			`{"id":"c1","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7,"prompt_tokens_details":{"cached_tokens":2}}}`,
+			`[DONE]`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		}
+	})
+	client := newTestClient(t, server)
+	model, err := client.Model(context.Background(), "llama3.1")
+	require.NoError(t, err)
+
+	var text string
+	resp, err := model.(aisdk.StreamingModelClient).CreateChatCompletionStream(context.Background(), &aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{{Role: "user", Content: "hi"}},
+	}, func(chunk *aisdk.StreamChunk) error {
+		for _, choice := range chunk.Choices {
+			text += choice.Delta.Content
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Hi", text)
+	calls := resp.Choices[0].Message.ToolCalls
+	require.Len(t, calls, 1)
+	assert.Equal(t, `{"path":"a"}`, string(calls[0].Function.Arguments))
+	assert.Equal(t, 7, resp.Usage.TotalTokens)
+	assert.Equal(t, 2, resp.Usage.PromptTokensCached)
+}
+
+func TestCreateChatCompletionError(t *testing.T) {
+	server := newTestServer(t, testModels, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"llama3.1\" not found, try pulling it first"}`)
+	})
+	client := newTestClient(t, server)
+	model, err := client.Model(context.Background(), "llama3.1")
+	require.NoError(t, err)
+
+	_, err = model.CreateChatCompletion(context.Background(), &aisdk.ChatCompletionRequest{
+		Messages: []*aisdk.Message{{Role: "user", Content: "hi"}},
+	})
+
+	var apiErr *orclient.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Message, "try pulling it first")
+}
+
+func TestNewClientBaseURL(t *testing.T) {
+	client, err := NewClient(Config{Name: "ollama"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:11434/v1", client.config.BaseURL)
+
+	_, err = NewClient(Config{Name: "my-server"})
+	assert.Error(t, err)
+}
@@ -23,12 +23,14 @@ var _ aisdk.Provider = (*Client)(nil)
 
 // Client is the OpenRouter API client.
 type Client struct {
-	config     Config
-	httpClient *http.Client
-	logger     *slog.Logger
-	baseURL    string
-	apiKey     string
-	modelCache *ModelCache
+	config       Config
+	httpClient   *http.Client
+	// streamClient has no overall timeout so long streams aren't cut off
+	streamClient *http.Client
+	logger       *slog.Logger
+	baseURL      string
+	apiKey       string
+	modelCache   *ModelCache
 }
 
 // NewClient creates a new OpenRouter API client.
@@ -43,9 +45,17 @@ func NewClient(config Config) *Client {
 		config.RetryDelay = time.Second
 	}
 
-	httpClient := &http.Client{
-		Timeout: defaultTimeout,
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		timeout := config.Timeout
+		if timeout == 0 {
+			timeout = defaultTimeout
+		}
+		httpClient = &http.Client{Timeout: timeout}
 	}
+	// Streams use the same transport without the overall timeout
+	streamClient := *httpClient
+	streamClient.Timeout = 0
 
 	logger := config.Logger
 	if logger == nil {
@@ -54,11 +64,12 @@ func NewClient(config Config) *Client {
 	logger = logger.With("component", "openrouter_client")
 
 	client := &Client{
-		config:     config,
-		httpClient: httpClient,
-		logger:     logger,
-		baseURL:    config.BaseURL,
-		apiKey:     config.APIKey,
+		config:       config,
+		httpClient:   httpClient,
+		streamClient: &streamClient,
+		logger:       logger,
+		baseURL:      config.BaseURL,
+		apiKey:       config.APIKey,
 	}
 
 	// Initialize model cache with 1 hour TTL
@@ -94,7 +105,7 @@ func (c *Client) createChatCompletion(ctx context.Context, req *aisdk.ChatComple
 		return nil, err
 	}
 
-	resp, err := c.doRequestWithRetry(httpReq)
+	resp, err := c.doRequestWithRetry(c.httpClient, httpReq)
 	if err != nil {
 		logger.Error("request failed", "error", err)
 		return nil, err
@@ -143,50 +154,8 @@ func (c *Client) newRequest(ctx context.Context, method, path string, body []byt
 }
 
 // doRequestWithRetry performs an HTTP request with retry logic.
-func (c *Client) doRequestWithRetry(req *http.Request) (*http.Response, error) {
-	var lastErr error
-
-	logger := c.logger.With("method", "doRequestWithRetry", "url", req.URL.String())
-
-	for i := 0; i < c.config.RetryCount; i++ {
-		// Clone the request for retry
-		reqCopy := req.Clone(req.Context())
-		if req.Body != nil {
-			bodyBytes, err := io.ReadAll(req.Body)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read request body: %w", err)
-			}
-			req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-			reqCopy.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-		}
-
-		resp, err := c.httpClient.Do(reqCopy)
-		if err != nil {
-			lastErr = err
-			logger.Debug("request attempt failed", "attempt", i+1, "error", err)
-			time.Sleep(c.config.RetryDelay * time.Duration(i+1))
-			continue
-		}
-
-		// Don't retry on client errors (4xx)
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			return resp, nil
-		}
-
-		// Success or client error - return immediately
-		if resp.StatusCode < 400 {
-			return resp, nil
-		}
-
-		// Server error - retry
-		resp.Body.Close()
-		lastErr = fmt.Errorf("server error: %d", resp.StatusCode)
-		logger.Debug("server error, retrying", "attempt", i+1, "status_code", resp.StatusCode)
-		time.Sleep(c.config.RetryDelay * time.Duration(i+1))
-	}
-
-	logger.Error("request failed after all retries", "retry_count", c.config.RetryCount, "error", lastErr)
-	return nil, fmt.Errorf("request failed after %d retries: %w", c.config.RetryCount, lastErr)
+func (c *Client) doRequestWithRetry(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	return DoWithRetry(httpClient, req, c.config.RetryCount, c.config.RetryDelay, c.logger)
 }
 
 // handleError processes error responses from the API.
@@ -248,8 +217,8 @@ func (c *Client) formatRequestForProvider(req *aisdk.ChatCompletionRequest) inte
 	case "openai":
 		return c.formatOpenAIRequest(req)
 	default:
-		// Return as-is for unknown providers
-		return req
+		// OpenRouter accepts the OpenAI format for every model
+		return c.formatOpenAIRequest(req)
 	}
 }
 
@@ -272,24 +241,28 @@ func (c *Client) formatAnthropicRequest(req *aisdk.ChatCompletionRequest) interf
 	// Create a custom type to handle Anthropic-specific formatting
 	type AnthropicMessage struct {
 		Role      string           `json:"role"`
-		Content   string           `json:"content"`
+		Content   interface{}      `json:"content"` // string or []aisdk.ContentPart
 		Name      string           `json:"name,omitempty"`
 		ToolUseID string           `json:"tool_use_id,omitempty"` // Anthropic uses tool_use_id
 		ToolCalls []aisdk.ToolCall `json:"tool_calls,omitempty"`
 	}
 
 	type AnthropicRequest struct {
-		Model       string             `json:"model"`
-		Messages    []AnthropicMessage `json:"messages"`
-		Temperature *float64           `json:"temperature,omitempty"`
-		MaxTokens   *int               `json:"max_tokens,omitempty"`
-		Stream      bool               `json:"stream,omitempty"`
-		Tools       []*aisdk.ChatTool  `json:"tools,omitempty"`
+		Model          string                `json:"model"`
+		Messages       []AnthropicMessage    `json:"messages"`
+		Temperature    *float64              `json:"temperature,omitempty"`
+		MaxTokens      *int                  `json:"max_tokens,omitempty"`
+		TopP           *float64              `json:"top_p,omitempty"`
+		Stream         bool                  `json:"stream,omitempty"`
+		StreamOptions  *aisdk.StreamOptions  `json:"stream_options,omitempty"`
+		Tools          []*aisdk.ChatTool     `json:"tools,omitempty"`
+		ToolChoice     string                `json:"tool_choice,omitempty"`
+		ResponseFormat *aisdk.ResponseFormat `json:"response_format,omitempty"`
 	}
 
 	// Convert messages
 	anthropicMessages := make([]AnthropicMessage, 0, len(req.Messages))
-	for _, msg := range req.Messages {
+	for _, msg := range aisdk.HoistToolImages(req.Messages) {
 		if msg == nil {
 			continue
 		}
@@ -311,7 +284,7 @@ func (c *Client) formatAnthropicRequest(req *aisdk.ChatCompletionRequest) interf
 		}
 		anthropicMessages = append(anthropicMessages, AnthropicMessage{
 			Role:      msg.Role,
-			Content:   msg.Content,
+			Content:   msg.OpenAIContent(),
 			Name:      msg.Name,
 			ToolUseID: msg.ToolCallID, // Map ToolCallID to tool_use_id
 			ToolCalls: toolCalls,
@@ -319,12 +292,16 @@ func (c *Client) formatAnthropicRequest(req *aisdk.ChatCompletionRequest) interf
 	}
 
 	return AnthropicRequest{
-		Model:       req.Model,
-		Messages:    anthropicMessages,
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		Stream:      false,
-		Tools:       req.Tools,
+		Model:          req.Model,
+		Messages:       anthropicMessages,
+		Temperature:    req.Temperature,
+		MaxTokens:      req.MaxTokens,
+		TopP:           req.TopP,
+		Stream:         req.Stream,
+		StreamOptions:  req.StreamOptions,
+		Tools:          req.Tools,
+		ToolChoice:     req.ToolChoice,
+		ResponseFormat: req.ResponseFormat,
 	}
 }
 
@@ -333,7 +310,7 @@ func (c *Client) formatGoogleRequest(req *aisdk.ChatCompletionRequest) interface
 	// For Google, ensure tool response names are not empty
 	type GoogleMessage struct {
 		Role       string           `json:"role"`
-		Content    string           `json:"content"`
+		Content    interface{}      `json:"content"` // string or []aisdk.ContentPart
 		Name       string           `json:"name,omitempty"`
 		ToolCallID string           `json:"tool_call_id,omitempty"`
 		ToolCalls  []aisdk.ToolCall `json:"tool_calls,omitempty"`
@@ -341,13 +318,13 @@ func (c *Client) formatGoogleRequest(req *aisdk.ChatCompletionRequest) interface
 
 	// Convert messages and ensure tool names are present
 	googleMessages := make([]GoogleMessage, 0, len(req.Messages))
-	for _, msg := range req.Messages {
+	for _, msg := range aisdk.HoistToolImages(req.Messages) {
 		if msg == nil {
 			continue
 		}
 		googleMsg := GoogleMessage{
 			Role:       msg.Role,
-			Content:    msg.Content,
+			Content:    msg.OpenAIContent(),
 			Name:       msg.Name,
 			ToolCallID: msg.ToolCallID,
 			ToolCalls:  msg.ToolCalls,
@@ -369,21 +346,29 @@ func (c *Client) formatGoogleRequest(req *aisdk.ChatCompletionRequest) interface
 
 	// Return modified request
 	type GoogleRequest struct {
-		Model       string          `json:"model"`
-		Messages    []GoogleMessage `json:"messages"`
-		Temperature *float64        `json:"temperature,omitempty"`
-		MaxTokens   *int            `json:"max_tokens,omitempty"`
-		Stream      bool            `json:"stream,omitempty"`
-		Tools       []*aisdk.ChatTool `json:"tools,omitempty"`
+		Model          string                `json:"model"`
+		Messages       []GoogleMessage       `json:"messages"`
+		Temperature    *float64              `json:"temperature,omitempty"`
+		MaxTokens      *int                  `json:"max_tokens,omitempty"`
+		TopP           *float64              `json:"top_p,omitempty"`
+		Stream         bool                  `json:"stream,omitempty"`
+		StreamOptions  *aisdk.StreamOptions  `json:"stream_options,omitempty"`
+		Tools          []*aisdk.ChatTool     `json:"tools,omitempty"`
+		ToolChoice     string                `json:"tool_choice,omitempty"`
+		ResponseFormat *aisdk.ResponseFormat `json:"response_format,omitempty"`
 	}
 
 	return GoogleRequest{
-		Model:       req.Model,
-		Messages:    googleMessages,
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		Stream:      false,
-		Tools:       req.Tools,
+		Model:          req.Model,
+		Messages:       googleMessages,
+		Temperature:    req.Temperature,
+		MaxTokens:      req.MaxTokens,
+		TopP:           req.TopP,
+		Stream:         req.Stream,
+		StreamOptions:  req.StreamOptions,
+		Tools:          req.Tools,
+		ToolChoice:     req.ToolChoice,
+		ResponseFormat: req.ResponseFormat,
 	}
 }
 
@@ -392,7 +377,7 @@ func (c *Client) formatOpenAIRequest(req *aisdk.ChatCompletionRequest) interface
 	// Since we're now using OpenAI format natively, just ensure Arguments are not null
 	type OpenAIMessage struct {
 		Role       string            `json:"role"`
-		Content    string            `json:"content"`
+		Content    interface{}       `json:"content"` // string or []aisdk.ContentPart
 		Name       string            `json:"name,omitempty"`
 		ToolCallID string            `json:"tool_call_id,omitempty"`
 		ToolCalls  []aisdk.ToolCall  `json:"tool_calls,omitempty"`
@@ -400,13 +385,13 @@ func (c *Client) formatOpenAIRequest(req *aisdk.ChatCompletionRequest) interface
 
 	// Convert messages
 	openaiMessages := make([]OpenAIMessage, 0, len(req.Messages))
-	for _, msg := range req.Messages {
+	for _, msg := range aisdk.HoistToolImages(req.Messages) {
 		if msg == nil {
 			continue
 		}
 		openaiMsg := OpenAIMessage{
 			Role:       msg.Role,
-			Content:    msg.Content,
+			Content:    msg.OpenAIContent(),
 			Name:       msg.Name,
 			ToolCallID: msg.ToolCallID,
 			ToolCalls:  msg.ToolCalls,
@@ -427,20 +412,38 @@ func (c *Client) formatOpenAIRequest(req *aisdk.ChatCompletionRequest) interface
 
 	// Return the formatted request
 	type OpenAIRequest struct {
-		Model       string            `json:"model"`
-		Messages    []OpenAIMessage   `json:"messages"`
-		Temperature *float64          `json:"temperature,omitempty"`
-		MaxTokens   *int              `json:"max_tokens,omitempty"`
-		Stream      bool              `json:"stream,omitempty"`
-		Tools       []*aisdk.ChatTool   `json:"tools,omitempty"`
+		Model            string                 `json:"model"`
+		Messages         []OpenAIMessage        `json:"messages"`
+		Temperature      *float64               `json:"temperature,omitempty"`
+		MaxTokens        *int                   `json:"max_tokens,omitempty"`
+		TopP             *float64               `json:"top_p,omitempty"`
+		FrequencyPenalty *float64               `json:"frequency_penalty,omitempty"`
+		PresencePenalty  *float64               `json:"presence_penalty,omitempty"`
+		Stop             []string               `json:"stop,omitempty"`
+		Stream           bool                   `json:"stream,omitempty"`
+		StreamOptions    *aisdk.StreamOptions   `json:"stream_options,omitempty"`
+		Tools            []*aisdk.ChatTool      `json:"tools,omitempty"`
+		ToolChoice       string                 `json:"tool_choice,omitempty"`
+		ResponseFormat   *aisdk.ResponseFormat  `json:"response_format,omitempty"`
+		User             string                 `json:"user,omitempty"`
+		Metadata         map[string]interface{} `json:"metadata,omitempty"`
 	}
 
 	return OpenAIRequest{
-		Model:       req.Model,
-		Messages:    openaiMessages,
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		Stream:      false,
-		Tools:       req.Tools,
+		Model:            req.Model,
+		Messages:         openaiMessages,
+		Temperature:      req.Temperature,
+		MaxTokens:        req.MaxTokens,
+		TopP:             req.TopP,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		Stop:             req.Stop,
+		Stream:           req.Stream,
+		StreamOptions:    req.StreamOptions,
+		Tools:            req.Tools,
+		ToolChoice:       req.ToolChoice,
+		ResponseFormat:   req.ResponseFormat,
+		User:             req.User,
+		Metadata:         req.Metadata,
 	}
 }
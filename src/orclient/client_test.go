@@ -0,0 +1,101 @@
+package orclient
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+func TestFormatRequestMultimodal(t *testing.T) {
+	client := NewClient(Config{APIKey: "test"})
+
+	toolResult := &aisdk.Message{Role: "tool", Name: "read_file", ToolCallID: "call_1"}
+	content := &aisdk.MultimodalContent{}
+	content.AddImage("jpg", "aGVsbG8=", "cat.jpg", 5)
+	toolResult.SetMultimodalContent(content)
+
+	for _, model := range []string{"openai/gpt-4o", "anthropic/claude-sonnet-4", "google/gemini-2.5-flash", "qwen/qwen2.5-vl-72b-instruct"} {
+		t.Run(model, func(t *testing.T) {
+			req := &aisdk.ChatCompletionRequest{
+				Model: model,
+				Messages: []*aisdk.Message{
+					{Role: "user", Content: "what is in cat.jpg?"},
+					{Role: "assistant", ToolCalls: []aisdk.ToolCall{{ID: "call_1", Type: "function", Function: aisdk.FunctionCall{Name: "read_file", Arguments: json.RawMessage(`{"path":"cat.jpg"}`)}}}},
+					toolResult,
+				},
+			}
+
+			body, err := json.Marshal(client.formatRequestForProvider(req))
+			if err != nil {
+				t.Fatalf("failed to marshal request: %v", err)
+			}
+			var got struct {
+				Messages []struct {
+					Role    string          `json:"role"`
+					Content json.RawMessage `json:"content"`
+				} `json:"messages"`
+			}
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("failed to decode request: %v", err)
+			}
+
+			// The image moves out of the tool result into a following user message
+			if len(got.Messages) != 4 {
+				t.Fatalf("expected 4 messages, got %d: %s", len(got.Messages), body)
+			}
+			var toolContent string
+			if err := json.Unmarshal(got.Messages[2].Content, &toolContent); err != nil {
+				t.Errorf("expected tool content to be a string, got %s", got.Messages[2].Content)
+			}
+
+			if got.Messages[3].Role != "user" {
+				t.Errorf("expected user message after tool results, got %s", got.Messages[3].Role)
+			}
+			var parts []aisdk.ContentPart
+			if err := json.Unmarshal(got.Messages[3].Content, &parts); err != nil {
+				t.Fatalf("expected content parts, got %s", got.Messages[3].Content)
+			}
+			if len(parts) != 2 || parts[1].Type != "image_url" {
+				t.Fatalf("expected text and image_url parts, got %+v", parts)
+			}
+			if !strings.HasPrefix(parts[1].ImageURL.URL, "data:image/jpeg;base64,aGVsbG8=") {
+				t.Errorf("unexpected image URL %s", parts[1].ImageURL.URL)
+			}
+		})
+	}
+}
+
+func TestFormatRequestKeepsOptions(t *testing.T) {
+	client := NewClient(Config{APIKey: "test"})
+	topP := 0.5
+
+	for _, model := range []string{"openai/gpt-4o", "anthropic/claude-sonnet-4", "google/gemini-2.5-flash", "qwen/qwen3-32b"} {
+		t.Run(model, func(t *testing.T) {
+			req := &aisdk.ChatCompletionRequest{
+				Model:          model,
+				Messages:       []*aisdk.Message{{Role: "user", Content: "hi"}},
+				TopP:           &topP,
+				ToolChoice:     "none",
+				ResponseFormat: &aisdk.ResponseFormat{Type: "json_object"},
+			}
+
+			body, err := json.Marshal(client.formatRequestForProvider(req))
+			if err != nil {
+				t.Fatalf("failed to marshal request: %v", err)
+			}
+			var got struct {
+				TopP           *float64              `json:"top_p"`
+				ToolChoice     string                `json:"tool_choice"`
+				ResponseFormat *aisdk.ResponseFormat `json:"response_format"`
+			}
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("failed to decode request: %v", err)
+			}
+			if got.TopP == nil || *got.TopP != topP || got.ToolChoice != "none" || got.ResponseFormat == nil || got.ResponseFormat.Type != "json_object" {
+				t.Errorf("request options were dropped: %s", body)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package orclient
 
 import (
 	"log/slog"
+	"net/http"
 	"time"
 )
 
@@ -11,6 +12,7 @@ type Config struct {
 	BaseURL   string        // Base URL for OpenRouter API
 	Logger    *slog.Logger  // Logger for debugging
 	Timeout   time.Duration // HTTP timeout
+	HTTPClient *http.Client // HTTP client to use instead of one with Timeout
 	RetryCount int          // Number of retries for failed requests
 	RetryDelay time.Duration // Delay between retries
 	SiteURL   string        // Site URL for ranking
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -89,6 +90,42 @@ func (e *APIError) IsRateLimit() bool {
 	return e.StatusCode == http.StatusTooManyRequests || e.Code == "rate_limit_exceeded"
 }
 
+// IsOverloaded returns true if the server failed or is overloaded (5xx).
+func (e *APIError) IsOverloaded() bool {
+	return (e.StatusCode >= 500 && e.StatusCode < 600) || e.Code == "overloaded_error" || e.Code == "server_error"
+}
+
+// contextLengthPhrases appear in the messages providers return when a
+// request does not fit in the model's context window
+var contextLengthPhrases = []string{
+	"context length",
+	"context_length",
+	"context window",
+	"context size",
+	"maximum context",
+	"prompt is too long",
+	"too many tokens",
+}
+
+// IsContextLength returns true if the request did not fit in the model's
+// context window. Providers have no common code for it, so the message is
+// checked as well.
+func (e *APIError) IsContextLength() bool {
+	if e.Code == "context_length_exceeded" || e.StatusCode == http.StatusRequestEntityTooLarge {
+		return true
+	}
+	if e.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	message := strings.ToLower(e.Message)
+	for _, phrase := range contextLengthPhrases {
+		if strings.Contains(message, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsAuthError returns true if this is an authentication error.
 func (e *APIError) IsAuthError() bool {
 	return e.StatusCode == http.StatusUnauthorized || e.Code == "invalid_api_key"
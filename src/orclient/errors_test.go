@@ -110,6 +110,65 @@ func TestAPIError(t *testing.T) {
 	}
 }
 
+func TestAPIErrorFallbackClassification(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             *APIError
+		isOverloaded    bool
+		isContextLength bool
+	}{
+		{
+			name:         "server error",
+			err:          &APIError{StatusCode: 502, Message: "Bad gateway"},
+			isOverloaded: true,
+		},
+		{
+			name:         "anthropic overloaded",
+			err:          &APIError{StatusCode: 529, Code: "overloaded_error", Message: "Overloaded"},
+			isOverloaded: true,
+		},
+		{
+			name:            "openai context length",
+			err:             &APIError{StatusCode: 400, Code: "context_length_exceeded", Message: "This model's maximum context length is 8192 tokens"},
+			isContextLength: true,
+		},
+		{
+			name:            "openrouter context length",
+			err:             &APIError{StatusCode: 400, Message: "This endpoint's maximum context length is 163840 tokens. However, you requested about 170000 tokens"},
+			isContextLength: true,
+		},
+		{
+			name:            "anthropic prompt too long",
+			err:             &APIError{StatusCode: 400, Code: "invalid_request_error", Message: "prompt is too long: 210000 tokens > 200000 maximum"},
+			isContextLength: true,
+		},
+		{
+			name:            "request too large",
+			err:             &APIError{StatusCode: 413, Message: "Request too large"},
+			isContextLength: true,
+		},
+		{
+			name: "bad request",
+			err:  &APIError{StatusCode: 400, Message: "tools.0.name: String should match pattern"},
+		},
+		{
+			name: "rate limit",
+			err:  &APIError{StatusCode: 429, Message: "Too many tokens per minute"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err.IsOverloaded() != tt.isOverloaded {
+				t.Errorf("IsOverloaded() = %v, want %v", tt.err.IsOverloaded(), tt.isOverloaded)
+			}
+			if tt.err.IsContextLength() != tt.isContextLength {
+				t.Errorf("IsContextLength() = %v, want %v", tt.err.IsContextLength(), tt.isContextLength)
+			}
+		})
+	}
+}
+
 func TestValidationError(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -33,6 +33,7 @@ func (c *Client) Model(ctx context.Context, modelName string) (aisdk.ModelClient
 func (mc *ModelClient) CreateChatCompletion(ctx context.Context, req *aisdk.ChatCompletionRequest) (*aisdk.ChatCompletionResponse, error) {
 	// Override the model in the request
 	req.Model = mc.model.ID
+	req.Messages = aisdk.PrepareMessagesForModel(req.Messages, mc.model)
 
 	// Use the underlying client to make the request
 	return mc.client.createChatCompletion(ctx, req)
@@ -43,3 +44,14 @@ func (mc *ModelClient) CreateChatCompletion(ctx context.Context, req *aisdk.Chat
 func (mc *ModelClient) GetModelInfo() *aisdk.ModelInfo {
 	return mc.model
 }
+
+var _ aisdk.StreamingModelClient = (*ModelClient)(nil)
+
+// CreateChatCompletionStream streams a chat completion with the bound model
+func (mc *ModelClient) CreateChatCompletionStream(ctx context.Context, req *aisdk.ChatCompletionRequest, handler aisdk.StreamHandler) (*aisdk.ChatCompletionResponse, error) {
+	// Override the model in the request
+	req.Model = mc.model.ID
+	req.Messages = aisdk.PrepareMessagesForModel(req.Messages, mc.model)
+
+	return mc.client.createChatCompletionStream(ctx, req, handler)
+}
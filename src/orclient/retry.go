@@ -0,0 +1,62 @@
+package orclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DoWithRetry sends a request up to attempts times, retrying transport
+// errors and 5xx responses with a linearly growing delay. The response of
+// the last attempt is returned even if it is a 5xx, so callers can turn it
+// into an APIError. It is shared by the OpenRouter, OpenAI-compatible and
+// Anthropic clients.
+func DoWithRetry(httpClient *http.Client, req *http.Request, attempts int, delay time.Duration, logger *slog.Logger) (*http.Response, error) {
+	var lastErr error
+
+	logger = logger.With("method", "doRequestWithRetry", "url", req.URL.String())
+
+	var bodyBytes []byte
+	if req.Body != nil {
+		var err error
+		bodyBytes, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body.Close()
+	}
+
+	for i := 0; i < attempts; i++ {
+		reqCopy := req.Clone(req.Context())
+		if bodyBytes != nil {
+			reqCopy.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		}
+
+		resp, err := httpClient.Do(reqCopy)
+		if err != nil {
+			lastErr = err
+			logger.Debug("request attempt failed", "attempt", i+1, "error", err)
+			if req.Context().Err() != nil {
+				return nil, req.Context().Err()
+			}
+			time.Sleep(delay * time.Duration(i+1))
+			continue
+		}
+
+		// Anthropic's 529 "overloaded" is retried like any other 5xx
+		if resp.StatusCode < 500 || i == attempts-1 {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+		lastErr = fmt.Errorf("server error: %d", resp.StatusCode)
+		logger.Debug("server error, retrying", "attempt", i+1, "status_code", resp.StatusCode)
+		time.Sleep(delay * time.Duration(i+1))
+	}
+
+	logger.Error("request failed after all retries", "retry_count", attempts, "error", lastErr)
+	return nil, fmt.Errorf("request failed after %d retries: %w", attempts, lastErr)
+}
@@ -0,0 +1,49 @@
+package orclient
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDoWithRetry(t *testing.T) {
+	var bodies []string
+	statuses := []int{http.StatusBadGateway, 529, http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(statuses[len(bodies)-1])
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte("body")))
+	resp, err := DoWithRetry(server.Client(), req, 3, 0, slog.Default())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the third attempt to succeed, got %d", resp.StatusCode)
+	}
+	for _, body := range bodies {
+		if body != "body" {
+			t.Errorf("expected every attempt to send the body, got %q", body)
+		}
+	}
+
+	// The last 5xx is returned, so it can become an APIError
+	bodies = nil
+	statuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+	req, _ = http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte("body")))
+	resp, err = DoWithRetry(server.Client(), req, 2, 0, slog.Default())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || len(bodies) != 2 {
+		t.Errorf("expected the last of 2 attempts to be returned, got %d after %d", resp.StatusCode, len(bodies))
+	}
+}
@@ -0,0 +1,110 @@
+package orclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+// streamEvent is the payload of a single SSE data line. OpenRouter reports
+// errors that happen after the stream has started as a data line with an error field.
+type streamEvent struct {
+	aisdk.StreamChunk
+	Error *streamError `json:"error,omitempty"`
+}
+
+// streamError is an error reported inside the stream. The code may be a number or a string.
+type streamError struct {
+	Message string          `json:"message"`
+	Code    json.RawMessage `json:"code,omitempty"`
+}
+
+// toAPIError converts the stream error into an APIError
+func (e *streamError) toAPIError() *APIError {
+	apiErr := &APIError{
+		StatusCode: http.StatusOK,
+		Message:    e.Message,
+	}
+	var status int
+	if err := json.Unmarshal(e.Code, &status); err == nil {
+		apiErr.StatusCode = status
+	} else {
+		var code string
+		if err := json.Unmarshal(e.Code, &code); err == nil {
+			apiErr.Code = code
+		}
+	}
+	return apiErr
+}
+
+// createChatCompletionStream sends a streaming chat completion request to OpenRouter (internal method).
+func (c *Client) createChatCompletionStream(ctx context.Context, req *aisdk.ChatCompletionRequest, handler aisdk.StreamHandler) (*aisdk.ChatCompletionResponse, error) {
+	logger := c.logger.With("method", "CreateChatCompletionStream", "model", req.Model)
+	logger.Debug("sending streaming chat completion request")
+
+	req.Stream = true
+	req.StreamOptions = &aisdk.StreamOptions{IncludeUsage: true}
+
+	formattedReq := c.formatRequestForProvider(req)
+
+	if c.logger.Enabled(ctx, slog.LevelDebug) {
+		if debugBody, err := json.MarshalIndent(formattedReq, "", "  "); err == nil {
+			logger.Debug("formatted request", "body", string(debugBody))
+		}
+	}
+
+	body, err := json.Marshal(formattedReq)
+	if err != nil {
+		logger.Error("failed to marshal request", "error", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := c.newRequest(ctx, "POST", "/chat/completions", body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.doRequestWithRetry(c.streamClient, httpReq)
+	if err != nil {
+		logger.Error("request failed", "error", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("received error response", "status_code", resp.StatusCode)
+		return nil, c.handleError(resp)
+	}
+
+	acc := aisdk.NewStreamAccumulator()
+	err = aisdk.ReadSSE(resp.Body, func(data []byte) error {
+		var event streamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if event.Error != nil {
+			return event.Error.toAPIError()
+		}
+
+		acc.Add(&event.StreamChunk)
+		if handler != nil {
+			return handler(&event.StreamChunk)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("stream failed", "error", err)
+		return nil, err
+	}
+
+	result := acc.Response()
+	logger.Info("chat completion stream successful",
+		"usage_total", result.Usage.TotalTokens,
+		"usage_cached", result.Usage.PromptTokensCached)
+	return result, nil
+}
@@ -0,0 +1,188 @@
+package orclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elee1766/gofer/src/aisdk"
+)
+
+func newStreamTestServer(t *testing.T, lines []string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if body["stream"] != true {
+			t.Errorf("expected stream=true in request, got %v", body["stream"])
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, line := range lines {
+			fmt.Fprintf(w, "%s\n\n", line)
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+func TestCreateChatCompletionStream(t *testing.T) {
+	server := newStreamTestServer(t, []string{
+		": OPENROUTER PROCESSING",
+		`data: {"id":"gen-1","model":"openai/gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+		`data: {"id":"gen-1","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+		`data: {"id":"gen-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":""}}]}}]}`,
+		`data: {"id":"gen-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+		`data: {"id":"gen-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"list_directory"}}]}}]}`,
+		`data: {"id":"gen-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a.txt\"}"}}]}}]}`,
+		`data: {"id":"gen-1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: {"id":"gen-1","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+		"data: [DONE]",
+	})
+	defer server.Close()
+
+	client := NewClient(Config{APIKey: "test", BaseURL: server.URL})
+
+	var chunks []string
+	resp, err := client.createChatCompletionStream(context.Background(), &aisdk.ChatCompletionRequest{
+		Model:    "openai/gpt-4o",
+		Messages: []*aisdk.Message{{Role: "user", Content: "hi"}},
+	}, func(chunk *aisdk.StreamChunk) error {
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				chunks = append(chunks, choice.Delta.Content)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Join(chunks, "|") != "Hel|lo" {
+		t.Errorf("unexpected chunks: %v", chunks)
+	}
+
+	if resp.ID != "gen-1" || resp.Model != "openai/gpt-4o" {
+		t.Errorf("unexpected response metadata: id=%q model=%q", resp.ID, resp.Model)
+	}
+	if resp.Usage.TotalTokens != 15 || resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 5 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" {
+		t.Errorf("expected finish reason tool_calls, got %q", choice.FinishReason)
+	}
+	if choice.Message.Content != "Hello" {
+		t.Errorf("expected content Hello, got %q", choice.Message.Content)
+	}
+	if len(choice.Message.ToolCalls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(choice.Message.ToolCalls))
+	}
+
+	first := choice.Message.ToolCalls[0]
+	if first.ID != "call_1" || first.Function.Name != "read_file" || string(first.Function.Arguments) != `{"path":"a.txt"}` {
+		t.Errorf("unexpected first tool call: %+v (args %s)", first, first.Function.Arguments)
+	}
+	second := choice.Message.ToolCalls[1]
+	if second.ID != "call_2" || second.Function.Name != "list_directory" || string(second.Function.Arguments) != "{}" {
+		t.Errorf("unexpected second tool call: %+v (args %s)", second, second.Function.Arguments)
+	}
+}
+
+func TestCreateChatCompletionStreamMidStreamError(t *testing.T) {
+	server := newStreamTestServer(t, []string{
+		`data: {"id":"gen-1","choices":[{"index":0,"delta":{"content":"partial"}}]}`,
+		`data: {"error":{"message":"upstream overloaded","code":502}}`,
+	})
+	defer server.Close()
+
+	client := NewClient(Config{APIKey: "test", BaseURL: server.URL})
+
+	_, err := client.createChatCompletionStream(context.Background(), &aisdk.ChatCompletionRequest{
+		Model:    "openai/gpt-4o",
+		Messages: []*aisdk.Message{{Role: "user", Content: "hi"}},
+	}, nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if apiErr.StatusCode != 502 || apiErr.Message != "upstream overloaded" {
+		t.Errorf("unexpected error: %+v", apiErr)
+	}
+	if !apiErr.IsRetryable() {
+		t.Error("expected mid-stream 502 to be retryable")
+	}
+}
+
+func TestCreateChatCompletionStreamHandlerAbort(t *testing.T) {
+	server := newStreamTestServer(t, []string{
+		`data: {"id":"gen-1","choices":[{"index":0,"delta":{"content":"a"}}]}`,
+		`data: {"id":"gen-1","choices":[{"index":0,"delta":{"content":"b"}}]}`,
+		"data: [DONE]",
+	})
+	defer server.Close()
+
+	client := NewClient(Config{APIKey: "test", BaseURL: server.URL})
+
+	stop := errors.New("stop")
+	calls := 0
+	_, err := client.createChatCompletionStream(context.Background(), &aisdk.ChatCompletionRequest{
+		Model:    "openai/gpt-4o",
+		Messages: []*aisdk.Message{{Role: "user", Content: "hi"}},
+	}, func(chunk *aisdk.StreamChunk) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected handler to be called once, got %d", calls)
+	}
+}
+
+// countingTransport counts the requests it passes on
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCreateChatCompletionStreamUsesConfiguredClient(t *testing.T) {
+	server := newStreamTestServer(t, []string{
+		`data: {"id":"gen-1","choices":[{"index":0,"delta":{"content":"a"}}]}`,
+		"data: [DONE]",
+	})
+	defer server.Close()
+
+	transport := &countingTransport{}
+	httpClient := &http.Client{Transport: transport, Timeout: time.Second}
+	client := NewClient(Config{APIKey: "test", BaseURL: server.URL, HTTPClient: httpClient})
+
+	_, err := client.createChatCompletionStream(context.Background(), &aisdk.ChatCompletionRequest{
+		Model:    "openai/gpt-4o",
+		Messages: []*aisdk.Message{{Role: "user", Content: "hi"}},
+	}, func(chunk *aisdk.StreamChunk) error { return nil })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("expected the stream to use the configured transport, got %d requests", transport.requests)
+	}
+	if client.streamClient.Timeout != 0 || httpClient.Timeout != time.Second {
+		t.Errorf("expected only the stream client to have no timeout")
+	}
+}
@@ -0,0 +1,103 @@
+package redact
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// detector finds one kind of secret. When group is set, only that group of
+// a match is the secret.
+type detector struct {
+	kind  string
+	re    *regexp.Regexp
+	group int
+	valid func(value string) bool // Rejects false positives when set
+}
+
+// builtinDetectors find common key formats, the most specific first, so a
+// secret matched by several detectors is named after the first
+var builtinDetectors = []detector{
+	{kind: "private_key", re: regexp.MustCompile(`-----BEGIN[A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END[A-Z0-9 ]*PRIVATE KEY-----`)},
+	{kind: "anthropic_api_key", re: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{kind: "openrouter_api_key", re: regexp.MustCompile(`\bsk-or-v1-[A-Za-z0-9]{32,}`)},
+	{kind: "openai_api_key", re: regexp.MustCompile(`\bsk-(?:proj-|svcacct-|admin-)?[A-Za-z0-9_-]{20,}`)},
+	{kind: "github_token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{kind: "gitlab_token", re: regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}`)},
+	{kind: "aws_access_key_id", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{kind: "slack_token", re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{kind: "stripe_key", re: regexp.MustCompile(`\b(?:sk|rk)_(?:live|test)_[A-Za-z0-9]{16,}`)},
+	{kind: "google_api_key", re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{kind: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{kind: "url_password", re: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:([^\s@/]+)@`), group: 1},
+}
+
+// assignmentDetector finds values assigned to names that say they are
+// secrets, like API_KEY=... in .env files and the output of env, or
+// "password": "..." in JSON and YAML
+var assignmentDetector = detector{
+	kind:  "secret",
+	re:    regexp.MustCompile(`(?i)[a-z0-9_.-]*(?:password|passwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credentials?)[a-z0-9_.-]*["']?\s*(?::=|[:=])\s*["']?([^\s"'` + "`" + `,;]+)`),
+	group: 1,
+	valid: isAssignedSecret,
+}
+
+// isAssignedSecret rejects assigned values that are references or code
+// rather than secrets, like $TOKEN, os.Getenv("TOKEN") or 1000
+func isAssignedSecret(value string) bool {
+	if len(value) < 8 || strings.ContainsAny(value, "$(){}<>[]") {
+		return false
+	}
+	if strings.Trim(value, "0123456789") == "" {
+		return false
+	}
+	return entropy(value) >= 2.5
+}
+
+// minEntropyLength is the length of the shortest token the entropy
+// detector considers
+const minEntropyLength = 20
+
+// entropyTokenPattern matches tokens that may be random keys
+var entropyTokenPattern = regexp.MustCompile(`[A-Za-z0-9+/=_-]{20,}`)
+
+// isHighEntropy reports whether a token looks random: it mixes upper and
+// lower case letters and digits, and has at least threshold bits of entropy
+// per character. Hex strings, like hashes and UUIDs, are never redacted.
+func isHighEntropy(token string, threshold float64) bool {
+	if len(token) < minEntropyLength {
+		return false
+	}
+	var upper, lower, digit bool
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !upper || !lower || !digit {
+		return false
+	}
+	return entropy(token) >= threshold
+}
+
+// entropy returns the Shannon entropy of a string in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var bits float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
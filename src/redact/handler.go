@@ -0,0 +1,73 @@
+package redact
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler is a slog.Handler that redacts the string attributes and the
+// message of records before passing them on
+type Handler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+// NewHandler returns a handler redacting the records of next
+func NewHandler(next slog.Handler, redactor *Redactor) *Handler {
+	return &Handler{next: next, redactor: redactor}
+}
+
+// Logger returns a logger whose records are redacted, or the logger itself
+// for a nil redactor
+func (r *Redactor) Logger(logger *slog.Logger) *slog.Logger {
+	if r == nil || logger == nil {
+		return logger
+	}
+	return slog.New(NewHandler(logger.Handler(), r))
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	out := slog.NewRecord(record.Time, record.Level, h.redactor.Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redactAttr(attr)
+	}
+	return &Handler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+// redactAttr redacts string values, and the values of groups
+func (h *Handler) redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redactor.Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, a := range group {
+			redacted[i] = h.redactAttr(a)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, h.redactor.Redact(err.Error()))
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
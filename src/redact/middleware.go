@@ -0,0 +1,84 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/elee1766/gofer/src/agent"
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/goferagent/tools"
+)
+
+// resolvingTools are the tools whose arguments may hold placeholders to
+// resolve. They only write files, but a later command can read a file back
+// in a form the detectors miss, like base64, so resolving is no barrier
+// against an agent set on reading the secrets.
+var resolvingTools = map[string]bool{
+	tools.WriteFileName: true,
+	tools.EditFileName:  true,
+	tools.PatchName:     true,
+}
+
+// Middleware redacts the results and errors of tool calls. Registered as
+// the innermost middleware, the middleware around it, like hooks and the
+// audit log, only see the redacted results. With a store it also resolves
+// the placeholders in the string arguments of the calls that write files,
+// so the files get the secrets, and a call with a placeholder the store
+// does not have fails.
+func (r *Redactor) Middleware() agent.ToolMiddleware {
+	return func(next agent.ToolExecutor) agent.ToolExecutor {
+		if r == nil {
+			return next
+		}
+		return func(ctx context.Context, call *aisdk.ToolCall) (*aisdk.ToolResponse, error) {
+			if r.CanResolve() && resolvingTools[call.Function.Name] {
+				resolved, err := r.resolveArguments(call.Function.Arguments)
+				if err != nil {
+					return aisdk.NewErrorToolResponse(err.Error()), nil
+				}
+				if resolved != nil {
+					out := *call
+					out.Function.Arguments = resolved
+					call = &out
+				}
+			}
+			result, err := next(ctx, call)
+			if err != nil {
+				if redacted := r.Redact(err.Error()); redacted != err.Error() {
+					err = errors.New(redacted)
+				}
+			}
+			return r.RedactResponse(result), err
+		}
+	}
+}
+
+// resolveArguments resolves the placeholders of the string arguments of a
+// call. It returns nil when there are none.
+func (r *Redactor) resolveArguments(raw json.RawMessage) (json.RawMessage, error) {
+	var args map[string]any
+	if json.Unmarshal(raw, &args) != nil {
+		return nil, nil
+	}
+	changed := false
+	for key, value := range args {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		resolved, err := r.Resolve(text)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %w", key, err)
+		}
+		if resolved != text {
+			args[key] = resolved
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, nil
+	}
+	return json.Marshal(args)
+}
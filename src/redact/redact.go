@@ -0,0 +1,280 @@
+// Package redact replaces secrets in text with placeholders like
+// [REDACTED:github_token:4f1c9a0b27de].
+//
+// Secrets are found by built-in detectors of common key formats, by values
+// assigned to names like API_KEY or password, by configured patterns and,
+// optionally, by the entropy of random-looking tokens. A placeholder names
+// the kind of secret and an ID keyed on its value, so the same secret always
+// gets the same placeholder. With a Store, the values are kept encrypted and
+// Resolve turns placeholders back into them.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/elee1766/gofer/src/aisdk"
+	"github.com/elee1766/gofer/src/config"
+)
+
+// defaultEntropyThreshold is the entropy, in bits per character, of tokens
+// the entropy detector redacts when no threshold is configured
+const defaultEntropyThreshold = 4.0
+
+// placeholderPattern matches the placeholders of Redact
+var placeholderPattern = regexp.MustCompile(`\[REDACTED:([a-z0-9_]+):([0-9a-f]{12})\]`)
+
+// Redactor replaces secrets with placeholders. The methods of a nil
+// *Redactor leave text as it is, so callers don't need to check whether
+// redaction is enabled.
+type Redactor struct {
+	detectors []detector
+	entropy   float64 // 0 when the entropy detector is off
+	allow     []*regexp.Regexp
+	idKey     []byte
+	store     *Store
+	logger    *slog.Logger
+}
+
+// New creates a redactor from the configuration. With the secret store
+// enabled it opens the store, and relative store paths are taken from the
+// project directory.
+func New(cfg config.RedactionConfig, projectDir string, logger *slog.Logger) (*Redactor, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r := &Redactor{logger: logger}
+
+	r.detectors = append(r.detectors, builtinDetectors...)
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern.Name, err)
+		}
+		d := detector{kind: kindName(pattern.Name), re: re}
+		if re.NumSubexp() > 0 {
+			d.group = 1
+		}
+		r.detectors = append(r.detectors, d)
+	}
+	r.detectors = append(r.detectors, assignmentDetector)
+
+	if cfg.Entropy {
+		r.entropy = cfg.EntropyThreshold
+		if r.entropy <= 0 {
+			r.entropy = defaultEntropyThreshold
+		}
+	}
+	for _, allow := range cfg.Allow {
+		re, err := regexp.Compile(allow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction allow pattern %q: %w", allow, err)
+		}
+		r.allow = append(r.allow, re)
+	}
+
+	if cfg.SecretStore.Enabled {
+		store, err := OpenStore(cfg.SecretStore, projectDir)
+		if err != nil {
+			return nil, err
+		}
+		r.store = store
+		r.idKey = store.idKey
+	} else {
+		// Without a store the IDs only need to be stable for this run
+		r.idKey = make([]byte, keySize)
+		if _, err := rand.Read(r.idKey); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// CanResolve reports whether the redactor keeps the values of its
+// placeholders
+func (r *Redactor) CanResolve() bool {
+	return r != nil && r.store != nil
+}
+
+// span is a secret found in a text
+type span struct {
+	start, end int
+	kind       string
+}
+
+// Redact replaces the secrets of a text with placeholders
+func (r *Redactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	spans := r.find(text)
+	if len(spans) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		value := text[s.start:s.end]
+		b.WriteString(text[last:s.start])
+		b.WriteString(r.placeholder(s.kind, value))
+		last = s.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// RedactResponse returns a copy of a tool response with the secrets of its
+// content and text items replaced
+func (r *Redactor) RedactResponse(resp *aisdk.ToolResponse) *aisdk.ToolResponse {
+	if r == nil || resp == nil {
+		return resp
+	}
+	out := *resp
+	out.Content = []byte(r.Redact(string(resp.Content)))
+	if resp.MultimodalContent != nil {
+		content := &aisdk.MultimodalContent{Items: make([]aisdk.ContentItem, len(resp.MultimodalContent.Items))}
+		for i, item := range resp.MultimodalContent.Items {
+			if text, ok := item.Data.(aisdk.TextContent); ok {
+				item.Data = aisdk.TextContent{Text: r.Redact(text.Text)}
+			}
+			content.Items[i] = item
+		}
+		out.MultimodalContent = content
+	}
+	return &out
+}
+
+// Resolve replaces the placeholders of a text with the values they stand
+// for. It fails for placeholders the store does not have.
+func (r *Redactor) Resolve(text string) (string, error) {
+	if !r.CanResolve() || !strings.Contains(text, "[REDACTED:") {
+		return text, nil
+	}
+	var missing []string
+	resolved := placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		id := placeholderPattern.FindStringSubmatch(placeholder)[2]
+		value, ok := r.store.Get(id)
+		if !ok {
+			missing = append(missing, placeholder)
+			return placeholder
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("unknown secret placeholders: %s", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// find returns the secrets of a text in order. Overlapping secrets are
+// merged, so no part of either is left.
+func (r *Redactor) find(text string) []span {
+	// Placeholders are already redacted
+	taken := placeholderPattern.FindAllStringIndex(text, -1)
+
+	var spans []span
+	add := func(start, end int, kind string) {
+		if start >= end || r.allowed(text[start:end]) {
+			return
+		}
+		for _, t := range taken {
+			if start < t[1] && t[0] < end {
+				return
+			}
+		}
+		spans = append(spans, span{start: start, end: end, kind: kind})
+	}
+
+	for _, d := range r.detectors {
+		for _, loc := range d.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if d.group > 0 {
+				start, end = loc[2*d.group], loc[2*d.group+1]
+				if start < 0 {
+					continue
+				}
+			}
+			if d.valid != nil && !d.valid(text[start:end]) {
+				continue
+			}
+			add(start, end, d.kind)
+		}
+	}
+	if r.entropy > 0 {
+		for _, loc := range entropyTokenPattern.FindAllStringIndex(text, -1) {
+			if isHighEntropy(text[loc[0]:loc[1]], r.entropy) {
+				add(loc[0], loc[1], "high_entropy")
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	// Earlier detectors win ties, since they are more specific
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s.start < last.end {
+			last.end = max(last.end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// allowed reports whether a value matches an allow pattern
+func (r *Redactor) allowed(value string) bool {
+	for _, re := range r.allow {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholder returns the placeholder of a secret, keeping its value in the
+// store if there is one
+func (r *Redactor) placeholder(kind, value string) string {
+	mac := hmac.New(sha256.New, r.idKey)
+	mac.Write([]byte(value))
+	id := hex.EncodeToString(mac.Sum(nil)[:6])
+	if r.store != nil {
+		if err := r.store.Put(id, value); err != nil {
+			r.logger.Error("Failed to store redacted secret", "kind", kind, "error", err)
+		}
+	}
+	return fmt.Sprintf("[REDACTED:%s:%s]", kind, id)
+}
+
+// kindName turns a pattern name into the kind of its placeholders
+func kindName(name string) string {
+	name = strings.ToLower(name)
+	var b strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "secret"
+	}
+	return b.String()
+}
@@ -0,0 +1,199 @@
+package redact
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/elee1766/gofer/src/config"
+)
+
+// keySize is the size of the store's key
+const keySize = 32
+
+// Store keeps redacted values, encrypted with AES-GCM under a key kept in a
+// separate file, so placeholders can be resolved in later runs
+type Store struct {
+	path   string
+	aead   cipher.AEAD
+	idKey  []byte // Key of the placeholder IDs
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// OpenStore opens the store of the configuration, creating its key file
+// when missing. A relative store path is taken from the project directory.
+func OpenStore(cfg config.SecretStoreConfig, projectDir string) (*Store, error) {
+	path := cfg.Path
+	if path == "" {
+		path = ".gofer/secrets.enc"
+	}
+	if !filepath.IsAbs(path) && !strings.HasPrefix(path, "~") {
+		path = filepath.Join(projectDir, path)
+	}
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	keyPath, err := expandHome(cfg.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+	if keyPath == "" {
+		return nil, fmt.Errorf("secret store key path is not set")
+	}
+
+	master, err := loadKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	encryptionKey, err := hkdf.Key(sha256.New, master, nil, "gofer secret store", keySize)
+	if err != nil {
+		return nil, err
+	}
+	idKey, err := hkdf.Key(sha256.New, master, nil, "gofer secret placeholder", keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{path: path, aead: aead, idKey: idKey, values: make(map[string]string)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the value of a placeholder ID
+func (s *Store) Get(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[id]
+	return value, ok
+}
+
+// Put stores the value of a placeholder ID, saving the store if it is new
+func (s *Store) Put(id, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[id]; ok {
+		return nil
+	}
+	s.values[id] = value
+	if err := s.save(); err != nil {
+		delete(s.values, id)
+		return err
+	}
+	return nil
+}
+
+// load reads and decrypts the store, if it exists
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read secret store: %w", err)
+	}
+	nonceSize := s.aead.NonceSize()
+	if len(data) < nonceSize {
+		return fmt.Errorf("secret store %s is corrupt", s.path)
+	}
+	plaintext, err := s.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt secret store %s; was its key replaced? %w", s.path, err)
+	}
+	if err := json.Unmarshal(plaintext, &s.values); err != nil {
+		return fmt.Errorf("secret store %s is corrupt: %w", s.path, err)
+	}
+	return nil
+}
+
+// save encrypts the store and replaces its file
+func (s *Store) save() error {
+	plaintext, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data := s.aead.Seal(nonce, nonce, plaintext, nil)
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create secret store directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write secret store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write secret store: %w", err)
+	}
+	return nil
+}
+
+// loadKey reads the key file, creating it with a random key when missing
+func loadKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("secret store key %s is not %d bytes", path, keySize)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read secret store key: %w", err)
+	}
+
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create secret store key directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		// Another run created it first
+		return loadKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret store key: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(key); err != nil {
+		return nil, fmt.Errorf("failed to write secret store key: %w", err)
+	}
+	return key, nil
+}
+
+// expandHome expands a leading ~ to the home directory
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
@@ -13,8 +13,17 @@ import (
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/elee1766/gofer/src/config"
+	"github.com/elee1766/gofer/src/shellparse"
 )
 
+// defaultCommandPolicy returns the command permissions of the default
+// configuration
+func defaultCommandPolicy() *config.PermissionChecker {
+	return config.NewPermissionChecker(&config.DefaultConfig().Permissions)
+}
+
 // PersistentShell maintains a shell session with current directory tracking
 type PersistentShell struct {
 	cmd           *exec.Cmd
@@ -27,6 +36,9 @@ type PersistentShell struct {
 	mu            sync.Mutex
 	logger        *slog.Logger
 	closed        bool
+
+	// policy holds the command permissions
+	policy *config.PermissionChecker
 }
 
 // ShellResult represents the result of a shell command
@@ -49,6 +61,10 @@ func NewPersistentShell(logger *slog.Logger) (*PersistentShell, error) {
 	// Create shell command with bash in a more robust way
 	cmd := exec.Command("bash", "--norc", "--noprofile", "-s")
 	cmd.Dir = currentDir
+
+	// Run the shell in its own process group, so Ctrl-C reaches gofer only
+	// and a cancelled command can be stopped with the whole group
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	
 	// Set up environment to minimize interference
 	cmd.Env = append(os.Environ(),
@@ -99,6 +115,7 @@ func NewPersistentShell(logger *slog.Logger) (*PersistentShell, error) {
 		sessionID:   sessionID,
 		logger:      logger,
 		closed:      false,
+		policy:      defaultCommandPolicy(),
 	}
 
 	shell.logger.Info("starting persistent shell session", "session_id", sessionID, "working_dir", currentDir)
@@ -449,57 +466,52 @@ func (ps *PersistentShell) ResetToOriginalDirectory(ctx context.Context) error {
 	return nil
 }
 
-// ValidateCommand checks if a command is safe to execute
+// ValidateCommand checks if a command is safe to execute. The command is
+// parsed, and denied when a cd leaves the project directory or the command
+// policy denies it. Commands the policy requires to be confirmed are not
+// rejected here; the permission check of run_command calls asks about them
+// when permissions are enabled.
 func (ps *PersistentShell) ValidateCommand(command string) error {
 	// Check for empty command
 	if strings.TrimSpace(command) == "" {
 		return fmt.Errorf("empty command not allowed")
 	}
-	
-	// Check for attempts to navigate outside using absolute paths
-	commandLower := strings.ToLower(command)
-	
+
+	eval := ps.policy.EvaluateCommand(command)
+	if eval.Err != nil {
+		return fmt.Errorf("command not allowed: %s", eval.Explain())
+	}
+
 	// Check for cd to absolute paths outside the project
-	if strings.Contains(commandLower, "cd ") {
-		// Extract the path after cd
-		parts := strings.Fields(command)
-		for i, part := range parts {
-			if part == "cd" && i+1 < len(parts) {
-				targetPath := parts[i+1]
-				// Check if it's an absolute path
-				if strings.HasPrefix(targetPath, "/") && targetPath != "/" {
-					// Allow navigation within the original directory
-					if !strings.HasPrefix(targetPath, ps.originalDir) {
-						return fmt.Errorf("cannot navigate to absolute path outside project directory: %s", targetPath)
-					}
-				} else if targetPath == "/" {
-					return fmt.Errorf("cannot navigate to root directory")
-				}
-			}
+	script, _ := shellparse.Parse(command)
+	var cdErr error
+	script.Walk(func(cmd *shellparse.Command) {
+		if cdErr != nil || len(cmd.Args) < 2 || cmd.Args[0].Value != "cd" {
+			return
 		}
-	}
-	
-	// Check for dangerous commands
-	dangerousCommands := []string{
-		"rm -rf", "rm -r", "sudo", "su ", "chmod 777", "chown",
-		"mkfs", "fdisk", "dd if=", ">/dev/", "curl", "wget",
-		"nc ", "netcat", "ssh", "scp", "rsync", "ftp",
-		"telnet", "rsh", "rcp", "mount", "umount", "kill -9",
-		"killall", "pkill", "systemctl", "service", "init",
-		"reboot", "shutdown", "halt", "poweroff", "passwd",
-		"adduser", "deluser", "userdel", "usermod", "groupadd",
-		"groupdel", "visudo", "crontab", "at ", "batch",
-	}
-
-	for _, dangerous := range dangerousCommands {
-		if strings.Contains(commandLower, dangerous) {
-			return fmt.Errorf("dangerous command not allowed: %s", command)
+		targetPath := cmd.Args[1].Value
+		if targetPath == "/" {
+			cdErr = fmt.Errorf("cannot navigate to root directory")
+		} else if strings.HasPrefix(targetPath, "/") && !strings.HasPrefix(targetPath, ps.originalDir) {
+			// Allow navigation within the original directory
+			cdErr = fmt.Errorf("cannot navigate to absolute path outside project directory: %s", targetPath)
 		}
+	})
+	if cdErr != nil {
+		return cdErr
+	}
+
+	if eval.Decision == config.CommandDeny {
+		return fmt.Errorf("command not allowed: %s", eval.Explain())
 	}
-	
 	return nil
 }
 
+// SetCommandPolicy sets the command permissions ValidateCommand enforces
+func (ps *PersistentShell) SetCommandPolicy(policy *config.PermissionChecker) {
+	ps.policy = policy
+}
+
 // Close terminates the shell session
 func (ps *PersistentShell) Close() error {
 	ps.mu.Lock()
@@ -534,6 +546,29 @@ func (ps *PersistentShell) Close() error {
 	return nil
 }
 
+// kill stops the shell and every command running in it. The caller must
+// hold ps.mu.
+func (ps *PersistentShell) kill() {
+	ps.logger.Info("killing persistent shell session", "session_id", ps.sessionID)
+	ps.closed = true
+	if ps.cmd != nil && ps.cmd.Process != nil {
+		syscall.Kill(-ps.cmd.Process.Pid, syscall.SIGKILL)
+	}
+	if ps.stdin != nil {
+		ps.stdin.Close()
+	}
+	if ps.cmd != nil && ps.cmd.Process != nil {
+		ps.cmd.Wait()
+	}
+}
+
+// IsClosed reports whether the shell was closed or killed
+func (ps *PersistentShell) IsClosed() bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.closed
+}
+
 // GetSessionID returns the unique session identifier
 func (ps *PersistentShell) GetSessionID() string {
 	return ps.sessionID
@@ -3,6 +3,7 @@ package shell
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -126,15 +127,29 @@ func (ps *PersistentShell) ExecuteCommandSimple(ctx context.Context, command str
 		return result, nil
 
 	case err := <-errorChan:
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, ps.cancelCommand(ctx, command)
+		}
 		ps.logger.Error("command execution error", "command", command, "error", err)
 		return nil, err
 
 	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, ps.cancelCommand(ctx, command)
+		}
 		ps.logger.Error("command timed out", "command", command, "timeout", timeout)
 		return nil, fmt.Errorf("command timed out after %v", timeout)
 	}
 }
 
+// cancelCommand stops a command whose context was cancelled. The command
+// can't be stopped without the shell, so the shell is killed with it.
+func (ps *PersistentShell) cancelCommand(ctx context.Context, command string) error {
+	ps.logger.Warn("command cancelled", "command", command)
+	ps.kill()
+	return fmt.Errorf("command cancelled, the shell session was reset: %w", ctx.Err())
+}
+
 // updateWorkingDirectoryInternal is an internal version that doesn't lock the mutex
 func (ps *PersistentShell) updateWorkingDirectoryInternal(ctx context.Context) error {
 	if ps.closed {
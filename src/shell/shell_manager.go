@@ -7,6 +7,8 @@ import (
 	"os"
 	"sync"
 	"time"
+
+	"github.com/elee1766/gofer/src/config"
 )
 
 // ShellManager manages persistent shell sessions per conversation
@@ -14,6 +16,9 @@ type ShellManager struct {
 	shells map[string]*PersistentShell // conversationID -> shell
 	mu     sync.RWMutex
 	logger *slog.Logger
+
+	// policy holds the command permissions of new shells
+	policy *config.PermissionChecker
 }
 
 // NewShellManager creates a new shell manager
@@ -21,6 +26,18 @@ func NewShellManager(logger *slog.Logger) *ShellManager {
 	return &ShellManager{
 		shells: make(map[string]*PersistentShell),
 		logger: logger,
+		policy: defaultCommandPolicy(),
+	}
+}
+
+// SetCommandPolicy sets the command permissions commands are checked against,
+// and whose max_timeout limits their timeout
+func (sm *ShellManager) SetCommandPolicy(policy *config.PermissionChecker) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.policy = policy
+	for _, shell := range sm.shells {
+		shell.SetCommandPolicy(policy)
 	}
 }
 
@@ -29,8 +46,9 @@ func (sm *ShellManager) GetShell(conversationID string) (*PersistentShell, error
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	// Check if shell already exists
-	if shell, exists := sm.shells[conversationID]; exists {
+	// Check if shell already exists; shells killed with a cancelled
+	// command are replaced
+	if shell, exists := sm.shells[conversationID]; exists && !shell.IsClosed() {
 		return shell, nil
 	}
 
@@ -39,6 +57,7 @@ func (sm *ShellManager) GetShell(conversationID string) (*PersistentShell, error
 	if err != nil {
 		return nil, fmt.Errorf("failed to create shell for conversation %s: %w", conversationID, err)
 	}
+	shell.SetCommandPolicy(sm.policy)
 
 	sm.shells[conversationID] = shell
 	sm.logger.Info("created new shell session", "conversation_id", conversationID, "session_id", shell.GetSessionID())
@@ -95,7 +114,7 @@ func (sm *ShellManager) ExecuteCommand(ctx context.Context, conversationID, comm
 	}
 
 	// Execute the command
-	result, err := shell.ExecuteCommand(ctx, command, timeout)
+	result, err := shell.ExecuteCommand(ctx, command, shell.policy.CommandTimeout(timeout))
 	if err != nil {
 		return nil, err
 	}
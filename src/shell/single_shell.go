@@ -5,12 +5,18 @@ import (
 	"fmt"
 	"log/slog"
 	"time"
+
+	"github.com/elee1766/gofer/src/config"
 )
 
 // SingleShellManager manages a single persistent shell session for CLI usage
 type SingleShellManager struct {
 	shell  *PersistentShell
 	logger *slog.Logger
+
+	// policy holds the command permissions, which are kept when the shell
+	// is replaced
+	policy *config.PermissionChecker
 }
 
 // NewSingleShellManager creates a new manager with a single persistent shell
@@ -23,9 +29,27 @@ func NewSingleShellManager(logger *slog.Logger) (*SingleShellManager, error) {
 	return &SingleShellManager{
 		shell:  shell,
 		logger: logger,
+		policy: shell.policy,
 	}, nil
 }
 
+// SetCommandPolicy sets the command permissions commands are checked against,
+// and whose max_timeout limits their timeout
+func (sm *SingleShellManager) SetCommandPolicy(policy *config.PermissionChecker) {
+	sm.policy = policy
+	sm.shell.SetCommandPolicy(policy)
+}
+
+// newShell starts a shell with the manager's command permissions
+func (sm *SingleShellManager) newShell() (*PersistentShell, error) {
+	shell, err := NewPersistentShell(sm.logger)
+	if err != nil {
+		return nil, err
+	}
+	shell.SetCommandPolicy(sm.policy)
+	return shell, nil
+}
+
 // ExecuteCommand executes a command in the persistent shell
 func (sm *SingleShellManager) ExecuteCommand(ctx context.Context, command string, timeout time.Duration) (*ShellResult, error) {
 	// Validate command safety
@@ -33,8 +57,17 @@ func (sm *SingleShellManager) ExecuteCommand(ctx context.Context, command string
 		return nil, err
 	}
 
+	// Replace a shell that was killed with a cancelled command
+	if sm.shell.IsClosed() {
+		newShell, err := sm.newShell()
+		if err != nil {
+			return nil, fmt.Errorf("failed to restart shell: %w", err)
+		}
+		sm.shell = newShell
+	}
+
 	// Execute the command
-	result, err := sm.shell.ExecuteCommand(ctx, command, timeout)
+	result, err := sm.shell.ExecuteCommand(ctx, command, sm.policy.CommandTimeout(timeout))
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +84,7 @@ func (sm *SingleShellManager) ExecuteCommand(ctx context.Context, command string
 			sm.logger.Error("failed to reset shell to original directory", "error", resetErr)
 			// Close and recreate the shell
 			sm.Close()
-			newShell, err := NewPersistentShell(sm.logger)
+			newShell, err := sm.newShell()
 			if err != nil {
 				return nil, fmt.Errorf("failed to recreate shell after reset failure: %w", err)
 			}
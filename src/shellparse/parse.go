@@ -0,0 +1,1012 @@
+// Package shellparse parses sh and bash command lines into the simple
+// commands they run, so commands can be checked against a policy before they
+// are run. Nothing is expanded or run: words keep their expansions as
+// written and are marked as not literal, and the commands of command and
+// process substitutions are parsed as nested scripts. Reserved words like if
+// and done are skipped without checking that they are balanced.
+package shellparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Script is a parsed command line
+type Script struct {
+	// Commands are the simple commands in source order, including those
+	// of pipelines, lists, subshells, groups and compound commands
+	Commands []*Command
+
+	// Substitutions are command substitutions outside of simple commands,
+	// such as in the word list of a for loop or the subject of a case
+	Substitutions []*Script
+}
+
+// Command is a simple command
+type Command struct {
+	// Text is the source text of the command, with its assignments and
+	// redirections
+	Text string
+
+	// Assigns are the variable assignments before the command name
+	Assigns []Word
+
+	// Args are the command name and its arguments. A command may have
+	// none, like "> file" or "FOO=bar".
+	Args []Word
+
+	Redirects []*Redirect
+}
+
+// Word is a word of a command
+type Word struct {
+	// Text is the source text of the word
+	Text string
+
+	// Value is the word after quote removal, with parameter expansions,
+	// arithmetic and substitutions kept as written
+	Value string
+
+	// Literal is set when the word has no expansions, so Value is the
+	// word the command gets. Globs and tildes are not considered
+	// expansions.
+	Literal bool
+
+	// Substitutions are the scripts of the command and process
+	// substitutions in the word
+	Substitutions []*Script
+}
+
+// Redirect is a redirection of a command
+type Redirect struct {
+	Op     string // e.g. ">", ">>", "<", "<<", ">&", "&>"
+	Fd     string // Explicit file descriptor, e.g. "2" of "2>&1"
+	Target Word   // File, file descriptor or here-document delimiter
+
+	// Heredoc is the body of a here-document
+	Heredoc *Word
+}
+
+// SyntaxError is returned for command lines that cannot be parsed
+type SyntaxError struct {
+	Offset  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", e.Offset, e.Message)
+}
+
+// Parse parses a command line
+func Parse(src string) (*Script, error) {
+	p := &parser{src: src}
+	script := &Script{}
+	if err := p.parseList(script, 0, false); err != nil {
+		return nil, err
+	}
+	// A here-document at the end of the input has no body
+	if err := p.readHeredocs(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Walk calls fn for every simple command of the script, including the
+// commands of substitutions, in source order. The commands of a command's
+// substitutions are visited after the command.
+func (s *Script) Walk(fn func(*Command)) {
+	for _, cmd := range s.Commands {
+		fn(cmd)
+		for _, w := range cmd.Assigns {
+			w.walk(fn)
+		}
+		for _, w := range cmd.Args {
+			w.walk(fn)
+		}
+		for _, r := range cmd.Redirects {
+			r.Target.walk(fn)
+			if r.Heredoc != nil {
+				r.Heredoc.walk(fn)
+			}
+		}
+	}
+	for _, sub := range s.Substitutions {
+		sub.Walk(fn)
+	}
+}
+
+func (w Word) walk(fn func(*Command)) {
+	for _, sub := range w.Substitutions {
+		sub.Walk(fn)
+	}
+}
+
+// reservedWords are skipped where a command name is expected
+var reservedWords = map[string]bool{
+	"if": true, "then": true, "elif": true, "else": true, "fi": true,
+	"while": true, "until": true, "do": true, "done": true,
+	"!": true, "{": true, "}": true,
+}
+
+// redirectOps are the redirection operators, longest first
+var redirectOps = []string{"&>>", "<<<", "<<-", "&>", "<<", ">>", ">|", ">&", "<&", "<>", ">", "<"}
+
+type parser struct {
+	src string
+	pos int
+
+	// heredocs are read from the line after the current one
+	heredocs []*Redirect
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Offset: p.pos, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *parser) peek() byte { return p.peekAt(0) }
+
+func (p *parser) peekAt(i int) byte {
+	if p.pos+i >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+i]
+}
+
+func (p *parser) hasPrefix(s string) bool { return strings.HasPrefix(p.src[p.pos:], s) }
+
+// atWord reports whether the input continues with the word s
+func (p *parser) atWord(s string) bool {
+	if !p.hasPrefix(s) {
+		return false
+	}
+	next := p.pos + len(s)
+	return next >= len(p.src) || isDelimiter(p.src[next])
+}
+
+// isDelimiter reports whether c ends an unquoted word
+func isDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\n;&|()<>", c) >= 0
+}
+
+// skipBlanks skips spaces, tabs and line continuations
+func (p *parser) skipBlanks() {
+	for !p.eof() {
+		switch {
+		case p.peek() == ' ' || p.peek() == '\t':
+			p.pos++
+		case p.hasPrefix("\\\n"):
+			p.pos += 2
+		default:
+			return
+		}
+	}
+}
+
+// skipSpace skips blanks, newlines and comments
+func (p *parser) skipSpace() error {
+	for {
+		p.skipBlanks()
+		switch p.peek() {
+		case '#':
+			p.skipComment()
+		case '\n':
+			p.pos++
+			if err := p.readHeredocs(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *parser) skipComment() {
+	if i := strings.IndexByte(p.src[p.pos:], '\n'); i >= 0 {
+		p.pos += i
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+// parseList parses commands until the end of the input or an unmatched
+// closer. In the body of a case item it also stops at ";;", ";&" and esac.
+func (p *parser) parseList(script *Script, closer byte, inCase bool) error {
+	for {
+		if err := p.skipSpace(); err != nil {
+			return err
+		}
+		if p.eof() {
+			if closer != 0 {
+				return p.errorf("missing %q", closer)
+			}
+			return nil
+		}
+		if inCase && (p.hasPrefix(";;") || p.hasPrefix(";&") || p.atWord("esac")) {
+			return nil
+		}
+
+		switch c := p.peek(); {
+		case c == ')':
+			if closer == ')' {
+				return nil
+			}
+			return p.errorf("unexpected )")
+		case c == '&' && p.peekAt(1) == '>':
+			if err := p.parseCommand(script); err != nil {
+				return err
+			}
+		case c == ';' || c == '&' || c == '|':
+			p.pos++
+		case p.hasPrefix("(("):
+			var w Word
+			if err := p.readArithmetic(&w, "(("); err != nil {
+				return err
+			}
+			script.Substitutions = append(script.Substitutions, w.Substitutions...)
+		case c == '(':
+			p.pos++
+			if err := p.parseList(script, ')', false); err != nil {
+				return err
+			}
+			p.pos++
+		default:
+			if err := p.parseCommand(script); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseCommand parses a simple command, or the head of a compound command
+func (p *parser) parseCommand(script *Script) error {
+	cmd := &Command{}
+	start := p.pos
+	for {
+		p.skipBlanks()
+		if p.eof() {
+			break
+		}
+		c := p.peek()
+		if c == '\n' || c == ';' || c == '|' || c == ')' || c == '#' || (c == '&' && p.peekAt(1) != '>') {
+			break
+		}
+		if c == '&' || (c == '<' || c == '>') && p.peekAt(1) != '(' {
+			if err := p.parseRedirect(cmd, ""); err != nil {
+				return err
+			}
+			continue
+		}
+		if c == '(' {
+			// name() starts a function definition, whose body follows
+			if len(cmd.Args) == 1 && len(cmd.Assigns) == 0 && len(cmd.Redirects) == 0 {
+				p.pos++
+				p.skipBlanks()
+				if p.peek() != ')' {
+					return p.errorf("expected ) in function definition")
+				}
+				p.pos++
+				return nil
+			}
+			return p.errorf("unexpected (")
+		}
+
+		// Keywords are only recognized where a command name is expected
+		if len(cmd.Args) == 0 && len(cmd.Assigns) == 0 && len(cmd.Redirects) == 0 {
+			done, err := p.parseKeyword(script)
+			if err != nil {
+				return err
+			}
+			if done {
+				return nil
+			}
+			if p.skipReservedWord() {
+				start = p.pos
+				continue
+			}
+		}
+
+		if len(cmd.Args) == 0 && p.atWord("[[") {
+			if err := p.readTest(cmd); err != nil {
+				return err
+			}
+			continue
+		}
+
+		w, err := p.readWord()
+		if err != nil {
+			return err
+		}
+		if next := p.peek(); (next == '<' || next == '>') && isNumber(w.Text) {
+			if err := p.parseRedirect(cmd, w.Text); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(cmd.Args) == 0 && isAssignment(w.Text) {
+			cmd.Assigns = append(cmd.Assigns, w)
+		} else {
+			cmd.Args = append(cmd.Args, w)
+		}
+	}
+
+	cmd.Text = strings.TrimSpace(p.src[start:p.pos])
+	if len(cmd.Args) > 0 || len(cmd.Assigns) > 0 || len(cmd.Redirects) > 0 {
+		script.Commands = append(script.Commands, cmd)
+	}
+	return nil
+}
+
+// skipReservedWord skips a reserved word that may precede a command name
+func (p *parser) skipReservedWord() bool {
+	for word := range reservedWords {
+		if p.atWord(word) {
+			p.pos += len(word)
+			return true
+		}
+	}
+	return false
+}
+
+// parseKeyword parses the heads of for, select and case statements and
+// function definitions with the function keyword. It reports whether it
+// parsed one, which ends the simple command.
+func (p *parser) parseKeyword(script *Script) (bool, error) {
+	switch {
+	case p.atWord("for") || p.atWord("select"):
+		// for name [in words]; the body is parsed as a list
+		if p.atWord("for") {
+			p.pos += len("for")
+		} else {
+			p.pos += len("select")
+		}
+		p.skipBlanks()
+		if p.hasPrefix("((") {
+			var w Word
+			if err := p.readArithmetic(&w, "(("); err != nil {
+				return false, err
+			}
+			script.Substitutions = append(script.Substitutions, w.Substitutions...)
+			return true, nil
+		}
+		for {
+			p.skipBlanks()
+			if p.eof() || p.peek() == '\n' || p.peek() == ';' {
+				return true, nil
+			}
+			if p.atWord("do") {
+				return true, nil
+			}
+			w, err := p.readWord()
+			if err != nil {
+				return false, err
+			}
+			if w.Text == "" {
+				return false, p.errorf("unexpected %q in for statement", p.peek())
+			}
+			script.Substitutions = append(script.Substitutions, w.Substitutions...)
+		}
+	case p.atWord("case"):
+		p.pos += len("case")
+		return true, p.parseCase(script)
+	case p.atWord("function"):
+		p.pos += len("function")
+		p.skipBlanks()
+		if _, err := p.readWord(); err != nil {
+			return false, err
+		}
+		p.skipBlanks()
+		if p.hasPrefix("()") {
+			p.pos += 2
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// parseCase parses a case statement after the case keyword
+func (p *parser) parseCase(script *Script) error {
+	p.skipBlanks()
+	subject, err := p.readWord()
+	if err != nil {
+		return err
+	}
+	script.Substitutions = append(script.Substitutions, subject.Substitutions...)
+	if err := p.skipSpace(); err != nil {
+		return err
+	}
+	if !p.atWord("in") {
+		return p.errorf("expected in after case word")
+	}
+	p.pos += len("in")
+
+	for {
+		if err := p.skipSpace(); err != nil {
+			return err
+		}
+		if p.eof() {
+			return p.errorf("missing esac")
+		}
+		if p.atWord("esac") {
+			p.pos += len("esac")
+			return nil
+		}
+		if p.peek() == '(' {
+			p.pos++
+		}
+		for {
+			p.skipBlanks()
+			pattern, err := p.readWord()
+			if err != nil {
+				return err
+			}
+			if pattern.Text == "" {
+				return p.errorf("expected case pattern")
+			}
+			script.Substitutions = append(script.Substitutions, pattern.Substitutions...)
+			p.skipBlanks()
+			if p.peek() == '|' {
+				p.pos++
+				continue
+			}
+			if p.peek() != ')' {
+				return p.errorf("expected ) after case pattern")
+			}
+			p.pos++
+			break
+		}
+		if err := p.parseList(script, 0, true); err != nil {
+			return err
+		}
+		switch {
+		case p.hasPrefix(";;&"):
+			p.pos += 3
+		case p.hasPrefix(";;") || p.hasPrefix(";&"):
+			p.pos += 2
+		}
+	}
+}
+
+// readTest reads a [[ ... ]] conditional as a command named [[
+func (p *parser) readTest(cmd *Command) error {
+	cmd.Args = append(cmd.Args, Word{Text: "[[", Value: "[[", Literal: true})
+	p.pos += 2
+	for {
+		p.skipBlanks()
+		if p.eof() {
+			return p.errorf("missing ]]")
+		}
+		if p.atWord("]]") {
+			p.pos += 2
+			cmd.Args = append(cmd.Args, Word{Text: "]]", Value: "]]", Literal: true})
+			return nil
+		}
+		if p.peek() == '\n' {
+			p.pos++
+			continue
+		}
+		if isDelimiter(p.peek()) {
+			// Operators of the conditional, like < and &&
+			op := p.src[p.pos : p.pos+1]
+			if p.hasPrefix("&&") || p.hasPrefix("||") {
+				op = p.src[p.pos : p.pos+2]
+			}
+			p.pos += len(op)
+			cmd.Args = append(cmd.Args, Word{Text: op, Value: op, Literal: true})
+			continue
+		}
+		w, err := p.readWord()
+		if err != nil {
+			return err
+		}
+		cmd.Args = append(cmd.Args, w)
+	}
+}
+
+// parseRedirect parses a redirection at the current position
+func (p *parser) parseRedirect(cmd *Command, fd string) error {
+	var op string
+	for _, candidate := range redirectOps {
+		if p.hasPrefix(candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return p.errorf("unexpected %q", p.peek())
+	}
+	p.pos += len(op)
+	p.skipBlanks()
+	if p.eof() || (isDelimiter(p.peek()) && !(p.hasPrefix("<(") || p.hasPrefix(">("))) {
+		return p.errorf("missing target of %s", op)
+	}
+	target, err := p.readWord()
+	if err != nil {
+		return err
+	}
+	r := &Redirect{Op: op, Fd: fd, Target: target}
+	cmd.Redirects = append(cmd.Redirects, r)
+	if op == "<<" || op == "<<-" {
+		p.heredocs = append(p.heredocs, r)
+	}
+	return nil
+}
+
+// readHeredocs reads the bodies of the pending here-documents, which start
+// at the current position
+func (p *parser) readHeredocs() error {
+	pending := p.heredocs
+	p.heredocs = nil
+	for _, r := range pending {
+		delimiter := r.Target.Value
+		start := p.pos
+		end := len(p.src)
+		for !p.eof() {
+			lineEnd := strings.IndexByte(p.src[p.pos:], '\n')
+			next := len(p.src)
+			if lineEnd >= 0 {
+				next = p.pos + lineEnd + 1
+				lineEnd += p.pos
+			} else {
+				lineEnd = len(p.src)
+			}
+			line := p.src[p.pos:lineEnd]
+			if r.Op == "<<-" {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == delimiter {
+				end = p.pos
+				p.pos = next
+				break
+			}
+			p.pos = next
+		}
+		body := p.src[start:min(end, len(p.src))]
+		heredoc := &Word{Text: body, Value: body, Literal: true}
+
+		// Unquoted delimiters expand the body like a double-quoted word
+		if !strings.ContainsAny(r.Target.Text, `'"\`) {
+			sub := &parser{src: body}
+			var value strings.Builder
+			for !sub.eof() {
+				switch sub.peek() {
+				case '\\':
+					value.WriteString(sub.src[sub.pos:min(sub.pos+2, len(sub.src))])
+					sub.pos += 2
+				case '$':
+					if err := sub.readDollar(heredoc, &value, true); err != nil {
+						return err
+					}
+				case '`':
+					if err := sub.readBackquote(heredoc, &value); err != nil {
+						return err
+					}
+				default:
+					value.WriteByte(sub.peek())
+					sub.pos++
+				}
+			}
+		}
+		r.Heredoc = heredoc
+	}
+	return nil
+}
+
+// readWord reads a word up to the next unquoted delimiter
+func (p *parser) readWord() (Word, error) {
+	w := Word{Literal: true}
+	start := p.pos
+	var value strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case (c == '<' || c == '>') && p.pos == start && p.peekAt(1) == '(':
+			// Process substitution
+			p.pos += 2
+			if err := p.readSubstitution(&w); err != nil {
+				return w, err
+			}
+			value.WriteString(p.src[start:p.pos])
+		case c == '(' && p.pos > start && p.src[p.pos-1] == '=' && isAssignment(p.src[start:p.pos]):
+			// Array assignment, a=(1 2 3)
+			open := p.pos
+			if err := p.readArray(&w); err != nil {
+				return w, err
+			}
+			value.WriteString(p.src[open:p.pos])
+		case isDelimiter(c):
+			w.Text = p.src[start:p.pos]
+			w.Value = value.String()
+			return w, nil
+		case c == '\\':
+			if p.peekAt(1) != '\n' && p.pos+1 < len(p.src) {
+				value.WriteByte(p.peekAt(1))
+			}
+			p.pos = min(p.pos+2, len(p.src))
+		case c == '\'':
+			end := strings.IndexByte(p.src[p.pos+1:], '\'')
+			if end < 0 {
+				return w, p.errorf("unterminated single quote")
+			}
+			value.WriteString(p.src[p.pos+1 : p.pos+1+end])
+			p.pos += end + 2
+		case c == '"':
+			p.pos++
+			if err := p.readDoubleQuoted(&w, &value); err != nil {
+				return w, err
+			}
+		case c == '$':
+			if err := p.readDollar(&w, &value, false); err != nil {
+				return w, err
+			}
+		case c == '`':
+			if err := p.readBackquote(&w, &value); err != nil {
+				return w, err
+			}
+		default:
+			value.WriteByte(c)
+			p.pos++
+		}
+	}
+	w.Text = p.src[start:p.pos]
+	w.Value = value.String()
+	return w, nil
+}
+
+// readDoubleQuoted reads a double-quoted string after its opening quote
+func (p *parser) readDoubleQuoted(w *Word, value *strings.Builder) error {
+	start := p.pos - 1
+	for !p.eof() {
+		switch c := p.peek(); c {
+		case '"':
+			p.pos++
+			return nil
+		case '\\':
+			switch next := p.peekAt(1); next {
+			case '$', '`', '"', '\\':
+				value.WriteByte(next)
+				p.pos += 2
+			case '\n':
+				p.pos += 2
+			default:
+				value.WriteByte(c)
+				p.pos++
+			}
+		case '$':
+			if err := p.readDollar(w, value, true); err != nil {
+				return err
+			}
+		case '`':
+			if err := p.readBackquote(w, value); err != nil {
+				return err
+			}
+		default:
+			value.WriteByte(c)
+			p.pos++
+		}
+	}
+	p.pos = start
+	return p.errorf("unterminated double quote")
+}
+
+// readDollar reads an expansion starting with $
+func (p *parser) readDollar(w *Word, value *strings.Builder, quoted bool) error {
+	start := p.pos
+	switch next := p.peekAt(1); {
+	case p.hasPrefix("$(("):
+		if err := p.readArithmetic(w, "$(("); err != nil {
+			return err
+		}
+	case next == '(':
+		p.pos += 2
+		if err := p.readSubstitution(w); err != nil {
+			return err
+		}
+	case next == '{':
+		p.pos += 2
+		if err := p.readBraced(w); err != nil {
+			return err
+		}
+	case next == '\'' && !quoted:
+		// ANSI-C quoting, $'...'
+		p.pos += 2
+		s, err := p.readANSIC()
+		if err != nil {
+			return err
+		}
+		value.WriteString(s)
+		return nil
+	case next == '"' && !quoted:
+		// Locale-translated string, $"..."
+		p.pos += 2
+		return p.readDoubleQuoted(w, value)
+	case isNameStart(next):
+		p.pos += 2
+		for !p.eof() && isNameChar(p.peek()) {
+			p.pos++
+		}
+		w.Literal = false
+	case next != 0 && strings.IndexByte("0123456789@*#?$!-", next) >= 0:
+		p.pos += 2
+		w.Literal = false
+	default:
+		p.pos++
+	}
+	value.WriteString(p.src[start:p.pos])
+	return nil
+}
+
+// readSubstitution parses the script of a command or process substitution
+// up to its closing parenthesis, which is consumed
+func (p *parser) readSubstitution(w *Word) error {
+	sub := &Script{}
+	if err := p.parseList(sub, ')', false); err != nil {
+		return err
+	}
+	p.pos++
+	w.Substitutions = append(w.Substitutions, sub)
+	w.Literal = false
+	return nil
+}
+
+// readBackquote parses an old-style command substitution, `...`
+func (p *parser) readBackquote(w *Word, value *strings.Builder) error {
+	start := p.pos
+	var script strings.Builder
+	p.pos++
+	for {
+		if p.eof() {
+			p.pos = start
+			return p.errorf("unterminated backquote")
+		}
+		c := p.peek()
+		if c == '`' {
+			p.pos++
+			break
+		}
+		if c == '\\' && strings.IndexByte("$`\\", p.peekAt(1)) >= 0 && p.peekAt(1) != 0 {
+			script.WriteByte(p.peekAt(1))
+			p.pos += 2
+			continue
+		}
+		script.WriteByte(c)
+		p.pos++
+	}
+	sub, err := Parse(script.String())
+	if err != nil {
+		return &SyntaxError{Offset: start, Message: fmt.Sprintf("in backquoted command: %v", err)}
+	}
+	w.Substitutions = append(w.Substitutions, sub)
+	w.Literal = false
+	value.WriteString(p.src[start:p.pos])
+	return nil
+}
+
+// readBraced reads a parameter expansion after its ${, which may contain
+// quotes and substitutions, like ${x:-$(pwd)}
+func (p *parser) readBraced(w *Word) error {
+	start := p.pos - 2
+	w.Literal = false
+	var discard strings.Builder
+	for depth := 1; ; {
+		if p.eof() {
+			p.pos = start
+			return p.errorf("unterminated ${")
+		}
+		switch c := p.peek(); c {
+		case '}':
+			p.pos++
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		case '\\':
+			p.pos = min(p.pos+2, len(p.src))
+		case '\'':
+			end := strings.IndexByte(p.src[p.pos+1:], '\'')
+			if end < 0 {
+				return p.errorf("unterminated single quote")
+			}
+			p.pos += end + 2
+		case '"':
+			p.pos++
+			if err := p.readDoubleQuoted(w, &discard); err != nil {
+				return err
+			}
+		case '$':
+			if p.peekAt(1) == '{' {
+				depth++
+				p.pos += 2
+				continue
+			}
+			if err := p.readDollar(w, &discard, true); err != nil {
+				return err
+			}
+		case '`':
+			if err := p.readBackquote(w, &discard); err != nil {
+				return err
+			}
+		default:
+			p.pos++
+		}
+	}
+}
+
+// readArithmetic reads an arithmetic expansion or command starting with
+// open, "$((" or "((", up to its closing "))". Command substitutions in the
+// expression are parsed.
+func (p *parser) readArithmetic(w *Word, open string) error {
+	start := p.pos
+	p.pos += len(open)
+	w.Literal = false
+	var discard strings.Builder
+	for depth := 2; ; {
+		if p.eof() {
+			p.pos = start
+			return p.errorf("unterminated %s", open)
+		}
+		switch c := p.peek(); c {
+		case '(':
+			depth++
+			p.pos++
+		case ')':
+			depth--
+			p.pos++
+			if depth == 0 {
+				return nil
+			}
+		case '$':
+			if err := p.readDollar(w, &discard, true); err != nil {
+				return err
+			}
+		case '`':
+			if err := p.readBackquote(w, &discard); err != nil {
+				return err
+			}
+		default:
+			p.pos++
+		}
+	}
+}
+
+// readArray reads the parenthesized value of an array assignment
+func (p *parser) readArray(w *Word) error {
+	start := p.pos
+	p.pos++
+	for {
+		p.skipBlanks()
+		if p.eof() {
+			p.pos = start
+			return p.errorf("unterminated array")
+		}
+		switch p.peek() {
+		case ')':
+			p.pos++
+			return nil
+		case '\n':
+			p.pos++
+			continue
+		}
+		element, err := p.readWord()
+		if err != nil {
+			return err
+		}
+		if element.Text == "" {
+			return p.errorf("unexpected %q in array", p.peek())
+		}
+		w.Substitutions = append(w.Substitutions, element.Substitutions...)
+		w.Literal = w.Literal && element.Literal
+	}
+}
+
+// readANSIC reads an ANSI-C quoted string after its $' and decodes its
+// escapes
+func (p *parser) readANSIC() (string, error) {
+	start := p.pos - 2
+	var value strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		if c == '\'' {
+			p.pos++
+			return value.String(), nil
+		}
+		if c != '\\' || p.pos+1 >= len(p.src) {
+			value.WriteByte(c)
+			p.pos++
+			continue
+		}
+		p.pos++
+		e := p.peek()
+		p.pos++
+		switch e {
+		case 'n':
+			value.WriteByte('\n')
+		case 't':
+			value.WriteByte('\t')
+		case 'r':
+			value.WriteByte('\r')
+		case 'a':
+			value.WriteByte('\a')
+		case 'b':
+			value.WriteByte('\b')
+		case 'e', 'E':
+			value.WriteByte(0x1b)
+		case 'f':
+			value.WriteByte('\f')
+		case 'v':
+			value.WriteByte('\v')
+		case 'x':
+			value.WriteByte(byte(p.readDigits(16, 2)))
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			p.pos--
+			value.WriteByte(byte(p.readDigits(8, 3)))
+		default:
+			value.WriteByte(e)
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated $' string")
+}
+
+// readDigits reads up to n digits in the given base
+func (p *parser) readDigits(base, n int) int {
+	v := 0
+	for i := 0; i < n && !p.eof(); i++ {
+		d := digitValue(p.peek())
+		if d < 0 || d >= base {
+			break
+		}
+		v = v*base + d
+		p.pos++
+	}
+	return v
+}
+
+func digitValue(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || c >= '0' && c <= '9'
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isAssignment reports whether a word is a variable assignment, name=value
+// or name+=value
+func isAssignment(s string) bool {
+	eq := strings.IndexByte(s, '=')
+	if eq <= 0 || !isNameStart(s[0]) {
+		return false
+	}
+	name := strings.TrimSuffix(s[:eq], "+")
+	for i := 0; i < len(name); i++ {
+		if !isNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
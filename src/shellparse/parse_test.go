@@ -0,0 +1,87 @@
+package shellparse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commandArgs returns the argument values of every command of a script
+func commandArgs(t *testing.T, src string) []string {
+	t.Helper()
+	script, err := Parse(src)
+	require.NoError(t, err)
+	var commands []string
+	script.Walk(func(cmd *Command) {
+		var args []string
+		for _, arg := range cmd.Args {
+			args = append(args, arg.Value)
+		}
+		commands = append(commands, strings.Join(args, " "))
+	})
+	return commands
+}
+
+func TestParseCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		commands []string
+	}{
+		{"simple", "ls -la", []string{"ls -la"}},
+		{"pipeline and lists", "cat f | grep x && echo ok; true || false &", []string{"cat f", "grep x", "echo ok", "true", "false"}},
+		{"subshell and group", "(cd dir; make) && { echo done; }", []string{"cd dir", "make", "echo done"}},
+		{"command substitution", "echo $(whoami) `date`", []string{"echo $(whoami) `date`", "whoami", "date"}},
+		{"nested substitution", `echo "$(echo $(id))"`, []string{`echo $(echo $(id))`, "echo $(id)", "id"}},
+		{"process substitution", "diff <(ls a) <(ls b)", []string{"diff <(ls a) <(ls b)", "ls a", "ls b"}},
+		{"quotes", `echo 'a b' "c d" e\ f`, []string{"echo a b c d e f"}},
+		{"ansi-c quotes", `$'\x72m' -rf x`, []string{"rm -rf x"}},
+		{"assignments and redirects", "FOO=1 make >out.log 2>&1 <in", []string{"make"}},
+		{"if", "if test -f x; then rm x; else touch x; fi", []string{"test -f x", "rm x", "touch x"}},
+		{"loops", "for f in *.go; do gofmt -l $f; done; while read l; do echo $l; done", []string{"gofmt -l $f", "read l", "echo $l"}},
+		{"case", "case $x in a|b) echo ab;; *) rm y;; esac", []string{"echo ab", "rm y"}},
+		{"function", "f() { rm -rf x; }; f", []string{"rm -rf x", "f"}},
+		{"test", "[[ -f x && $y == z ]] && echo yes", []string{"[[ -f x && $y == z ]]", "echo yes"}},
+		{"arithmetic", "echo $(( $(nproc) * 2 ))", []string{"echo $(( $(nproc) * 2 ))", "nproc"}},
+		{"heredoc", "cat <<EOF | sh\nrm -rf $(pwd)\nEOF\n", []string{"cat", "pwd", "sh"}},
+		{"comments and continuations", "echo a \\\n  b # rm -rf /", []string{"echo a b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.commands, commandArgs(t, tt.src))
+		})
+	}
+}
+
+func TestParseWords(t *testing.T) {
+	script, err := Parse(`bash -c "$(printf rm) -rf /" 'literal'`)
+	require.NoError(t, err)
+	require.Len(t, script.Commands, 1)
+
+	args := script.Commands[0].Args
+	require.Len(t, args, 4)
+	assert.True(t, args[1].Literal)
+	assert.False(t, args[2].Literal, "a word with a substitution is only known when it runs")
+	require.Len(t, args[2].Substitutions, 1)
+	assert.Equal(t, "printf rm", args[2].Substitutions[0].Commands[0].Text)
+	assert.True(t, args[3].Literal)
+	assert.Equal(t, "literal", args[3].Value)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"echo 'unterminated",
+		`echo "unterminated`,
+		"echo $(ls",
+		"ls )",
+	} {
+		t.Run(src, func(t *testing.T) {
+			_, err := Parse(src)
+			var syntaxErr *SyntaxError
+			assert.ErrorAs(t, err, &syntaxErr)
+		})
+	}
+}
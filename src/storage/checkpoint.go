@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/google/uuid"
+)
+
+// CreateRunCheckpoint records the turn state of a run before it executes tool calls
+func CreateRunCheckpoint(ctx context.Context, db Execer, checkpoint *RunCheckpoint) error {
+	if checkpoint.ID == "" {
+		checkpoint.ID = uuid.New().String()
+	}
+	if checkpoint.CreatedAt.IsZero() {
+		checkpoint.CreatedAt = time.Now()
+	}
+	checkpoint.UpdatedAt = checkpoint.CreatedAt
+	if checkpoint.Status == "" {
+		checkpoint.Status = CheckpointRunning
+	}
+
+	query := `INSERT INTO run_checkpoints (id, conversation_id, message_id, turn, max_turns, tool_calls, finished_tool_call_ids, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.ExecContext(ctx, query,
+		checkpoint.ID,
+		checkpoint.ConversationID,
+		checkpoint.MessageID,
+		checkpoint.Turn,
+		checkpoint.MaxTurns,
+		checkpoint.ToolCalls,
+		checkpoint.FinishedToolCallIDs,
+		checkpoint.Status,
+		checkpoint.CreatedAt,
+		checkpoint.UpdatedAt,
+	)
+	return err
+}
+
+// UpdateRunCheckpoint updates the status and finished tool calls of a checkpoint
+func UpdateRunCheckpoint(ctx context.Context, db Execer, checkpoint *RunCheckpoint) error {
+	checkpoint.UpdatedAt = time.Now()
+	query := `UPDATE run_checkpoints SET status = ?, finished_tool_call_ids = ?, updated_at = ? WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, checkpoint.Status, checkpoint.FinishedToolCallIDs, checkpoint.UpdatedAt, checkpoint.ID)
+	return err
+}
+
+// GetOpenRunCheckpoint retrieves the latest checkpoint of a conversation if
+// its run was interrupted or stopped while executing tool calls, or nil
+func GetOpenRunCheckpoint(ctx context.Context, db sqlscan.Querier, conversationID string) (*RunCheckpoint, error) {
+	query := `SELECT id, conversation_id, message_id, turn, max_turns, tool_calls, finished_tool_call_ids, status, created_at, updated_at FROM run_checkpoints WHERE conversation_id = ? ORDER BY created_at DESC, rowid DESC LIMIT 1`
+	var checkpoints []RunCheckpoint
+	if err := sqlscan.Select(ctx, db, &checkpoints, query, conversationID); err != nil {
+		return nil, err
+	}
+	if len(checkpoints) == 0 {
+		return nil, nil
+	}
+	checkpoint := &checkpoints[0]
+	if checkpoint.Status != CheckpointRunning && checkpoint.Status != CheckpointInterrupted {
+		return nil, nil
+	}
+	return checkpoint, nil
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/google/uuid"
+)
+
+// CreateCompaction records the compaction of a conversation
+func CreateCompaction(ctx context.Context, db Execer, compaction *Compaction) error {
+	if compaction.ID == "" {
+		compaction.ID = uuid.New().String()
+	}
+	if compaction.CreatedAt.IsZero() {
+		compaction.CreatedAt = time.Now()
+	}
+
+	query := `INSERT INTO compactions (id, conversation_id, first_message_id, last_message_id, message_count, summary, trigger, model, tokens_before, tokens_after, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.ExecContext(ctx, query,
+		compaction.ID,
+		compaction.ConversationID,
+		compaction.FirstMessageID,
+		compaction.LastMessageID,
+		compaction.MessageCount,
+		compaction.Summary,
+		compaction.Trigger,
+		compaction.Model,
+		compaction.TokensBefore,
+		compaction.TokensAfter,
+		compaction.CreatedAt,
+	)
+	return err
+}
+
+// GetCompactionsByConversationID retrieves the compactions of a conversation ordered by creation time
+func GetCompactionsByConversationID(ctx context.Context, db sqlscan.Querier, conversationID string) ([]Compaction, error) {
+	query := `SELECT id, conversation_id, first_message_id, last_message_id, message_count, summary, trigger, model, tokens_before, tokens_after, created_at FROM compactions WHERE conversation_id = ? ORDER BY created_at`
+	var compactions []Compaction
+	if err := sqlscan.Select(ctx, db, &compactions, query, conversationID); err != nil {
+		return nil, err
+	}
+	return compactions, nil
+}
+
+// GetLatestCompaction retrieves the most recent compaction of a conversation,
+// or nil if it was never compacted
+func GetLatestCompaction(ctx context.Context, db sqlscan.Querier, conversationID string) (*Compaction, error) {
+	compactions, err := GetCompactionsByConversationID(ctx, db, conversationID)
+	if err != nil || len(compactions) == 0 {
+		return nil, err
+	}
+	return &compactions[len(compactions)-1], nil
+}
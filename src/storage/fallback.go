@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/google/uuid"
+)
+
+// CreateModelFallback records a switch to a fallback model
+func CreateModelFallback(ctx context.Context, db Execer, fallback *ModelFallback) error {
+	if fallback.ID == "" {
+		fallback.ID = uuid.New().String()
+	}
+	if fallback.CreatedAt.IsZero() {
+		fallback.CreatedAt = time.Now()
+	}
+
+	query := `INSERT INTO model_fallbacks (id, conversation_id, turn, from_model, to_model, reason, error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.ExecContext(ctx, query,
+		fallback.ID,
+		fallback.ConversationID,
+		fallback.Turn,
+		fallback.FromModel,
+		fallback.ToModel,
+		fallback.Reason,
+		fallback.Error,
+		fallback.CreatedAt,
+	)
+	return err
+}
+
+// GetModelFallbacksByConversationID retrieves the fallbacks of a conversation ordered by creation time
+func GetModelFallbacksByConversationID(ctx context.Context, db sqlscan.Querier, conversationID string) ([]ModelFallback, error) {
+	query := `SELECT id, conversation_id, turn, from_model, to_model, reason, error, created_at FROM model_fallbacks WHERE conversation_id = ? ORDER BY created_at, rowid`
+	var fallbacks []ModelFallback
+	if err := sqlscan.Select(ctx, db, &fallbacks, query, conversationID); err != nil {
+		return nil, err
+	}
+	return fallbacks, nil
+}
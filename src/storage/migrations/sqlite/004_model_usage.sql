@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Token usage and cost of each model call
+CREATE TABLE model_usage (
+    id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    message_id TEXT, -- assistant message of the call, if it was saved
+    model TEXT NOT NULL,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    cached_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_write_tokens INTEGER NOT NULL DEFAULT 0,
+    prompt_cost REAL, -- NULL when the model has no pricing
+    completion_cost REAL,
+    total_cost REAL,
+    currency TEXT NOT NULL DEFAULT 'USD',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_model_usage_conversation_id ON model_usage(conversation_id);
+CREATE INDEX idx_model_usage_created_at ON model_usage(created_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_model_usage_created_at;
+DROP INDEX IF EXISTS idx_model_usage_conversation_id;
+DROP TABLE IF EXISTS model_usage;
+
+-- +goose StatementEnd
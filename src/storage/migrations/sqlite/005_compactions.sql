@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Summaries that replace older messages of a conversation when it is sent to
+-- the model. The messages themselves are kept.
+CREATE TABLE compactions (
+    id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    first_message_id TEXT, -- first and last message covered, NULL when none were saved
+    last_message_id TEXT,
+    message_count INTEGER NOT NULL DEFAULT 0, -- messages replaced in the model request
+    summary TEXT NOT NULL,
+    trigger TEXT NOT NULL, -- auto or manual
+    model TEXT NOT NULL,
+    tokens_before INTEGER NOT NULL DEFAULT 0, -- estimated request tokens
+    tokens_after INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (first_message_id) REFERENCES messages(id) ON DELETE SET NULL,
+    FOREIGN KEY (last_message_id) REFERENCES messages(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_compactions_conversation_id ON compactions(conversation_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_compactions_conversation_id;
+DROP TABLE IF EXISTS compactions;
+
+-- +goose StatementEnd
@@ -0,0 +1,68 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Tool result messages are saved with the call they answer
+ALTER TABLE messages ADD COLUMN tool_call_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN name TEXT NOT NULL DEFAULT ''; -- tool name of tool messages
+ALTER TABLE messages ADD COLUMN multimodal_content TEXT; -- JSON, for results with images
+
+ALTER TABLE tool_executions ADD COLUMN tool_call_id TEXT NOT NULL DEFAULT '';
+
+-- Executions used to be saved with a random message_id. Link them to the
+-- latest assistant message before them that called the tool.
+UPDATE tool_executions SET message_id = (
+    SELECT m.id FROM messages m
+    WHERE m.conversation_id = tool_executions.conversation_id
+      AND m.role = 'assistant'
+      AND m.created_at <= tool_executions.created_at
+      AND EXISTS (
+          SELECT 1 FROM json_each(m.tool_calls) tc
+          WHERE json_extract(tc.value, '$.function.name') = tool_executions.tool_name
+      )
+    ORDER BY m.created_at DESC
+    LIMIT 1
+)
+WHERE message_id NOT IN (SELECT id FROM messages);
+
+-- Find the call each execution answered by its tool name and arguments
+UPDATE tool_executions SET tool_call_id = COALESCE((
+    SELECT json_extract(tc.value, '$.id')
+    FROM messages m, json_each(m.tool_calls) tc
+    WHERE m.id = tool_executions.message_id
+      AND json_extract(tc.value, '$.function.name') = tool_executions.tool_name
+      AND CASE
+          WHEN json_valid(tool_executions.input) AND json_valid(json_extract(tc.value, '$.function.arguments'))
+          THEN json(tool_executions.input) = json(json_extract(tc.value, '$.function.arguments'))
+          ELSE tool_executions.input = json_extract(tc.value, '$.function.arguments')
+      END
+    LIMIT 1
+), '')
+WHERE message_id IS NOT NULL;
+
+-- Rebuild the tool messages of matched executions
+INSERT INTO messages (id, conversation_id, role, provider, model, content, tool_call_id, name, created_at)
+SELECT lower(hex(randomblob(16))), te.conversation_id, 'tool', te.provider, te.model, COALESCE(te.output, ''), te.tool_call_id, te.tool_name, te.created_at
+FROM tool_executions te
+WHERE te.tool_call_id != ''
+  AND te.rowid = (
+      SELECT MIN(rowid) FROM tool_executions
+      WHERE message_id = te.message_id AND tool_call_id = te.tool_call_id
+  );
+
+CREATE INDEX idx_messages_tool_call_id ON messages(conversation_id, tool_call_id) WHERE tool_call_id != '';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_messages_tool_call_id;
+DELETE FROM messages WHERE role = 'tool';
+
+ALTER TABLE tool_executions DROP COLUMN tool_call_id;
+
+ALTER TABLE messages DROP COLUMN multimodal_content;
+ALTER TABLE messages DROP COLUMN name;
+ALTER TABLE messages DROP COLUMN tool_call_id;
+
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Turn state of runs that are executing tool calls, so an interrupted run can
+-- be resumed. Finished runs keep their checkpoint with status completed.
+CREATE TABLE run_checkpoints (
+    id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    message_id TEXT, -- assistant message that made the tool calls
+    turn INTEGER NOT NULL, -- turn of the tool calls, starting at 1
+    max_turns INTEGER NOT NULL,
+    tool_calls TEXT NOT NULL, -- JSON array of the turn's tool calls
+    finished_tool_call_ids TEXT NOT NULL DEFAULT '[]', -- JSON array, set when interrupted
+    status TEXT NOT NULL, -- running, interrupted, completed, rerun or cancelled
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_run_checkpoints_conversation_id ON run_checkpoints(conversation_id, created_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_run_checkpoints_conversation_id;
+DROP TABLE IF EXISTS run_checkpoints;
+
+-- +goose StatementEnd
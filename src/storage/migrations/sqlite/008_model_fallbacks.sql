@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Switches to a fallback model after a model call failed
+CREATE TABLE model_fallbacks (
+    id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    turn INTEGER NOT NULL, -- turn of the failed call, starting at 1
+    from_model TEXT NOT NULL,
+    to_model TEXT NOT NULL,
+    reason TEXT NOT NULL, -- rate_limit, overloaded or context_length
+    error TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_model_fallbacks_conversation_id ON model_fallbacks(conversation_id, created_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS idx_model_fallbacks_conversation_id;
+DROP TABLE IF EXISTS model_fallbacks;
+
+-- +goose StatementEnd
@@ -12,19 +12,23 @@ type Conversation struct {
 }
 
 type Message struct {
-	ID             string    `json:"id" db:"id"`
-	ConversationID string    `json:"conversation_id" db:"conversation_id"`
-	Role           string    `json:"role" db:"role"`
-	Provider       string    `json:"provider" db:"provider"`
-	Model          string    `json:"model" db:"model"`
-	Content        string    `json:"content" db:"content"`
-	ToolCalls      *string   `json:"tool_calls,omitempty" db:"tool_calls"` // JSON array of tool calls
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	ID                string    `json:"id" db:"id"`
+	ConversationID    string    `json:"conversation_id" db:"conversation_id"`
+	Role              string    `json:"role" db:"role"`
+	Provider          string    `json:"provider" db:"provider"`
+	Model             string    `json:"model" db:"model"`
+	Content           string    `json:"content" db:"content"`
+	ToolCalls         *string   `json:"tool_calls,omitempty" db:"tool_calls"`                 // JSON array of tool calls
+	ToolCallID        string    `json:"tool_call_id,omitempty" db:"tool_call_id"`             // Call a tool message answers
+	Name              string    `json:"name,omitempty" db:"name"`                             // Tool name of a tool message
+	MultimodalContent *string   `json:"multimodal_content,omitempty" db:"multimodal_content"` // JSON content of tool results with images
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
 }
 
 type ToolExecution struct {
 	ID             string    `json:"id" db:"id"`
-	MessageID      string    `json:"message_id" db:"message_id"`
+	MessageID      *string   `json:"message_id,omitempty" db:"message_id"` // Assistant message that made the call
+	ToolCallID     string    `json:"tool_call_id" db:"tool_call_id"`
 	ConversationID string    `json:"conversation_id" db:"conversation_id"`
 	Provider       string    `json:"provider" db:"provider"`
 	Model          string    `json:"model" db:"model"`
@@ -36,6 +40,77 @@ type ToolExecution struct {
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
 }
 
+// ModelUsage is the token usage and cost of a single model call. Costs are
+// nil when the model has no pricing.
+type ModelUsage struct {
+	ID               string    `json:"id" db:"id"`
+	ConversationID   string    `json:"conversation_id" db:"conversation_id"`
+	MessageID        *string   `json:"message_id,omitempty" db:"message_id"`
+	Model            string    `json:"model" db:"model"`
+	PromptTokens     int       `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens" db:"completion_tokens"`
+	CachedTokens     int       `json:"cached_tokens" db:"cached_tokens"`
+	CacheWriteTokens int       `json:"cache_write_tokens" db:"cache_write_tokens"`
+	PromptCost       *float64  `json:"prompt_cost,omitempty" db:"prompt_cost"`
+	CompletionCost   *float64  `json:"completion_cost,omitempty" db:"completion_cost"`
+	TotalCost        *float64  `json:"total_cost,omitempty" db:"total_cost"`
+	Currency         string    `json:"currency" db:"currency"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// Compaction is a summary that replaces older messages of a conversation in
+// model requests. FirstMessageID and LastMessageID are the saved messages it
+// covers; the messages stay in the database.
+type Compaction struct {
+	ID             string    `json:"id" db:"id"`
+	ConversationID string    `json:"conversation_id" db:"conversation_id"`
+	FirstMessageID *string   `json:"first_message_id,omitempty" db:"first_message_id"`
+	LastMessageID  *string   `json:"last_message_id,omitempty" db:"last_message_id"`
+	MessageCount   int       `json:"message_count" db:"message_count"`
+	Summary        string    `json:"summary" db:"summary"`
+	Trigger        string    `json:"trigger" db:"trigger"`
+	Model          string    `json:"model" db:"model"`
+	TokensBefore   int       `json:"tokens_before" db:"tokens_before"`
+	TokensAfter    int       `json:"tokens_after" db:"tokens_after"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// Run checkpoint statuses
+const (
+	CheckpointRunning     = "running"
+	CheckpointInterrupted = "interrupted"
+	CheckpointCompleted   = "completed"
+	CheckpointRerun       = "rerun"
+	CheckpointCancelled   = "cancelled"
+)
+
+// RunCheckpoint is the turn state of a run while it executes tool calls
+type RunCheckpoint struct {
+	ID                  string          `json:"id" db:"id"`
+	ConversationID      string          `json:"conversation_id" db:"conversation_id"`
+	MessageID           *string         `json:"message_id,omitempty" db:"message_id"`
+	Turn                int             `json:"turn" db:"turn"`
+	MaxTurns            int             `json:"max_turns" db:"max_turns"`
+	ToolCalls           string          `json:"tool_calls" db:"tool_calls"` // JSON array of tool calls
+	FinishedToolCallIDs JSONStringArray `json:"finished_tool_call_ids" db:"finished_tool_call_ids"`
+	Status              string          `json:"status" db:"status"`
+	CreatedAt           time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// ModelFallback records a switch to a fallback model after a model call
+// failed
+type ModelFallback struct {
+	ID             string    `json:"id" db:"id"`
+	ConversationID string    `json:"conversation_id" db:"conversation_id"`
+	Turn           int       `json:"turn" db:"turn"`
+	FromModel      string    `json:"from_model" db:"from_model"`
+	ToModel        string    `json:"to_model" db:"to_model"`
+	Reason         string    `json:"reason" db:"reason"`
+	Error          string    `json:"error" db:"error"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
 type Session struct {
 	ID                    string          `json:"id" db:"id"`
 	CurrentConversationID *string         `json:"current_conversation_id,omitempty" db:"current_conversation_id"`
@@ -100,7 +100,7 @@ func CreateConversation(ctx context.Context, db Execer, conversation *Conversati
 
 // GetMessagesByConversationID retrieves all messages for a conversation ordered by creation time
 func GetMessagesByConversationID(ctx context.Context, db sqlscan.Querier, conversationID string) ([]Message, error) {
-	query := `SELECT id, conversation_id, role, provider, model, content, tool_calls, created_at FROM messages WHERE conversation_id = ? ORDER BY created_at`
+	query := `SELECT id, conversation_id, role, provider, model, content, tool_calls, tool_call_id, name, multimodal_content, created_at FROM messages WHERE conversation_id = ? ORDER BY created_at, rowid`
 	var messages []Message
 	err := sqlscan.Select(ctx, db, &messages, query, conversationID)
 	if err != nil {
@@ -118,8 +118,8 @@ func CreateMessage(ctx context.Context, db Execer, message *Message) error {
 		message.CreatedAt = time.Now()
 	}
 
-	query := `INSERT INTO messages (id, conversation_id, role, provider, model, content, tool_calls, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := db.ExecContext(ctx, query, message.ID, message.ConversationID, message.Role, message.Provider, message.Model, message.Content, message.ToolCalls, message.CreatedAt)
+	query := `INSERT INTO messages (id, conversation_id, role, provider, model, content, tool_calls, tool_call_id, name, multimodal_content, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.ExecContext(ctx, query, message.ID, message.ConversationID, message.Role, message.Provider, message.Model, message.Content, message.ToolCalls, message.ToolCallID, message.Name, message.MultimodalContent, message.CreatedAt)
 	return err
 }
 
@@ -132,10 +132,11 @@ func CreateToolExecution(ctx context.Context, db Execer, execution *ToolExecutio
 		execution.CreatedAt = time.Now()
 	}
 
-	query := `INSERT INTO tool_executions (id, message_id, conversation_id, provider, model, tool_name, input, output, error, duration_ms, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO tool_executions (id, message_id, tool_call_id, conversation_id, provider, model, tool_name, input, output, error, duration_ms, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err := db.ExecContext(ctx, query,
 		execution.ID,
 		execution.MessageID,
+		execution.ToolCallID,
 		execution.ConversationID,
 		execution.Provider,
 		execution.Model,
@@ -147,4 +148,14 @@ func CreateToolExecution(ctx context.Context, db Execer, execution *ToolExecutio
 		execution.CreatedAt,
 	)
 	return err
-}
\ No newline at end of file
+}
+
+// GetToolExecutionsByMessageID retrieves the tool executions of an assistant message ordered by creation time
+func GetToolExecutionsByMessageID(ctx context.Context, db sqlscan.Querier, messageID string) ([]ToolExecution, error) {
+	query := `SELECT id, message_id, tool_call_id, conversation_id, provider, model, tool_name, input, output, error, duration_ms, created_at FROM tool_executions WHERE message_id = ? ORDER BY created_at, rowid`
+	var executions []ToolExecution
+	if err := sqlscan.Select(ctx, db, &executions, query, messageID); err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
@@ -18,13 +18,34 @@ var sessionsJSONArray string
 //go:embed migrations/sqlite/003_add_tool_calls_to_messages.sql
 var addToolCallsToMessages string
 
+//go:embed migrations/sqlite/004_model_usage.sql
+var modelUsage string
+
+//go:embed migrations/sqlite/005_compactions.sql
+var compactions string
+
+//go:embed migrations/sqlite/006_tool_messages.sql
+var toolMessages string
+
+//go:embed migrations/sqlite/007_run_checkpoints.sql
+var runCheckpoints string
+
+//go:embed migrations/sqlite/008_model_fallbacks.sql
+var modelFallbacks string
+
 type DB struct {
 	path string
 	db   *sql.DB
 }
 
 func Open(path string) (*DB, error) {
-	db, err := sql.Open("sqlite", path)
+	// Foreign keys are off by default in SQLite and are a per-connection
+	// setting, so they are turned on in the DSN for every connection
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", path+sep+"_pragma=foreign_keys(1)")
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +106,11 @@ func (d *DB) runMigrations() error {
 		{1, extractUpMigration(initialSchema)},
 		{2, extractUpMigration(sessionsJSONArray)},
 		{3, extractUpMigration(addToolCallsToMessages)},
+		{4, extractUpMigration(modelUsage)},
+		{5, extractUpMigration(compactions)},
+		{6, extractUpMigration(toolMessages)},
+		{7, extractUpMigration(runCheckpoints)},
+		{8, extractUpMigration(modelFallbacks)},
 	}
 	
 	// Apply pending migrations
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+	"github.com/google/uuid"
+)
+
+// UsageGroup is a dimension usage can be summarized by
+type UsageGroup string
+
+const (
+	UsageByDay          UsageGroup = "day"
+	UsageByModel        UsageGroup = "model"
+	UsageByConversation UsageGroup = "conversation"
+	UsageByProject      UsageGroup = "project"
+)
+
+// usageGroupColumns maps groups to the key and label expressions of the summary query
+var usageGroupColumns = map[UsageGroup][2]string{
+	// created_at holds the local time as text, its first ten characters are the date
+	UsageByDay:          {"substr(u.created_at, 1, 10)", "''"},
+	UsageByModel:        {"u.model", "''"},
+	UsageByConversation: {"u.conversation_id", "MAX(c.title)"},
+	UsageByProject:      {"c.project_directory", "''"},
+}
+
+// UsageFilter restricts the model calls included in a summary
+type UsageFilter struct {
+	Since   string // First day to include, as YYYY-MM-DD
+	Until   string // Last day to include, as YYYY-MM-DD
+	Project string // Project directory
+	Model   string
+}
+
+// UsageSummary is the total usage of one group
+type UsageSummary struct {
+	Key              string  `json:"key" db:"key"`
+	Label            string  `json:"label,omitempty" db:"label"` // Conversation title
+	Calls            int     `json:"calls" db:"calls"`
+	PromptTokens     int     `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens" db:"completion_tokens"`
+	CachedTokens     int     `json:"cached_tokens" db:"cached_tokens"`
+	CacheWriteTokens int     `json:"cache_write_tokens" db:"cache_write_tokens"`
+	TotalCost        float64 `json:"total_cost" db:"total_cost"`
+	UnpricedCalls    int     `json:"unpriced_calls" db:"unpriced_calls"` // Calls to models without pricing
+}
+
+// Add adds the usage of another summary
+func (s *UsageSummary) Add(other UsageSummary) {
+	s.Calls += other.Calls
+	s.PromptTokens += other.PromptTokens
+	s.CompletionTokens += other.CompletionTokens
+	s.CachedTokens += other.CachedTokens
+	s.CacheWriteTokens += other.CacheWriteTokens
+	s.TotalCost += other.TotalCost
+	s.UnpricedCalls += other.UnpricedCalls
+	if s.Label == "" {
+		s.Label = other.Label
+	}
+}
+
+// CreateModelUsage records the usage of a model call
+func CreateModelUsage(ctx context.Context, db Execer, usage *ModelUsage) error {
+	if usage.ID == "" {
+		usage.ID = uuid.New().String()
+	}
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = time.Now()
+	}
+	if usage.Currency == "" {
+		usage.Currency = "USD"
+	}
+
+	query := `INSERT INTO model_usage (id, conversation_id, message_id, model, prompt_tokens, completion_tokens, cached_tokens, cache_write_tokens, prompt_cost, completion_cost, total_cost, currency, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.ExecContext(ctx, query,
+		usage.ID,
+		usage.ConversationID,
+		usage.MessageID,
+		usage.Model,
+		usage.PromptTokens,
+		usage.CompletionTokens,
+		usage.CachedTokens,
+		usage.CacheWriteTokens,
+		usage.PromptCost,
+		usage.CompletionCost,
+		usage.TotalCost,
+		usage.Currency,
+		usage.CreatedAt,
+	)
+	return err
+}
+
+// GetModelUsageByConversationID retrieves the usage records of a conversation ordered by creation time
+func GetModelUsageByConversationID(ctx context.Context, db sqlscan.Querier, conversationID string) ([]ModelUsage, error) {
+	query := `SELECT id, conversation_id, message_id, model, prompt_tokens, completion_tokens, cached_tokens, cache_write_tokens, prompt_cost, completion_cost, total_cost, currency, created_at FROM model_usage WHERE conversation_id = ? ORDER BY created_at`
+	var usage []ModelUsage
+	if err := sqlscan.Select(ctx, db, &usage, query, conversationID); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// SummarizeUsage totals model usage by the given group. Days are ordered
+// chronologically, every other group by descending cost.
+func SummarizeUsage(ctx context.Context, db sqlscan.Querier, group UsageGroup, filter UsageFilter) ([]UsageSummary, error) {
+	columns, ok := usageGroupColumns[group]
+	if !ok {
+		return nil, fmt.Errorf("unknown usage group %q", group)
+	}
+
+	var where []string
+	var args []interface{}
+	if filter.Since != "" {
+		where = append(where, "substr(u.created_at, 1, 10) >= ?")
+		args = append(args, filter.Since)
+	}
+	if filter.Until != "" {
+		where = append(where, "substr(u.created_at, 1, 10) <= ?")
+		args = append(args, filter.Until)
+	}
+	if filter.Project != "" {
+		where = append(where, "c.project_directory = ?")
+		args = append(args, filter.Project)
+	}
+	if filter.Model != "" {
+		where = append(where, "u.model = ?")
+		args = append(args, filter.Model)
+	}
+
+	order := "total_cost DESC, key"
+	if group == UsageByDay {
+		order = "key"
+	}
+
+	query := `SELECT ` + columns[0] + ` AS key, ` + columns[1] + ` AS label,
+		COUNT(*) AS calls,
+		SUM(u.prompt_tokens) AS prompt_tokens,
+		SUM(u.completion_tokens) AS completion_tokens,
+		SUM(u.cached_tokens) AS cached_tokens,
+		SUM(u.cache_write_tokens) AS cache_write_tokens,
+		COALESCE(SUM(u.total_cost), 0) AS total_cost,
+		SUM(CASE WHEN u.total_cost IS NULL THEN 1 ELSE 0 END) AS unpriced_calls
+	FROM model_usage u JOIN conversations c ON c.id = u.conversation_id`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` GROUP BY key ORDER BY ` + order
+
+	var summaries []UsageSummary
+	if err := sqlscan.Select(ctx, db, &summaries, query, args...); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}